@@ -1,9 +1,11 @@
 package app
 
 import (
+	"context"
 	"files/pkg/drivers/posix/upload"
 	"files/pkg/drivers/sync/seahub"
 	"files/pkg/global"
+	"files/pkg/hertz/biz/handler/api/share"
 	"files/pkg/redisutils"
 	"files/pkg/tasks"
 	"sync"
@@ -22,6 +24,7 @@ import (
 var (
 	dailyCleanupMux sync.Mutex
 	mountRefreshMux sync.Mutex
+	shareExpiryMux  sync.Mutex
 )
 
 // InitCrontabs registers all scheduled jobs and starts the scheduler. It
@@ -67,6 +70,26 @@ func InitCrontabs() *cron.Cron {
 		klog.Info("Crontab task: GetMountedData added successfully.")
 	}
 
+	_, err = c.AddFunc("15 * * * *", func() {
+		// share rows are cluster-wide; every node running the job would
+		// notify owners and archive shares once per node
+		if !global.GlobalNode.IsMasterNode(global.CurrentNodeName) {
+			return
+		}
+		if !shareExpiryMux.TryLock() {
+			klog.Warning("Crontab: share lifecycle still running, skipping this tick")
+			return
+		}
+		defer shareExpiryMux.Unlock()
+
+		share.RunShareLifecycle(context.Background(), time.Now())
+	})
+	if err != nil {
+		klog.Errorf("AddFunc RunShareLifecycle err: %v", err)
+	} else {
+		klog.Info("Crontab task: RunShareLifecycle added successfully.")
+	}
+
 	upload.Init(c)
 
 	c.Start()
@@ -18,6 +18,7 @@ import (
 	"files/pkg/integration"
 	"files/pkg/lifecycle"
 	"files/pkg/models"
+	"files/pkg/preview"
 	"files/pkg/redisutils"
 	"files/pkg/samba"
 	"files/pkg/tasks"
@@ -193,6 +194,9 @@ user created with the credentials from options "username" and "password".`,
 		}
 		global.InitGlobalMounted()
 
+		// step6-1: count what the preview cache holds, now the users are known
+		go diskcache.GetFileCache().Scan(global.GlobalData.GetGlobalUsers())
+
 		// step7: init seahub (for test now)
 		seaserv.InitSeaRPC()
 
@@ -200,7 +204,21 @@ user created with the credentials from options "username" and "password".`,
 		if err := integration.NewIntegrationManager(); err != nil {
 			klog.Fatalf("init integration manager: %v", err)
 		}
+		// Users that leave the platform drop out of the share groups
+		// they were added to; the built-in groups follow the user list.
+		integration.IntegrationManager().OnUsersChanged(func(added, removed []string) {
+			if database.DB == nil || len(removed) == 0 {
+				return
+			}
+			n, err := database.RemoveShareGroupUsers(removed)
+			if err != nil {
+				klog.Errorf("remove users %v from share groups error: %v", removed, err)
+				return
+			}
+			klog.Infof("removed users %v from %d share group membership(s)", removed, n)
+		})
 		upload.Start()
+		preview.StartPregen()
 
 		coord := lifecycle.New()
 		// Hooks run in reverse-registration order. Register database/redis
@@ -261,6 +279,9 @@ user created with the credentials from options "username" and "password".`,
 		// upload webhook worker
 		coord.Add("upload-webhook", 5*time.Second, upload.Stop)
 
+		// preview pre-generation worker
+		coord.Add("preview-pregen", 5*time.Second, preview.StopPregen)
+
 		// fsnotify external mount watcher
 		coord.Add("external-fsnotify", 2*time.Second, func(context.Context) error {
 			return global.ExternalWatcherClose()
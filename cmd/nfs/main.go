@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"files/pkg/client"
+	"files/pkg/global"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/lifecycle"
+	"files/pkg/nfs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// nfsShutdownTimeout caps total teardown time after the first signal.
+	nfsShutdownTimeout = 30 * time.Second
+)
+
+var rootCmd = &cobra.Command{
+	Use: "nfs-share-server",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := database.Init(); err != nil {
+			klog.Fatalf("database.Init: %v", err)
+		}
+
+		f, err := client.NewFactory()
+		if err != nil {
+			klog.Fatalf("new factory error: %v", err)
+		}
+
+		config, err := f.ClientConfig()
+		if err != nil {
+			klog.Fatalf("get client config error: %v", err)
+		}
+
+		if err := global.InitGlobalData(config); err != nil {
+			klog.Fatalf("init global data error: %v", err)
+		}
+		global.InitGlobalMounted()
+
+		// exports are rebuilt from the share rows on a timer, so there is
+		// no CRD to watch: new, deleted and expired shares are all picked
+		// up by the next refresh
+		nfs.NewNfsManager()
+		nfs.NfsService.Start()
+
+		coord := lifecycle.New()
+		coord.Add("postgres", 5*time.Second, func(context.Context) error {
+			return database.Close()
+		})
+		coord.Add("external-fsnotify", 2*time.Second, func(context.Context) error {
+			return global.ExternalWatcherClose()
+		})
+		coord.Add("nfs-exports", 10*time.Second, func(ctx context.Context) error {
+			return nfs.NfsService.Stop(ctx)
+		})
+
+		// Signal handling: first SIGTERM/SIGINT triggers ordered shutdown
+		// with a hard deadline; a second signal aborts immediately so an
+		// operator can always force-exit a stuck binary.
+		sigCh := make(chan os.Signal, 2)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		<-sigCh
+		klog.Infof("nfs: received shutdown signal, draining (timeout=%s)", nfsShutdownTimeout)
+		go func() {
+			<-sigCh
+			klog.Warning("nfs: second shutdown signal received, forcing exit")
+			os.Exit(1)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), nfsShutdownTimeout)
+		defer cancel()
+		coord.Run(ctx)
+	},
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		klog.Fatal(err)
+	}
+}
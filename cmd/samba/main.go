@@ -4,6 +4,7 @@ import (
 	"context"
 	v1 "files/pkg/apis/sys.bytetrade.io/v1"
 	"files/pkg/client"
+	"files/pkg/common"
 	"files/pkg/global"
 	"files/pkg/hertz/biz/dal/database"
 	"files/pkg/lifecycle"
@@ -65,6 +66,22 @@ var rootCmd = &cobra.Command{
 			return samba.SambaService.Stop(ctx)
 		})
 
+		auditCtx, auditCancel := context.WithCancel(context.Background())
+		auditDone := make(chan struct{})
+		go func() {
+			defer close(auditDone)
+			samba.NewAuditCollector(common.SambaAuditLogPath).Run(auditCtx)
+		}()
+		coord.Add("samba-audit", 5*time.Second, func(ctx context.Context) error {
+			auditCancel()
+			select {
+			case <-auditDone:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+
 		watcherCtx, watcherCancel := context.WithCancel(context.Background())
 		var w = watchers.NewWatchers(watcherCtx, config)
 		if err := watchers.AddToWatchers[v1.ShareSamba](w, samba.SambaGVR, samba.SambaService.HandlerEvent()); err != nil {
@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+set -Eeuo pipefail
+
+exports="/etc/exports"
+
+# Start from an empty export table, the share server renders the real one
+[ -f "$exports" ] || : > "$exports"
+
+# The nfsd filesystem carries the kernel server's control files
+if ! grep -qs " /proc/fs/nfsd " /proc/mounts; then
+    mount -t nfsd nfsd /proc/fs/nfsd || { echo "Failed to mount nfsd, is the container privileged?"; exit 1; }
+fi
+
+mkdir -p /var/lib/nfs/rpc_pipefs /var/lib/nfs/v4recovery
+grep -qs " /var/lib/nfs/rpc_pipefs " /proc/mounts || mount -t rpc_pipefs rpc_pipefs /var/lib/nfs/rpc_pipefs || true
+
+# Start the NFS services:
+#  rpcbind: port mapper, needed by NFSv3 clients.
+#  rpc.statd: lock recovery for NFSv3.
+#  rpc.nfsd: 8 kernel server threads, listening on 2049.
+#  rpc.mountd: answers mount requests, pinned to 20048 so it can be exposed.
+rpcbind -w
+rpc.statd --no-notify
+exportfs -ra
+rpc.nfsd 8
+exec rpc.mountd --port 20048
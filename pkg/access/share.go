@@ -27,6 +27,27 @@ type ShareAccess struct {
 	FromShare bool
 }
 
+// Action names the operation for the share activity log; it is empty
+// when no operation flag is set.
+func (a *ShareAccess) Action() string {
+	switch {
+	case a.Resource:
+		return common.ShareActivityResource
+	case a.Preview:
+		return common.ShareActivityPreview
+	case a.Raw:
+		return common.ShareActivityRaw
+	case a.Download:
+		return common.ShareActivityDownload
+	case a.Upload:
+		return common.ShareActivityUpload
+	case a.Paste:
+		return common.ShareActivityPaste
+	default:
+		return ""
+	}
+}
+
 // SharePermitted reports whether a share grant of the given permission
 // level allows the requested operation. The matrix mirrors the share
 // permission semantics:
@@ -104,7 +125,7 @@ func ShareCheckPaste(owner, shareID string, write bool) (*share.SharePath, error
 		return shared, nil
 	}
 
-	member, err := database.GetShareMember(shared.ID, owner)
+	member, err := resolveShareMember(shared.ID, owner)
 	if err != nil {
 		return nil, fmt.Errorf("query share member: %w", err)
 	}
@@ -122,9 +143,9 @@ func ShareCheckPaste(owner, shareID string, write bool) (*share.SharePath, error
 	return shared, nil
 }
 
-// ShareResolvePath loads the share record by id and enforces expiry.
-// The returned int64 is a unix timestamp used to surface link-expired
-// errors to the caller.
+// ShareResolvePath loads the share record by id and enforces expiry,
+// including the quota of an exhausted external link. The returned int64
+// is a unix timestamp used to surface link-expired errors to the caller.
 func ShareResolvePath(currentUser, shareId string, fromShare bool) (*share.SharePath, int64, error) {
 	sharePath, err := database.GetSharePath(shareId)
 	if err != nil {
@@ -144,13 +165,18 @@ func ShareResolvePath(currentUser, shareId string, fromShare bool) (*share.Share
 		klog.Errorf("sharePath expired, expireTime: %s", sharePath.ExpireTime)
 		return nil, exp, errors.New(common.ErrorMessageLinkExpired)
 	}
+	if sharePathExhausted(sharePath) {
+		klog.Errorf("sharePath quota exhausted, shareId: %s", shareId)
+		return nil, time.Now().Unix(), errors.New(common.ErrorMessageLinkExpired)
+	}
 	return sharePath, 0, nil
 }
 
-// ShareCheckInternal validates an internal share member and returns it
-// when the requested operation is permitted.
+// ShareCheckInternal validates an internal share member, direct or
+// through a share group, and returns it when the requested operation is
+// permitted.
 func ShareCheckInternal(currentOwner string, sharePaths *share.SharePath, a *ShareAccess) (*share.ShareMember, error) {
-	shareMember, err := database.GetShareMember(sharePaths.ID, currentOwner)
+	shareMember, err := resolveShareMember(sharePaths.ID, currentOwner)
 	if err != nil {
 		return nil, fmt.Errorf("GetShareMember error: %v", err)
 	}
@@ -192,6 +218,10 @@ func ShareCheckExternal(currentUser, token string, sharePaths *share.SharePath,
 		klog.Errorf("[share] shareToken expired, expireAt: %s", shareToken.ExpireAt)
 		return expired.Unix(), false, fmt.Errorf("shareToken expired, expireAt: %s", shareToken.ExpireAt)
 	}
+	if ShareQuotaExhausted(shareToken.MaxDownloads, shareToken.DownloadCount, shareToken.MaxBytes, shareToken.BytesServed) {
+		klog.Errorf("[share] shareToken quota exhausted, shareId: %s", sharePaths.ID)
+		return time.Now().Unix(), false, errors.New("shareToken quota exhausted")
+	}
 
 	return 0, SharePermitted(currentUser, sharePaths.Owner, sharePaths.ShareType, sharePaths.Permission, a), nil
 }
@@ -0,0 +1,67 @@
+package access
+
+import (
+	"strings"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"files/pkg/integration"
+)
+
+// IsShareGroupRef reports whether a share member names a group rather
+// than a user.
+func IsShareGroupRef(member string) bool {
+	return strings.HasPrefix(member, common.ShareGroupPrefix)
+}
+
+// ShareGroupRefs returns the group references user is covered by: the
+// built-in all_users/all_admins groups resolved from the platform user
+// list, and the share groups the user was added to.
+func ShareGroupRefs(user string) ([]string, error) {
+	var refs []string
+	if is := integration.IntegrationService; is != nil && is.UserExists(user) {
+		refs = append(refs, common.ShareGroupAllUsers)
+		if is.IsPlatformAdmin(user) {
+			refs = append(refs, common.ShareGroupAllAdmins)
+		}
+	}
+
+	ids, err := database.QueryShareGroupIdsByMember(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		refs = append(refs, common.ShareGroupPrefix+id)
+	}
+	return refs, nil
+}
+
+// resolveShareMember returns the membership user holds on a share,
+// directly or through a group. When several apply the highest
+// permission wins; a group grant is reported under the user's name.
+func resolveShareMember(pathID, user string) (*share.ShareMember, error) {
+	member, err := database.GetShareMember(pathID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := ShareGroupRefs(user)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := database.QueryShareMembersByRefs(pathID, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range grants {
+		if member != nil && member.Permission >= g.Permission {
+			continue
+		}
+		var m = *g
+		m.ShareMember = user
+		member = &m
+	}
+	return member, nil
+}
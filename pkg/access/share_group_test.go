@@ -0,0 +1,66 @@
+package access
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/model/api/share"
+)
+
+func TestShareCheckInternal_Groups(t *testing.T) {
+	newShareTestDB(t)
+	seedShareMember(t, "p1", "viewer", 1)
+	seedShareMember(t, "p1", common.ShareGroupPrefix+"g1", 3)
+	seedShareMember(t, "p1", common.ShareGroupAllUsers, 1)
+	seedShareGroupMember(t, "g1", "carol")
+	seedShareGroupMember(t, "g1", "viewer")
+	seedShareGroupMember(t, "g2", "dave")
+
+	shared := &share.SharePath{ID: "p1", Owner: "alice", ShareType: common.ShareTypeInternal}
+	upload := &ShareAccess{Method: http.MethodPost, Upload: true}
+
+	t.Run("group member", func(t *testing.T) {
+		member, err := ShareCheckInternal("carol", shared, upload)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if member.ShareMember != "carol" || member.Permission != 3 {
+			t.Fatalf("member = %+v, want carol with permission 3", member)
+		}
+	})
+
+	t.Run("group grant above direct", func(t *testing.T) {
+		member, err := ShareCheckInternal("viewer", shared, upload)
+		if err != nil || member.Permission != 3 {
+			t.Fatalf("member = %+v, err = %v, want permission 3", member, err)
+		}
+	})
+
+	t.Run("other group", func(t *testing.T) {
+		// all_users needs the platform user list, which is absent here.
+		if _, err := ShareCheckInternal("dave", shared, &ShareAccess{Method: http.MethodGet, Resource: true}); err == nil {
+			t.Fatalf("want error for a member of an unrelated group")
+		}
+	})
+
+	t.Run("paste destination through group", func(t *testing.T) {
+		seedSharePath(t, "p1", "alice", common.ShareTypeInternal, futureRFC3339(time.Hour), 4)
+		if _, err := ShareCheckPaste("carol", "p1", true); err != nil {
+			t.Fatalf("paste through group: %v", err)
+		}
+	})
+}
+
+func TestIsShareGroupRef(t *testing.T) {
+	for member, want := range map[string]bool{
+		"alice":                          false,
+		common.ShareGroupAllUsers:        true,
+		common.ShareGroupPrefix + "0b7c": true,
+	} {
+		if got := IsShareGroupRef(member); got != want {
+			t.Errorf("IsShareGroupRef(%q) = %v, want %v", member, got, want)
+		}
+	}
+}
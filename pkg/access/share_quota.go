@@ -0,0 +1,87 @@
+package access
+
+import (
+	"errors"
+	"strings"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+)
+
+// External links, and the tokens they hand out, may cap how many
+// downloads and how many bytes they serve. A link that has used up
+// either quota resolves as expired until the owner raises the limit;
+// raw reads are charged by the share proxy through ShareChargeQuota.
+
+// ErrShareQuotaExhausted is returned by ShareChargeQuota when serving a
+// response would take the link or its token past a limit.
+var ErrShareQuotaExhausted = errors.New("share quota exhausted")
+
+// ShareQuotaExhausted reports whether a download or byte quota has run
+// out. A zero limit is unlimited.
+func ShareQuotaExhausted(maxDownloads, downloads, maxBytes, bytes int64) bool {
+	return (maxDownloads > 0 && downloads >= maxDownloads) || (maxBytes > 0 && bytes >= maxBytes)
+}
+
+// ShareQuotaRemaining returns what is left of a quota, or nil when it
+// is unlimited.
+func ShareQuotaRemaining(max, used int64) *int64 {
+	if max <= 0 {
+		return nil
+	}
+	var left = max - used
+	if left < 0 {
+		left = 0
+	}
+	return &left
+}
+
+func sharePathExhausted(s *share.SharePath) bool {
+	return strings.ToLower(s.ShareType) == common.ShareTypeExternal &&
+		ShareQuotaExhausted(s.MaxDownloads, s.DownloadCount, s.MaxBytes, s.BytesServed)
+}
+
+// ShareMetered reports whether reads through shared are charged: only
+// external links opened from the share host count against quotas.
+func ShareMetered(shared *share.SharePath, a *ShareAccess) bool {
+	return a.FromShare && strings.ToLower(shared.ShareType) == common.ShareTypeExternal
+}
+
+// ShareBytesRemaining returns how many more bytes shared and token may
+// serve, or -1 when neither has a byte limit.
+func ShareBytesRemaining(shared *share.SharePath, token string) (int64, error) {
+	var left = int64(-1)
+	if p := ShareQuotaRemaining(shared.MaxBytes, shared.BytesServed); p != nil {
+		left = *p
+	}
+	if token == "" {
+		return left, nil
+	}
+
+	shareToken, err := database.QueryShareExternalById(shared.ID, token)
+	if err != nil {
+		return 0, err
+	}
+	if p := ShareQuotaRemaining(shareToken.MaxBytes, shareToken.BytesServed); p != nil && (left < 0 || *p < left) {
+		left = *p
+	}
+	return left, nil
+}
+
+// ShareChargeQuota charges one response of bytes (and a download when
+// download is set) to shared and token.
+func ShareChargeQuota(shared *share.SharePath, token string, download bool, bytes int64) error {
+	var downloads int64
+	if download {
+		downloads = 1
+	}
+	charged, err := database.ChargeShareQuota(shared.ID, strings.TrimSpace(token), downloads, bytes)
+	if err != nil {
+		return err
+	}
+	if !charged {
+		return ErrShareQuotaExhausted
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+)
+
+func TestShareQuotaRemaining(t *testing.T) {
+	if got := ShareQuotaRemaining(0, 5); got != nil {
+		t.Fatalf("unlimited remaining = %d, want nil", *got)
+	}
+	if got := ShareQuotaRemaining(10, 4); got == nil || *got != 6 {
+		t.Fatalf("remaining = %v, want 6", got)
+	}
+	if got := ShareQuotaRemaining(3, 5); got == nil || *got != 0 {
+		t.Fatalf("overdrawn remaining = %v, want 0", got)
+	}
+}
+
+func TestShareChargeQuota(t *testing.T) {
+	newShareTestDB(t)
+	seedSharePath(t, "p1", "alice", common.ShareTypeExternal, futureRFC3339(time.Hour), 1)
+	seedShareToken(t, "p1", "tok", futureRFC3339(time.Hour))
+	if err := database.DB.Exec(`UPDATE share_paths SET max_downloads = 2, max_bytes = 100 WHERE id = 'p1'`).Error; err != nil {
+		t.Fatalf("set limits: %v", err)
+	}
+	if err := database.DB.Exec(`UPDATE share_tokens SET max_bytes = 50 WHERE token = 'tok'`).Error; err != nil {
+		t.Fatalf("set token limit: %v", err)
+	}
+
+	shared, _, err := ShareResolvePath("bob", "p1", true)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	if err := ShareChargeQuota(shared, "tok", true, 40); err != nil {
+		t.Fatalf("first charge: %v", err)
+	}
+	// The token has 10 bytes left; the link would still take 20.
+	if err := ShareChargeQuota(shared, "tok", false, 20); !errors.Is(err, ErrShareQuotaExhausted) {
+		t.Fatalf("token overrun err = %v, want ErrShareQuotaExhausted", err)
+	}
+	if err := ShareChargeQuota(shared, "", true, 20); err != nil {
+		t.Fatalf("second download: %v", err)
+	}
+	if err := ShareChargeQuota(shared, "", true, 0); !errors.Is(err, ErrShareQuotaExhausted) {
+		t.Fatalf("third download err = %v, want ErrShareQuotaExhausted", err)
+	}
+
+	if _, expires, err := ShareResolvePath("bob", "p1", true); err == nil || expires <= 0 {
+		t.Fatalf("exhausted link resolved: expires=%d err=%v", expires, err)
+	}
+	if _, _, err := ShareResolvePath("alice", "p1", false); err != nil {
+		t.Fatalf("owner blocked by quota: %v", err)
+	}
+
+	left, err := ShareBytesRemaining(shared, "tok")
+	if err != nil || left != 10 {
+		t.Fatalf("bytes remaining = %d, %v, want 10", left, err)
+	}
+}
@@ -0,0 +1,145 @@
+package access
+
+import (
+	"errors"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+)
+
+// A file request is an upload-only external link. Every visitor names
+// themselves when asking for a token, their uploads are filed in a folder
+// of their own under the share, and the owner may cap how much each
+// uploader sends and which extensions are accepted.
+
+var (
+	ErrShareUploaderRequired = errors.New(common.ErrorMessageShareUploaderRequired)
+	ErrShareRequestFileType  = errors.New(common.ErrorMessageShareRequestFileType)
+	ErrShareRequestSize      = errors.New(common.ErrorMessageShareRequestSizeExceeded)
+)
+
+const shareUploaderNameMax = 64
+
+// IsShareRequest reports whether shared is a file request link.
+func IsShareRequest(shared *share.SharePath) bool {
+	return shared.FileRequest == 1 && strings.ToLower(shared.ShareType) == common.ShareTypeExternal
+}
+
+// ShareUploader validates the name and optional email a visitor gives
+// for a file request and returns them in stored form.
+func ShareUploader(name, email string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > shareUploaderNameMax {
+		return "", "", ErrShareUploaderRequired
+	}
+	email = strings.TrimSpace(email)
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			return "", "", errors.New("invalid email address")
+		}
+		email = strings.ToLower(addr.Address)
+	}
+	return name, email, nil
+}
+
+// ShareRequestFolder names the folder an uploader's files are filed in,
+// e.g. "Alice (alice@example.com)". Path separators and characters other
+// platforms reject are replaced so the name is always a single segment.
+func ShareRequestFolder(name, email string) string {
+	var folder = name
+	if email != "" {
+		folder += " (" + email + ")"
+	}
+	folder = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, folder)
+	folder = strings.Trim(folder, " .")
+	if folder == "" {
+		folder = "_"
+	}
+	return folder
+}
+
+// NormalizeShareRequestTypes turns an accepted-extension list such as
+// "PDF, .docx" into ".pdf,.docx".
+func NormalizeShareRequestTypes(types string) string {
+	var res []string
+	for _, t := range strings.Split(types, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || t == "." {
+			continue
+		}
+		if !strings.HasPrefix(t, ".") {
+			t = "." + t
+		}
+		if !common.ListContains(res, t) {
+			res = append(res, t)
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+// ShareRequestTypeAllowed reports whether filename carries one of the
+// accepted extensions; an empty list accepts any file.
+func ShareRequestTypeAllowed(types, filename string) bool {
+	if types == "" {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext != "" && common.ListContains(strings.Split(types, ","), ext)
+}
+
+// ShareRequestCheckUpload enforces a file request's restrictions on one
+// upload of size bytes by the uploader token was issued to. The size
+// budget is counted across every token the same uploader was issued;
+// the check is a cheap one for every chunk, ShareRequestReserve holds
+// the bytes.
+func ShareRequestCheckUpload(shared *share.SharePath, token *share.ShareToken, filename string, size int64) error {
+	if token == nil || token.UploaderName == "" {
+		return ErrShareUploaderRequired
+	}
+	if !ShareRequestTypeAllowed(shared.RequestFileTypes, filename) {
+		return ErrShareRequestFileType
+	}
+	if shared.RequestMaxSize <= 0 {
+		return nil
+	}
+	used, err := database.QueryShareUploaderBytes(shared.ID, token.UploaderName, token.UploaderEmail)
+	if err != nil {
+		return err
+	}
+	if used+size > shared.RequestMaxSize {
+		return ErrShareRequestSize
+	}
+	return nil
+}
+
+// ShareRequestReserve charges the size bytes of an upload starting
+// through a file request to the uploader token was issued to, or fails
+// with ErrShareRequestSize when it would exceed their budget. The bytes
+// are given back with ShareRequestRelease if the upload is aborted.
+func ShareRequestReserve(shared *share.SharePath, token *share.ShareToken, size int64) error {
+	reserved, err := database.ReserveShareUploaderBytes(token, shared.RequestMaxSize, size)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return ErrShareRequestSize
+	}
+	return nil
+}
+
+// ShareRequestRelease gives back size bytes ShareRequestReserve charged
+// to token.
+func ShareRequestRelease(token *share.ShareToken, size int64) error {
+	return database.ReleaseShareUploaderBytes(token.ID, size)
+}
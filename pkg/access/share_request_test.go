@@ -0,0 +1,154 @@
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+)
+
+func TestShareUploader(t *testing.T) {
+	name, email, err := ShareUploader("  Alice ", "Alice <Alice@Example.com>")
+	if err != nil || name != "Alice" || email != "alice@example.com" {
+		t.Fatalf("ShareUploader = %q, %q, %v", name, email, err)
+	}
+	if _, _, err := ShareUploader(" ", ""); !errors.Is(err, ErrShareUploaderRequired) {
+		t.Fatalf("empty name err = %v, want ErrShareUploaderRequired", err)
+	}
+	if _, _, err := ShareUploader("Bob", "not-an-email"); err == nil {
+		t.Fatalf("invalid email accepted")
+	}
+}
+
+func TestShareRequestFolder(t *testing.T) {
+	cases := map[[2]string]string{
+		{"Alice", ""}:                  "Alice",
+		{"Alice", "alice@example.com"}: "Alice (alice@example.com)",
+		{"../etc/passwd", ""}:          "_etc_passwd",
+		{"a:b*c?", ""}:                 "a_b_c_",
+		{"..", ""}:                     "_",
+	}
+	for in, want := range cases {
+		if got := ShareRequestFolder(in[0], in[1]); got != want {
+			t.Errorf("ShareRequestFolder(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestShareRequestTypes(t *testing.T) {
+	types := NormalizeShareRequestTypes(" PDF, .docx,,pdf, . ")
+	if types != ".pdf,.docx" {
+		t.Fatalf("normalized types = %q", types)
+	}
+	for name, want := range map[string]bool{
+		"report.PDF": true,
+		"notes.docx": true,
+		"image.png":  false,
+		"Makefile":   false,
+	} {
+		if got := ShareRequestTypeAllowed(types, name); got != want {
+			t.Errorf("ShareRequestTypeAllowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !ShareRequestTypeAllowed("", "anything") {
+		t.Fatalf("empty type list rejected a file")
+	}
+}
+
+func TestShareRequestCheckUpload(t *testing.T) {
+	newShareTestDB(t)
+	seedSharePath(t, "p1", "alice", common.ShareTypeExternal, futureRFC3339(time.Hour), 2)
+	seedShareToken(t, "p1", "tok1", futureRFC3339(time.Hour))
+	seedShareToken(t, "p1", "tok2", futureRFC3339(time.Hour))
+	seedShareToken(t, "p1", "anon", futureRFC3339(time.Hour))
+	for _, stmt := range []string{
+		`UPDATE share_paths SET file_request = 1, request_max_size = 100, request_file_types = '.pdf' WHERE id = 'p1'`,
+		`UPDATE share_tokens SET uploader_name = 'Bob', uploader_email = '', uploaded_bytes = 60 WHERE token = 'tok1'`,
+		`UPDATE share_tokens SET uploader_name = 'Bob', uploader_email = '' WHERE token = 'tok2'`,
+		`UPDATE share_tokens SET uploader_name = '', uploader_email = '' WHERE token = 'anon'`,
+	} {
+		if err := database.DB.Exec(stmt).Error; err != nil {
+			t.Fatalf("seed file request: %v", err)
+		}
+	}
+
+	shared, _, err := ShareResolvePath("bob", "p1", true)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if !IsShareRequest(shared) {
+		t.Fatalf("share not recognised as a file request")
+	}
+	token := func(tok string) *share.ShareToken {
+		st, err := database.QueryShareExternalById("p1", tok)
+		if err != nil {
+			t.Fatalf("query token %s: %v", tok, err)
+		}
+		return st
+	}
+
+	if err := ShareRequestCheckUpload(shared, token("anon"), "a.pdf", 1); !errors.Is(err, ErrShareUploaderRequired) {
+		t.Fatalf("anonymous upload err = %v, want ErrShareUploaderRequired", err)
+	}
+	if err := ShareRequestCheckUpload(shared, token("tok2"), "a.png", 1); !errors.Is(err, ErrShareRequestFileType) {
+		t.Fatalf("wrong type err = %v, want ErrShareRequestFileType", err)
+	}
+	// Bob already sent 60 bytes under another token.
+	if err := ShareRequestCheckUpload(shared, token("tok2"), "a.pdf", 40); err != nil {
+		t.Fatalf("upload within budget: %v", err)
+	}
+	if err := ShareRequestCheckUpload(shared, token("tok2"), "a.pdf", 41); !errors.Is(err, ErrShareRequestSize) {
+		t.Fatalf("upload over budget err = %v, want ErrShareRequestSize", err)
+	}
+}
+
+func TestShareRequestReserve(t *testing.T) {
+	newShareTestDB(t)
+	seedSharePath(t, "p1", "alice", common.ShareTypeExternal, futureRFC3339(time.Hour), 2)
+	seedShareToken(t, "p1", "tok1", futureRFC3339(time.Hour))
+	seedShareToken(t, "p1", "tok2", futureRFC3339(time.Hour))
+	for _, stmt := range []string{
+		`UPDATE share_paths SET file_request = 1, request_max_size = 100 WHERE id = 'p1'`,
+		`UPDATE share_tokens SET uploader_name = 'Bob', uploader_email = '', uploaded_bytes = 60 WHERE token = 'tok1'`,
+		`UPDATE share_tokens SET uploader_name = 'Bob', uploader_email = '' WHERE token = 'tok2'`,
+	} {
+		if err := database.DB.Exec(stmt).Error; err != nil {
+			t.Fatalf("seed file request: %v", err)
+		}
+	}
+	shared, _, err := ShareResolvePath("bob", "p1", true)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	tok2, err := database.QueryShareExternalById("p1", "tok2")
+	if err != nil {
+		t.Fatalf("query token: %v", err)
+	}
+
+	// two uploads of 30 bytes each pass the check, only one fits
+	for i := 0; i < 2; i++ {
+		if err := ShareRequestCheckUpload(shared, tok2, "a.pdf", 30); err != nil {
+			t.Fatalf("check upload %d: %v", i, err)
+		}
+	}
+	if err := ShareRequestReserve(shared, tok2, 30); err != nil {
+		t.Fatalf("first reservation: %v", err)
+	}
+	if err := ShareRequestReserve(shared, tok2, 30); !errors.Is(err, ErrShareRequestSize) {
+		t.Fatalf("second reservation err = %v, want ErrShareRequestSize", err)
+	}
+
+	// an aborted upload gives its bytes back
+	if err := ShareRequestRelease(tok2, 30); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if used, _ := database.QueryShareUploaderBytes("p1", "Bob", ""); used != 60 {
+		t.Fatalf("used after release = %d, want 60", used)
+	}
+	if err := ShareRequestReserve(shared, tok2, 40); err != nil {
+		t.Fatalf("reservation after release: %v", err)
+	}
+}
@@ -158,3 +158,24 @@ func TestSharePermitted_Matrix(t *testing.T) {
 		})
 	}
 }
+
+func TestShareAccessAction(t *testing.T) {
+	cases := []struct {
+		access *ShareAccess
+		want   string
+	}{
+		{&ShareAccess{Method: http.MethodGet, Resource: true}, common.ShareActivityResource},
+		{&ShareAccess{Method: http.MethodGet, Preview: true}, common.ShareActivityPreview},
+		{&ShareAccess{Method: http.MethodGet, Raw: true}, common.ShareActivityRaw},
+		{&ShareAccess{Method: http.MethodGet, Download: true}, common.ShareActivityDownload},
+		{&ShareAccess{Method: http.MethodGet, Upload: true}, common.ShareActivityUpload},
+		{&ShareAccess{Method: http.MethodPatch, Paste: true}, common.ShareActivityPaste},
+		{&ShareAccess{Method: http.MethodGet}, ""},
+	}
+
+	for _, tc := range cases {
+		if got := tc.access.Action(); got != tc.want {
+			t.Errorf("Action(%+v) = %q, want %q", tc.access, got, tc.want)
+		}
+	}
+}
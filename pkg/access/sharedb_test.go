@@ -30,7 +30,16 @@ func newShareTestDB(t *testing.T) {
 			path TEXT,
 			share_type TEXT,
 			expire_time TEXT,
-			permission INTEGER
+			permission INTEGER,
+			max_downloads INTEGER NOT NULL DEFAULT 0,
+			max_bytes INTEGER NOT NULL DEFAULT 0,
+			max_visitors INTEGER NOT NULL DEFAULT 0,
+			download_count INTEGER NOT NULL DEFAULT 0,
+			bytes_served INTEGER NOT NULL DEFAULT 0,
+			visitor_count INTEGER NOT NULL DEFAULT 0,
+			file_request INTEGER NOT NULL DEFAULT 0,
+			request_max_size INTEGER NOT NULL DEFAULT 0,
+			request_file_types TEXT
 		)`,
 		`CREATE TABLE share_members (
 			id INTEGER PRIMARY KEY,
@@ -38,11 +47,25 @@ func newShareTestDB(t *testing.T) {
 			share_member TEXT,
 			permission INTEGER
 		)`,
+		`CREATE TABLE share_group_members (
+			id INTEGER PRIMARY KEY,
+			group_id TEXT,
+			member TEXT,
+			create_time TEXT
+		)`,
 		`CREATE TABLE share_tokens (
 			id INTEGER PRIMARY KEY,
 			path_id TEXT,
 			token TEXT,
-			expire_at TEXT
+			expire_at TEXT,
+			ip TEXT,
+			max_downloads INTEGER NOT NULL DEFAULT 0,
+			max_bytes INTEGER NOT NULL DEFAULT 0,
+			download_count INTEGER NOT NULL DEFAULT 0,
+			bytes_served INTEGER NOT NULL DEFAULT 0,
+			uploader_name TEXT,
+			uploader_email TEXT,
+			uploaded_bytes INTEGER NOT NULL DEFAULT 0
 		)`,
 	}
 	for _, stmt := range ddl {
@@ -95,3 +118,14 @@ func seedShareToken(t *testing.T, pathID, token, expireAt string) {
 func futureRFC3339(d time.Duration) string {
 	return time.Now().Add(d).UTC().Format(time.RFC3339Nano)
 }
+
+func seedShareGroupMember(t *testing.T, groupID, member string) {
+	t.Helper()
+	err := database.DB.Exec(
+		`INSERT INTO share_group_members (group_id, member) VALUES (?, ?)`,
+		groupID, member,
+	).Error
+	if err != nil {
+		t.Fatalf("seed share_group_members: %v", err)
+	}
+}
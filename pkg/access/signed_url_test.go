@@ -0,0 +1,86 @@
+package access
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURLRoundTrip(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Unix(1_800_000_000, 0)
+	claims := &SignedURLClaims{
+		Owner:       "alice",
+		Path:        "/drive/Home/Movies/a b#1.mp4",
+		Expires:     now.Add(time.Hour).Unix(),
+		IP:          "10.0.0.2",
+		Disposition: DispositionInline,
+	}
+	sig := SignURL(secret, claims)
+
+	u, err := url.Parse(SignedURL("/api/signed_raw", claims, sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/api/signed_raw/drive/Home/Movies/a b#1.mp4" {
+		t.Fatalf("path = %q", u.Path)
+	}
+	q := u.Query()
+	parsed := &SignedURLClaims{
+		Owner:       q.Get("owner"),
+		Path:        strings.TrimPrefix(u.Path, "/api/signed_raw"),
+		Expires:     claims.Expires,
+		IP:          q.Get("ip"),
+		Disposition: q.Get("disposition"),
+	}
+	if q.Get("expires") != "1800003600" {
+		t.Fatalf("expires = %q", q.Get("expires"))
+	}
+	if err = VerifySignedURL(secret, parsed, q.Get("sig"), "10.0.0.2", now); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		claims   SignedURLClaims
+		secret   string
+		clientIP string
+		now      time.Time
+		want     error
+	}{
+		"other path":  {claims: SignedURLClaims{Owner: "alice", Path: "/drive/Home/x", Expires: claims.Expires, IP: claims.IP, Disposition: DispositionInline}, want: ErrSignedURLInvalid},
+		"other owner": {claims: SignedURLClaims{Owner: "bob", Path: claims.Path, Expires: claims.Expires, IP: claims.IP, Disposition: DispositionInline}, want: ErrSignedURLInvalid},
+		"longer ttl":  {claims: SignedURLClaims{Owner: "alice", Path: claims.Path, Expires: claims.Expires + 1, IP: claims.IP, Disposition: DispositionInline}, want: ErrSignedURLInvalid},
+		"unbound":     {claims: SignedURLClaims{Owner: "alice", Path: claims.Path, Expires: claims.Expires, Disposition: DispositionInline}, want: ErrSignedURLInvalid},
+		"attachment":  {claims: SignedURLClaims{Owner: "alice", Path: claims.Path, Expires: claims.Expires, IP: claims.IP, Disposition: DispositionAttachment}, want: ErrSignedURLInvalid},
+		"other key":   {claims: *claims, secret: "other", want: ErrSignedURLInvalid},
+		"expired":     {claims: *claims, now: now.Add(2 * time.Hour), want: ErrSignedURLExpired},
+		"other ip":    {claims: *claims, clientIP: "10.0.0.3", want: ErrSignedURLIPMismatch},
+	} {
+		key, clientIP, at := secret, "10.0.0.2", now
+		if tc.secret != "" {
+			key = []byte(tc.secret)
+		}
+		if tc.clientIP != "" {
+			clientIP = tc.clientIP
+		}
+		if !tc.now.IsZero() {
+			at = tc.now
+		}
+		if err = VerifySignedURL(key, &tc.claims, sig, clientIP, at); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestSignedURLSecret(t *testing.T) {
+	t.Setenv(signedURLSecretEnv, "")
+	if _, err := SignedURLSecret(); !errors.Is(err, ErrSignedURLDisabled) {
+		t.Fatalf("err = %v, want disabled", err)
+	}
+	t.Setenv(signedURLSecretEnv, "k")
+	if secret, err := SignedURLSecret(); err != nil || string(secret) != "k" {
+		t.Fatalf("secret = %q, err %v", secret, err)
+	}
+}
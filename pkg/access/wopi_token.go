@@ -0,0 +1,74 @@
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"files/pkg/models"
+	"strings"
+	"time"
+)
+
+// WOPI access tokens let an office server (a WOPI client such as
+// Collabora or OnlyOffice) read and save one file on behalf of the user
+// who opened it. The token carries the user, the resource path, the
+// Level the user had when it was issued and an expiry, signed with the
+// same $SIGNED_URL_SECRET as signed raw URLs under its own tag, so a
+// token never verifies as a URL signature or the other way round.
+
+const WOPITokenTTL = 2 * time.Hour
+
+var (
+	ErrWOPITokenInvalid = errors.New("wopi access token is invalid")
+	ErrWOPITokenExpired = errors.New("wopi access token expired")
+)
+
+// WOPIClaims is what a WOPI access token grants. Level caps what the
+// token allows; the user's current Level is checked on every use too.
+type WOPIClaims struct {
+	Owner   string       `json:"o"`
+	Path    string       `json:"p"`
+	Level   models.Level `json:"l"`
+	Expires int64        `json:"e"` // unix milliseconds, as WOPI's access_token_ttl
+}
+
+func wopiMAC(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("wopi1."))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// SignWOPIToken encodes and signs the claims into an opaque token.
+func SignWOPIToken(secret []byte, claims *WOPIClaims) string {
+	data, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(wopiMAC(secret, payload))
+}
+
+// ParseWOPIToken checks the signature and the expiry of token and
+// returns its claims.
+func ParseWOPIToken(secret []byte, token string, now time.Time) (*WOPIClaims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrWOPITokenInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, wopiMAC(secret, payload)) {
+		return nil, ErrWOPITokenInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrWOPITokenInvalid
+	}
+	var claims WOPIClaims
+	if err = json.Unmarshal(data, &claims); err != nil || claims.Owner == "" || claims.Path == "" {
+		return nil, ErrWOPITokenInvalid
+	}
+	if now.UnixMilli() > claims.Expires {
+		return nil, ErrWOPITokenExpired
+	}
+	return &claims, nil
+}
@@ -0,0 +1,64 @@
+package access
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"files/pkg/models"
+)
+
+func TestWOPITokenRoundTrip(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.UnixMilli(1_800_000_000_000)
+	claims := &WOPIClaims{
+		Owner:   "alice",
+		Path:    "/sync/repo-1/Docs/plan.docx",
+		Level:   models.LevelWrite,
+		Expires: now.Add(WOPITokenTTL).UnixMilli(),
+	}
+	token := SignWOPIToken(secret, claims)
+
+	got, err := ParseWOPIToken(secret, token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *claims {
+		t.Fatalf("claims = %+v, want %+v", got, claims)
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	forged := SignWOPIToken(secret, &WOPIClaims{Owner: "alice", Path: claims.Path, Level: models.LevelAdmin, Expires: claims.Expires})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	for name, tc := range map[string]struct {
+		token  string
+		secret string
+		now    time.Time
+		want   error
+	}{
+		"other key":       {token: token, secret: "other", want: ErrWOPITokenInvalid},
+		"swapped payload": {token: forgedPayload + "." + sig, want: ErrWOPITokenInvalid},
+		"no signature":    {token: payload, want: ErrWOPITokenInvalid},
+		"garbage":         {token: "a.b", want: ErrWOPITokenInvalid},
+		"expired":         {token: token, now: now.Add(WOPITokenTTL + time.Millisecond), want: ErrWOPITokenExpired},
+	} {
+		key, at := secret, now
+		if tc.secret != "" {
+			key = []byte(tc.secret)
+		}
+		if !tc.now.IsZero() {
+			at = tc.now
+		}
+		if _, err = ParseWOPIToken(key, tc.token, at); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.want)
+		}
+	}
+
+	// a token is no signed url signature and vice versa
+	urlClaims := &SignedURLClaims{Owner: "alice", Path: claims.Path, Expires: claims.Expires / 1000}
+	if _, err = ParseWOPIToken(secret, payload+"."+SignURL(secret, urlClaims), now); !errors.Is(err, ErrWOPITokenInvalid) {
+		t.Errorf("url signature accepted as token: %v", err)
+	}
+}
@@ -19,6 +19,13 @@ const (
 	SERVER_HOST = "127.0.0.1:8080"
 
 	SambaConfTemplatePath = "/etc/samba/smb.conf"
+	SambaAuditLogPath     = "/var/log/samba/audit.log"
+	NfsExportsPath        = "/etc/exports"
+
+	// SambaRecycleDir and SambaSnapshotDir are kept by smb shares in
+	// their folder for the recycle bin and Previous Versions.
+	SambaRecycleDir  = ".recycle"
+	SambaSnapshotDir = ".snapshots"
 
 	DefaultNamespace              = "os-framework"
 	DefaultServiceAccount         = "os-internal"
@@ -85,6 +92,10 @@ const (
 	ActionUploadFinalize  = "upload_finalize"
 	ActionCompress        = "compress"
 	ActionExtract         = "extract"
+	ActionVerify          = "verify"
+	ActionHash            = "hash"
+	ActionDuplicates      = "duplicates"
+	ActionDiskUsage       = "disk_usage"
 
 	AsyncFinalizeThreshold int64 = 2 * 1024 * 1024 * 1024 // 2GB
 )
@@ -104,9 +115,20 @@ const (
 	ArchiveConflictRename    = "rename"
 	ArchiveConflictOverwrite = "overwrite"
 	ArchiveConflictSkip      = "skip"
+
+	PasteConflictRename    = "rename"
+	PasteConflictOverwrite = "overwrite"
+	PasteConflictSkip      = "skip"
+	PasteConflictKeepNewer = "keep_newer"
+	PasteConflictAsk       = "ask"
 )
 
 var (
+	// PasteConflicts lists every conflict policy a paste accepts.
+	PasteConflicts = []string{
+		PasteConflictRename, PasteConflictOverwrite, PasteConflictSkip,
+		PasteConflictKeepNewer, PasteConflictAsk,
+	}
 	// ArchiveFormatsWrite lists every format the compress endpoint
 	// accepts; all of them are produced by the 7z CLI backend.
 	ArchiveFormatsWrite = []string{
@@ -183,15 +205,35 @@ var (
 	DefaultUploadTempDir             = ".uploadstemp"
 	DefaultUploadToCloudTempPath     = DefaultLocalFileCachePath + DefaultUploadTempDir
 
-	CacheBuffer = "buffer"
-	CacheThumb  = "thumb"
-	CloudCache  = "cloud_cache"
+	CacheBuffer   = "buffer"
+	CacheThumb    = "thumb"
+	CacheMetadata = "metadata"
+	CloudCache    = "cloud_cache"
 )
 
 var (
 	ShareTypeInternal = "internal"
 	ShareTypeExternal = "external"
 	ShareTypeSMB      = "smb"
+	ShareTypeNFS      = "nfs"
+
+	ShareActivityResource = "resource"
+	ShareActivityPreview  = "preview"
+	ShareActivityRaw      = "raw"
+	ShareActivityDownload = "download"
+	ShareActivityUpload   = "upload"
+	ShareActivityPaste    = "paste"
+
+	ShareActivitySmbMkdir  = "smb_mkdir"
+	ShareActivitySmbWrite  = "smb_write"
+	ShareActivitySmbRename = "smb_rename"
+	ShareActivitySmbDelete = "smb_delete"
+
+	// A share member naming a group instead of a user. all_users and
+	// all_admins are resolved from the platform user list.
+	ShareGroupPrefix    = "group:"
+	ShareGroupAllUsers  = "group:all_users"
+	ShareGroupAllAdmins = "group:all_admins"
 
 	ErrorMessageDirNotExists               = "Directory not exist."
 	ErrorMessageShareNotExists             = "This share no longer exists. The link may have been deleted."
@@ -208,6 +250,14 @@ var (
 	ErrorMessageTokenInvalid               = "Token is invalid."
 	ErrorMessageLinkExpired                = "Link expired."
 	ErrorMessageGetTokenError              = "GetToken failed."
+	ErrorMessageShareVisitorLimit          = "This share link has reached its visitor limit."
+	ErrorMessageShareUploaderRequired      = "Please enter your name to upload files."
+	ErrorMessageShareRequestFileType       = "This file type is not accepted by this file request."
+	ErrorMessageShareRequestSizeExceeded   = "This upload exceeds the size allowed per uploader."
+	ErrorMessageShareRequestNotSupport     = "File requests are only supported for external shares of local folders."
+	ErrorMessageNfsNotSupport              = "NFS shares are only supported for local folders."
+	ErrorMessageShareGroupNotExists        = "Share group not exists."
+	ErrorMessageShareGroupSyncNotSupport   = "Sharing sync folders with groups is not supported."
 	ErrorMessagePermissionDenied           = "Permission denied."
 	ErrorMessageUserExists                 = "User already exists or is used by another account."
 	ErrorMessageShareExists                = "Share exists."
@@ -18,6 +18,11 @@ import (
 // an internal forward.
 const HeaderInternalShareToken = "X-Internal-Share-Token"
 
+// HeaderShareVisitor carries the user behind a share-proxied request,
+// whose owner header the proxy rewrites to the grantor. It is trusted
+// only next to a valid HeaderInternalShareToken.
+const HeaderShareVisitor = "X-Share-Visitor"
+
 var (
 	internalTokenOnce sync.Once
 	internalToken     string
@@ -7,6 +7,7 @@ import (
 	"files/pkg/common"
 	"files/pkg/global"
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -49,3 +50,27 @@ func GenerateCacheKey(s string) string {
 	hash := hex.EncodeToString(hasher.Sum(nil))
 	return hash
 }
+
+// SourceKey is where the entries made from a file are kept: the path of
+// the file in the cache, so the entries of a file, or of every file in
+// a folder, are found and dropped together.
+func SourceKey(fileType, extend, path string) string {
+	return filepath.Join(fileType, filepath.Clean("/"+extend), filepath.Clean("/"+path))
+}
+
+// GenerateSourceCacheKey is the key of what variant names, made from the
+// file at path in its version. Storing one version of a file drops the
+// entries of the others.
+func GenerateSourceCacheKey(fileType, extend, path, version, variant string) string {
+	return SourceKey(fileType, extend, path) + "/" + GenerateCacheKey(version) + "/" + GenerateCacheKey(variant)
+}
+
+// splitSourceKey returns the source and version folders of a key made by
+// GenerateSourceCacheKey.
+func splitSourceKey(key string) (source, version string, ok bool) {
+	dir := path.Dir(key)
+	if dir == "." || !strings.Contains(dir, "/") {
+		return "", "", false
+	}
+	return path.Dir(dir), dir, true
+}
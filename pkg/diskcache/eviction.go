@@ -0,0 +1,504 @@
+package diskcache
+
+import (
+	"container/list"
+	"files/pkg/common"
+	"files/pkg/global"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+// Tags are the kinds of entries Store is used with. Scan reads them back
+// into the index, the other folders of the cache are not the FileCache's.
+var Tags = []string{common.CacheThumb, common.CacheMetadata}
+
+// DefaultLimit is the byte budget of the cache of a node when
+// PREVIEW_CACHE_LIMIT is not set.
+const DefaultLimit int64 = 10 << 30
+
+// lowWater is the share of the budget an eviction frees the cache down
+// to, so a full cache does not evict on every Store.
+const lowWater = 0.9
+
+// touchInterval is how old the modification time of an entry gets before
+// a Load writes it back. It orders the entries again after a restart,
+// without a write on every hit.
+const touchInterval = time.Hour
+
+var versionName = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+type entry struct {
+	name    string // path in the cache
+	owner   string
+	key     string
+	tag     string
+	size    int64
+	access  time.Time
+	stamped time.Time // modification time on disk
+}
+
+// OwnerUsage is what the cache holds for one owner.
+type OwnerUsage struct {
+	Owner   string           `json:"owner"`
+	Bytes   int64            `json:"bytes"`
+	Entries int              `json:"entries"`
+	Tags    map[string]int64 `json:"tags"`
+}
+
+// Usage is what the cache holds, the owners using the most first.
+// Until Scanned, entries stored before the start are not counted.
+type Usage struct {
+	Limit   int64         `json:"limit"`
+	Bytes   int64         `json:"bytes"`
+	Entries int           `json:"entries"`
+	Scanned bool          `json:"scanned"`
+	Owners  []*OwnerUsage `json:"owners"`
+}
+
+// index tracks the size and last use of the entries of the cache.
+type index struct {
+	mu       sync.Mutex
+	limit    int64
+	bytes    int64
+	lru      *list.List // of *entry, the most recently used first
+	entries  map[string]*list.Element
+	owners   map[string]*OwnerUsage
+	scanned  bool
+	evicting bool
+}
+
+func newIndex(limit int64) *index {
+	return &index{
+		limit:   limit,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		owners:  make(map[string]*OwnerUsage),
+	}
+}
+
+// cacheLimit applies PREVIEW_CACHE_LIMIT, a quantity like 20Gi; 0 turns
+// eviction off.
+func cacheLimit() int64 {
+	var n = DefaultLimit
+	if v := os.Getenv("PREVIEW_CACHE_LIMIT"); v != "" {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			klog.Warningf("[diskcache] invalid PREVIEW_CACHE_LIMIT %q, using %d", v, n)
+		} else {
+			n = q.Value()
+		}
+	}
+	return n
+}
+
+func (x *index) account(e *entry, sign int64) {
+	x.bytes += sign * e.size
+	u, ok := x.owners[e.owner]
+	if !ok {
+		u = &OwnerUsage{Owner: e.owner, Tags: make(map[string]int64)}
+		x.owners[e.owner] = u
+	}
+	u.Bytes += sign * e.size
+	u.Entries += int(sign)
+	u.Tags[e.tag] += sign * e.size
+	if u.Tags[e.tag] == 0 {
+		delete(u.Tags, e.tag)
+	}
+	if u.Entries == 0 {
+		delete(x.owners, e.owner)
+	}
+}
+
+// put adds e as the most recently used entry, replacing the one of the
+// same name. A scanned entry does not replace one already known.
+func (x *index) put(e *entry, scanned bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if el, ok := x.entries[e.name]; ok {
+		if scanned {
+			return
+		}
+		x.account(el.Value.(*entry), -1)
+		x.lru.Remove(el)
+	}
+	x.account(e, 1)
+	if scanned {
+		// scanned entries are older than any stored since the start
+		x.entries[e.name] = x.lru.PushBack(e)
+		return
+	}
+	x.entries[e.name] = x.lru.PushFront(e)
+}
+
+// touch marks the entry name used now, and tells whether its time on disk
+// is to be written back.
+func (x *index) touch(name string, now time.Time) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	el, ok := x.entries[name]
+	if !ok {
+		return false
+	}
+	e := el.Value.(*entry)
+	e.access = now
+	x.lru.MoveToFront(el)
+	if now.Sub(e.stamped) < touchInterval {
+		return false
+	}
+	e.stamped = now
+	return true
+}
+
+func (x *index) remove(name string) {
+	x.take(name)
+}
+
+// take drops the entry name and returns it, nil when it is not indexed.
+func (x *index) take(name string) *entry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	el, ok := x.entries[name]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	x.account(e, -1)
+	x.lru.Remove(el)
+	delete(x.entries, name)
+	return e
+}
+
+// removeWhere drops the entries match picks and returns them.
+func (x *index) removeWhere(match func(e *entry) bool) []*entry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var removed []*entry
+	for el := x.lru.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); match(e) {
+			x.account(e, -1)
+			x.lru.Remove(el)
+			delete(x.entries, e.name)
+			removed = append(removed, e)
+		}
+		el = next
+	}
+	return removed
+}
+
+// startEviction tells whether the cache is over its budget with no
+// eviction running, and if so marks one running.
+func (x *index) startEviction() bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.limit <= 0 || x.bytes <= x.limit || x.evicting {
+		return false
+	}
+	x.evicting = true
+	return true
+}
+
+func (x *index) endEviction() {
+	x.mu.Lock()
+	x.evicting = false
+	x.mu.Unlock()
+}
+
+// victims drops the least recently used entries until the cache is back
+// to lowWater of its budget, and returns them.
+func (x *index) victims() []*entry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var target = int64(float64(x.limit) * lowWater)
+	var removed []*entry
+	for x.bytes > target {
+		el := x.lru.Back()
+		if el == nil {
+			break
+		}
+		e := el.Value.(*entry)
+		x.account(e, -1)
+		x.lru.Remove(el)
+		delete(x.entries, e.name)
+		removed = append(removed, e)
+	}
+	return removed
+}
+
+func (x *index) usage() *Usage {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	res := &Usage{Limit: x.limit, Bytes: x.bytes, Entries: len(x.entries), Scanned: x.scanned, Owners: []*OwnerUsage{}}
+	for _, u := range x.owners {
+		c := *u
+		c.Tags = make(map[string]int64, len(u.Tags))
+		for k, v := range u.Tags {
+			c.Tags[k] = v
+		}
+		res.Owners = append(res.Owners, &c)
+	}
+	sort.Slice(res.Owners, func(i, j int) bool {
+		if res.Owners[i].Bytes != res.Owners[j].Bytes {
+			return res.Owners[i].Bytes > res.Owners[j].Bytes
+		}
+		return res.Owners[i].Owner < res.Owners[j].Owner
+	})
+	return res
+}
+
+func (x *index) ownerNames() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	names := make([]string, 0, len(x.owners))
+	for o := range x.owners {
+		names = append(names, o)
+	}
+	return names
+}
+
+// Scan indexes the entries the owners stored before the start, their
+// modification times standing for their last use, then evicts if the
+// cache is over its budget. It is run once the owners are known.
+func (f *FileCache) Scan(owners []string) {
+	var count int
+	for _, owner := range owners {
+		for _, tag := range Tags {
+			prefixPath := f.formatPath(owner, tag)
+			err := afero.Walk(f.fs, strings.TrimSuffix(prefixPath, "/"), func(p string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				f.index.put(&entry{
+					name:    p,
+					owner:   owner,
+					key:     strings.TrimPrefix(p, prefixPath),
+					tag:     tag,
+					size:    info.Size(),
+					access:  info.ModTime(),
+					stamped: info.ModTime(),
+				}, true)
+				count++
+				return nil
+			})
+			if err != nil && !os.IsNotExist(err) {
+				klog.Warningf("[diskcache] scan %s error: %v", prefixPath, err)
+			}
+		}
+	}
+
+	// the walk puts entries in no order of use, sort them by time
+	f.index.mu.Lock()
+	f.index.scanned = true
+	var all []*entry
+	for el := f.index.lru.Front(); el != nil; el = el.Next() {
+		all = append(all, el.Value.(*entry))
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].access.After(all[j].access) })
+	f.index.lru.Init()
+	for _, e := range all {
+		f.index.entries[e.name] = f.index.lru.PushBack(e)
+	}
+	f.index.mu.Unlock()
+
+	usage := f.Usage()
+	klog.Infof("[diskcache] scanned %d entries, cache holds %d bytes of %d", count, usage.Bytes, usage.Limit)
+	f.evictIfFull()
+}
+
+// Usage reports what the cache holds.
+func (f *FileCache) Usage() *Usage {
+	return f.index.usage()
+}
+
+// evictIfFull starts an eviction when the cache is over its budget.
+func (f *FileCache) evictIfFull() {
+	if !f.index.startEviction() {
+		return
+	}
+	go func() {
+		defer f.index.endEviction()
+		victims := f.index.victims()
+		var freed int64
+		for _, e := range victims {
+			f.removeEntry(e)
+			freed += e.size
+		}
+		klog.Infof("[diskcache] evicted %d entries, %d bytes", len(victims), freed)
+	}()
+}
+
+func (f *FileCache) removeEntry(e *entry) {
+	mu := f.getScopedLocks(e.owner + e.key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := f.fs.Remove(e.name); err != nil && !os.IsNotExist(err) {
+		klog.Errorf("[diskcache] remove %s error: %v", e.name, err)
+		return
+	}
+	f.prune(path.Dir(e.name), f.formatPath(e.owner, e.tag))
+}
+
+// prune removes dir and the folders above it, up to root, while empty.
+func (f *FileCache) prune(dir, root string) {
+	root = strings.TrimSuffix(root, "/")
+	for strings.HasPrefix(dir, root+"/") {
+		if err := f.fs.Remove(dir); err != nil {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+// dropVersions removes the entries of the other versions of source.
+func (f *FileCache) dropVersions(prefixPath, source, version string) {
+	dir := prefixPath + source
+	names, err := afero.ReadDir(f.fs, dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range names {
+		if !fi.IsDir() || !versionName.MatchString(fi.Name()) || source+"/"+fi.Name() == version {
+			continue
+		}
+		old := dir + "/" + fi.Name()
+		if err := f.fs.RemoveAll(old); err != nil {
+			klog.Errorf("[diskcache] remove %s error: %v", old, err)
+		}
+		f.index.removeWhere(func(e *entry) bool { return strings.HasPrefix(e.name, old+"/") })
+	}
+}
+
+// Invalidate drops the entries owner keeps of the file or folder source,
+// a SourceKey, once it is deleted or changed.
+func (f *FileCache) Invalidate(owner string, source string) {
+	for _, tag := range Tags {
+		prefixPath := f.formatPath(owner, tag)
+		dir := prefixPath + source
+		if err := f.fs.RemoveAll(dir); err != nil {
+			klog.Errorf("[diskcache] invalidate %s error: %v", dir, err)
+			continue
+		}
+		f.index.removeWhere(func(e *entry) bool { return strings.HasPrefix(e.name, dir+"/") })
+		f.prune(path.Dir(dir), prefixPath)
+	}
+}
+
+// Expire removes the entries last used before t.
+func (f *FileCache) Expire(t time.Time) {
+	expired := f.index.removeWhere(func(e *entry) bool { return e.access.Before(t) })
+	for _, e := range expired {
+		f.removeEntry(e)
+	}
+	klog.Infof("[diskcache] expired %d entries unused since %s", len(expired), t.Format(time.RFC3339))
+}
+
+// Purge removes the entries of owner with tag, "" for any, and returns
+// how many entries and bytes the index counted for them. Every owner's
+// cache folder found on disk is purged when owner is "", whether the
+// index knows of it or not. Each file is removed under the lock Store
+// writes it under, so a Store running meanwhile is not cut short.
+func (f *FileCache) Purge(owner, tag string) (int, int64, error) {
+	var tags = []string{tag}
+	if tag == "" {
+		tags = Tags
+	}
+	roots, err := f.purgeRoots(owner)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count int
+	var freed int64
+	var firstErr error
+	for _, root := range roots {
+		for _, t := range tags {
+			prefixPath := f.cachePath(root.pvc, t)
+			var dirs []string
+			_ = afero.Walk(f.fs, strings.TrimSuffix(prefixPath, "/"), func(p string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				if info.IsDir() {
+					dirs = append(dirs, p)
+					return nil
+				}
+				mu := f.getScopedLocks(root.owner + strings.TrimPrefix(p, prefixPath))
+				mu.Lock()
+				e := f.index.take(p)
+				err = f.fs.Remove(p)
+				mu.Unlock()
+				if e != nil {
+					count++
+					freed += e.size
+				}
+				if err != nil && !os.IsNotExist(err) && firstErr == nil {
+					firstErr = err
+				}
+				return nil
+			})
+			// the deepest first; a folder a Store just wrote into stays
+			for i := len(dirs) - 1; i >= 0; i-- {
+				_ = f.fs.Remove(dirs[i])
+			}
+		}
+	}
+
+	// entries whose files were already gone
+	removed := f.index.removeWhere(func(e *entry) bool {
+		return (owner == "" || e.owner == owner) && (tag == "" || e.tag == tag)
+	})
+	for _, e := range removed {
+		count++
+		freed += e.size
+	}
+	return count, freed, firstErr
+}
+
+// purgeRoot is the cache folder of an owner, owner "" when the folder is
+// of no known user.
+type purgeRoot struct {
+	owner string
+	pvc   string
+}
+
+// purgeRoots lists the cache folders Purge clears: owner's, or all
+// those on disk when owner is "".
+func (f *FileCache) purgeRoots(owner string) ([]purgeRoot, error) {
+	if owner != "" {
+		return []purgeRoot{{owner: owner, pvc: global.GlobalData.GetPvcCache(owner)}}, nil
+	}
+	infos, err := afero.ReadDir(f.fs, "/")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var roots []purgeRoot
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			continue
+		}
+		o, _ := global.GlobalData.GetPvcCacheName(fi.Name())
+		roots = append(roots, purgeRoot{owner: o, pvc: fi.Name()})
+	}
+	return roots, nil
+}
@@ -0,0 +1,204 @@
+package diskcache
+
+import (
+	"context"
+	"files/pkg/common"
+	"files/pkg/global"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// newTestCache returns a FileCache on memory with the byte budget limit,
+// alice and bob keeping their caches in their own folders.
+func newTestCache(t *testing.T, limit int64) *FileCache {
+	t.Helper()
+	saved := global.GlobalData
+	global.GlobalData = &global.Data{CachePvcMap: map[string]string{"alice": "cache-alice", "bob": "cache-bob"}}
+	t.Cleanup(func() { global.GlobalData = saved })
+
+	f := New(afero.NewMemMapFs(), "/appcache")
+	f.index.limit = limit
+	return f
+}
+
+func store(t *testing.T, f *FileCache, owner, key, tag string, size int) {
+	t.Helper()
+	if err := f.Store(context.Background(), owner, key, tag, make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(f *FileCache, owner, key, tag string) bool {
+	ok, _ := afero.Exists(f.fs, f.formatPath(owner, tag)+key)
+	return ok
+}
+
+// waitEviction waits for the eviction Store started.
+func waitEviction(t *testing.T, f *FileCache) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		f.index.mu.Lock()
+		evicting := f.index.evicting
+		f.index.mu.Unlock()
+		if !evicting {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("eviction did not end")
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	f := newTestCache(t, 1000)
+	store(t, f, "alice", "a", common.CacheThumb, 300)
+	store(t, f, "bob", "b", common.CacheThumb, 300)
+	store(t, f, "alice", "c", common.CacheThumb, 300)
+
+	// a is used again, b is now the least recently used
+	if _, ok, err := f.Load(context.Background(), "alice", "a", common.CacheThumb); !ok || err != nil {
+		t.Fatalf("load a: %v %v", ok, err)
+	}
+	store(t, f, "alice", "d", common.CacheThumb, 300)
+	waitEviction(t, f)
+
+	if exists(f, "bob", "b", common.CacheThumb) {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if !exists(f, "alice", key, common.CacheThumb) {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	u := f.Usage()
+	if u.Bytes != 900 || u.Entries != 3 || len(u.Owners) != 1 || u.Owners[0].Owner != "alice" || u.Owners[0].Tags[common.CacheThumb] != 900 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestVersionsAndInvalidate(t *testing.T) {
+	f := newTestCache(t, 0)
+	v1 := GenerateSourceCacheKey("drive", "Home", "/Pictures/a.jpg", "v1", "thumb")
+	v2 := GenerateSourceCacheKey("drive", "Home", "/Pictures/a.jpg", "v2", "thumb")
+	other := GenerateSourceCacheKey("drive", "Home", "/Pictures/b.jpg", "v1", "thumb")
+	md := GenerateSourceCacheKey("drive", "Home", "/Pictures/b.jpg", "v1", "metadata")
+
+	store(t, f, "alice", v1, common.CacheThumb, 10)
+	store(t, f, "alice", other, common.CacheThumb, 10)
+	store(t, f, "alice", md, common.CacheMetadata, 10)
+	store(t, f, "alice", v2, common.CacheThumb, 10)
+
+	if exists(f, "alice", v1, common.CacheThumb) {
+		t.Error("the old version was kept")
+	}
+	if !exists(f, "alice", v2, common.CacheThumb) || !exists(f, "alice", other, common.CacheThumb) {
+		t.Error("a current entry was dropped")
+	}
+	if u := f.Usage(); u.Entries != 3 || u.Bytes != 30 {
+		t.Errorf("usage after a new version = %+v", u)
+	}
+
+	// deleting the folder drops the entries of every file in it, of all tags
+	f.Invalidate("alice", SourceKey("drive", "Home", "/Pictures/"))
+	if u := f.Usage(); u.Entries != 0 || u.Bytes != 0 {
+		t.Errorf("usage after invalidate = %+v", u)
+	}
+	if ok, _ := afero.DirExists(f.fs, f.formatPath("alice", common.CacheThumb)+"drive"); ok {
+		t.Error("empty folders were left behind")
+	}
+}
+
+func TestScanAndPurge(t *testing.T) {
+	f := newTestCache(t, 0)
+	store(t, f, "alice", "a", common.CacheThumb, 100)
+	store(t, f, "alice", "m", common.CacheMetadata, 10)
+	store(t, f, "bob", "b", common.CacheThumb, 200)
+
+	old := time.Now().Add(-48 * time.Hour)
+	_ = f.fs.Chtimes(f.formatPath("bob", common.CacheThumb)+"b", old, old)
+
+	// a restart forgets the index, Scan reads it back
+	f.index = newIndex(0)
+	f.Scan([]string{"alice", "bob"})
+	u := f.Usage()
+	if !u.Scanned || u.Entries != 3 || u.Bytes != 310 || u.Owners[0].Owner != "bob" {
+		t.Fatalf("usage after scan = %+v", u)
+	}
+
+	f.Expire(time.Now().Add(-24 * time.Hour))
+	if exists(f, "bob", "b", common.CacheThumb) {
+		t.Error("an expired entry was kept")
+	}
+
+	entries, bytes, err := f.Purge("alice", common.CacheThumb)
+	if err != nil || entries != 1 || bytes != 100 {
+		t.Errorf("purge = %d, %d, %v", entries, bytes, err)
+	}
+	if exists(f, "alice", "a", common.CacheThumb) || !exists(f, "alice", "m", common.CacheMetadata) {
+		t.Error("purge removed the wrong entries")
+	}
+}
+
+func TestPurgeAll(t *testing.T) {
+	f := newTestCache(t, 0)
+	store(t, f, "alice", "a", common.CacheThumb, 100)
+	store(t, f, "bob", "b", common.CacheMetadata, 200)
+
+	// a restart forgets the index, the files are purged all the same
+	f.index = newIndex(0)
+	store(t, f, "alice", "c", common.CacheThumb, 10)
+
+	// a Store holding its lock is waited for
+	mu := f.getScopedLocks("alice" + "c")
+	mu.Lock()
+	done := make(chan struct{})
+	var entries int
+	var bytes int64
+	var err error
+	go func() {
+		entries, bytes, err = f.Purge("", "")
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if !exists(f, "alice", "c", common.CacheThumb) {
+		t.Error("an entry was removed under a Store")
+	}
+	mu.Unlock()
+	<-done
+
+	if err != nil || entries != 1 || bytes != 10 {
+		t.Errorf("purge = %d, %d, %v", entries, bytes, err)
+	}
+	for _, e := range []struct{ owner, key, tag string }{
+		{"alice", "a", common.CacheThumb}, {"alice", "c", common.CacheThumb}, {"bob", "b", common.CacheMetadata},
+	} {
+		if exists(f, e.owner, e.key, e.tag) {
+			t.Errorf("%s %s was kept", e.owner, e.key)
+		}
+	}
+	if u := f.Usage(); u.Entries != 0 || u.Bytes != 0 {
+		t.Errorf("usage after purge = %+v", u)
+	}
+}
+
+func TestSourceKey(t *testing.T) {
+	for _, c := range []struct{ fileType, extend, path, want string }{
+		{"drive", "Home", "/Pictures/a.jpg", "drive/Home/Pictures/a.jpg"},
+		{"drive", "Home", "/Pictures/", "drive/Home/Pictures"},
+		{"sync", "repo", "/../../etc/passwd", "sync/repo/etc/passwd"},
+		{"cache", "../node", "/a", "cache/node/a"},
+	} {
+		if got := SourceKey(c.fileType, c.extend, c.path); got != c.want {
+			t.Errorf("SourceKey(%q, %q, %q) = %q, want %q", c.fileType, c.extend, c.path, got, c.want)
+		}
+	}
+	if _, _, ok := splitSourceKey(GenerateCacheKey("flat")); ok {
+		t.Error("a flat key split")
+	}
+	if s, v, ok := splitSourceKey(GenerateSourceCacheKey("drive", "Home", "/a", "v", "x")); !ok || s != "drive/Home/a" || !strings.HasPrefix(v, s+"/") {
+		t.Errorf("split = %q %q %v", s, v, ok)
+	}
+}
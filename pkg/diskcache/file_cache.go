@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 	"k8s.io/klog/v2"
@@ -20,7 +21,8 @@ var fileCache *FileCache
 // var CacheDir = os.Getenv("FILE_CACHE_DIR") // "/data/file_cache"
 
 type FileCache struct {
-	fs afero.Fs
+	fs    afero.Fs
+	index *index
 
 	// granular locks
 	scopedLocks struct {
@@ -32,7 +34,8 @@ type FileCache struct {
 
 func New(fs afero.Fs, root string) *FileCache {
 	fileCache = &FileCache{
-		fs: afero.NewBasePathFs(fs, root),
+		fs:    afero.NewBasePathFs(fs, root),
+		index: newIndex(cacheLimit()),
 	}
 	return fileCache
 }
@@ -62,21 +65,40 @@ func (f *FileCache) Store(ctx context.Context, owner string, key string, tag str
 		return err
 	}
 
+	if source, version, ok := splitSourceKey(key); ok {
+		f.dropVersions(prefixPath, source, version)
+	}
+	var now = time.Now()
+	f.index.put(&entry{name: fileName, owner: owner, key: key, tag: tag, size: int64(len(value)), access: now, stamped: now}, false)
+	f.evictIfFull()
+
 	return nil
 }
 
 func (f *FileCache) Load(ctx context.Context, owner string, key string, tag string) (value []byte, exist bool, err error) {
 	prefixPath := f.formatPath(owner, tag)
 	r, ok, err := f.open(prefixPath, key)
-	if err != nil || !ok {
+	if err != nil {
 		return nil, ok, err
 	}
+	if !ok {
+		// removed behind the cache's back
+		f.index.remove(prefixPath + key)
+		return nil, false, nil
+	}
 	defer r.Close()
 
 	value, err = io.ReadAll(r)
 	if err != nil {
 		return nil, false, err
 	}
+
+	var now = time.Now()
+	if f.index.touch(prefixPath+key, now) {
+		if err := f.fs.Chtimes(prefixPath+key, now, now); err != nil {
+			klog.Warningf("discache touch %s error: %v", prefixPath+key, err)
+		}
+	}
 	return value, true, nil
 }
 
@@ -89,11 +111,18 @@ func (f *FileCache) Delete(ctx context.Context, owner string, key string, tag st
 	if err := f.fs.Remove(prefixPath + key); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
+	f.index.remove(prefixPath + key)
 	return nil
 }
 
 func (f *FileCache) formatPath(owner string, tag string) string {
-	var p = filepath.Join(global.GlobalData.GetPvcCache(owner), common.DefaultLocalFileCachePath, tag)
+	return f.cachePath(global.GlobalData.GetPvcCache(owner), tag)
+}
+
+// cachePath is the folder of the entries with tag in the cache folder
+// pvc of an owner.
+func (f *FileCache) cachePath(pvc string, tag string) string {
+	var p = filepath.Join(pvc, common.DefaultLocalFileCachePath, tag)
 	return p + "/"
 }
 
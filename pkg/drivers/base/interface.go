@@ -3,6 +3,7 @@ package base
 import (
 	"files/pkg/models"
 	"files/pkg/tasks"
+	"time"
 )
 
 type Execute interface {
@@ -42,3 +43,12 @@ type Execute interface {
 
 	CheckPathExists(p *models.FileParam) (exists, isDir bool, err error)
 }
+
+// EditStater is implemented by the drivers whose Edit checks If-Match and
+// If-Unmodified-Since, so that reads hand out the same ETag and mtime the
+// precondition is checked against.
+type EditStater interface {
+	// EditState returns the current ETag and mtime of a file; an empty
+	// ETag when it does not exist or is a directory.
+	EditState(fileParam *models.FileParam) (etag string, modTime time.Time, err error)
+}
@@ -79,9 +79,29 @@ func (s *CloudStorage) List(contextArgs *models.HttpContextArgs) ([]byte, error)
 		}
 	}
 
+	// the remote always returns the whole folder, so paging only trims
+	// the response
+	if list := contextArgs.QueryParam.List; list != nil && fileData != nil {
+		var page files.PageInfo
+		fileData.Data, page = files.Paginate(fileData.Data, cloudPageEntry, list)
+		fileData.NumDirs = page.NumDirs
+		fileData.NumFiles = page.NumFiles
+		fileData.NextCursor = page.NextCursor
+	}
+
 	return common.ToBytes(fileData), nil
 }
 
+func cloudPageEntry(item *models.CloudResponseData) files.PageEntry {
+	e := files.PageEntry{Name: item.Name, IsDir: item.IsDir, Size: item.Size}
+	if item.Modified != nil {
+		if t, err := time.Parse(time.RFC3339Nano, *item.Modified); err == nil {
+			e.ModTime = t
+		}
+	}
+	return e
+}
+
 /**
  * ~ Preview
  */
@@ -115,12 +135,12 @@ func (s *CloudStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Pre
 	klog.Infof("Cloud preview, file meta: %s", string(res))
 
 	fileType := common.MimeTypeByExtension(fileMeta.Item.Name)
-	if !strings.HasPrefix(fileType, "image") {
+	if !strings.HasPrefix(fileType, "image") && !preview.IsTextName(fileMeta.Item.Name) {
 		return nil, fmt.Errorf("can't create preview for %s type", fileType)
 	}
 
-	var previewCacheName = fileParam.FileType + fileParam.Extend + fileMeta.Item.Path + fileMeta.Item.ModTime + queryParam.PreviewSize
-	var key = diskcache.GenerateCacheKey(previewCacheName)
+	var previewCacheName = fileParam.FileType + fileParam.Extend + fileMeta.Item.Path + fileMeta.Item.ModTime
+	var key = preview.CacheKey(fileParam, fileMeta.Item.ModTime, queryParam)
 	cachedData, ok, err := preview.GetPreviewCache(owner, key, common.CacheThumb)
 	if err != nil {
 		klog.Errorf("Cloud preview, get cache failed, user: %s, error: %v", owner, err)
@@ -153,13 +173,23 @@ func (s *CloudStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Pre
 		}
 	}
 
-	var downloader = NewDownloader(s.handler.Ctx, s.service, fileParam, fileMeta.Item.Name, fileMeta.Item.Path, fileMeta.Item.Size, previewCachedPath)
-	if e := downloader.download(); e != nil {
-		return nil, e
-	}
-
 	var imageFilePath = filepath.Join(previewCachedPath, fileMeta.Item.Name)
 
+	if strings.HasPrefix(fileType, "image") {
+		var downloader = NewDownloader(s.handler.Ctx, s.service, fileParam, fileMeta.Item.Name, fileMeta.Item.Path, fileMeta.Item.Size, previewCachedPath)
+		if e := downloader.download(); e != nil {
+			return nil, e
+		}
+	} else {
+		// a text preview reads the head or the tail of the file only
+		var configName = fmt.Sprintf("%s_%s_%s", owner, fileParam.FileType, fileParam.Extend)
+		var servePath = files.GetPrefixPath(path) + url.PathEscape(fileMeta.Item.Name)
+		var ranges = preview.TextRanges(fileMeta.Item.Name, fileMeta.Item.Size, queryParam)
+		if e := s.downloadRanges(configName, servePath, imageFilePath, fileMeta.Item.Size, ranges); e != nil {
+			return nil, e
+		}
+	}
+
 	file, err := files.NewFileInfo(files.FileOptions{
 		Fs:       afero.NewBasePathFs(afero.NewOsFs(), imageFilePath),
 		FsType:   fileParam.FileType,
@@ -185,7 +215,19 @@ func (s *CloudStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Pre
 			Data:         data,
 		}, nil
 	default:
-		return nil, fmt.Errorf("can't create preview for %s type", file.Type)
+		if !preview.IsText(file) {
+			return nil, fmt.Errorf("can't create preview for %s type", file.Type)
+		}
+		data, err := preview.CreateTextPreview(file, queryParam)
+		if err != nil {
+			return nil, err
+		}
+		return &models.PreviewHandlerResponse{
+			FileName:     file.Name,
+			FileModified: file.ModTime,
+			Data:         data,
+			ContentType:  preview.TextContentType,
+		}, nil
 	}
 }
 
@@ -601,6 +643,20 @@ func (s *CloudStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHa
 		return nil, errors.New("only support editing file")
 	}
 
+	// the provider has no conditional upload, so the check runs just
+	// before it; a write landing in between is not caught.
+	if header := contextArgs.QueryParam.Header; models.HasEditPrecondition(header) {
+		etag, modTime, err := s.editState(contextArgs.FileParam)
+		if err != nil {
+			klog.Errorf("Cloud edit, file stat error: %v, path: %s", err, contextArgs.FileParam.Path)
+			return nil, err
+		}
+		if err = models.CheckEditPrecondition(header, etag, modTime); err != nil {
+			klog.Warningf("Cloud edit, path: %s, %v", contextArgs.FileParam.Path, err)
+			return nil, err
+		}
+	}
+
 	filename := filepath.Base(contextArgs.FileParam.Path)
 	if _, err := s.service.CreateFile(contextArgs.FileParam, filename, contextArgs.QueryParam.Body); err != nil {
 		klog.Errorf("Cloud edit, file error: %v, path: %s", err, contextArgs.FileParam.Path)
@@ -608,11 +664,43 @@ func (s *CloudStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHa
 	}
 
 	klog.Errorf("Cloud create, file done! path: %s", contextArgs.FileParam.Path)
+	etag, _, err := s.editState(contextArgs.FileParam)
+	if err != nil {
+		klog.Warningf("Cloud edit, file stat error: %v, path: %s", err, contextArgs.FileParam.Path)
+	}
 	return &models.EditHandlerResponse{
-		Etag: "",
+		Etag: etag,
 	}, nil
 }
 
+func (s *CloudStorage) EditState(fileParam *models.FileParam) (string, time.Time, error) {
+	if strings.HasSuffix(fileParam.Path, "/") {
+		return "", time.Time{}, nil
+	}
+	return s.editState(fileParam)
+}
+
+// editState returns the current ETag and mtime of a cloud file, the ETag
+// built from mtime and size as the posix driver does; an empty ETag when
+// the file does not exist.
+func (s *CloudStorage) editState(fileParam *models.FileParam) (string, time.Time, error) {
+	res, err := s.service.FileStat(fileParam)
+	if err != nil || res == nil {
+		return "", time.Time{}, err
+	}
+
+	var fileMeta *operations.OperationsStat
+	if err = json.Unmarshal(res, &fileMeta); err != nil {
+		return "", time.Time{}, err
+	}
+	if fileMeta == nil || fileMeta.Item == nil {
+		return "", time.Time{}, nil
+	}
+
+	modTime, _ := common.ParseRFC3339Nano(fileMeta.Item.ModTime)
+	return fmt.Sprintf(`"%x%x"`, modTime.UnixNano(), fileMeta.Item.Size), modTime, nil
+}
+
 func (s *CloudStorage) generateListingData(fileParam *models.FileParam,
 	files *models.CloudListResponse, driveId string, stopChan <-chan struct{}, dataChan chan<- string) {
 	defer close(dataChan)
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"files/pkg/common"
 	"files/pkg/models"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"time"
 
 	"k8s.io/klog/v2"
@@ -124,3 +128,50 @@ func (d *Download) checkCtx() error {
 		return nil
 	}
 }
+
+// downloadRanges fetches the byte ranges of the file at path through the
+// rclone serve of configName into target, a sparse file as large as the
+// file; the bytes outside the ranges read as zeros.
+func (s *CloudStorage) downloadRanges(configName, path, target string, size int64, ranges [][2]int64) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, r := range ranges {
+		if r[1] <= r[0] {
+			continue
+		}
+		var header = http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", r[0], r[1]-1)}}
+		resp := s.service.command.GetServe().Get(configName, path, &header)
+		if resp == nil {
+			return fmt.Errorf("serve %s not found", configName)
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		err = copyRange(f, resp.Body, resp.StatusCode, r)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return f.Truncate(size)
+}
+
+// copyRange writes the range r of a serve response body at its offset in
+// f. A server ignoring the Range header sends the whole file.
+func copyRange(f *os.File, body io.Reader, status int, r [2]int64) error {
+	switch status {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, body, r[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("serve range %d-%d, status %d", r[0], r[1]-1, status)
+	}
+	_, err := io.CopyN(io.NewOffsetWriter(f, r[0]), body, r[1]-r[0])
+	return err
+}
@@ -20,6 +20,8 @@ type Interface interface {
 	GetJob() job.Interface
 
 	GetFilesSize(fileParam *models.FileParam) (int64, error)
+	GetFilesHash(fileParam *models.FileParam, hashType string) (map[string]string, error)
+	GetFileNativeHash(fileParam *models.FileParam, hashType string) (string, error)
 	GetFilesList(param *models.FileParam, getPrefix bool) (*operations.OperationsList, error)
 	CreateEmptyDirectory(param *models.FileParam) error
 	CreateEmptyDirectories(src, target *models.FileParam) error
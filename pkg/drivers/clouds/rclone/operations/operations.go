@@ -33,6 +33,7 @@ type Interface interface {
 	Purge(fs string, remote string) error
 
 	Size(fs string) (*OperationsSizeResp, error)
+	Hashsum(fs string, hashType string, download bool) (*OperationsHashsumResp, error)
 	CopyIdAsync(fs string, args []string) (*OperationsAsyncJobResp, error)
 	MoveId(fs string, args []string) error
 	CopyfileAsync(srcFs string, srcR string, dstFs string, dstR string) (*OperationsAsyncJobResp, error)
@@ -74,6 +75,36 @@ func (o *operations) Size(fs string) (*OperationsSizeResp, error) {
 	return data, nil
 }
 
+func (o *operations) Hashsum(fs string, hashType string, download bool) (*OperationsHashsumResp, error) {
+	// Produces a hashsum file for all the objects in the path; with download
+	// set, hashes unsupported by the backend are computed by reading the data.
+	var url = fmt.Sprintf("%s/%s", common.ServeAddr, HashsumPath)
+
+	var param = OperationsHashsumReq{
+		Fs:       fs,
+		HashType: hashType,
+		Download: download,
+	}
+
+	klog.Infof("[rclone] operations hashsum, param: %s", commonutils.ToJson(param))
+
+	resp, err := utils.Request(context.Background(), url, http.MethodPost, nil, []byte(commonutils.ToJson(param)))
+	if err != nil {
+		klog.Errorf("[rclone] operations hashsum error: %v, fs: %s", err, fs)
+		return nil, err
+	}
+
+	var data *OperationsHashsumResp
+	if err := json.Unmarshal(resp, &data); err != nil {
+		klog.Errorf("[rclone] operations hashsum unmarshal error: %v, fs: %s", err, fs)
+		return nil, err
+	}
+
+	klog.Infof("[rclone] operations hashsum done, fs: %s, count: %d", fs, len(data.Hashsum))
+
+	return data, nil
+}
+
 func (o *operations) Stat(fs string, remote string, opts *OperationsOpt) (*OperationsStat, error) {
 	// Give information about the supplied file or directory
 	var url = fmt.Sprintf("%s/%s", common.ServeAddr, StatPath)
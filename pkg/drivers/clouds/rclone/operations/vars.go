@@ -13,6 +13,7 @@ var (
 	StatPath       = "operations/stat"
 	SizePath       = "operations/size"
 	AboutPath      = "operations/about"
+	HashsumPath    = "operations/hashsum"
 
 	SyncCopyPath = "sync/copy"
 	SyncMovePath = "sync/move"
@@ -121,6 +122,24 @@ type OperationsSizeResp struct {
 	Sizeless int64 `json:"sizeless"`
 }
 
+type OperationsHashsumReq struct {
+	Fs       string `json:"fs"`
+	HashType string `json:"hashType"`
+	Download bool   `json:"download"`
+}
+
+/*
+*
+  - {
+    "hashType": "md5",
+    "hashsum": ["0ef726ce9b1a7692357ff70dd321d595  file.txt"]
+    }
+*/
+type OperationsHashsumResp struct {
+	HashType string   `json:"hashType"`
+	Hashsum  []string `json:"hashsum"`
+}
+
 // sync
 type SyncCopyReq struct {
 	SrcFs              string `json:"srcFs"`
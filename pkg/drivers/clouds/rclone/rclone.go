@@ -290,6 +290,73 @@ func (r *rclone) GetFilesSize(fileParam *models.FileParam) (int64, error) {
 
 }
 
+// GetFilesHash returns the hashes of every file under fileParam keyed by
+// the path relative to it. A file param yields a single entry keyed by
+// its own name. Provider-native hashes are used when the backend has
+// them; otherwise rclone downloads the objects and hashes the stream.
+func (r *rclone) GetFilesHash(fileParam *models.FileParam, hashType string) (map[string]string, error) {
+	var fsPrefix, err = r.GetFsPrefix(fileParam)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs = fsPrefix + fileParam.Path
+
+	resp, err := r.operation.Hashsum(fs, hashType, false)
+	if err != nil || resp == nil || hashsumHasEmpty(resp.Hashsum) {
+		klog.Infof("[rclone] hashsum %s not native on fs: %s, error: %v, fallback to download", hashType, fs, err)
+		resp, err = r.operation.Hashsum(fs, hashType, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result = make(map[string]string)
+	if resp == nil {
+		return result, nil
+	}
+	for _, line := range resp.Hashsum {
+		hash, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			continue
+		}
+		result[name] = hash
+	}
+
+	return result, nil
+}
+
+// GetFileNativeHash returns the provider-native hash of a single file.
+// An empty string without error means the backend does not store that
+// hash type, and the caller has to stream the object itself.
+func (r *rclone) GetFileNativeHash(fileParam *models.FileParam, hashType string) (string, error) {
+	var fsPrefix, err = r.GetFsPrefix(fileParam)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.operation.Hashsum(fsPrefix+fileParam.Path, hashType, false)
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || len(resp.Hashsum) == 0 || hashsumHasEmpty(resp.Hashsum) {
+		return "", nil
+	}
+
+	hash, _, _ := strings.Cut(resp.Hashsum[0], "  ")
+	return strings.ToLower(strings.TrimSpace(hash)), nil
+}
+
+func hashsumHasEmpty(lines []string) bool {
+	for _, line := range lines {
+		hash, _, _ := strings.Cut(line, "  ")
+		if strings.TrimSpace(hash) == "" || strings.EqualFold(hash, "UNSUPPORTED") {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *rclone) GetFilesList(param *models.FileParam, getPrefix bool) (*operations.OperationsList, error) {
 
 	var fsPrefix, err = r.GetFsPrefix(param)
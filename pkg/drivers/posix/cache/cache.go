@@ -7,6 +7,7 @@ import (
 	"files/pkg/global"
 	"files/pkg/models"
 	"fmt"
+	"time"
 )
 
 type CacheStorage struct {
@@ -53,6 +54,10 @@ func (s *CacheStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHa
 	return s.posix.Edit(contextArgs)
 }
 
+func (s *CacheStorage) EditState(fileParam *models.FileParam) (string, time.Time, error) {
+	return s.posix.EditState(fileParam)
+}
+
 func (s *CacheStorage) UploadLink(fileUploadArg *models.FileUploadArgs) ([]byte, error) {
 	return s.posix.UploadLink(fileUploadArg)
 }
@@ -7,6 +7,7 @@ import (
 	"files/pkg/global"
 	"files/pkg/models"
 	"fmt"
+	"time"
 )
 
 type ExternalStorage struct {
@@ -53,6 +54,10 @@ func (s *ExternalStorage) Edit(contextArgs *models.HttpContextArgs) (*models.Edi
 	return s.posix.Edit(contextArgs)
 }
 
+func (s *ExternalStorage) EditState(fileParam *models.FileParam) (string, time.Time, error) {
+	return s.posix.EditState(fileParam)
+}
+
 func (s *ExternalStorage) UploadLink(fileUploadArg *models.FileUploadArgs) ([]byte, error) {
 	return s.posix.UploadLink(fileUploadArg)
 }
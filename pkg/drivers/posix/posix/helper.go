@@ -1,11 +1,65 @@
 package posix
 
 import (
+	"files/pkg/common"
 	"files/pkg/files"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
 
 	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
 )
 
 func getRawFile(file *files.FileInfo) (afero.File, error) {
 	return file.Fs.Open(file.Path)
 }
+
+// posixEtag is the ETag Edit hands out and checks If-Match against.
+func posixEtag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// editLocks serialise the precondition check and the write of a
+// conditional Edit, so two requests carrying the same If-Match cannot
+// both pass. Paths are striped over a fixed set of mutexes.
+var editLocks [64]sync.Mutex
+
+func editLock(path string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return &editLocks[h.Sum32()%uint32(len(editLocks))]
+}
+
+// shareVersionFolders are the folders left out of the listing of
+// fileParam when it is the root of an smb share keeping its recycle bin
+// or snapshots there; those are served over smb only. External storage
+// is shared by all users, so any user's share counts there.
+func shareVersionFolders(fileParam *models.FileParam) []string {
+	if database.DB == nil {
+		return nil
+	}
+	var owner = fileParam.Owner
+	if fileParam.FileType == common.External {
+		owner = ""
+	}
+	shares, err := database.QuerySmbShareVersions(owner, fileParam.FileType, fileParam.Extend, fileParam.Path)
+	if err != nil {
+		klog.Errorf("query smb shares of %s error: %v", fileParam.Path, err)
+		return nil
+	}
+
+	var hide []string
+	for _, s := range shares {
+		if s.SmbRecycle == 1 && !common.ListContains(hide, common.SambaRecycleDir) {
+			hide = append(hide, common.SambaRecycleDir)
+		}
+		if s.SmbSnapshotHours > 0 && !common.ListContains(hide, common.SambaSnapshotDir) {
+			hide = append(hide, common.SambaSnapshotDir)
+		}
+	}
+	return hide
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"files/pkg/common"
-	"files/pkg/diskcache"
 	"files/pkg/drivers/base"
 	"files/pkg/drivers/posix/upload"
 	"files/pkg/files"
@@ -12,6 +11,7 @@ import (
 	"files/pkg/integration"
 	"files/pkg/models"
 	"files/pkg/preview"
+	"files/pkg/recent"
 	"files/pkg/tasks"
 	"fmt"
 	"io"
@@ -93,6 +93,28 @@ func (s *PosixStorage) CheckPathExists(p *models.FileParam) (exists, isDir bool,
 	return true, false, nil
 }
 
+func (s *PosixStorage) EditState(fileParam *models.FileParam) (string, time.Time, error) {
+	type editState struct {
+		etag    string
+		modTime time.Time
+	}
+	state, err := runWithExternalMountGuard(fileParam, "edit_state", func() (editState, error) {
+		uri, err := fileParam.GetResourceUri()
+		if err != nil {
+			return editState{}, err
+		}
+		info, err := os.Stat(uri + fileParam.Path)
+		if os.IsNotExist(err) {
+			return editState{}, nil
+		}
+		if err != nil || info.IsDir() {
+			return editState{}, err
+		}
+		return editState{posixEtag(info), info.ModTime()}, nil
+	})
+	return state.etag, state.modTime, err
+}
+
 type RemoteStatusError struct {
 	Code int
 }
@@ -164,8 +186,11 @@ func (s *PosixStorage) List(contextArgs *models.HttpContextArgs) ([]byte, error)
 	if s.shouldUseFastExternalRootList(fileParam, shareId) {
 		klog.Infof("Posix list, fast list, user: %s, path: %s, shareId: %s, sharePath: %s", owner, fileParam.Path, shareId, sharePath)
 		fileData, err = s.listExternalRootFast(fileParam)
+		if err == nil && fileData.IsDir {
+			fileData.Listing.ApplyPage(contextArgs.QueryParam.List)
+		}
 	} else {
-		fileData, err = s.getFiles(fileParam, Expand, Content)
+		fileData, err = s.listFiles(fileParam, contextArgs.QueryParam.List)
 	}
 	if err != nil {
 		if strings.Contains(err.Error(), "no such file or directory") {
@@ -197,8 +222,11 @@ func (s *PosixStorage) List(contextArgs *models.HttpContextArgs) ([]byte, error)
 
 		}
 
-		fileData.Listing.Sorting = files.DefaultSorting
-		fileData.Listing.ApplySort()
+		// a paged listing comes back already in its own order
+		if contextArgs.QueryParam.List == nil {
+			fileData.Listing.Sorting = files.DefaultSorting
+			fileData.Listing.ApplySort()
+		}
 
 		// Items lives on the embedded *Listing (nil for files).
 		if len(fileData.Items) > 0 {
@@ -244,8 +272,8 @@ func (s *PosixStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Pre
 		return nil, err
 	}
 
-	var previewFileName = fileParam.FileType + fileParam.Extend + fileData.Path + fileData.ModTime.String() + queryParam.PreviewSize
-	var key = diskcache.GenerateCacheKey(previewFileName)
+	var previewFileName = fileParam.FileType + fileParam.Extend + fileData.Path + fileData.ModTime.String()
+	var key = preview.CacheKey(fileParam, fileData.ModTime.String(), queryParam)
 
 	klog.Infof("Posix preview, user: %s, fileType: %s, ext: %s, name: %s", owner, fileData.Type, fileData.Extension, fileData.Name)
 
@@ -279,7 +307,19 @@ func (s *PosixStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Pre
 			Data:         data,
 		}, nil
 	default:
-		return nil, fmt.Errorf("can't create preview for %s type", fileData.Type)
+		if !preview.IsText(fileData) {
+			return nil, fmt.Errorf("can't create preview for %s type", fileData.Type)
+		}
+		data, err := preview.CreateTextPreview(fileData, queryParam)
+		if err != nil {
+			return nil, err
+		}
+		return &models.PreviewHandlerResponse{
+			FileName:     fileData.Name,
+			FileModified: fileData.ModTime,
+			Data:         data,
+			ContentType:  preview.TextContentType,
+		}, nil
 	}
 }
 
@@ -633,12 +673,27 @@ func (s *PosixStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHa
 			return nil, fmt.Errorf("file %s not exists", fileParam.Path)
 		}
 
+		mu := editLock(filePath)
+		mu.Lock()
+		defer mu.Unlock()
+
+		if header := contextArgs.QueryParam.Header; models.HasEditPrecondition(header) {
+			current, err := os.Stat(filePath)
+			if err != nil {
+				return nil, err
+			}
+			if err = models.CheckEditPrecondition(header, posixEtag(current), current.ModTime()); err != nil {
+				klog.Warningf("Posix edit, user: %s, file path: %s, %v", user, filePath, err)
+				return nil, err
+			}
+		}
+
 		info, err := files.WriteFile(filePath, contextArgs.QueryParam.Body)
 		if err != nil {
 			klog.Errorf("Posix edit, write file %s failed: %v", filePath, err)
 			return nil, err
 		}
-		etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
+		etag := posixEtag(info)
 
 		return &models.EditHandlerResponse{
 			Etag: etag,
@@ -844,12 +899,26 @@ func statMountedExternalRootItem(fullPath string, mounted *files.DiskInfo) (os.F
 }
 
 func (s *PosixStorage) getFiles(fileParam *models.FileParam, expand, content bool) (*files.FileInfo, error) {
+	return s.statFiles(fileParam, expand, content, nil)
+}
+
+// listFiles is getFiles for List, narrowed to the page list selects.
+func (s *PosixStorage) listFiles(fileParam *models.FileParam, list *files.ListOptions) (*files.FileInfo, error) {
+	return s.statFiles(fileParam, Expand, Content, list)
+}
+
+func (s *PosixStorage) statFiles(fileParam *models.FileParam, expand, content bool, list *files.ListOptions) (*files.FileInfo, error) {
 	return runWithExternalMountGuard(fileParam, "get_files", func() (*files.FileInfo, error) {
 		var resourceUri, err = fileParam.GetResourceUri()
 		if err != nil {
 			return nil, err
 		}
 
+		var hide []string
+		if expand {
+			hide = shareVersionFolders(fileParam)
+		}
+
 		file, err := files.NewFileInfo(files.FileOptions{
 			Fs:       afero.NewBasePathFs(afero.NewOsFs(), resourceUri),
 			FsType:   fileParam.FileType,
@@ -857,6 +926,8 @@ func (s *PosixStorage) getFiles(fileParam *models.FileParam, expand, content boo
 			Path:     fileParam.Path,
 			Expand:   expand,
 			Content:  content,
+			List:     list,
+			Hide:     hide,
 		})
 		if err != nil {
 			return nil, err
@@ -1000,6 +1071,13 @@ func (s *PosixStorage) UploadChunks(fileUploadArg *models.FileUploadArgs) ([]byt
 			}))
 			fileInfo.TaskId = task.Id()
 			klog.Infof("Posix uploadChunks, large file, async finalize task: %s", fileInfo.TaskId)
+		} else if chunkInfo.Share == "" && !fileUploadArg.FileParam.IsCloud() {
+			recent.Changed(user, &models.FileParam{
+				Owner:    fileUploadArg.FileParam.Owner,
+				FileType: fileUploadArg.FileParam.FileType,
+				Extend:   fileUploadArg.FileParam.Extend,
+				Path:     fileUploadArg.FileParam.Path + chunkInfo.ResumableRelativePath,
+			})
 		}
 
 		klog.Infof("Posix uploadChunks, done! data: %s", common.ToJson(fileInfo))
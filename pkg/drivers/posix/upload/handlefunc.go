@@ -6,6 +6,7 @@ import (
 	"files/pkg/files"
 	"files/pkg/global"
 	"files/pkg/models"
+	"files/pkg/preview"
 	uploadwh "files/pkg/webhook/upload"
 	"fmt"
 	"os"
@@ -450,11 +451,19 @@ func HandleUploadChunks(fileParam *models.FileParam, uploadId string, resumableI
 		FileInfoManager.DelFileInfo(innerIdentifier, tmpName, uploadTempPath) // handlerfunc
 
 		uploadwh.Enqueue(uploadwh.NewItem{
-			Path:        uploadwh.BuildFrontendPath(fileParam, &resumableInfo),
-			StoragePath: info.FullPath,
-			Mime:        uploadwh.GuessMime(info.FullPath),
-			UploadedAt:  uploadwh.NowMillis(),
-			Owner:       fileParam.Owner,
+			Path:          uploadwh.BuildFrontendPath(fileParam, &resumableInfo),
+			StoragePath:   info.FullPath,
+			Mime:          uploadwh.GuessMime(info.FullPath),
+			UploadedAt:    uploadwh.NowMillis(),
+			Owner:         fileParam.Owner,
+			Uploader:      resumableInfo.Uploader,
+			UploaderEmail: resumableInfo.UploaderEmail,
+		})
+		preview.Pregenerate(&models.FileParam{
+			Owner:    fileParam.Owner,
+			FileType: fileParam.FileType,
+			Extend:   fileParam.Extend,
+			Path:     fileParam.Path + resumableInfo.ResumableRelativePath,
 		})
 
 		return true, data, nil
@@ -123,6 +123,16 @@ func RepoGetHandler(w http.ResponseWriter, r *http.Request) (int, error) {
 }
 
 func HandleGetRepoDir(fileParam *models.FileParam) ([]byte, error) {
+	return handleGetRepoDir(fileParam, -1, -1)
+}
+
+// HandleGetRepoDirPage is HandleGetRepoDir for limit dirents from offset,
+// left in the order seafile keeps them rather than directories first.
+func HandleGetRepoDirPage(fileParam *models.FileParam, offset, limit int) ([]byte, error) {
+	return handleGetRepoDir(fileParam, offset, limit)
+}
+
+func handleGetRepoDir(fileParam *models.FileParam, offset, limit int) ([]byte, error) {
 	repoId := fileParam.Extend
 
 	thumbnailSize := 48
@@ -178,6 +188,22 @@ func HandleGetRepoDir(fileParam *models.FileParam) ([]byte, error) {
 		klog.Infof("mtime: %s", lastModify)
 	}
 
+	if limit >= 0 {
+		direntList, err := getDirentInfoList(username, repo, parentDir, true, thumbnailSize, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		if direntList == nil {
+			direntList = []map[string]interface{}{}
+		}
+		return common.ToBytes(map[string]interface{}{
+			"user_perm":   permission,
+			"dir_id":      dirId,
+			"last_modify": lastModify,
+			"dirent_list": direntList,
+		}), nil
+	}
+
 	for _, dir := range parentDirs {
 		dirInfo, fileInfo, err := getDirFileInfoList(
 			username,
@@ -273,69 +299,75 @@ func getThumbnailSrc(repoId string, size int, path string) string {
 func getDirFileInfoList(username string, repoObj map[string]string, parentDir string,
 	withThumbnail bool, thumbnailSize int) ([]map[string]interface{}, []map[string]interface{}, error) {
 
-	repoId := repoObj["id"]
+	infoList, err := getDirentInfoList(username, repoObj, parentDir, withThumbnail, thumbnailSize, -1, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var dirInfoList []map[string]interface{}
 	var fileInfoList []map[string]interface{}
+	for _, info := range infoList {
+		if info["type"] == "dir" {
+			dirInfoList = append(dirInfoList, info)
+		} else {
+			fileInfoList = append(fileInfoList, info)
+		}
+	}
+
+	sort.Slice(dirInfoList, func(i, j int) bool {
+		return strings.ToLower(dirInfoList[i]["name"].(string)) < strings.ToLower(dirInfoList[j]["name"].(string))
+	})
+	sort.Slice(fileInfoList, func(i, j int) bool {
+		return strings.ToLower(fileInfoList[i]["name"].(string)) < strings.ToLower(fileInfoList[j]["name"].(string))
+	})
+
+	return dirInfoList, fileInfoList, nil
+}
+
+// getDirentInfoList describes the dirents of parentDir in the order
+// seafile keeps them; offset and limit page them on the server, -1 for
+// both lists every dirent.
+func getDirentInfoList(username string, repoObj map[string]string, parentDir string,
+	withThumbnail bool, thumbnailSize int, offset, limit int) ([]map[string]interface{}, error) {
+
+	repoId := repoObj["id"]
+	var infoList []map[string]interface{}
 
 	parentDirID, err := seaserv.GlobalSeafileAPI.GetDirIdByPath(repoId, parentDir, true)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	dirFileList, err := seaserv.GlobalSeafileAPI.ListDirWithPerm(repoId, parentDir, parentDirID, username, -1, -1)
+	dirFileList, err := seaserv.GlobalSeafileAPI.ListDirWithPerm(repoId, parentDir, parentDirID, username, offset, limit)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	var dirList []map[string]interface{}
+	var direntList []map[string]interface{}
 	for _, dirent := range dirFileList {
 		isDir, err := IsDirectory(dirent["mode"])
 		if err != nil {
 			klog.Error(err)
 			continue
 		}
-		if isDir {
-			dirList = append(dirList, ConvertMap(dirent))
-		}
+		d := ConvertMap(dirent)
+		d["is_dir"] = isDir
+		direntList = append(direntList, d)
 	}
 
 	if parentDir != "/" {
 		parentDir += "/"
 	} // for compatible for responses of other disks
 
-	for _, dirent := range dirList {
-		dirInfo := map[string]interface{}{
-			"type":        "dir",
-			"id":          dirent["obj_id"],
-			"name":        dirent["obj_name"],
-			"mtime":       dirent["mtime"],
-			"last_modify": TimestampToISO(dirent["mtime"]),
-			"permission":  dirent["permission"],
-			"parent_dir":  parentDir,
-			"path":        filepath.Join(parentDir, dirent["obj_name"].(string)),
-			"mode":        dirent["mode"],
-		}
-		dirInfoList = append(dirInfoList, dirInfo)
-	}
-
-	var fileList []map[string]interface{}
-	for _, dirent := range dirFileList {
-		isDir, err := IsDirectory(dirent["mode"])
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-		if !isDir {
-			fileList = append(fileList, ConvertMap(dirent))
-		}
-	}
-
 	nicknameDict := make(map[string]string)
 	contactEmailDict := make(map[string]string)
 	modifierSet := make(map[string]struct{})
 	lockOwnerSet := make(map[string]struct{})
 
-	for _, f := range fileList {
+	for _, f := range direntList {
+		if f["is_dir"].(bool) {
+			continue
+		}
 		modifierSet[f["modifier"].(string)] = struct{}{}
 		lockOwnerSet[f["lock_owner"].(string)] = struct{}{}
 	}
@@ -358,7 +390,23 @@ func getDirFileInfoList(username string, repoObj map[string]string, parentDir st
 		}
 	}
 
-	for _, dirent := range fileList {
+	for _, dirent := range direntList {
+		if dirent["is_dir"].(bool) {
+			dirInfo := map[string]interface{}{
+				"type":        "dir",
+				"id":          dirent["obj_id"],
+				"name":        dirent["obj_name"],
+				"mtime":       dirent["mtime"],
+				"last_modify": TimestampToISO(dirent["mtime"]),
+				"permission":  dirent["permission"],
+				"parent_dir":  parentDir,
+				"path":        filepath.Join(parentDir, dirent["obj_name"].(string)),
+				"mode":        dirent["mode"],
+			}
+			infoList = append(infoList, dirInfo)
+			continue
+		}
+
 		fileName := dirent["obj_name"].(string)
 		filePath := path.Join(parentDir, fileName)
 		fileObjID := dirent["obj_id"]
@@ -415,17 +463,10 @@ func getDirFileInfoList(username string, repoObj map[string]string, parentDir st
 			}
 		}
 
-		fileInfoList = append(fileInfoList, fileInfo)
+		infoList = append(infoList, fileInfo)
 	}
 
-	sort.Slice(dirInfoList, func(i, j int) bool {
-		return strings.ToLower(dirInfoList[i]["name"].(string)) < strings.ToLower(dirInfoList[j]["name"].(string))
-	})
-	sort.Slice(fileInfoList, func(i, j int) bool {
-		return strings.ToLower(fileInfoList[i]["name"].(string)) < strings.ToLower(fileInfoList[j]["name"].(string))
-	})
-
-	return dirInfoList, fileInfoList, nil
+	return infoList, nil
 }
 
 func HandleDirOperation(owner, repoId, pathParam, destName, operation string, forceNew bool) ([]byte, error) {
@@ -1,11 +1,11 @@
 package seahub
 
 import (
-	"bytes"
 	"errors"
 	"files/pkg/common"
 	"files/pkg/drivers/sync/seahub/seaserv"
 	"files/pkg/models"
+	"files/pkg/textenc"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,12 +16,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/saintfish/chardet"
-	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
 	"k8s.io/klog/v2"
 )
 
@@ -293,8 +287,6 @@ const (
 	ENABLE_WATERMARK        = false
 )
 
-var FILE_ENCODING_TRY_LIST = []string{"utf-8", "gbk", "iso-8859-1"}
-
 func ViewLibFile(fileParam *models.FileParam, op string) ([]byte, error) {
 	repoId := fileParam.Extend
 	filePath := fileParam.Path
@@ -542,81 +534,17 @@ func repoFileGet(rawPath, fileEnc string) (errorMsg string, content string, enco
 	}
 
 	if fileEnc != "auto" {
-		return decodeWithEncoding(body, fileEnc)
-	}
-	return autoDetectEncoding(body)
-}
-
-func decodeWithEncoding(content []byte, enc string) (errorMsg string, decoded string, encoding string) {
-	decoder, err := getDecoder(enc)
-	if err != nil {
-		return "The encoding you chose is not proper.", "", enc
-	}
-
-	result, err := decodeContent(content, decoder)
-	if err != nil {
-		return "The encoding you chose is not proper.", "", enc
-	}
-	return "", result, enc
-}
-
-func autoDetectEncoding(content []byte) (errorMsg string, contentStr string, encoding string) {
-	for _, enc := range FILE_ENCODING_TRY_LIST {
-		if decoder, err := getDecoder(enc); err == nil {
-			if result, err := decodeContent(content, decoder); err == nil {
-				return "", result, enc
-			}
-		}
-	}
-
-	detector := chardet.NewTextDetector()
-	result, err := detector.DetectBest(content)
-	if err != nil {
-		klog.Errorf("Encoding detection failed: %v", err)
-		return "Unknown file encoding", "", ""
-	}
-
-	detectedEncoding := strings.ToLower(result.Charset)
-	decoder, err := getDecoder(detectedEncoding)
-	if err != nil {
-		return "Unknown file encoding", "", ""
-	}
-
-	decoded, err := decodeContent(content, decoder)
-	if err != nil {
-		if decoded, err = decodeContent(content, unicode.UTF8); err == nil {
-			return "", decoded, "utf-8"
+		decoded, err := textenc.DecodeLenient(body, fileEnc)
+		if err != nil {
+			return err.Error(), "", fileEnc
 		}
-		return "Unknown file encoding", "", ""
+		return "", decoded, fileEnc
 	}
-
-	return "", decoded, detectedEncoding
-}
-
-func getDecoder(encodingName string) (encoding.Encoding, error) {
-	switch strings.ToLower(encodingName) {
-	case "utf-8":
-		return unicode.UTF8, nil
-	case "gbk":
-		return simplifiedchinese.GBK, nil
-	case "iso-8859-1":
-		return charmap.ISO8859_1, nil
-	default:
-		return nil, fmt.Errorf("unsupported encoding")
-	}
-}
-
-func decodeContent(content []byte, decoder encoding.Encoding) (string, error) {
-	content = bytes.TrimPrefix(content, []byte{0xEF, 0xBB, 0xBF}) // UTF-8
-	content = bytes.TrimPrefix(content, []byte{0xFE, 0xFF})       // UTF-16BE
-	content = bytes.TrimPrefix(content, []byte{0xFF, 0xFE})       // UTF-16LE
-
-	reader := transform.NewReader(bytes.NewReader(content), decoder.NewDecoder())
-	decoded, err := io.ReadAll(reader)
+	decoded, enc, err := textenc.Detect(body)
 	if err != nil {
-		return "", err
+		return err.Error(), "", ""
 	}
-	return string(decoded), nil
+	return "", decoded, enc
 }
 
 func handleMediaFile(fileType FileType, returnDict map[string]interface{}) {
@@ -124,6 +124,11 @@ func (c *SeafServerThreadedRpcClient) SeafileGetFileSize(storeId string, version
 		storeId, version, fileId)
 }
 
+func (c *SeafServerThreadedRpcClient) SeafileGetDirSize(storeId string, version int, dirId string) (interface{}, error) {
+	return CreateRPCMethod(c, "seafile_get_dir_size", "int64", []string{"string", "int", "string"})(
+		storeId, version, dirId)
+}
+
 func (c *SeafServerThreadedRpcClient) SeafilePostDir(repoId, parentDir, newDirName, user string) (interface{}, error) {
 	return CreateRPCMethod(c, "seafile_post_dir", "int", []string{"string", "string", "string", "string"})(
 		repoId, parentDir, newDirName, user)
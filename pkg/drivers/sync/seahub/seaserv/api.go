@@ -323,6 +323,14 @@ func (s *SeafileAPI) GetFileSize(storeId string, version int, fileId string) (in
 	return ReturnInt64(ret)
 }
 
+func (s *SeafileAPI) GetDirSize(storeId string, version int, dirId string) (int64, error) {
+	ret, err := s.rpcClient.SeafileGetDirSize(storeId, version, dirId)
+	if err != nil {
+		return 0, err
+	}
+	return ReturnInt64(ret)
+}
+
 func (s *SeafileAPI) GetFileIdByPath(repoId, path string) (string, error) {
 	ret, err := rpcWithRetry(func() (interface{}, error) {
 		return s.rpcClient.SeafileGetFileIdByPath(repoId, path)
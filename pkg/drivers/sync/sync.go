@@ -286,7 +286,18 @@ func (s *SyncStorage) List(contextArgs *models.HttpContextArgs) ([]byte, error)
 
 	klog.Infof("Sync list, owner: %s, param: %s", owner, fileParam.Json())
 
-	filesData, err := s.getFiles(fileParam)
+	list := contextArgs.QueryParam.List
+
+	// seafile pages a directory in its own order, ask it for one dirent
+	// past the page to learn whether another follows
+	offset, limit, native := list.NativeWindow()
+	var filesData *Files
+	var err error
+	if native {
+		filesData, err = s.getFilesPage(fileParam, offset, limit+1)
+	} else {
+		filesData, err = s.getFiles(fileParam)
+	}
 	if err != nil {
 		if shareId != "" && strings.Contains(err.Error(), "not found") {
 			return nil, errors.New(common.ErrorMessageShareNotExists)
@@ -294,10 +305,35 @@ func (s *SyncStorage) List(contextArgs *models.HttpContextArgs) ([]byte, error)
 		return nil, err
 	}
 
+	if list == nil || filesData == nil {
+		fileData := TransSyncFilesToFileInfo(filesData, contextArgs)
+		return common.ToBytes(fileData), nil
+	}
+
+	// page the dirents before converting them, the conversion logs
+	// every item
+	var page files.PageInfo
+	if native {
+		filesData.Items, page = files.NativePage(filesData.Items, (*File).pageEntry, list)
+	} else {
+		filesData.Items, page = files.Paginate(filesData.Items, (*File).pageEntry, list)
+	}
 	fileData := TransSyncFilesToFileInfo(filesData, contextArgs)
+	fileData.Listing.SetPage(list, page)
 	return common.ToBytes(fileData), nil
 }
 
+func (f *File) pageEntry() files.PageEntry {
+	e := files.PageEntry{Name: f.Name, IsDir: f.Type == "dir", Size: f.Size}
+	if !e.IsDir {
+		e.Type = getSyncFileType(f.Name)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f.LastModify); err == nil {
+		e.ModTime = t
+	}
+	return e
+}
+
 func (s *SyncStorage) Preview(contextArgs *models.HttpContextArgs) (*models.PreviewHandlerResponse, error) {
 	var fileParam = contextArgs.FileParam
 	var queryParam = contextArgs.QueryParam
@@ -326,12 +362,11 @@ func (s *SyncStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Prev
 
 	// Preview size selector is not used downstream by this function
 	// (the seafile API only exposes the original file via this
-	// path; thumb generation happens at the cache layer). Keep
-	// queryParam.PreviewSize as part of the cache key so big/thumb
-	// previews don't collide.
-	var previewFileName = fileParam.FileType + fileParam.Extend + fileInfo.Path + time.Unix(fileInfo.LastModified, 0).String() + queryParam.PreviewSize
+	// path; thumb generation happens at the cache layer). The cache
+	// key holds the size, box and format so renditions don't collide.
+	var previewFileName = fileParam.FileType + fileParam.Extend + fileInfo.Path + time.Unix(fileInfo.LastModified, 0).String()
 	klog.Infof("Preview preview, fileName: %s", previewFileName)
-	var key = diskcache.GenerateCacheKey(previewFileName)
+	var key = preview.CacheKey(fileParam, time.Unix(fileInfo.LastModified, 0).String(), queryParam)
 
 	klog.Infof("Sync preview, user: %s, fileType: %s, ext: %s, name: %s", owner, fileInfo.FileType, fileInfo.FileExt, fileInfo.FileName)
 
@@ -461,7 +496,19 @@ func (s *SyncStorage) Preview(contextArgs *models.HttpContextArgs) (*models.Prev
 			Data:         data,
 		}, nil
 	default:
-		return nil, fmt.Errorf("can't create preview for %s type", file.Type)
+		if !preview.IsText(file) {
+			return nil, fmt.Errorf("can't create preview for %s type", file.Type)
+		}
+		data, err := preview.CreateTextPreview(file, queryParam)
+		if err != nil {
+			return nil, err
+		}
+		return &models.PreviewHandlerResponse{
+			FileName:     file.Name,
+			FileModified: file.ModTime,
+			Data:         data,
+			ContentType:  preview.TextContentType,
+		}, nil
 	}
 }
 
@@ -686,6 +733,20 @@ func (s *SyncStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHan
 		return nil, fmt.Errorf("path %s is not file", fileParam.Path)
 	}
 
+	// seafile has no conditional update, so the check runs just before
+	// the upload; a write landing in between is not caught.
+	if header := contextArgs.QueryParam.Header; models.HasEditPrecondition(header) {
+		etag, modTime, err := syncEditState(fileParam)
+		if err != nil {
+			klog.Errorf("Sync edit, get dirent error: %v, path: %s", err, fileParam.Path)
+			return nil, err
+		}
+		if err = models.CheckEditPrecondition(header, etag, modTime); err != nil {
+			klog.Warningf("Sync edit, user: %s, path: %s, %v", user, fileParam.Path, err)
+			return nil, err
+		}
+	}
+
 	getRespBody, err := seahub.HandleUpdateLink(contextArgs.FileParam, "api")
 	if err != nil {
 		klog.Errorf("Sync edit, update link error: %v, path: %s", err, fileParam.Path)
@@ -756,6 +817,10 @@ func (s *SyncStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHan
 	}
 
 	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		// update-api answers with the id of the new file object
+		etag = syncEtag(strings.Trim(strings.TrimSpace(string(respBody)), "\""))
+	}
 	if etag != "" {
 		klog.Infof("ETag: %s", etag)
 	} else {
@@ -770,6 +835,36 @@ func (s *SyncStorage) Edit(contextArgs *models.HttpContextArgs) (*models.EditHan
 	}, nil
 }
 
+// syncEtag is the ETag of a sync file: its seafile object id, which
+// changes with every new version of the content.
+func syncEtag(objId string) string {
+	if len(objId) != 40 || strings.Trim(objId, "0123456789abcdef") != "" {
+		return ""
+	}
+	return `"` + objId + `"`
+}
+
+func (s *SyncStorage) EditState(fileParam *models.FileParam) (string, time.Time, error) {
+	return syncEditState(fileParam)
+}
+
+// syncEditState returns the current ETag and mtime of a sync file; an
+// empty ETag when it does not exist.
+func syncEditState(fileParam *models.FileParam) (string, time.Time, error) {
+	dirent, err := seaserv.GlobalSeafileAPI.GetDirentByPath(fileParam.Extend, filepath.Clean(fileParam.Path))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if dirent == nil || dirent["obj_id"] == "" {
+		return "", time.Time{}, nil
+	}
+	var modTime time.Time
+	if mtime, err := strconv.ParseInt(dirent["mtime"], 10, 64); err == nil {
+		modTime = time.Unix(mtime, 0)
+	}
+	return syncEtag(dirent["obj_id"]), modTime, nil
+}
+
 func (s *SyncStorage) generateDirentsData(fileParam *models.FileParam, filesData *Files, stopChan <-chan struct{}, dataChan chan<- string) {
 	defer close(dataChan)
 
@@ -818,6 +913,20 @@ func (s *SyncStorage) getFiles(fileParam *models.FileParam) (*Files, error) {
 	if err != nil {
 		return nil, err
 	}
+	return decodeFiles(fileParam, res)
+}
+
+// getFilesPage is getFiles for limit dirents from offset, in seafile's
+// order.
+func (s *SyncStorage) getFilesPage(fileParam *models.FileParam, offset, limit int) (*Files, error) {
+	res, err := seahub.HandleGetRepoDirPage(fileParam, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFiles(fileParam, res)
+}
+
+func decodeFiles(fileParam *models.FileParam, res []byte) (*Files, error) {
 	if res == nil {
 		return nil, nil
 	}
@@ -2,15 +2,10 @@ package files
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"files/pkg/common"
 	"fmt"
-	"hash"
 	"io"
 	"mime"
 	"net/http"
@@ -89,6 +84,10 @@ type FileOptions struct {
 	ReadHeader bool
 	Token      string
 	Content    bool
+	// List pages and filters the listing of a directory, nil lists all.
+	List *ListOptions
+	// Hide names folders left out of the listing of a directory.
+	Hide []string
 }
 
 var TerminusdHost = os.Getenv("TERMINUSD_HOST")
@@ -267,7 +266,7 @@ func NewFileInfo(opts FileOptions) (*FileInfo, error) {
 
 	if opts.Expand {
 		if file.IsDir {
-			if e := file.readListing(opts.ReadHeader); e != nil {
+			if e := file.readListing(opts.ReadHeader, opts.List, opts.Hide); e != nil {
 				return nil, e
 			}
 			return file, nil
@@ -364,19 +363,9 @@ func (i *FileInfo) Checksum(algo string) error {
 	}
 	defer reader.Close()
 
-	var h hash.Hash
-
-	switch algo {
-	case "md5":
-		h = md5.New()
-	case "sha1":
-		h = sha1.New()
-	case "sha256":
-		h = sha256.New()
-	case "sha512":
-		h = sha512.New()
-	default:
-		return common.ErrInvalidOption
+	h, err := NewHash(algo)
+	if err != nil {
+		return err
 	}
 
 	_, err = io.Copy(h, reader)
@@ -506,24 +495,35 @@ func (i *FileInfo) detectSubtitles() {
 	}
 }
 
-func (i *FileInfo) readListing(readHeader bool) error {
+// listingEntry is a directory entry with symlinks already followed.
+type listingEntry struct {
+	info          os.FileInfo
+	path          string
+	isSymlink     bool
+	isInvalidLink bool
+}
+
+func (e listingEntry) pageEntry() PageEntry {
+	return PageEntry{Name: e.info.Name(), IsDir: e.info.IsDir(), Size: e.info.Size(), ModTime: e.info.ModTime()}
+}
+
+func (i *FileInfo) pageEntry() PageEntry {
+	return PageEntry{Name: i.Name, IsDir: i.IsDir, Size: i.Size, ModTime: i.ModTime, Type: i.Type}
+}
+
+func (i *FileInfo) readListing(readHeader bool, page *ListOptions, hide []string) error {
 	afs := &afero.Afero{Fs: i.Fs}
 	dir, err := afs.ReadDir(i.Path)
 	if err != nil {
 		return err
 	}
 
-	listing := &Listing{
-		Items:         []*FileInfo{},
-		NumDirs:       0,
-		NumFiles:      0,
-		NumTotalFiles: 0,
-		Size:          0,
-		FileSize:      0,
-	}
-
+	entries := make([]listingEntry, 0, len(dir))
 	for _, f := range dir {
 		name := f.Name()
+		if f.IsDir() && common.ListContains(hide, name) {
+			continue
+		}
 		fPath := path.Join(i.Path, name)
 		if f.IsDir() {
 			if !strings.HasSuffix(fPath, "/") {
@@ -531,38 +531,56 @@ func (i *FileInfo) readListing(readHeader bool) error {
 			}
 		}
 
-		isSymlink, isInvalidLink := false, false
+		entry := listingEntry{info: f, path: fPath}
 		if IsSymlink(f.Mode()) {
-			isSymlink = true
+			entry.isSymlink = true
 			info, err := i.Fs.Stat(fPath)
 			if err == nil {
-				f = info
+				entry.info = renamedFileInfo{info, name}
 			} else {
-				isInvalidLink = true
+				entry.isInvalidLink = true
 			}
 		}
+		entries = append(entries, entry)
+	}
 
+	listing := &Listing{
+		Items:         []*FileInfo{},
+		NumDirs:       0,
+		NumFiles:      0,
+		NumTotalFiles: 0,
+		Size:          0,
+		FileSize:      0,
+	}
+
+	// Paging filters and sorts on what ReadDir already returned, so
+	// type detection only runs for the entries on the page.
+	if page != nil {
+		var info PageInfo
+		entries, info = Paginate(entries, listingEntry.pageEntry, page)
+		listing.SetPage(page, info)
+	}
+
+	for _, e := range entries {
+		f := e.info
+		name := f.Name()
 		_, fext := common.SplitNameExt(name)
 		file := &FileInfo{
 			Fs:        i.Fs,
 			FsType:    i.FsType,
 			FsExtend:  i.FsExtend,
-			Path:      fPath,
+			Path:      e.path,
 			Name:      name,
 			Size:      f.Size(),
 			ModTime:   f.ModTime(),
 			Mode:      f.Mode(),
 			IsDir:     f.IsDir(),
-			IsSymlink: isSymlink,
+			IsSymlink: e.isSymlink,
 			Extension: fext,
 		}
 
-		if file.IsDir {
-			listing.NumDirs++
-		} else {
-			listing.NumFiles++
-
-			if isInvalidLink {
+		if !file.IsDir {
+			if e.isInvalidLink {
 				file.Type = "invalid_link"
 			} else {
 				err := file.detectType(true, false, readHeader)
@@ -570,9 +588,16 @@ func (i *FileInfo) readListing(readHeader bool) error {
 					return err
 				}
 			}
+		}
 
-			listing.Size += file.Size
-			listing.FileSize += file.Size
+		if page == nil {
+			if file.IsDir {
+				listing.NumDirs++
+			} else {
+				listing.NumFiles++
+				listing.Size += file.Size
+				listing.FileSize += file.Size
+			}
 		}
 
 		listing.Items = append(listing.Items, file)
@@ -581,3 +606,11 @@ func (i *FileInfo) readListing(readHeader bool) error {
 	i.Listing = listing
 	return nil
 }
+
+// renamedFileInfo keeps the link's own name on its target's stat.
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamedFileInfo) Name() string { return r.name }
@@ -0,0 +1,40 @@
+package files
+
+import (
+	"files/pkg/common"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestReadListingHide(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"a.txt", ".recycle/alice/b.txt", ".snapshots/@GMT-2026.01.01-00.00.00/a.txt", ".hidden/c.txt"} {
+		if err := afero.WriteFile(fs, "/share/"+name, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(hide []string) []string {
+		file, err := NewFileInfo(FileOptions{Fs: fs, Path: "/share/", Expand: true, Hide: hide})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res []string
+		for _, item := range file.Listing.Items {
+			res = append(res, item.Name)
+		}
+		return res
+	}
+
+	if got := names(nil); len(got) != 4 {
+		t.Errorf("folders hidden without a share: %v", got)
+	}
+	if got := names([]string{common.SambaRecycleDir, common.SambaSnapshotDir}); !reflect.DeepEqual(got, []string{".hidden", "a.txt"}) {
+		t.Errorf("items = %v", got)
+	}
+	if got := names([]string{"a.txt"}); len(got) != 4 {
+		t.Errorf("a file was hidden: %v", got)
+	}
+}
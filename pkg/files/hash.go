@@ -0,0 +1,117 @@
+package files
+
+import (
+	"container/list"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"files/pkg/common"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+const (
+	HashMd5    = "md5"
+	HashSha1   = "sha1"
+	HashSha256 = "sha256"
+	HashSha512 = "sha512"
+	HashBlake3 = "blake3"
+	HashXxhash = "xxhash"
+)
+
+// HashAlgos lists the algorithms accepted by NewHash, in the order they
+// are advertised to clients.
+var HashAlgos = []string{HashMd5, HashSha1, HashSha256, HashSha512, HashBlake3, HashXxhash}
+
+// NewHash returns a fresh hash.Hash for algo, or common.ErrInvalidOption
+// when the algorithm is not supported.
+func NewHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case HashMd5:
+		return md5.New(), nil
+	case HashSha1:
+		return sha1.New(), nil
+	case HashSha256:
+		return sha256.New(), nil
+	case HashSha512:
+		return sha512.New(), nil
+	case HashBlake3:
+		return blake3.New(), nil
+	case HashXxhash:
+		return xxhash.New(), nil
+	default:
+		return nil, common.ErrInvalidOption
+	}
+}
+
+// hashCacheMaxEntries bounds the in-memory checksum cache; the least
+// recently used entry is dropped once it is exceeded.
+const hashCacheMaxEntries = 10000
+
+// HashCache remembers computed checksums. Entries are keyed by the owner
+// and file location together with its size and mtime, so any
+// modification of the file naturally misses the cache instead of
+// returning a stale value, and users never see each other's entries.
+var HashCache = NewChecksumCache(hashCacheMaxEntries)
+
+type ChecksumCache struct {
+	mu      sync.Mutex
+	max     int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type checksumEntry struct {
+	key  string
+	hash string
+}
+
+func NewChecksumCache(max int) *ChecksumCache {
+	return &ChecksumCache{
+		max:     max,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// ChecksumKey builds the cache key for a file of owner, uri being the
+// storage-qualified location (e.g. /drive/Home/a.txt). The same uri of
+// two users names two different files.
+func ChecksumKey(owner string, uri string, size int64, modTime int64, algo string) string {
+	return fmt.Sprintf("%s|%s|%d|%d|%s", owner, uri, size, modTime, algo)
+}
+
+func (c *ChecksumCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*checksumEntry).hash, true
+}
+
+func (c *ChecksumCache) Set(key string, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*checksumEntry).hash = hash
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.ll.PushFront(&checksumEntry{key: key, hash: hash})
+	for c.max > 0 && c.ll.Len() > c.max {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.entries, last.Value.(*checksumEntry).key)
+	}
+}
@@ -0,0 +1,48 @@
+package files
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNewHash(t *testing.T) {
+	want := map[string]string{
+		HashMd5:    "900150983cd24fb0d6963f7d28e17f72",
+		HashSha1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		HashSha256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		HashBlake3: "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85",
+		HashXxhash: "44bc2cf5ad770999",
+	}
+	for algo, sum := range want {
+		h, err := NewHash(algo)
+		if err != nil {
+			t.Fatalf("NewHash(%s): %v", algo, err)
+		}
+		h.Write([]byte("abc"))
+		if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+			t.Errorf("%s(abc) = %s, want %s", algo, got, sum)
+		}
+	}
+
+	if _, err := NewHash("crc32"); err == nil {
+		t.Error("NewHash(crc32) should fail")
+	}
+}
+
+func TestChecksumCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewChecksumCache(2)
+	c.Set("a", "1")
+	c.Set("b", "2")
+	c.Get("a")
+	c.Set("c", "3")
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("a = %q, %v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || v != "3" {
+		t.Errorf("c = %q, %v", v, ok)
+	}
+}
@@ -22,6 +22,29 @@ type Listing struct {
 	Sorting       Sorting     `json:"sorting"`
 	Size          int64       `json:"size"`
 	FileSize      int64       `json:"fileSize"`
+	// NextCursor continues a paged listing; empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ApplyPage replaces Items with the page o selects and the counts with
+// those of the filtered set.
+func (l *Listing) ApplyPage(o *ListOptions) {
+	if l == nil || o == nil {
+		return
+	}
+	var info PageInfo
+	l.Items, info = Paginate(l.Items, (*FileInfo).pageEntry, o)
+	l.SetPage(o, info)
+}
+
+// SetPage records a page Paginate cut from the listing's source.
+func (l *Listing) SetPage(o *ListOptions, info PageInfo) {
+	l.NumDirs = info.NumDirs
+	l.NumFiles = info.NumFiles
+	l.FileSize = info.FileSize
+	l.Size = info.FileSize
+	l.Sorting = o.Sorting
+	l.NextCursor = info.NextCursor
 }
 
 // ApplySort applies the sort order using .Order and .Sort
@@ -0,0 +1,454 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"files/pkg/common"
+
+	"github.com/maruel/natural"
+)
+
+// MaxListLimit caps the page size a client may ask for.
+const MaxListLimit = 10000
+
+// SortNative lists entries in the order the storage returns them.
+const SortNative = "native"
+
+var (
+	ErrInvalidCursor      = errors.New("invalid or stale cursor")
+	ErrInvalidListOptions = errors.New("invalid list options")
+)
+
+// ListOptions narrows and pages a directory listing. Entries are kept in
+// a total order (directories first, then the sort key, then the name) so
+// a cursor taken from one page still points at the right place when
+// entries are added or removed between requests. Sorting by "native"
+// keeps the storage's own order instead and pages it by offset, which
+// lets a storage that pages on its side skip reading the whole
+// directory.
+type ListOptions struct {
+	// Limit is the page size, 0 returns every matching entry.
+	Limit int
+	// Name is a case-insensitive path.Match glob on the entry name.
+	Name string
+	// Kind is "dir", "file" or a file type as set on FileInfo.Type
+	// (image, video, audio, pdf, text, blob).
+	Kind           string
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Sorting        Sorting
+
+	after *listCursor
+}
+
+// PageEntry is what pagination needs to know about one entry.
+type PageEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	// Type is the storage's own file type, TypeByName when empty.
+	Type string
+}
+
+// PageInfo summarizes the filtered set a page was cut from.
+type PageInfo struct {
+	NumDirs    int
+	NumFiles   int
+	FileSize   int64
+	NextCursor string
+}
+
+type listCursor struct {
+	By      string `json:"b"`
+	Asc     bool   `json:"a"`
+	Filter  string `json:"f"`
+	IsDir   bool   `json:"d"`
+	Name    string `json:"n"`
+	Size    int64  `json:"s"`
+	ModTime int64  `json:"m"`
+	Offset  int    `json:"o,omitempty"`
+}
+
+// ParseListOptions reads limit, cursor, name, kind, modified_after,
+// modified_before, sort and order from query. It returns nil when none
+// of them is set, so clients that don't page keep the full listing.
+func ParseListOptions(query func(string) string) (*ListOptions, error) {
+	get := func(key string) string { return strings.TrimSpace(query(key)) }
+
+	limit, cursor, name, kind := get("limit"), get("cursor"), get("name"), get("kind")
+	after, before := get("modified_after"), get("modified_before")
+	by, order := get("sort"), get("order")
+	if limit == "" && cursor == "" && name == "" && kind == "" && after == "" && before == "" && by == "" && order == "" {
+		return nil, nil
+	}
+
+	o := &ListOptions{Name: name, Kind: strings.ToLower(kind), Sorting: DefaultSorting}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: limit %q", ErrInvalidListOptions, limit)
+		}
+		o.Limit = min(n, MaxListLimit)
+	}
+	if o.Name != "" {
+		if _, err := path.Match(o.Name, ""); err != nil {
+			return nil, fmt.Errorf("%w: name %q", ErrInvalidListOptions, o.Name)
+		}
+	}
+
+	var err error
+	if o.ModifiedAfter, err = parseListTime(after); err != nil {
+		return nil, fmt.Errorf("%w: modified_after %q", ErrInvalidListOptions, after)
+	}
+	if o.ModifiedBefore, err = parseListTime(before); err != nil {
+		return nil, fmt.Errorf("%w: modified_before %q", ErrInvalidListOptions, before)
+	}
+
+	switch by {
+	case "":
+	case "name", "size", "modified", SortNative:
+		o.Sorting.By = by
+	default:
+		return nil, fmt.Errorf("%w: sort %q", ErrInvalidListOptions, by)
+	}
+	switch order {
+	case "", "asc":
+	case "desc":
+		if o.Sorting.By == SortNative {
+			return nil, fmt.Errorf("%w: order %q with sort %q", ErrInvalidListOptions, order, by)
+		}
+		o.Sorting.Asc = false
+	default:
+		return nil, fmt.Errorf("%w: order %q", ErrInvalidListOptions, order)
+	}
+
+	if cursor != "" {
+		c, err := decodeListCursor(cursor)
+		if err != nil || c.By != o.Sorting.By || c.Asc != o.Sorting.Asc || c.Filter != o.filterHash() {
+			return nil, ErrInvalidCursor
+		}
+		o.after = c
+	}
+	return o, nil
+}
+
+// parseListTime accepts RFC 3339 or unix seconds.
+func parseListTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// filterHash ties a cursor to the filters it was issued for; paging on
+// with different filters would silently skip or repeat entries.
+func (o *ListOptions) filterHash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(o.Name),
+		o.Kind,
+		strconv.FormatInt(unixNanoOrZero(o.ModifiedAfter), 10),
+		strconv.FormatInt(unixNanoOrZero(o.ModifiedBefore), 10),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func unixNanoOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func decodeListCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// encodeOffsetCursor is encodeCursor for the native order, where the
+// position is the number of entries already returned.
+func (o *ListOptions) encodeOffsetCursor(offset int) string {
+	data, _ := json.Marshal(listCursor{
+		By:     o.Sorting.By,
+		Asc:    o.Sorting.Asc,
+		Filter: o.filterHash(),
+		Offset: offset,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (o *ListOptions) encodeCursor(e PageEntry) string {
+	data, _ := json.Marshal(listCursor{
+		By:      o.Sorting.By,
+		Asc:     o.Sorting.Asc,
+		Filter:  o.filterHash(),
+		IsDir:   e.IsDir,
+		Name:    e.Name,
+		Size:    e.Size,
+		ModTime: e.ModTime.UnixNano(),
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// TypeByName is the type detectType gives a file without reading it.
+func TypeByName(name string, size int64) string {
+	_, ext := common.SplitNameExt(name)
+	mimetype := mime.TypeByExtension(ext)
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		return "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		return "image"
+	case strings.HasSuffix(mimetype, "pdf"):
+		return "pdf"
+	case size <= 10*1024*1024:
+		return "text"
+	default:
+		return "blob"
+	}
+}
+
+func (o *ListOptions) match(e PageEntry, lowerName string) bool {
+	if o.Name != "" {
+		if ok, _ := path.Match(strings.ToLower(o.Name), lowerName); !ok {
+			return false
+		}
+	}
+	switch o.Kind {
+	case "":
+	case "dir":
+		if !e.IsDir {
+			return false
+		}
+	case "file":
+		if e.IsDir {
+			return false
+		}
+	default:
+		if e.IsDir {
+			return false
+		}
+		t := e.Type
+		if t == "" {
+			t = TypeByName(e.Name, e.Size)
+		}
+		if t != o.Kind {
+			return false
+		}
+	}
+	if !o.ModifiedAfter.IsZero() && !e.ModTime.After(o.ModifiedAfter) {
+		return false
+	}
+	if !o.ModifiedBefore.IsZero() && !e.ModTime.Before(o.ModifiedBefore) {
+		return false
+	}
+	return true
+}
+
+type pageItem struct {
+	index int
+	entry PageEntry
+	lower string
+}
+
+// compare orders directories first in either direction; the sort key,
+// the case-folded name and finally the exact name decide the rest, so
+// no two distinct entries compare equal.
+func (o *ListOptions) compare(a, b pageItem) int {
+	if a.entry.IsDir != b.entry.IsDir {
+		if a.entry.IsDir {
+			return -1
+		}
+		return 1
+	}
+
+	c := 0
+	switch o.Sorting.By {
+	case "size":
+		c = compareInt(a.entry.Size, b.entry.Size)
+	case "modified":
+		c = compareInt(a.entry.ModTime.UnixNano(), b.entry.ModTime.UnixNano())
+	}
+	if c == 0 {
+		c = compareNatural(a.lower, b.lower)
+	}
+	if c == 0 {
+		c = strings.Compare(a.entry.Name, b.entry.Name)
+	}
+	if !o.Sorting.Asc {
+		c = -c
+	}
+	return c
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareNatural(a, b string) int {
+	switch {
+	case natural.Less(a, b):
+		return -1
+	case natural.Less(b, a):
+		return 1
+	}
+	return 0
+}
+
+// Paginate filters items, orders them and cuts the page that follows
+// the cursor in o. The returned PageInfo counts the whole filtered set,
+// not just the page.
+func Paginate[T any](items []T, entry func(T) PageEntry, o *ListOptions) ([]T, PageInfo) {
+	if o.Sorting.By == SortNative {
+		return paginateNative(items, entry, o)
+	}
+
+	var info PageInfo
+	var after *pageItem
+	if o.after != nil {
+		after = &pageItem{
+			entry: PageEntry{
+				Name:    o.after.Name,
+				IsDir:   o.after.IsDir,
+				Size:    o.after.Size,
+				ModTime: time.Unix(0, o.after.ModTime),
+			},
+			lower: strings.ToLower(o.after.Name),
+		}
+	}
+
+	rest := make([]pageItem, 0, len(items))
+	for idx, item := range items {
+		it := pageItem{index: idx, entry: entry(item)}
+		it.lower = strings.ToLower(it.entry.Name)
+		if !o.match(it.entry, it.lower) {
+			continue
+		}
+		if it.entry.IsDir {
+			info.NumDirs++
+		} else {
+			info.NumFiles++
+			info.FileSize += it.entry.Size
+		}
+		if after != nil && o.compare(it, *after) <= 0 {
+			continue
+		}
+		rest = append(rest, it)
+	}
+
+	sort.Slice(rest, func(i, j int) bool { return o.compare(rest[i], rest[j]) < 0 })
+	if o.Limit > 0 && len(rest) > o.Limit {
+		rest = rest[:o.Limit]
+		info.NextCursor = o.encodeCursor(rest[len(rest)-1].entry)
+	}
+
+	page := make([]T, len(rest))
+	for i, it := range rest {
+		page[i] = items[it.index]
+	}
+	return page, info
+}
+
+// paginateNative keeps items in the order given and cuts the page at the
+// cursor's offset into the filtered set.
+func paginateNative[T any](items []T, entry func(T) PageEntry, o *ListOptions) ([]T, PageInfo) {
+	var info PageInfo
+	offset := 0
+	if o.after != nil {
+		offset = o.after.Offset
+	}
+
+	var page []T
+	n := 0
+	for _, item := range items {
+		e := entry(item)
+		if !o.match(e, strings.ToLower(e.Name)) {
+			continue
+		}
+		if e.IsDir {
+			info.NumDirs++
+		} else {
+			info.NumFiles++
+			info.FileSize += e.Size
+		}
+		n++
+		if n <= offset {
+			continue
+		}
+		if o.Limit > 0 && len(page) == o.Limit {
+			info.NextCursor = o.encodeOffsetCursor(offset + o.Limit)
+			continue
+		}
+		page = append(page, item)
+	}
+	if page == nil {
+		page = []T{}
+	}
+	return page, info
+}
+
+// NativeWindow reports the offset and limit of the page o asks for when
+// a storage can cut it in its own order, without filtering. ok is false
+// when the page has to come from Paginate over the whole directory.
+func (o *ListOptions) NativeWindow() (offset, limit int, ok bool) {
+	if o == nil || o.Sorting.By != SortNative || o.Limit == 0 ||
+		o.Name != "" || o.Kind != "" || !o.ModifiedAfter.IsZero() || !o.ModifiedBefore.IsZero() {
+		return 0, 0, false
+	}
+	if o.after != nil {
+		offset = o.after.Offset
+	}
+	return offset, o.Limit, true
+}
+
+// NativePage finishes a page a storage fetched itself for NativeWindow,
+// asking for one entry more than the limit to learn whether another page
+// follows. The counts in PageInfo cover the page only, the rest of the
+// directory is never read.
+func NativePage[T any](items []T, entry func(T) PageEntry, o *ListOptions) ([]T, PageInfo) {
+	var info PageInfo
+	offset, limit, _ := o.NativeWindow()
+	if len(items) > limit {
+		items = items[:limit]
+		info.NextCursor = o.encodeOffsetCursor(offset + limit)
+	}
+	for _, item := range items {
+		e := entry(item)
+		if e.IsDir {
+			info.NumDirs++
+		} else {
+			info.NumFiles++
+			info.FileSize += e.Size
+		}
+	}
+	return items, info
+}
@@ -0,0 +1,195 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func listOptions(t *testing.T, query string) *ListOptions {
+	t.Helper()
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := ParseListOptions(v.Get)
+	if err != nil {
+		t.Fatalf("ParseListOptions(%q): %v", query, err)
+	}
+	return o
+}
+
+func TestParseListOptions(t *testing.T) {
+	if o := listOptions(t, "size=big&shareid=x"); o != nil {
+		t.Errorf("options without list params = %+v, want nil", o)
+	}
+
+	o := listOptions(t, "limit=50000&sort=modified&order=desc&modified_after=2024-01-01T00:00:00Z&modified_before=1735689600")
+	if o.Limit != MaxListLimit || o.Sorting != (Sorting{By: "modified", Asc: false}) {
+		t.Errorf("options = %+v", o)
+	}
+	if !o.ModifiedBefore.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("modified_before = %v", o.ModifiedBefore)
+	}
+
+	for _, query := range []string{"limit=-1", "limit=x", "sort=owner", "order=up", "name=[", "modified_after=yesterday"} {
+		v, _ := url.ParseQuery(query)
+		if _, err := ParseListOptions(v.Get); !errors.Is(err, ErrInvalidListOptions) {
+			t.Errorf("ParseListOptions(%q) = %v, want ErrInvalidListOptions", query, err)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var items []PageEntry
+	for i := 0; i < 25; i++ {
+		items = append(items, PageEntry{Name: fmt.Sprintf("IMG_%d.jpg", i), Size: int64(i % 3), ModTime: base.Add(time.Duration(i%5) * time.Hour)})
+	}
+	items = append(items,
+		PageEntry{Name: "notes.txt", Size: 10, ModTime: base},
+		PageEntry{Name: "Albums", IsDir: true, ModTime: base},
+	)
+	self := func(e PageEntry) PageEntry { return e }
+
+	pageAll := func(query string, items []PageEntry) []string {
+		var names []string
+		for cursor := ""; ; {
+			o := listOptions(t, query+"&cursor="+cursor)
+			page, info := Paginate(items, self, o)
+			for _, e := range page {
+				names = append(names, e.Name)
+			}
+			if info.NextCursor == "" {
+				return names
+			}
+			cursor = info.NextCursor
+		}
+	}
+
+	full := listOptions(t, "sort=size")
+	want, info := Paginate(items, self, full)
+	if info.NumDirs != 1 || info.NumFiles != 26 || want[0].Name != "Albums" {
+		t.Fatalf("full listing: %+v, first %q", info, want[0].Name)
+	}
+	var wantNames []string
+	for _, e := range want {
+		wantNames = append(wantNames, e.Name)
+	}
+	if got := pageAll("sort=size&limit=4", items); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("paged by size = %v\nwant %v", got, wantNames)
+	}
+
+	// the natural order puts IMG_2 before IMG_10
+	if got := pageAll("kind=image&limit=3", items); got[2] != "IMG_2.jpg" || len(got) != 25 {
+		t.Errorf("images by name = %v", got)
+	}
+	if got := pageAll("name=img_1*.JPG&order=desc&limit=2", items); !reflect.DeepEqual(got, []string{
+		"IMG_19.jpg", "IMG_18.jpg", "IMG_17.jpg", "IMG_16.jpg", "IMG_15.jpg", "IMG_14.jpg",
+		"IMG_13.jpg", "IMG_12.jpg", "IMG_11.jpg", "IMG_10.jpg", "IMG_1.jpg",
+	}) {
+		t.Errorf("name glob, descending = %v", got)
+	}
+	if _, info := Paginate(items, self, listOptions(t, "modified_after=2024-05-01T02:30:00Z")); info.NumFiles != 10 {
+		t.Errorf("modified_after kept %d files, want 10", info.NumFiles)
+	}
+
+	// an entry added before the cursor is neither repeated nor does it
+	// shift the next page
+	o := listOptions(t, "limit=5")
+	first, info := Paginate(items, self, o)
+	grown := append([]PageEntry{{Name: "IMG_0.jpeg"}}, items...)
+	next, _ := Paginate(grown, self, listOptions(t, "limit=5&cursor="+info.NextCursor))
+	if first[4].Name != "IMG_3.jpg" || next[0].Name != "IMG_4.jpg" {
+		t.Errorf("pages %v then %v", first, next)
+	}
+
+	v, _ := url.ParseQuery("limit=5&kind=dir&cursor=" + info.NextCursor)
+	if _, err := ParseListOptions(v.Get); err != ErrInvalidCursor {
+		t.Errorf("cursor reused with other filters: %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestPaginateNative(t *testing.T) {
+	var items []PageEntry
+	for _, name := range []string{"c.jpg", "Albums", "b.txt", "a.jpg", "Docs"} {
+		items = append(items, PageEntry{Name: name, IsDir: name == "Albums" || name == "Docs", Size: 1})
+	}
+	self := func(e PageEntry) PageEntry { return e }
+	names := func(page []PageEntry) (out []string) {
+		for _, e := range page {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+
+	v, _ := url.ParseQuery("sort=native&order=desc")
+	if _, err := ParseListOptions(v.Get); !errors.Is(err, ErrInvalidListOptions) {
+		t.Errorf("native, descending: %v, want ErrInvalidListOptions", err)
+	}
+	if _, _, ok := listOptions(t, "sort=native&limit=2&kind=image").NativeWindow(); ok {
+		t.Error("filtered native listing paged on the storage")
+	}
+	if _, _, ok := listOptions(t, "limit=2").NativeWindow(); ok {
+		t.Error("name sorted listing paged on the storage")
+	}
+
+	// the storage is asked for limit+1 entries from the offset
+	var got []string
+	for cursor := ""; ; {
+		o := listOptions(t, "sort=native&limit=2&cursor="+cursor)
+		offset, limit, ok := o.NativeWindow()
+		if !ok || limit != 2 {
+			t.Fatalf("NativeWindow() = %d, %d, %v", offset, limit, ok)
+		}
+		page, info := NativePage(items[offset:min(offset+limit+1, len(items))], self, o)
+		got = append(got, names(page)...)
+		if info.NumDirs+info.NumFiles != len(page) {
+			t.Errorf("page %v counted %+v", names(page), info)
+		}
+		if info.NextCursor == "" {
+			break
+		}
+		cursor = info.NextCursor
+	}
+	if want := names(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("native pages = %v, want %v", got, want)
+	}
+
+	// with a filter the order is kept but the page is cut in memory
+	o := listOptions(t, "sort=native&kind=image&limit=1")
+	first, info := Paginate(items, self, o)
+	if !reflect.DeepEqual(names(first), []string{"c.jpg"}) || info.NumFiles != 2 || info.NextCursor == "" {
+		t.Fatalf("first image page %v, %+v", names(first), info)
+	}
+	next, info := Paginate(items, self, listOptions(t, "sort=native&kind=image&limit=1&cursor="+info.NextCursor))
+	if !reflect.DeepEqual(names(next), []string{"a.jpg"}) || info.NextCursor != "" {
+		t.Errorf("second image page %v, %+v", names(next), info)
+	}
+}
+
+func TestReadListingPage(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"b.mp4", "a.png", "c.png", "Docs/x"} {
+		if err := afero.WriteFile(fs, "/dir/"+name, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := NewFileInfo(FileOptions{Fs: fs, Path: "/dir/", Expand: true, List: listOptions(t, "kind=image&limit=1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := file.Listing
+	if len(l.Items) != 1 || l.Items[0].Name != "a.png" || l.Items[0].Type != "image" {
+		t.Fatalf("items = %+v", l.Items)
+	}
+	if l.NumFiles != 2 || l.NumDirs != 0 || l.FileSize != 8 || l.NextCursor == "" {
+		t.Errorf("listing = %+v", l)
+	}
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"errors"
+	"files/pkg/hertz/biz/model/api/lock"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// file locks
+
+var (
+	ErrFileLocked      = errors.New("file is locked by another user")
+	ErrFileLockNotHeld = errors.New("file lock is not held")
+)
+
+// lockTimeFormat has a fixed number of fraction digits, so expire_at
+// also compares correctly as text.
+const lockTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func FormatLockTime(t time.Time) string {
+	return t.UTC().Format(lockTimeFormat)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func fileLockKey(tx *gorm.DB, owner, fileType, extend, path string) *gorm.DB {
+	return tx.Model(&lock.FileLock{}).Where("owner = ? AND file_type = ? AND extend = ? AND path = ?", owner, fileType, extend, path)
+}
+
+// AcquireFileLock takes the lock on l's path for l.Holder until
+// l.ExpireAt, dropping an expired lock first. Acquiring a lock the holder
+// already has extends it and keeps its token; a live lock of another
+// holder is returned with ErrFileLocked.
+func AcquireFileLock(l *lock.FileLock, now time.Time) (*lock.FileLock, error) {
+	if l.CreateTime == "" {
+		l.CreateTime = FormatLockTime(now)
+	}
+
+	var res *lock.FileLock
+	var locked bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := fileLockKey(tx, l.Owner, l.FileType, l.Extend, l.Path).Where("expire_at <= ?", FormatLockTime(now)).Delete(&lock.FileLock{}).Error; err != nil {
+			return err
+		}
+
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(l)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 1 {
+			res = l
+			return nil
+		}
+
+		var current lock.FileLock
+		if err := fileLockKey(tx, l.Owner, l.FileType, l.Extend, l.Path).First(&current).Error; err != nil {
+			return err
+		}
+		if current.Holder != l.Holder {
+			res, locked = &current, true
+			return nil
+		}
+		if err := fileLockKey(tx, l.Owner, l.FileType, l.Extend, l.Path).Update("expire_at", l.ExpireAt).Error; err != nil {
+			return err
+		}
+		current.ExpireAt = l.ExpireAt
+		res = &current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return res, ErrFileLocked
+	}
+	return res, nil
+}
+
+// RefreshFileLock moves the expiry of a live lock held by holder with
+// token to expireAt.
+func RefreshFileLock(owner, fileType, extend, path, holder, token string, now, expireAt time.Time) (*lock.FileLock, error) {
+	res := fileLockKey(DB, owner, fileType, extend, path).
+		Where("holder = ? AND token = ? AND expire_at > ?", holder, token, FormatLockTime(now)).
+		Update("expire_at", FormatLockTime(expireAt))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrFileLockNotHeld
+	}
+
+	var current lock.FileLock
+	if err := fileLockKey(DB, owner, fileType, extend, path).First(&current).Error; err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// RelockFileLock swaps the token of a live lock held by holder with
+// oldToken for newToken and moves its expiry to expireAt.
+func RelockFileLock(owner, fileType, extend, path, holder, oldToken, newToken string, now, expireAt time.Time) (*lock.FileLock, error) {
+	res := fileLockKey(DB, owner, fileType, extend, path).
+		Where("holder = ? AND token = ? AND expire_at > ?", holder, oldToken, FormatLockTime(now)).
+		Updates(map[string]interface{}{"token": newToken, "expire_at": FormatLockTime(expireAt)})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrFileLockNotHeld
+	}
+
+	var current lock.FileLock
+	if err := fileLockKey(DB, owner, fileType, extend, path).First(&current).Error; err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+func ReleaseFileLock(owner, fileType, extend, path, holder, token string) error {
+	res := fileLockKey(DB, owner, fileType, extend, path).Where("holder = ? AND token = ?", holder, token).Delete(&lock.FileLock{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrFileLockNotHeld
+	}
+	return nil
+}
+
+// QueryFileLocks returns the live locks on any of paths, and, when under
+// is not empty, on under and everything below it.
+func QueryFileLocks(owner, fileType, extend string, paths []string, under string, now time.Time) ([]*lock.FileLock, error) {
+	var res []*lock.FileLock
+	tx := DB.Where("owner = ? AND file_type = ? AND extend = ? AND expire_at > ?", owner, fileType, extend, FormatLockTime(now))
+	switch {
+	case under != "" && len(paths) > 0:
+		tx = tx.Where(DB.Where("path IN ?", paths).Or(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(under)+"%"))
+	case under != "":
+		tx = tx.Where(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(under)+"%")
+	case len(paths) > 0:
+		tx = tx.Where("path IN ?", paths)
+	default:
+		return nil, nil
+	}
+	err := tx.Order("path ASC").Find(&res).Error
+	return res, err
+}
+
+// MoveFileLocks re-keys the locks on from and below it to to, after a
+// rename by their holder.
+func MoveFileLocks(owner, fileType, extend, from, to string) error {
+	var rows []*lock.FileLock
+	if err := DB.Where("owner = ? AND file_type = ? AND extend = ?", owner, fileType, extend).
+		Where(DB.Where("path = ?", from).Or(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(strings.TrimSuffix(from, "/")+"/")+"%")).
+		Find(&rows).Error; err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			moved := to + strings.TrimPrefix(row.Path, from)
+			if err := tx.Model(&lock.FileLock{}).Where("id = ?", row.ID).Update("path", moved).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteFileLocks drops the locks on path and below it, after the path
+// was deleted.
+func DeleteFileLocks(owner, fileType, extend, path string) error {
+	return DB.Where("owner = ? AND file_type = ? AND extend = ?", owner, fileType, extend).
+		Where(DB.Where("path = ?", path).Or(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(strings.TrimSuffix(path, "/")+"/")+"%")).
+		Delete(&lock.FileLock{}).Error
+}
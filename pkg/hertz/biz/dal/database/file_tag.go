@@ -0,0 +1,165 @@
+package database
+
+import (
+	"files/pkg/hertz/biz/model/api/tag"
+	"files/pkg/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// file tags and marks
+
+func fileTagPath(tx *gorm.DB, user, owner, fileType, extend, path string) *gorm.DB {
+	return tx.Where("user_name = ? AND owner = ? AND file_type = ? AND extend = ? AND path = ?", user, owner, fileType, extend, path)
+}
+
+// pathAndBelow matches path and, as a folder with or without its
+// trailing slash, everything below it.
+func pathAndBelow(tx *gorm.DB, path string) *gorm.DB {
+	return tx.Where(DB.Where("path = ?", path).Or(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(strings.TrimSuffix(path, "/")+"/")+"%"))
+}
+
+// SetFileTags replaces the tags user has on a path.
+func SetFileTags(user, owner, fileType, extend, path string, tags []string, now time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := fileTagPath(tx, user, owner, fileType, extend, path).Delete(&tag.FileTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		rows := make([]*tag.FileTag, 0, len(tags))
+		for _, name := range tags {
+			rows = append(rows, &tag.FileTag{
+				UserName:   user,
+				Owner:      owner,
+				FileType:   fileType,
+				Extend:     extend,
+				Path:       path,
+				Tag:        name,
+				CreateTime: FormatLockTime(now),
+			})
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// SetFileMark stores the favorite flag and label of m; a mark with
+// neither is deleted.
+func SetFileMark(m *tag.FileMark, now time.Time) error {
+	if !m.Favorite && m.Label == "" {
+		return fileTagPath(DB, m.UserName, m.Owner, m.FileType, m.Extend, m.Path).Delete(&tag.FileMark{}).Error
+	}
+	m.UpdateTime = FormatLockTime(now)
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_name"}, {Name: "owner"}, {Name: "file_type"}, {Name: "extend"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"favorite", "label", "update_time"}),
+	}).Create(m).Error
+}
+
+// QueryFileTags returns the tags and marks user has on any of paths,
+// and, when under is not empty, on under and everything below it.
+func QueryFileTags(user, owner, fileType, extend string, paths []string, under string) ([]*tag.FileTag, []*tag.FileMark, error) {
+	where := func() *gorm.DB {
+		tx := DB.Where("user_name = ? AND owner = ? AND file_type = ? AND extend = ?", user, owner, fileType, extend)
+		switch {
+		case under != "" && len(paths) > 0:
+			return tx.Where(DB.Where("path IN ?", paths).Or(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(under)+"%"))
+		case under != "":
+			return tx.Where(`path LIKE ? ESCAPE '\'`, likeEscaper.Replace(under)+"%")
+		default:
+			return tx.Where("path IN ?", paths)
+		}
+	}
+	if under == "" && len(paths) == 0 {
+		return nil, nil, nil
+	}
+
+	var tags []*tag.FileTag
+	if err := where().Order("path ASC, tag ASC").Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	var marks []*tag.FileMark
+	if err := where().Order("path ASC").Find(&marks).Error; err != nil {
+		return nil, nil, err
+	}
+	return tags, marks, nil
+}
+
+// ListUserFileTags returns everything user has tagged or marked, across
+// all storages.
+func ListUserFileTags(user string) ([]*tag.FileTag, []*tag.FileMark, error) {
+	var tags []*tag.FileTag
+	if err := DB.Where("user_name = ?", user).Order("file_type ASC, extend ASC, path ASC, tag ASC").Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	var marks []*tag.FileMark
+	if err := DB.Where("user_name = ?", user).Order("file_type ASC, extend ASC, path ASC").Find(&marks).Error; err != nil {
+		return nil, nil, err
+	}
+	return tags, marks, nil
+}
+
+// CountUserTags returns the tags user has used, with the number of
+// paths carrying each.
+func CountUserTags(user string) ([]*tag.ViewTag, error) {
+	var res []*tag.ViewTag
+	err := DB.Model(&tag.FileTag{}).
+		Select("tag AS name, COUNT(*) AS count").
+		Where("user_name = ?", user).
+		Group("tag").
+		Order("tag ASC").
+		Scan(&res).Error
+	return res, err
+}
+
+// MoveFileTags re-keys the tags and marks of every user on src and
+// below it to dst, after a rename or a move. Those left on dst by a file
+// the move replaced are dropped.
+func MoveFileTags(src, dst *models.FileParam) error {
+	srcOwner, dstOwner := src.StorageOwner(), dst.StorageOwner()
+	// without the trailing slash a folder maps onto its new place
+	// whichever way either side was written
+	from, to := strings.TrimSuffix(src.Path, "/"), strings.TrimSuffix(dst.Path, "/")
+	if srcOwner == dstOwner && src.FileType == dst.FileType && src.Extend == dst.Extend && from == to {
+		return nil
+	}
+	// substr counts characters on both postgres and sqlite
+	rest := utf8.RuneCountInString(from) + 1
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&tag.FileTag{}, &tag.FileMark{}} {
+			replaced := tx.Where("owner = ? AND file_type = ? AND extend = ?", dstOwner, dst.FileType, dst.Extend)
+			if err := pathAndBelow(replaced, to).Delete(model).Error; err != nil {
+				return err
+			}
+			moved := tx.Model(model).Where("owner = ? AND file_type = ? AND extend = ?", srcOwner, src.FileType, src.Extend)
+			if err := pathAndBelow(moved, from).Updates(map[string]interface{}{
+				"owner":     dstOwner,
+				"file_type": dst.FileType,
+				"extend":    dst.Extend,
+				"path":      gorm.Expr("? || substr(path, ?)", to, rest),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteFileTags drops the tags and marks of every user on fp and below
+// it, after it was deleted.
+func DeleteFileTags(fp *models.FileParam) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&tag.FileTag{}, &tag.FileMark{}} {
+			rows := tx.Where("owner = ? AND file_type = ? AND extend = ?", fp.StorageOwner(), fp.FileType, fp.Extend)
+			if err := pathAndBelow(rows, fp.Path).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"files/pkg/hertz/biz/model/api/recent"
+	"files/pkg/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// recently opened and changed files
+
+// UpsertRecentFiles stores rows, moving a path already in a user's feed
+// to the time it was accessed again. rows must not repeat a path.
+func UpsertRecentFiles(rows []*recent.RecentFile) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_name"}, {Name: "kind"}, {Name: "owner"}, {Name: "file_type"}, {Name: "extend"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access_time"}),
+	}).Create(&rows).Error
+}
+
+// TrimRecentFiles keeps the newest keep entries of one feed of user and
+// returns how many were dropped.
+func TrimRecentFiles(user, kind string, keep int) (int64, error) {
+	newest := DB.Model(&recent.RecentFile{}).
+		Select("id").
+		Where("user_name = ? AND kind = ?", user, kind).
+		Order("access_time DESC, id DESC").
+		Limit(keep)
+	res := DB.Where("user_name = ? AND kind = ? AND id NOT IN (?)", user, kind, newest).Delete(&recent.RecentFile{})
+	return res.RowsAffected, res.Error
+}
+
+// ListRecentFiles returns the newest entries of one feed of user, on
+// the given file types only when fileTypes is not empty.
+func ListRecentFiles(user, kind string, fileTypes []string, limit int) ([]*recent.RecentFile, error) {
+	tx := DB.Where("user_name = ? AND kind = ?", user, kind)
+	if len(fileTypes) > 0 {
+		tx = tx.Where("file_type IN ?", fileTypes)
+	}
+	var res []*recent.RecentFile
+	err := tx.Order("access_time DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ClearRecentFiles empties the feeds of user, only the one of kind when
+// kind is not empty.
+func ClearRecentFiles(user, kind string) error {
+	tx := DB.Where("user_name = ?", user)
+	if kind != "" {
+		tx = tx.Where("kind = ?", kind)
+	}
+	return tx.Delete(&recent.RecentFile{}).Error
+}
+
+// ForgetRecentFiles drops fp and everything below it from the feeds of
+// every user, after it was deleted or moved away.
+func ForgetRecentFiles(fp *models.FileParam) error {
+	rows := DB.Where("owner = ? AND file_type = ? AND extend = ?", fp.StorageOwner(), fp.FileType, fp.Extend)
+	return pathAndBelow(rows, fp.Path).Delete(&recent.RecentFile{}).Error
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"files/pkg/common"
 	"files/pkg/hertz/biz/model/api/search"
 	"files/pkg/hertz/biz/model/api/share"
@@ -8,9 +9,11 @@ import (
 	"files/pkg/models"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"k8s.io/klog/v2"
 )
@@ -95,7 +98,7 @@ func QueryShareSmbMembers(shareIds []string) ([]*models.SambaMemberUserView, err
 
 func QuerySmbShares(owner string, shareType string, shareIds []string, userIds []string) ([]*share.SmbShareView, error) {
 	var res []*share.SmbShareView
-	var tx = DB.Table("share_paths").Select("share_paths.id, share_paths.owner, share_paths.file_type, share_paths.extend, share_paths.path, share_paths.share_type, share_paths.name, share_paths.expire_in, share_paths.expire_time, share_paths.permission as share_permission, share_paths.smb_share_public, share_smb_members.permission, share_smb_users.user_id, share_smb_users.user_name, share_smb_users.password").Joins("LEFT JOIN share_smb_members ON share_paths.id = share_smb_members.path_id").Joins("LEFT JOIN share_smb_users ON share_smb_members.user_id = share_smb_users.user_id").Where("share_paths.share_type  = ?", shareType)
+	var tx = DB.Table("share_paths").Select("share_paths.id, share_paths.owner, share_paths.file_type, share_paths.extend, share_paths.path, share_paths.share_type, share_paths.name, share_paths.expire_in, share_paths.expire_time, share_paths.permission as share_permission, share_paths.smb_share_public, share_paths.smb_time_machine, share_paths.smb_quota, share_paths.smb_used_bytes, share_paths.smb_recycle, share_paths.smb_recycle_days, share_paths.smb_snapshot_hours, share_paths.smb_snapshot_keep, share_paths.smb_audit, share_smb_members.permission, share_smb_users.user_id, share_smb_users.user_name, share_smb_users.password").Joins("LEFT JOIN share_smb_members ON share_paths.id = share_smb_members.path_id").Joins("LEFT JOIN share_smb_users ON share_smb_members.user_id = share_smb_users.user_id").Where("share_paths.share_type  = ?", shareType)
 
 	// Use chain assignment (`tx = tx.Where(...)`) instead of bare
 	// `tx.Where(...)`. GORM v2 happens to mutate tx.Statement
@@ -126,6 +129,12 @@ func QuerySmbShares(owner string, shareType string, shareIds []string, userIds [
 	return res, nil
 }
 
+// UpdateSmbShareUsage stores the measured size of an smb share folder.
+// It leaves update_time alone, the share itself did not change.
+func UpdateSmbShareUsage(pathID string, usedBytes int64) error {
+	return DB.Model(&share.SharePath{}).Where("id = ?", pathID).UpdateColumn("smb_used_bytes", usedBytes).Error
+}
+
 func QuerySmbSharePathByIds(ids []string) ([]*share.SharePath, error) {
 	var res []*share.SharePath
 	if err := DB.Where("share_type = ? AND id IN ?", "smb", ids).Find(&res).Error; err != nil {
@@ -135,6 +144,23 @@ func QuerySmbSharePathByIds(ids []string) ([]*share.SharePath, error) {
 	return res, nil
 }
 
+// QuerySmbShareVersions returns the smb shares rooted at the folder
+// fileType/extend/path that keep a recycle bin or snapshots in it. An
+// empty owner matches the shares of every user.
+func QuerySmbShareVersions(owner, fileType, extend, path string) ([]*share.SharePath, error) {
+	var dir = strings.TrimSuffix(path, "/")
+	var res []*share.SharePath
+	var tx = DB.Where("share_type = ? AND file_type = ? AND extend = ? AND path IN ?", common.ShareTypeSMB, fileType, extend, []string{dir, dir + "/"}).
+		Where("smb_recycle = 1 OR smb_snapshot_hours > 0")
+	if owner != "" {
+		tx = tx.Where("owner = ?", owner)
+	}
+	if err := tx.Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func VerifySharePathPassword(pathID, inputPassword string) (bool, error) {
 	var path share.SharePath
 	if err := DB.Select("password_md5").Where("id = ?", pathID).First(&path).Error; err != nil {
@@ -332,6 +358,191 @@ func QueryShareToken(params *QueryParams, page, pageSize int64, orderBy, order s
 	return res, total, nil
 }
 
+// share quota
+
+// errShareQuotaRollback aborts ChargeShareQuota's transaction when the
+// token cannot take the charge the link already accepted.
+var errShareQuotaRollback = errors.New("share token quota exhausted")
+
+// ChargeShareQuota adds downloads and bytes to the counters of an
+// external link and, when token is set, of that token. Nothing is
+// charged and false is returned when either would exceed its limit.
+// Counters are written as plain columns so serving a file does not
+// touch the share's update_time.
+func ChargeShareQuota(pathID, token string, downloads, bytes int64) (bool, error) {
+	var charge = func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("max_downloads = 0 OR download_count + ? <= max_downloads", downloads).
+			Where("max_bytes = 0 OR bytes_served + ? <= max_bytes", bytes).
+			UpdateColumns(map[string]interface{}{
+				"download_count": gorm.Expr("download_count + ?", downloads),
+				"bytes_served":   gorm.Expr("bytes_served + ?", bytes),
+			})
+	}
+
+	var charged = true
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		res := charge(tx.Model(&share.SharePath{}).Where("id = ?", pathID))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			charged = false
+			return nil
+		}
+		if token == "" {
+			return nil
+		}
+		res = charge(tx.Model(&share.ShareToken{}).Where("path_id = ? AND token = ?", pathID, token))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errShareQuotaRollback
+		}
+		return nil
+	})
+	if errors.Is(err, errShareQuotaRollback) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return charged, nil
+}
+
+// AddShareBytesServed records bytes sent on a stream whose length was
+// not known when ChargeShareQuota ran; it is never refused.
+func AddShareBytesServed(pathID, token string, bytes int64) error {
+	var expr = gorm.Expr("bytes_served + ?", bytes)
+	if err := DB.Model(&share.SharePath{}).Where("id = ?", pathID).UpdateColumn("bytes_served", expr).Error; err != nil {
+		return err
+	}
+	if token == "" {
+		return nil
+	}
+	return DB.Model(&share.ShareToken{}).Where("path_id = ? AND token = ?", pathID, token).UpdateColumn("bytes_served", expr).Error
+}
+
+// AddShareVisitor counts ip as a new visitor of pathID unless a token
+// was already issued to it. It returns false when the link has no room
+// for another visitor.
+func AddShareVisitor(pathID, ip string) (bool, error) {
+	var seen int64
+	if err := DB.Model(&share.ShareToken{}).Where("path_id = ? AND ip = ?", pathID, ip).Count(&seen).Error; err != nil {
+		return false, err
+	}
+	if seen > 0 {
+		return true, nil
+	}
+
+	res := DB.Model(&share.SharePath{}).
+		Where("id = ?", pathID).
+		Where("max_visitors = 0 OR visitor_count < max_visitors").
+		UpdateColumn("visitor_count", gorm.Expr("visitor_count + 1"))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// QueryShareUploaderBytes sums what one file request uploader has sent
+// to pathID across every token they were issued.
+func QueryShareUploaderBytes(pathID, name, email string) (int64, error) {
+	var total int64
+	err := DB.Model(&share.ShareToken{}).
+		Where("path_id = ? AND uploader_name = ? AND uploader_email = ?", pathID, name, email).
+		Select("COALESCE(SUM(uploaded_bytes), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// ReserveShareUploaderBytes charges bytes to token when the uploader it
+// was issued to stays within maxBytes across all their tokens, 0 meaning
+// no limit, and returns false otherwise. The uploader's tokens are locked
+// meanwhile so concurrent uploads cannot overrun the budget together.
+func ReserveShareUploaderBytes(token *share.ShareToken, maxBytes, bytes int64) (bool, error) {
+	var uploader = func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&share.ShareToken{}).Where("path_id = ? AND uploader_name = ? AND uploader_email = ?", token.PathID, token.UploaderName, token.UploaderEmail)
+	}
+
+	var reserved bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		if err := uploader(tx).Clauses(clause.Locking{Strength: "UPDATE"}).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		used := uploader(tx).Select("COALESCE(SUM(uploaded_bytes), 0)")
+		res := tx.Model(&share.ShareToken{}).
+			Where("id = ?", token.ID).
+			Where("? = 0 OR (?) + ? <= ?", maxBytes, used, bytes, maxBytes).
+			UpdateColumn("uploaded_bytes", gorm.Expr("uploaded_bytes + ?", bytes))
+		if res.Error != nil {
+			return res.Error
+		}
+		reserved = res.RowsAffected > 0
+		return nil
+	})
+	return reserved, err
+}
+
+// ReleaseShareUploaderBytes gives back bytes ReserveShareUploaderBytes
+// charged to tokenID for an upload that did not complete.
+func ReleaseShareUploaderBytes(tokenID, bytes int64) error {
+	return DB.Model(&share.ShareToken{}).Where("id = ?", tokenID).
+		UpdateColumn("uploaded_bytes", gorm.Expr("CASE WHEN uploaded_bytes > ? THEN uploaded_bytes - ? ELSE 0 END", bytes, bytes)).Error
+}
+
+// share activity
+
+func CreateShareActivity(activities []*share.ShareActivity, db *gorm.DB) error {
+	return db.Create(activities).Error
+}
+
+func DeleteShareActivity(pathID string, db *gorm.DB) error {
+	return db.Where("path_id = ?", pathID).Delete(&share.ShareActivity{}).Error
+}
+
+// PruneShareActivity drops activity rows older than before and returns
+// how many were removed.
+func PruneShareActivity(before time.Time, db *gorm.DB) (int64, error) {
+	res := db.Where("create_time < ?", before).Delete(&share.ShareActivity{})
+	return res.RowsAffected, res.Error
+}
+
+func QueryShareActivity(params *QueryParams, page, pageSize int64, orderBy, order string, joinParams []*JoinCondition) ([]*share.ShareActivity, int64, error) {
+	var res []*share.ShareActivity
+	total, err := QueryData(&share.ShareActivity{}, &res, params, page, pageSize, orderBy, order, joinParams)
+	if err != nil {
+		klog.Error(err)
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+// QueryShareActivityStats aggregates the activity of the given shares.
+// Resource listings, previews and inline raw reads count as views.
+func QueryShareActivityStats(shareIds []string) ([]*share.ShareActivityStats, error) {
+	var res []*share.ShareActivityStats
+	if len(shareIds) == 0 {
+		return res, nil
+	}
+	err := DB.Table("share_activities").
+		Select("path_id, "+
+			"COUNT(*) FILTER (WHERE action IN ?) AS views, "+
+			"COUNT(*) FILTER (WHERE action = ?) AS downloads, "+
+			"COUNT(*) FILTER (WHERE action = ?) AS uploads, "+
+			"MAX(create_time) AS last_access",
+			[]string{common.ShareActivityResource, common.ShareActivityPreview, common.ShareActivityRaw},
+			common.ShareActivityDownload, common.ShareActivityUpload).
+		Where("path_id IN ?", shareIds).
+		Group("path_id").
+		Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // share member
 
 func CreateShareMember(members []*share.ShareMember, db *gorm.DB) ([]*share.ShareMember, error) {
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"files/pkg/common"
+	"files/pkg/hertz/biz/model/api/share"
+
+	"gorm.io/gorm"
+)
+
+// share group
+
+func CreateShareGroupTx(group *share.ShareGroup, members []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return createShareGroupMembers(tx, group.ID, members)
+	})
+}
+
+// UpdateShareGroupTx renames the group when name is set and replaces
+// its members when members is not nil.
+func UpdateShareGroupTx(groupID, name string, members []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if name != "" {
+			if err := tx.Model(&share.ShareGroup{}).Where("id = ?", groupID).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+		if members == nil {
+			return nil
+		}
+		if err := tx.Where("group_id = ?", groupID).Delete(&share.ShareGroupMember{}).Error; err != nil {
+			return err
+		}
+		return createShareGroupMembers(tx, groupID, members)
+	})
+}
+
+// DeleteShareGroupTx drops the groups, their members, and every share
+// membership granted through them.
+func DeleteShareGroupTx(owner string, groupIDs []string) error {
+	var refs = make([]string, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		refs = append(refs, common.ShareGroupPrefix+id)
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var owned []string
+		if err := tx.Model(&share.ShareGroup{}).Where("owner = ? AND id IN ?", owner, groupIDs).Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		if len(owned) != len(groupIDs) {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("share_member IN ?", refs).Delete(&share.ShareMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id IN ?", groupIDs).Delete(&share.ShareGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", groupIDs).Delete(&share.ShareGroup{}).Error
+	})
+}
+
+func createShareGroupMembers(tx *gorm.DB, groupID string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	var rows = make([]*share.ShareGroupMember, 0, len(members))
+	for _, m := range members {
+		rows = append(rows, &share.ShareGroupMember{GroupID: groupID, Member: m})
+	}
+	return tx.Create(rows).Error
+}
+
+func GetShareGroup(groupID string) (*share.ShareGroup, error) {
+	var res *share.ShareGroup
+	if err := DB.Table("share_groups").Where("id = ?", groupID).First(&res).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return res, nil
+}
+
+func QueryShareGroups(owner string) ([]*share.ShareGroup, error) {
+	var res []*share.ShareGroup
+	if err := DB.Where("owner = ?", owner).Order("name ASC").Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func QueryShareGroupsByIds(groupIDs []string) ([]*share.ShareGroup, error) {
+	var res []*share.ShareGroup
+	if len(groupIDs) == 0 {
+		return res, nil
+	}
+	if err := DB.Where("id IN ?", groupIDs).Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func QueryShareGroupMembers(groupIDs []string) ([]*share.ShareGroupMember, error) {
+	var res []*share.ShareGroupMember
+	if len(groupIDs) == 0 {
+		return res, nil
+	}
+	if err := DB.Where("group_id IN ?", groupIDs).Order("member ASC").Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// QueryShareGroupIdsByMember returns the groups user was added to.
+func QueryShareGroupIdsByMember(user string) ([]string, error) {
+	var res []string
+	if err := DB.Model(&share.ShareGroupMember{}).Where("member = ?", user).Pluck("group_id", &res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// QueryShareMembersByRefs returns the share_members rows naming any of
+// refs, optionally limited to one share.
+func QueryShareMembersByRefs(pathID string, refs []string) ([]*share.ShareMember, error) {
+	var res []*share.ShareMember
+	if len(refs) == 0 {
+		return res, nil
+	}
+	db := DB.Where("share_member IN ?", refs)
+	if pathID != "" {
+		db = db.Where("path_id = ?", pathID)
+	}
+	if err := db.Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RemoveShareGroupUsers drops users that left the platform from every
+// share group.
+func RemoveShareGroupUsers(users []string) (int64, error) {
+	if len(users) == 0 {
+		return 0, nil
+	}
+	res := DB.Where("member IN ?", users).Delete(&share.ShareGroupMember{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"files/pkg/common"
+	"files/pkg/hertz/biz/model/api/share"
+	"time"
+
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+// share lifecycle
+
+var lifecycleShareTypes = []string{common.ShareTypeInternal, common.ShareTypeExternal}
+
+// QueryExpiringSharePaths returns the internal and external shares that
+// expire in (from, to] and whose owner was not yet reminded of that
+// expiry. Extending a share changes expire_time, so it is reminded again.
+func QueryExpiringSharePaths(from, to time.Time) ([]*share.SharePath, error) {
+	var res []*share.SharePath
+	err := DB.Where("share_type IN ?", lifecycleShareTypes).
+		Where("expire_time > ? AND expire_time <= ?", from, to).
+		Where("reminded_expire_time IS NULL OR reminded_expire_time <> expire_time").
+		Order("expire_time ASC").
+		Find(&res).Error
+	return res, err
+}
+
+func MarkSharePathReminded(pathID string) error {
+	return DB.Model(&share.SharePath{}).Where("id = ?", pathID).UpdateColumn("reminded_expire_time", gorm.Expr("expire_time")).Error
+}
+
+// QueryExpiredSharePaths returns up to limit internal and external shares
+// that expired before before, oldest first.
+func QueryExpiredSharePaths(before time.Time, limit int) ([]*share.SharePath, error) {
+	var res []*share.SharePath
+	err := DB.Where("share_type IN ?", lifecycleShareTypes).
+		Where("expire_time < ?", before).
+		Order("expire_time ASC").
+		Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func CreateShareArchive(archive *share.ShareArchive, db *gorm.DB) error {
+	return db.Create(archive).Error
+}
+
+func QueryShareArchive(params *QueryParams, page, pageSize int64, orderBy, order string, joinParams []*JoinCondition) ([]*share.ShareArchive, int64, error) {
+	var res []*share.ShareArchive
+	total, err := QueryData(&share.ShareArchive{}, &res, params, page, pageSize, orderBy, order, joinParams)
+	if err != nil {
+		klog.Error(err)
+		return nil, 0, err
+	}
+	return res, total, nil
+}
@@ -0,0 +1,14 @@
+package database
+
+import (
+	"files/pkg/common"
+	"files/pkg/hertz/biz/model/api/share"
+)
+
+// QueryNfsShares returns every nfs share, expired ones included; the nfs
+// sidecar decides which of them are exported.
+func QueryNfsShares() ([]*share.SharePath, error) {
+	var res []*share.SharePath
+	err := DB.Where("share_type = ?", common.ShareTypeNFS).Order("id ASC").Find(&res).Error
+	return res, err
+}
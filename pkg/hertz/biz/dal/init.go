@@ -2,7 +2,10 @@ package dal
 
 import (
 	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/lock"
+	"files/pkg/hertz/biz/model/api/recent"
 	"files/pkg/hertz/biz/model/api/share"
+	"files/pkg/hertz/biz/model/api/tag"
 	"os"
 	"strings"
 
@@ -59,6 +62,14 @@ func Init() error {
 	migration(&share.ShareMember{}, "share_members", rebuild)
 	migration(&share.ShareSmbUser{}, "share_smb_users", rebuild)
 	migration(&share.ShareSmbMember{}, "share_smb_members", rebuild)
+	migration(&share.ShareGroup{}, "share_groups", rebuild)
+	migration(&share.ShareGroupMember{}, "share_group_members", rebuild)
+	migration(&share.ShareActivity{}, "share_activities", rebuild)
+	migration(&share.ShareArchive{}, "share_archives", rebuild)
+	migration(&lock.FileLock{}, "file_locks", rebuild)
+	migration(&tag.FileTag{}, "file_tags", rebuild)
+	migration(&tag.FileMark{}, "file_marks", rebuild)
+	migration(&recent.RecentFile{}, "recent_files", rebuild)
 
 	cleanupOwnerAsShareMember()
 	return nil
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"files/pkg/common"
+	"files/pkg/integration"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// requireAdmin lets platform admins through; the cache holds previews of
+// every user's files.
+func requireAdmin(c *app.RequestContext) (string, bool) {
+	var owner = string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return "", false
+	}
+	if integration.IntegrationService == nil || !integration.IntegrationService.IsPlatformAdmin(owner) {
+		klog.Warningf("[cache] denied to %s", owner)
+		c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return "", false
+	}
+	return owner, true
+}
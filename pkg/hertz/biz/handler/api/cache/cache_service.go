@@ -0,0 +1,95 @@
+// Code generated by hertz generator.
+
+package cache
+
+import (
+	"context"
+	"files/pkg/common"
+	"files/pkg/diskcache"
+	"fmt"
+	"strings"
+
+	cache "files/pkg/hertz/biz/model/api/cache"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// GetCacheUsage .
+// @router /api/cache/ [GET]
+func GetCacheUsage(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cache.GetCacheUsageReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	var fileCache = diskcache.GetFileCache()
+	if fileCache == nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": "cache not ready"})
+		return
+	}
+
+	usage := fileCache.Usage()
+	resp := &cache.GetCacheUsageResp{
+		Node:    common.NodeName,
+		Limit:   usage.Limit,
+		Bytes:   usage.Bytes,
+		Entries: int32(usage.Entries),
+		Scanned: usage.Scanned,
+		Owners:  make([]*cache.CacheOwnerUsage, 0, len(usage.Owners)),
+	}
+	for _, u := range usage.Owners {
+		resp.Owners = append(resp.Owners, &cache.CacheOwnerUsage{
+			Owner:   u.Owner,
+			Bytes:   u.Bytes,
+			Entries: int32(u.Entries),
+			Tags:    u.Tags,
+		})
+	}
+
+	c.JSON(consts.StatusOK, resp)
+}
+
+// PurgeCache .
+// @router /api/cache/ [DELETE]
+func PurgeCache(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cache.PurgeCacheReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	admin, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+	if req.Tag != "" && !common.ListContains(diskcache.Tags, req.Tag) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("tag must be one of %s", strings.Join(diskcache.Tags, ", "))})
+		return
+	}
+	var fileCache = diskcache.GetFileCache()
+	if fileCache == nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": "cache not ready"})
+		return
+	}
+
+	entries, bytes, err := fileCache.Purge(req.Owner, req.Tag)
+	if err != nil {
+		klog.Errorf("[cache] purge error: %v, owner: %q, tag: %q", err, req.Owner, req.Tag)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	klog.Infof("[cache] %s purged owner: %q, tag: %q, entries: %d, bytes: %d", admin, req.Owner, req.Tag, entries, bytes)
+
+	c.JSON(consts.StatusOK, &cache.PurgeCacheResp{Entries: int32(entries), Bytes: bytes})
+}
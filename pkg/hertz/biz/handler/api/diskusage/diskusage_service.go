@@ -0,0 +1,157 @@
+// Package diskusage implements the /api/disk_usage/:node/ endpoints.
+//
+// A scan is submitted to the existing Task system and returns a task_id;
+// once it has finished, GET serves the cached totals of the scanned
+// folder (and, on local storages, of every folder below it) so the FE
+// can drill into a treemap without rescanning.
+package diskusage
+
+import (
+	"context"
+	"fmt"
+
+	"files/pkg/common"
+	"files/pkg/drivers"
+	"files/pkg/drivers/base"
+	bizhandler "files/pkg/hertz/biz/handler"
+	usagemodel "files/pkg/hertz/biz/model/api/diskusage"
+	"files/pkg/models"
+	"files/pkg/tasks"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// defaultTop is how many children GET returns when top is not set.
+const defaultTop = 50
+
+// ScanDiskUsageMethod handles POST /api/disk_usage/:node/.
+//
+// Body shape (JSON):
+//
+//	{
+//	  "path":    "/external/node/usb/",
+//	  "refresh": false   // rescan even if a recent result is cached
+//	}
+//
+// When a cached result exists and refresh is not set, it is returned
+// directly instead of a task_id.
+func ScanDiskUsageMethod(ctx context.Context, c *app.RequestContext) {
+	var req usagemodel.ScanDiskUsageReq
+	if err := c.BindAndValidate(&req); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	root, ok := usageRoot(ctx, c, owner, req.Path)
+	if !ok {
+		return
+	}
+
+	if !req.Refresh {
+		if usage := tasks.GetDiskUsage(usageUri(root)); usage != nil {
+			c.JSON(consts.StatusOK, utils.H{"code": 0, "message": "success", "usage": usage.Top(defaultTop)})
+			return
+		}
+	}
+
+	pasteParam := &models.PasteParam{
+		Owner:  owner,
+		Action: common.ActionDiskUsage,
+		Src:    root,
+		Dst:    root,
+	}
+
+	task := tasks.TaskManager.CreateTask(pasteParam)
+	if err := task.Execute(task.DiskUsage(root)); err != nil {
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{
+			"code":    1,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp := usagemodel.ScanDiskUsageResp{Code: 0, Message: "success", TaskID: task.Id()}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// GetDiskUsageMethod handles GET /api/disk_usage/:node/?path=<folder>&top=<n>.
+// It answers 404 when the folder has not been scanned, or the scan
+// has expired.
+func GetDiskUsageMethod(ctx context.Context, c *app.RequestContext) {
+	var req usagemodel.GetDiskUsageReq
+	if err := c.BindAndValidate(&req); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	fp, err := models.CreateFileParam(owner, req.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("path param error: %v", err)})
+		return
+	}
+	if !bizhandler.Gate(ctx, c, fp, models.ActionRead, false, "disk_usage") {
+		return
+	}
+
+	usage := tasks.GetDiskUsage(usageUri(fp))
+	if usage == nil {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "not scanned"})
+		return
+	}
+
+	var top = int(req.Top)
+	if top <= 0 {
+		top = defaultTop
+	}
+	c.JSON(consts.StatusOK, usage.Top(top))
+}
+
+func usageRoot(ctx context.Context, c *app.RequestContext, owner, p string) (*models.FileParam, bool) {
+	fp, err := models.CreateFileParam(owner, p)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("path param error: %v", err)})
+		return nil, false
+	}
+	if !common.ListContains(common.PosixFileTypes, fp.FileType) && !fp.IsSync() && !fp.IsCloud() {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("disk usage not supported on %s", fp.FileType)})
+		return nil, false
+	}
+	if !bizhandler.Gate(ctx, c, fp, models.ActionRead, false, "disk_usage") {
+		return nil, false
+	}
+
+	h := drivers.Adaptor.NewFileHandler(fp.FileType, &base.HandlerParam{Ctx: ctx, Owner: owner})
+	if h == nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("unsupported file type: %s", fp.FileType)})
+		return nil, false
+	}
+	exists, isDir, err := h.CheckPathExists(fp)
+	if err != nil || !exists || !isDir {
+		klog.Warningf("[disk_usage] path invalid: owner=%s, path=%s, exists=%v, isDir=%v, err=%v", owner, p, exists, isDir, err)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("path %s is not a folder", p)})
+		return nil, false
+	}
+
+	return fp, true
+}
+
+// usageUri is the storage-qualified location the scan results are
+// cached under, matching tasks.DiskUsage.
+func usageUri(fp *models.FileParam) string {
+	return "/" + fp.FileType + "/" + fp.Extend + fp.Path
+}
@@ -0,0 +1,395 @@
+// Package duplicates implements the /api/duplicates/:node/... endpoints.
+//
+// A scan is submitted to the existing Task system and returns a task_id;
+// the FE polls /api/task/:node/?task_id=... for progress and then fetches
+// the grouped result here. Resolving a group deletes the selected copies
+// through the same driver Delete path as DELETE /api/resources, or
+// replaces them with hard links to the kept copy on local storages.
+package duplicates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"files/pkg/access"
+	"files/pkg/common"
+	"files/pkg/drivers"
+	"files/pkg/drivers/base"
+	"files/pkg/files"
+	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/hertz/biz/handler/api/lock"
+	"files/pkg/hertz/biz/handler/api/resources"
+	"files/pkg/hertz/biz/handler/api/share"
+	dupmodel "files/pkg/hertz/biz/model/api/duplicates"
+	"files/pkg/models"
+	"files/pkg/tasks"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+const (
+	resolveModeDelete   = "delete"
+	resolveModeHardLink = "hardlink"
+)
+
+// FindDuplicatesMethod handles POST /api/duplicates/:node/.
+//
+// Body shape (JSON):
+//
+//	{
+//	  "roots":   ["/drive/Home/Pictures/", "/external/node/usb/", "/google/<key>/Photos/"],
+//	  "minSize": 1048576   // optional, bytes
+//	}
+func FindDuplicatesMethod(ctx context.Context, c *app.RequestContext) {
+	var req dupmodel.FindDuplicatesReq
+	if err := c.BindAndValidate(&req); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	if len(req.Roots) == 0 {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "roots is empty"})
+		return
+	}
+
+	roots := make([]*models.FileParam, 0, len(req.Roots))
+	for _, r := range req.Roots {
+		fp, err := models.CreateFileParam(owner, r)
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("root param error: %v", err)})
+			return
+		}
+		if !common.ListContains(common.PosixFileTypes, fp.FileType) && !fp.IsSync() && !fp.IsCloud() {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("duplicates not supported on %s", fp.FileType)})
+			return
+		}
+		if !bizhandler.Gate(ctx, c, fp, models.ActionRead, false, "duplicates") {
+			return
+		}
+		h := drivers.Adaptor.NewFileHandler(fp.FileType, &base.HandlerParam{Ctx: ctx, Owner: owner})
+		if h == nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("unsupported file type: %s", fp.FileType)})
+			return
+		}
+		exists, isDir, lerr := h.CheckPathExists(fp)
+		if lerr != nil || !exists || !isDir {
+			klog.Warningf("[duplicates] root invalid: owner=%s, root=%s, exists=%v, isDir=%v, err=%v", owner, r, exists, isDir, lerr)
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("root %s is not a folder", r)})
+			return
+		}
+		roots = append(roots, fp)
+	}
+
+	pasteParam := &models.PasteParam{
+		Owner:  owner,
+		Action: common.ActionDuplicates,
+		Src:    roots[0], // routing hint for task listings; the scan uses Srcs
+		Srcs:   roots,
+		Dst:    roots[0],
+	}
+
+	task := tasks.TaskManager.CreateTask(pasteParam)
+	if err := task.Execute(task.FindDuplicates(roots, req.MinSize)); err != nil {
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{
+			"code":    1,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	resp := dupmodel.FindDuplicatesResp{Code: 0, Message: "success", TaskID: task.Id()}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// GetDuplicatesMethod handles GET /api/duplicates/:node/?task_id=<id>.
+// Groups are only present once the task has completed.
+func GetDuplicatesMethod(ctx context.Context, c *app.RequestContext) {
+	var req dupmodel.GetDuplicatesReq
+	if err := c.BindAndValidate(&req); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	report, state, err := tasks.TaskManager.GetDuplicates(owner, req.TaskId)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": err.Error()})
+		return
+	}
+
+	res := utils.H{"task_id": req.TaskId, "status": state}
+	if report != nil {
+		res["roots"] = report.Roots
+		res["scanned"] = report.Scanned
+		res["wasted"] = report.Wasted
+		res["groups"] = report.Groups
+	}
+	c.JSON(consts.StatusOK, res)
+}
+
+// ResolveDuplicatesMethod handles POST /api/duplicates/:node/resolve.
+//
+// Body shape (JSON):
+//
+//	{
+//	  "task_id": "<duplicates task>",
+//	  "mode":    "delete" | "hardlink",
+//	  "keep":    "/drive/Home/Pictures/a.jpg",
+//	  "files":   ["/drive/Home/Backup/a.jpg"]
+//	}
+//
+// keep and every entry of files must belong to the same group of the
+// task's report. A file whose size or mtime changed since the scan, or
+// whose bytes no longer match keep's, is left alone; when keep itself changed or is gone, nothing is resolved
+// and the request fails with 409. When any of files is locked by
+// another user, nothing is resolved and the request fails with 423.
+func ResolveDuplicatesMethod(ctx context.Context, c *app.RequestContext) {
+	var req dupmodel.ResolveDuplicatesReq
+	if err := c.BindAndValidate(&req); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	if req.Mode != resolveModeDelete && req.Mode != resolveModeHardLink {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("invalid mode: %s", req.Mode)})
+		return
+	}
+	if len(req.Files) == 0 {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "files is empty"})
+		return
+	}
+
+	report, _, err := tasks.TaskManager.GetDuplicates(owner, req.TaskId)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": err.Error()})
+		return
+	}
+	if report == nil {
+		c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": "duplicates task not finished"})
+		return
+	}
+
+	group := findDuplicateGroup(report, req.Keep)
+	if group == nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "keep is not part of the report"})
+		return
+	}
+	var members = make(map[string]*tasks.DuplicateFile, len(group.Files))
+	for _, f := range group.Files {
+		members[f.Path] = f
+	}
+	for _, f := range req.Files {
+		if f == req.Keep {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "keep cannot be resolved"})
+			return
+		}
+		if _, ok := members[f]; !ok {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("%s is not a duplicate of keep", f)})
+			return
+		}
+	}
+
+	keep, err := models.CreateFileParam(owner, req.Keep)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("keep param error: %v", err)})
+		return
+	}
+	if !bizhandler.Gate(ctx, c, keep, models.ActionRead, false, "duplicates") {
+		return
+	}
+
+	// the copy kept must still be the one scanned, or the duplicates
+	// would be deleted or linked to something else
+	kept, err := tasks.StatHashTarget(keep, "")
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": fmt.Sprintf("keep is not available: %v", err)})
+		return
+	}
+	if kept.Size != members[req.Keep].Size || kept.ModTime != members[req.Keep].ModTime {
+		c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": "keep changed since scan"})
+		return
+	}
+
+	// like DELETE /api/resources, a copy locked by someone else stops the
+	// whole request before anything is deleted or linked
+	for _, f := range req.Files {
+		fp, err := models.CreateFileParam(owner, f)
+		if err != nil {
+			continue
+		}
+		if !lock.CheckWrite(c, fp, bizhandler.RequestUser(c)) {
+			return
+		}
+	}
+
+	var done []string
+	var failed = make(map[string]string)
+	var deletes = make(map[string][]*models.FileParam)
+	var deleteOrder []string
+
+	for _, f := range req.Files {
+		fp, err := models.CreateFileParam(owner, f)
+		if err != nil {
+			failed[f] = err.Error()
+			continue
+		}
+
+		var action = models.ActionDelete
+		if req.Mode == resolveModeHardLink {
+			action = models.ActionWrite
+		}
+		lvl, aerr := access.CheckAccessParam(ctx, owner, fp)
+		if aerr != nil || !lvl.Allow(action) {
+			failed[f] = common.ErrorMessagePermissionDenied
+			continue
+		}
+
+		current, err := tasks.StatHashTarget(fp, "")
+		if err != nil {
+			failed[f] = err.Error()
+			continue
+		}
+		if current.Size != members[f].Size || current.ModTime != members[f].ModTime {
+			failed[f] = "file changed since scan"
+			continue
+		}
+		// the scan's hashes may be stale; only identical bytes go
+		if same, err := tasks.SameContent(ctx, keep, fp); err != nil || !same {
+			if err != nil {
+				failed[f] = err.Error()
+			} else {
+				failed[f] = "file differs from keep"
+			}
+			continue
+		}
+
+		if req.Mode == resolveModeHardLink {
+			if err = tasks.HardLinkDuplicate(keep, fp); err != nil {
+				failed[f] = err.Error()
+				continue
+			}
+			done = append(done, f)
+			continue
+		}
+
+		parent := files.GetPrefixPath(fp.Path)
+		key := fp.FileType + "/" + fp.Extend + parent
+		if _, ok := deletes[key]; !ok {
+			deleteOrder = append(deleteOrder, key)
+		}
+		deletes[key] = append(deletes[key], fp)
+	}
+
+	for _, key := range deleteOrder {
+		ok, fail := deleteDuplicates(ctx, owner, deletes[key])
+		done = append(done, ok...)
+		for k, v := range fail {
+			failed[k] = v
+		}
+	}
+
+	tasks.TaskManager.ForgetDuplicates(owner, req.TaskId, done)
+
+	klog.Infof("[duplicates] resolve, owner: %s, task: %s, mode: %s, keep: %s, done: %d, failed: %d", owner, req.TaskId, req.Mode, req.Keep, len(done), len(failed))
+
+	c.JSON(consts.StatusOK, utils.H{
+		"mode":   req.Mode,
+		"done":   done,
+		"failed": failed,
+	})
+}
+
+// deleteDuplicates removes files sharing one parent folder exactly like
+// DELETE /api/resources does: share relations first, then the driver,
+// then what is kept about the deleted paths.
+func deleteDuplicates(ctx context.Context, owner string, fps []*models.FileParam) ([]string, map[string]string) {
+	var failed = make(map[string]string)
+	var parent = &models.FileParam{
+		Owner:    owner,
+		FileType: fps[0].FileType,
+		Extend:   fps[0].Extend,
+		Path:     files.GetPrefixPath(fps[0].Path),
+	}
+	var dirents = make([]string, 0, len(fps))
+	var uris = make(map[string]string, len(fps))
+	for _, fp := range fps {
+		name := "/" + strings.TrimPrefix(fp.Path, parent.Path)
+		dirents = append(dirents, name)
+		uris[name] = "/" + fp.FileType + "/" + fp.Extend + fp.Path
+	}
+
+	failAll := func(msg string) ([]string, map[string]string) {
+		for _, uri := range uris {
+			failed[uri] = msg
+		}
+		return nil, failed
+	}
+
+	if err := share.DeleteRelativeAdjustShare(parent, dirents, nil); err != nil {
+		return failAll(err.Error())
+	}
+
+	handler := drivers.Adaptor.NewFileHandler(parent.FileType, &base.HandlerParam{Ctx: ctx, Owner: owner})
+	if handler == nil {
+		return failAll(fmt.Sprintf("handler not found, type: %s", parent.FileType))
+	}
+
+	res, err := handler.Delete(&models.FileDeleteArgs{FileParam: parent, Dirents: dirents})
+	if err != nil {
+		var deleteFailedPaths []string
+		if res != nil {
+			json.Unmarshal(res, &deleteFailedPaths)
+		}
+		if len(deleteFailedPaths) == 0 {
+			return failAll(err.Error())
+		}
+		for _, d := range deleteFailedPaths {
+			if uri, ok := uris[d]; ok {
+				failed[uri] = err.Error()
+				delete(uris, d)
+			}
+		}
+	}
+
+	var done []string
+	for _, d := range dirents {
+		if uri, ok := uris[d]; ok {
+			resources.ForgetDeleted(lock.ChildParam(parent, d))
+			done = append(done, uri)
+		}
+	}
+	return done, failed
+}
+
+func findDuplicateGroup(report *tasks.DuplicatesReport, path string) *tasks.DuplicateGroup {
+	for _, g := range report.Groups {
+		for _, f := range g.Files {
+			if f.Path == path {
+				return g
+			}
+		}
+	}
+	return nil
+}
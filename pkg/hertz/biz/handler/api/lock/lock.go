@@ -0,0 +1,208 @@
+package lock
+
+import (
+	"errors"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+	"strings"
+	"time"
+
+	lock "files/pkg/hertz/biz/model/api/lock"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// Locks are advisory: they do not stop a program writing the disk
+// directly, but every write through the resources and paste endpoints
+// checks them. A lock on a file blocks others from editing, renaming,
+// moving or deleting it; a lock on a folder (path ending with a slash)
+// covers everything below it, and a folder with a locked file inside
+// cannot be renamed, moved or deleted by others either.
+
+const (
+	LockDefaultTTL = 5 * time.Minute
+	LockMaxTTL     = time.Hour
+)
+
+// Scope is the owner locks on fp are keyed by. Sync libraries,
+// external devices and drive/Common look the same to every user, so a
+// lock there is seen by all of them.
+func Scope(fp *models.FileParam) string {
+	return fp.StorageOwner()
+}
+
+// lockTTL turns a requested ttl in seconds into a duration, 0 meaning
+// the default.
+func lockTTL(seconds int64) (time.Duration, error) {
+	if seconds == 0 {
+		return LockDefaultTTL, nil
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if ttl > LockMaxTTL {
+		return 0, errors.New("lock ttl is at most 3600 seconds")
+	}
+	return ttl, nil
+}
+
+// coveringPaths returns p and the folders above it, each folder with a
+// trailing slash: /a/b/c gives /a/b/c, /, /a/ and /a/b/.
+func coveringPaths(p string) []string {
+	if p == "/" {
+		return []string{p}
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	paths := []string{p, "/"}
+	dir := "/"
+	for _, part := range parts[:len(parts)-1] {
+		dir += part + "/"
+		paths = append(paths, dir)
+	}
+	return paths
+}
+
+// Conflicts returns the locks of other holders that a write by user to
+// fp runs into: on fp, on a folder above it, and, when fp is a folder,
+// on anything below it. A folder may come without its trailing slash,
+// so fp is always looked below; for a file nothing is found there.
+func Conflicts(fp *models.FileParam, user string, now time.Time) ([]*lock.FileLock, error) {
+	under := strings.TrimSuffix(fp.Path, "/") + "/"
+	rows, err := database.QueryFileLocks(Scope(fp), fp.FileType, fp.Extend, coveringPaths(fp.Path), under, now)
+	if err != nil {
+		return nil, err
+	}
+	var res []*lock.FileLock
+	for _, row := range rows {
+		if row.Holder != user {
+			res = append(res, row)
+		}
+	}
+	return res, nil
+}
+
+// CheckWrite is the lock check of the endpoints that change fp on behalf
+// of user. It aborts with 423 Locked and returns false when fp is locked
+// by someone else. Without a database there are no locks.
+func CheckWrite(c *app.RequestContext, fp *models.FileParam, user string) bool {
+	if database.DB == nil || fp == nil {
+		return true
+	}
+	rows, err := Conflicts(fp, user, time.Now())
+	if err != nil {
+		klog.Errorf("[lock] query locks error: %v, path: %s", err, fp.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return false
+	}
+	if len(rows) > 0 {
+		klog.Infof("[lock] %s %s denied to %s, locked by %s", string(c.Method()), fp.Path, user, rows[0].Holder)
+		c.AbortWithStatusJSON(consts.StatusLocked, utils.H{
+			"error":     "file is locked by " + rows[0].Holder,
+			"locked_by": rows[0].Holder,
+			"path":      rows[0].Path,
+		})
+		return false
+	}
+	return true
+}
+
+// ChildParam returns the FileParam of name inside the folder fp.
+func ChildParam(fp *models.FileParam, name string) *models.FileParam {
+	child := *fp
+	child.Path = strings.TrimSuffix(fp.Path, "/") + "/" + strings.TrimLeft(name, "/")
+	return &child
+}
+
+// listingItemKeys are where each driver puts the entries of a folder
+// listing: posix, sync and cloud.
+var listingItemKeys = []string{"items", "dirent_list", "data"}
+
+// AnnotateListing sets locked_by on a resources GET response for fp: on
+// the response itself for a file and on each entry of a folder listing.
+func AnnotateListing(fp *models.FileParam, resp map[string]interface{}) {
+	if database.DB == nil || resp == nil {
+		return
+	}
+
+	dir := strings.HasSuffix(fp.Path, "/")
+	var under string
+	if dir {
+		under = fp.Path
+	}
+	rows, err := database.QueryFileLocks(Scope(fp), fp.FileType, fp.Extend, []string{fp.Path}, under, time.Now())
+	if err != nil {
+		klog.Errorf("[lock] query locks error: %v, path: %s", err, fp.Path)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+	holders := make(map[string]string, len(rows))
+	for _, row := range rows {
+		holders[row.Path] = row.Holder
+	}
+
+	if holder, ok := holders[fp.Path]; ok {
+		resp["locked_by"] = holder
+	}
+	if !dir {
+		return
+	}
+	for _, key := range listingItemKeys {
+		items, ok := resp[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := entry["name"].(string)
+			if name == "" {
+				continue
+			}
+			p := fp.Path + name
+			if isDir, _ := entry["isDir"].(bool); isDir || entry["type"] == "dir" {
+				p += "/"
+			}
+			if holder, ok := holders[p]; ok {
+				entry["locked_by"] = holder
+			}
+		}
+	}
+}
+
+// MoveLocks re-keys the locks of a renamed path; DropLocks forgets those
+// of a deleted one. Failures are logged, the locks then run out.
+func MoveLocks(fp *models.FileParam, to string) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.MoveFileLocks(Scope(fp), fp.FileType, fp.Extend, fp.Path, to); err != nil {
+		klog.Errorf("[lock] move locks error: %v, path: %s, to: %s", err, fp.Path, to)
+	}
+}
+
+func DropLocks(fp *models.FileParam) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.DeleteFileLocks(Scope(fp), fp.FileType, fp.Extend, fp.Path); err != nil {
+		klog.Errorf("[lock] drop locks error: %v, path: %s", err, fp.Path)
+	}
+}
+
+func viewFileLock(row *lock.FileLock, user string) *lock.ViewFileLock {
+	view := &lock.ViewFileLock{
+		Path:     row.Path,
+		Holder:   row.Holder,
+		ExpireAt: row.ExpireAt,
+	}
+	if row.Holder == user {
+		token := row.Token
+		view.Token = &token
+	}
+	return view
+}
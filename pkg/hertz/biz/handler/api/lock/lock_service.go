@@ -0,0 +1,212 @@
+// Code generated by hertz generator.
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/models"
+	"fmt"
+	"strings"
+	"time"
+
+	lock "files/pkg/hertz/biz/model/api/lock"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// ListFileLock .
+// @router /api/locks/*path [GET]
+func ListFileLock(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req lock.ListFileLockReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, user, ok := lockFileParam(ctx, c, models.ActionList)
+	if !ok {
+		return
+	}
+
+	var under string
+	if strings.HasSuffix(fileParam.Path, "/") {
+		under = fileParam.Path
+	}
+	rows, err := database.QueryFileLocks(Scope(fileParam), fileParam.FileType, fileParam.Extend, coveringPaths(fileParam.Path), under, time.Now())
+	if err != nil {
+		klog.Errorf("[lock] query locks error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := &lock.ListFileLockResp{Locks: make([]*lock.ViewFileLock, 0, len(rows))}
+	for _, row := range rows {
+		resp.Locks = append(resp.Locks, viewFileLock(row, user))
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// AcquireFileLock .
+// @router /api/locks/*path [POST]
+func AcquireFileLock(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req lock.AcquireFileLockReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	ttl, err := lockTTL(req.TTL)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, user, ok := lockFileParam(ctx, c, models.ActionWrite)
+	if !ok {
+		return
+	}
+	// a lock may not be taken inside, above or on someone else's
+	if !CheckWrite(c, fileParam, user) {
+		return
+	}
+
+	now := time.Now()
+	row, err := database.AcquireFileLock(&lock.FileLock{
+		Owner:    Scope(fileParam),
+		FileType: fileParam.FileType,
+		Extend:   fileParam.Extend,
+		Path:     fileParam.Path,
+		Holder:   user,
+		Token:    uuid.NewString(),
+		ExpireAt: database.FormatLockTime(now.Add(ttl)),
+	}, now)
+	if errors.Is(err, database.ErrFileLocked) {
+		c.AbortWithStatusJSON(consts.StatusLocked, utils.H{
+			"error":     "file is locked by " + row.Holder,
+			"locked_by": row.Holder,
+			"path":      row.Path,
+		})
+		return
+	}
+	if err != nil {
+		klog.Errorf("[lock] acquire lock error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	klog.Infof("[lock] %s locked %s/%s%s until %s", user, fileParam.FileType, fileParam.Extend, fileParam.Path, row.ExpireAt)
+	c.JSON(consts.StatusOK, viewFileLock(row, user))
+}
+
+// RefreshFileLock .
+// @router /api/locks/*path [PUT]
+func RefreshFileLock(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req lock.RefreshFileLockReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	ttl, err := lockTTL(req.TTL)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, user, ok := lockFileParam(ctx, c, models.ActionWrite)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	row, err := database.RefreshFileLock(Scope(fileParam), fileParam.FileType, fileParam.Extend, fileParam.Path, user, req.Token, now, now.Add(ttl))
+	if errors.Is(err, database.ErrFileLockNotHeld) {
+		c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		klog.Errorf("[lock] refresh lock error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(consts.StatusOK, viewFileLock(row, user))
+}
+
+// ReleaseFileLock .
+// @router /api/locks/*path [DELETE]
+func ReleaseFileLock(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req lock.ReleaseFileLockReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, user, ok := lockFileParam(ctx, c, models.ActionWrite)
+	if !ok {
+		return
+	}
+
+	err = database.ReleaseFileLock(Scope(fileParam), fileParam.FileType, fileParam.Extend, fileParam.Path, user, req.Token)
+	if errors.Is(err, database.ErrFileLockNotHeld) {
+		c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		klog.Errorf("[lock] release lock error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(consts.StatusOK, &lock.ReleaseFileLockResp{Success: true})
+}
+
+// lockFileParam resolves the path of a locks request and checks the
+// caller may perform action on it. Locks live in the database, so the
+// endpoints are unavailable without one.
+func lockFileParam(ctx context.Context, c *app.RequestContext, action models.Action) (*models.FileParam, string, bool) {
+	if database.DB == nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": "file locks need a database"})
+		return nil, "", false
+	}
+
+	var path = strings.TrimPrefix(string(c.Path()), "/api/locks")
+	if path == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "path invalid"})
+		return nil, "", false
+	}
+
+	var owner = string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return nil, "", false
+	}
+
+	fileParam, err := models.CreateFileParam(owner, path)
+	if err != nil {
+		klog.Errorf("file param error: %v, owner: %s", err, owner)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("file param error: %v", err)})
+		return nil, "", false
+	}
+
+	if !bizhandler.Gate(ctx, c, fileParam, action, false, "lock") {
+		return nil, "", false
+	}
+	return fileParam, owner, true
+}
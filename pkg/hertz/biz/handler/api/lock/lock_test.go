@@ -0,0 +1,227 @@
+package lock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+
+	lock "files/pkg/hertz/biz/model/api/lock"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newLockTestDB points database.DB at an in-memory sqlite with the
+// file_locks table migrated from the model.
+func newLockTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open in-memory sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&lock.FileLock{}); err != nil {
+		t.Fatalf("create file_locks: %v", err)
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = saved })
+}
+
+func acquire(t *testing.T, fp *models.FileParam, holder string, now time.Time, ttl time.Duration) (*lock.FileLock, error) {
+	t.Helper()
+	return database.AcquireFileLock(&lock.FileLock{
+		Owner:    Scope(fp),
+		FileType: fp.FileType,
+		Extend:   fp.Extend,
+		Path:     fp.Path,
+		Holder:   holder,
+		Token:    holder + "-" + now.Format(time.RFC3339Nano),
+		ExpireAt: database.FormatLockTime(now.Add(ttl)),
+	}, now)
+}
+
+func TestAcquireFileLock(t *testing.T) {
+	newLockTestDB(t)
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	fp := &models.FileParam{Owner: "alice", FileType: common.Sync, Extend: "repo-1", Path: "/notes.md"}
+
+	held, err := acquire(t, fp, "alice", now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held.Owner != "" {
+		t.Fatalf("sync lock keyed by owner %q", held.Owner)
+	}
+
+	// the same file seen by bob in the same library
+	bobFP := *fp
+	bobFP.Owner = "bob"
+	if other, err := acquire(t, &bobFP, "bob", now.Add(time.Second), time.Minute); !errors.Is(err, database.ErrFileLocked) || other.Holder != "alice" {
+		t.Fatalf("bob acquire: holder %v, err %v", other, err)
+	}
+
+	again, err := acquire(t, fp, "alice", now.Add(30*time.Second), time.Minute)
+	if err != nil || again.Token != held.Token || again.ExpireAt != database.FormatLockTime(now.Add(90*time.Second)) {
+		t.Fatalf("re-acquire: %+v, err %v", again, err)
+	}
+
+	if _, err = database.RefreshFileLock("", fp.FileType, fp.Extend, fp.Path, "alice", "wrong", now, now.Add(time.Hour)); !errors.Is(err, database.ErrFileLockNotHeld) {
+		t.Fatalf("refresh with wrong token: %v", err)
+	}
+	if err = database.ReleaseFileLock("", fp.FileType, fp.Extend, fp.Path, "bob", held.Token); !errors.Is(err, database.ErrFileLockNotHeld) {
+		t.Fatalf("release by bob: %v", err)
+	}
+
+	// expired, bob takes it over
+	if _, err = acquire(t, &bobFP, "bob", now.Add(2*time.Minute), time.Minute); err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	if _, err = database.RefreshFileLock("", fp.FileType, fp.Extend, fp.Path, "alice", held.Token, now.Add(2*time.Minute), now.Add(time.Hour)); !errors.Is(err, database.ErrFileLockNotHeld) {
+		t.Fatalf("refresh of a lost lock: %v", err)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	newLockTestDB(t)
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	param := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: p}
+	}
+	if _, err := acquire(t, param("/Docs/report.md"), "bob", now, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquire(t, param("/Shared/"), "carol", now, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]string{
+		"/Docs/report.md":     "bob",
+		"/Docs/":              "bob",
+		"/Docs":               "bob",
+		"/":                   "bob",
+		"/Docs/other.md":      "",
+		"/Docs/report.md.bak": "",
+		"/Shared/a/b.txt":     "carol",
+		"/Shared/":            "carol",
+		"/Sharedx/":           "",
+	} {
+		rows, err := Conflicts(param(p), "alice", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if len(rows) > 0 {
+			got = rows[0].Holder
+		}
+		if got != want {
+			t.Errorf("%s: locked by %q, want %q", p, got, want)
+		}
+	}
+
+	if rows, _ := Conflicts(param("/Docs/report.md"), "bob", now); len(rows) != 0 {
+		t.Errorf("holder conflicts with own lock: %+v", rows)
+	}
+	if rows, _ := Conflicts(param("/Docs/report.md"), "alice", now.Add(2*time.Hour)); len(rows) != 0 {
+		t.Errorf("expired lock still conflicts: %+v", rows)
+	}
+	// another user's Home is another storage
+	other := param("/Docs/report.md")
+	other.Owner = "dave"
+	if rows, _ := Conflicts(other, "dave", now); len(rows) != 0 {
+		t.Errorf("lock leaked into another home: %+v", rows)
+	}
+}
+
+func TestAnnotateListing(t *testing.T) {
+	newLockTestDB(t)
+	now := time.Now()
+	param := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: p}
+	}
+	for _, p := range []string{"/Docs/a.md", "/Docs/sub/", "/Docs/sub/deep.md"} {
+		if _, err := acquire(t, param(p), "bob", now, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a.md", "isDir": false},
+			map[string]interface{}{"name": "b.md", "isDir": false},
+			map[string]interface{}{"name": "sub", "isDir": true},
+		},
+	}
+	AnnotateListing(param("/Docs/"), listing)
+	items := listing["items"].([]interface{})
+	for i, want := range []string{"bob", "", "bob"} {
+		got, _ := items[i].(map[string]interface{})["locked_by"].(string)
+		if got != want {
+			t.Errorf("item %d locked_by %q, want %q", i, got, want)
+		}
+	}
+
+	syncListing := map[string]interface{}{
+		"dirent_list": []interface{}{map[string]interface{}{"name": "sub", "type": "dir"}},
+	}
+	AnnotateListing(param("/Docs/"), syncListing)
+	if syncListing["dirent_list"].([]interface{})[0].(map[string]interface{})["locked_by"] != "bob" {
+		t.Error("sync dirent not annotated")
+	}
+
+	file := map[string]interface{}{"name": "a.md"}
+	AnnotateListing(param("/Docs/a.md"), file)
+	if file["locked_by"] != "bob" {
+		t.Errorf("file locked_by = %v", file["locked_by"])
+	}
+}
+
+func TestMoveFileLocks(t *testing.T) {
+	newLockTestDB(t)
+	now := time.Now()
+	fp := &models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: "/Docs/"}
+	for _, p := range []string{"/Docs/a.md", "/Docs/sub/", "/Docsx/b.md"} {
+		child := *fp
+		child.Path = p
+		if _, err := acquire(t, &child, "alice", now, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	MoveLocks(fp, "/Papers/")
+	rows, err := database.QueryFileLocks("alice", common.Drive, "Home", nil, "/", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, row := range rows {
+		paths = append(paths, row.Path)
+	}
+	if got := strings.Join(paths, ","); got != "/Docsx/b.md,/Papers/a.md,/Papers/sub/" {
+		t.Fatalf("paths after move = %s", got)
+	}
+
+	DropLocks(&models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: "/Papers"})
+	if rows, _ = database.QueryFileLocks("alice", common.Drive, "Home", nil, "/", now); len(rows) != 1 {
+		t.Fatalf("locks after drop = %+v", rows)
+	}
+}
+
+func TestCoveringPaths(t *testing.T) {
+	for p, want := range map[string]string{
+		"/":          "/",
+		"/a.md":      "/a.md,/",
+		"/a/b/c.md":  "/a/b/c.md,/,/a/,/a/b/",
+		"/a/b/":      "/a/b/,/,/a/",
+		"/a/b_%/c.m": "/a/b_%/c.m,/,/a/,/a/b_%/",
+	} {
+		if got := strings.Join(coveringPaths(p), ","); got != want {
+			t.Errorf("coveringPaths(%s) = %s, want %s", p, got, want)
+		}
+	}
+}
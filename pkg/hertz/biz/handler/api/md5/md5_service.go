@@ -4,10 +4,12 @@ package md5
 
 import (
 	"context"
+	"errors"
 	"files/pkg/common"
 	"files/pkg/files"
 	"files/pkg/hertz/biz/handler"
 	"files/pkg/models"
+	"files/pkg/tasks"
 	"fmt"
 	"os"
 	"strings"
@@ -96,3 +98,128 @@ func Md5Method(ctx context.Context, c *app.RequestContext) {
 	}
 	c.JSON(consts.StatusOK, resp)
 }
+
+// hashInlineMaxSize is the largest local file hashed directly in the
+// request; anything bigger, and every remote (sync / cloud) stream, runs
+// as a cancellable task reported through /api/task.
+const hashInlineMaxSize = 64 << 20
+
+// HashMethod .
+// @router /api/hash/*path [GET]
+func HashMethod(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req md5.HashReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var algo = strings.ToLower(req.Algo)
+	if algo == "" {
+		algo = files.HashMd5
+	}
+	if !common.ListContains(files.HashAlgos, algo) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("unsupported algo %s, expected one of %s", algo, strings.Join(files.HashAlgos, ","))})
+		return
+	}
+
+	var path = strings.TrimPrefix(string(c.Path()), "/api/hash")
+	if path == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "path invalid"})
+		return
+	}
+
+	var owner = string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+	fileParam, err := models.CreateFileParam(owner, path)
+	if err != nil {
+		klog.Errorf("file param error: %v, owner: %s", err, owner)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("file param error: %v", err)})
+		return
+	}
+	if !common.ListContains(common.PosixFileTypes, fileParam.FileType) && !fileParam.IsSync() && !fileParam.IsCloud() {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("hash not supported on %s", fileParam.FileType)})
+		return
+	}
+
+	if !handler.Gate(ctx, c, fileParam, models.ActionRead, true, "hash") {
+		return
+	}
+
+	target, err := tasks.StatHashTarget(fileParam, algo)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "file not found"})
+			return
+		}
+		if errors.Is(err, common.ErrIsDirectory) {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "only support hash for file"})
+			return
+		}
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	res := map[string]interface{}{
+		"algo":   algo,
+		"size":   target.Size,
+		"cached": false,
+	}
+
+	if hash, ok := files.HashCache.Get(target.CacheKey()); ok {
+		res["hash"] = hash
+		res["cached"] = true
+		hashResponse(c, res)
+		return
+	}
+
+	if common.ListContains(common.PosixFileTypes, fileParam.FileType) && target.Size <= hashInlineMaxSize {
+		uri, err := fileParam.GetResourceUri()
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
+		file := &files.FileInfo{
+			Fs:   afero.NewBasePathFs(afero.NewOsFs(), uri),
+			Path: fileParam.Path,
+		}
+		if err = file.Checksum(algo); err != nil {
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+		files.HashCache.Set(target.CacheKey(), file.Checksums[algo])
+		res["hash"] = file.Checksums[algo]
+		hashResponse(c, res)
+		return
+	}
+
+	task := tasks.TaskManager.CreateTask(&models.PasteParam{
+		Owner:  owner,
+		Action: common.ActionHash,
+		Src:    fileParam,
+		Dst:    fileParam,
+	})
+	if err = task.Execute(task.Hash(target)); err != nil {
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	klog.Infof("[hash] owner: %s, algo: %s, path: %s, size: %d, task: %s", owner, algo, path, target.Size, task.Id())
+
+	res["task_id"] = task.Id()
+	hashResponse(c, res)
+}
+
+func hashResponse(c *app.RequestContext, res map[string]interface{}) {
+	resp := new(md5.HashResp)
+	if !handler.DecodeResponse(c, common.ToBytes(res), resp) {
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
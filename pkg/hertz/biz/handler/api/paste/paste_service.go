@@ -9,6 +9,7 @@ import (
 	"files/pkg/drivers"
 	"files/pkg/drivers/base"
 	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/hertz/biz/handler/api/lock"
 	"files/pkg/models"
 	"files/pkg/tasks"
 	"fmt"
@@ -54,6 +55,11 @@ func PasteMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	if req.Conflict != "" && !common.ListContains(common.PasteConflicts, req.Conflict) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("invalid conflict: %s", req.Conflict)})
+		return
+	}
+
 	// Share==1 means SrcOwner / DstOwner / SrcSharePath / DstSharePath
 	// in the request body have *already* been resolved server-side by
 	// proxySharePaste. We refuse to take those fields from anyone else;
@@ -76,6 +82,8 @@ func PasteMethod(ctx context.Context, c *app.RequestContext) {
 		DstShareType: req.DstShareType,
 		SrcOwner:     req.SrcOwner,
 		DstOwner:     req.DstOwner,
+		Verify:       req.Verify,
+		Conflict:     req.Conflict,
 	}
 
 	if pasteParam.Share == 1 {
@@ -159,6 +167,17 @@ func PasteMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	// A move takes the source away, a paste may overwrite the
+	// destination; neither may touch a path someone else has locked.
+	dstFP := *pasteParam.Dst
+	dstFP.Owner = dstOwner
+	if req.Action == common.ActionMove && !lock.CheckWrite(c, &srcFP, bizhandler.RequestUser(c)) {
+		return
+	}
+	if !lock.CheckWrite(c, &dstFP, bizhandler.RequestUser(c)) {
+		return
+	}
+
 	handler := drivers.Adaptor.NewFileHandler(pasteParam.Src.FileType, &base.HandlerParam{})
 
 	task, err := handler.Paste(pasteParam)
@@ -282,6 +301,11 @@ func PauseResumeTaskMethod(ctx context.Context, c *app.RequestContext) {
 			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
 			return
 		}
+	} else if req.Op == "resolve" {
+		if err := tasks.TaskManager.ResolveConflict(owner, req.TaskId, req.Resolution, req.ApplyAll == 1); err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
 	} else {
 		if err := tasks.TaskManager.ResumeTask(owner, req.TaskId); err != nil {
 			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
@@ -300,3 +324,80 @@ func PauseResumeTaskMethod(ctx context.Context, c *app.RequestContext) {
 	}
 	c.JSON(consts.StatusOK, resp)
 }
+
+// VerifyMethod .
+// @router /api/verify/:node/ [POST]
+func VerifyMethod(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req paste.VerifyReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	var owner = string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	src, err := models.CreateFileParam(owner, req.Source)
+	if err != nil {
+		klog.Errorf("file param error: %v, owner: %s", err, owner)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("file param error: %v", err)})
+		return
+	}
+
+	dst, err := models.CreateFileParam(owner, req.Destination)
+	if err != nil {
+		klog.Errorf("file param error: %v, owner: %s", err, owner)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("file param error: %v", err)})
+		return
+	}
+
+	// Both trees are only read, so read access on each side is enough.
+	for _, p := range []*models.FileParam{src, dst} {
+		uri := "/" + p.FileType + "/" + p.Extend + p.Path
+		if lvl, aerr := access.CheckAccessParam(ctx, owner, p); aerr != nil || !lvl.Allow(models.ActionRead) {
+			klog.Warningf("[verify] permission denied: owner=%s, path=%s, level=%v, err=%v", owner, uri, lvl, aerr)
+			c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": common.ErrorMessagePermissionDenied})
+			return
+		}
+
+		h := drivers.Adaptor.NewFileHandler(p.FileType, &base.HandlerParam{Ctx: ctx, Owner: owner})
+		if h == nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("unsupported file type: %s", p.FileType)})
+			return
+		}
+		if exists, _, lerr := h.CheckPathExists(p); lerr != nil || !exists {
+			klog.Warningf("[verify] precheck failed: exists=%v, err=%v, owner: %s, path: %s", exists, lerr, owner, uri)
+			c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "file not found"})
+			return
+		}
+	}
+
+	var pasteParam = &models.PasteParam{
+		Owner:  owner,
+		Action: common.ActionVerify,
+		Src:    src,
+		Dst:    dst,
+	}
+
+	task := tasks.TaskManager.CreateTask(pasteParam)
+	if err = task.Execute(task.VerifyFolder); err != nil {
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{
+			"code":    1,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var res = map[string]string{"task_id": task.Id()}
+
+	resp := new(paste.VerifyResp)
+	if !bizhandler.DecodeResponse(c, common.ToBytes(res), resp) {
+		return
+	}
+	c.JSON(consts.StatusOK, resp)
+}
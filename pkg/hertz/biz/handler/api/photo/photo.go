@@ -0,0 +1,340 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/img"
+	"files/pkg/models"
+	"files/pkg/preview"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	photo "files/pkg/hertz/biz/model/api/photo"
+
+	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
+)
+
+const (
+	MaxTimelineFolders = 20
+	MaxTimelinePhotos  = 5000
+	// MaxTimelineFiles bounds the walk of the folders, photos or not.
+	MaxTimelineFiles = 100000
+)
+
+const (
+	// timelineReaders read the metadata of a timeline's photos at once.
+	timelineReaders = 8
+	// timelineReadTimeout bounds how long a timeline waits on metadata.
+	// The photos not read by then are placed by their modification time;
+	// their metadata, cached once read, serves the next request.
+	timelineReadTimeout = 10 * time.Second
+)
+
+// locationCell is the size in degrees of the squares photos are grouped
+// by, about 11 km north to south.
+const locationCell = 0.1
+
+var (
+	errStorage      = errors.New("photos are read from drive, cache and external storages only")
+	errTimelineWalk = errors.New("too many files in the folders")
+)
+
+// photoStorage tells whether the server reads the files of fileType
+// itself.
+func photoStorage(fileType string) bool {
+	switch fileType {
+	case common.Drive, common.Cache, common.External, common.Internal, common.Hdd, common.Smb, common.Usb:
+		return true
+	}
+	return false
+}
+
+// photoFile is a photo found on a storage, its metadata once read.
+type photoFile struct {
+	fp      *models.FileParam
+	fs      afero.Fs
+	size    int64
+	modTime time.Time
+	md      *img.Metadata
+}
+
+func (p *photoFile) uri() string {
+	return "/" + p.fp.FileType + "/" + p.fp.Extend + p.fp.Path
+}
+
+// time is the capture time of the photo, or its modification time.
+func (p *photoFile) time() (string, bool) {
+	if p.md != nil && p.md.TakenAt != "" {
+		return p.md.TakenAt, true
+	}
+	return p.modTime.Format(time.RFC3339), false
+}
+
+func (p *photoFile) read() error {
+	md, err := p.metadata()
+	if err != nil {
+		return err
+	}
+	p.md = md
+	return nil
+}
+
+func (p *photoFile) metadata() (*img.Metadata, error) {
+	return preview.ImageMetadata(p.fp.Owner, p.fs, p.fp.FileType, p.fp.Extend, p.fp.Path, p.modTime)
+}
+
+// readPhotos reads the metadata of photos with timelineReaders readers
+// until ctx is done. It drops the photos that fail to read, keeps those
+// not read in time without metadata, and tells whether all were read.
+func readPhotos(ctx context.Context, photos []*photoFile) ([]*photoFile, bool) {
+	type result struct {
+		md   *img.Metadata
+		err  error
+		done bool
+	}
+	var (
+		mu      sync.Mutex
+		results = make([]result, len(photos))
+		closed  bool
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+
+	go func() {
+		defer close(next)
+		for i := range photos {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < timelineReaders; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				md, err := photos[i].metadata()
+				mu.Lock()
+				if !closed {
+					results[i] = result{md: md, err: err, done: true}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var finished = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+	// readers still busy finish their photo for the cache only
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+
+	var read = make([]*photoFile, 0, len(photos))
+	var complete = true
+	for i, p := range photos {
+		switch r := results[i]; {
+		case !r.done:
+			complete = false
+			read = append(read, p)
+		case r.err != nil:
+			klog.Warningf("[photo] read metadata error: %v, path: %s", r.err, p.fp.Path)
+		default:
+			p.md = r.md
+			read = append(read, p)
+		}
+	}
+	return read, complete
+}
+
+func storageFs(fp *models.FileParam) (afero.Fs, error) {
+	uri, err := fp.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+	return afero.NewBasePathFs(afero.NewOsFs(), uri), nil
+}
+
+func location(l *img.Location) *photo.PhotoLocation {
+	if l == nil {
+		return nil
+	}
+	return &photo.PhotoLocation{Latitude: l.Latitude, Longitude: l.Longitude, Altitude: int32(l.Altitude)}
+}
+
+func photoInfo(p *photoFile) *photo.PhotoInfo {
+	res := &photo.PhotoInfo{
+		FileType: p.fp.FileType,
+		Extend:   p.fp.Extend,
+		Path:     p.fp.Path,
+		URI:      p.uri(),
+		Name:     path.Base(p.fp.Path),
+		Size:     p.size,
+		Modified: p.modTime.UnixMilli(),
+	}
+	if md := p.md; md != nil {
+		res.Format = md.Format
+		res.Width, res.Height = int32(md.Width), int32(md.Height)
+		res.Orientation = int32(md.Orientation)
+		res.TakenAt = md.TakenAt
+		res.Make, res.Model, res.Lens = md.Make, md.Model, md.Lens
+		res.ExposureTime = md.ExposureTime
+		res.FNumber = md.FNumber
+		res.Iso = int32(md.ISO)
+		res.FocalLength = md.FocalLength
+		res.Location = location(md.Location)
+	}
+	return res
+}
+
+// scanFolder appends the photos in the folder fp and below it to found,
+// skipping hidden entries and those seen already. It stops with
+// errTimelineWalk after walking MaxTimelineFiles files in all.
+func scanFolder(fp *models.FileParam, found []*photoFile, seen map[string]bool, walked *int) ([]*photoFile, error) {
+	fs, err := storageFs(fp)
+	if err != nil {
+		return found, err
+	}
+	if info, err := fs.Stat(fp.Path); err != nil {
+		return found, err
+	} else if !info.IsDir() {
+		return found, os.ErrNotExist
+	}
+	err = afero.Walk(fs, fp.Path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			klog.Warningf("[photo] walk error: %v, path: %s", err, p)
+			return nil
+		}
+		if p != fp.Path && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+		if *walked++; *walked > MaxTimelineFiles {
+			return errTimelineWalk
+		}
+		if !preview.IsPhoto(info.Name()) {
+			return nil
+		}
+		key := fp.FileType + "/" + fp.Extend + p
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		found = append(found, &photoFile{
+			fp:      &models.FileParam{Owner: fp.Owner, FileType: fp.FileType, Extend: fp.Extend, Path: p},
+			fs:      fs,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	return found, err
+}
+
+// wallClock cuts the zone off a photo time, so times compare as the
+// clocks read them.
+func wallClock(t string) string {
+	if len(t) > len("2006-01-02T15:04:05") {
+		return t[:len("2006-01-02T15:04:05")]
+	}
+	return t
+}
+
+type groupKey struct {
+	period   string
+	located  bool
+	lat, lon int
+}
+
+// timeline groups photos by the day, or month, they were taken on and
+// by the place, newest first.
+func timeline(photos []*photoFile, byMonth bool) []*photo.TimelineGroup {
+	type entry struct {
+		p        *photoFile
+		time     string
+		captured bool
+	}
+	entries := make([]entry, 0, len(photos))
+	for _, p := range photos {
+		t, captured := p.time()
+		entries = append(entries, entry{p, t, captured})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if a, b := wallClock(entries[i].time), wallClock(entries[j].time); a != b {
+			return a > b
+		}
+		return entries[i].p.fp.Path < entries[j].p.fp.Path
+	})
+
+	periodLen := len("2006-01-02")
+	if byMonth {
+		periodLen = len("2006-01")
+	}
+
+	var groups []*photo.TimelineGroup
+	var sums = make(map[*photo.TimelineGroup][2]float64)
+	var index = make(map[groupKey]*photo.TimelineGroup)
+	for _, e := range entries {
+		k := groupKey{period: e.time[:periodLen]}
+		var loc *img.Location
+		if e.p.md != nil {
+			loc = e.p.md.Location
+		}
+		if loc != nil {
+			k.located = true
+			k.lat = int(math.Floor(loc.Latitude / locationCell))
+			k.lon = int(math.Floor(loc.Longitude / locationCell))
+		}
+
+		g, ok := index[k]
+		if !ok {
+			g = &photo.TimelineGroup{Period: k.period, Photos: []*photo.TimelinePhoto{}}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		item := &photo.TimelinePhoto{
+			URI:      e.p.uri(),
+			Name:     path.Base(e.p.fp.Path),
+			Time:     e.time,
+			Captured: e.captured,
+			Location: location(loc),
+		}
+		if e.p.md != nil {
+			item.Width, item.Height = int32(e.p.md.Width), int32(e.p.md.Height)
+		}
+		g.Photos = append(g.Photos, item)
+		g.Count++
+		if loc != nil {
+			s := sums[g]
+			sums[g] = [2]float64{s[0] + loc.Latitude, s[1] + loc.Longitude}
+		}
+	}
+
+	for g, s := range sums {
+		n := float64(g.Count)
+		g.Location = &photo.PhotoLocation{Latitude: s[0] / n, Longitude: s[1] / n}
+	}
+	return groups
+}
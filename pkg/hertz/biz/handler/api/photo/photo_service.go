@@ -0,0 +1,161 @@
+// Code generated by hertz generator.
+
+package photo
+
+import (
+	"context"
+	"errors"
+	"files/pkg/common"
+	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/img"
+	"files/pkg/models"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	photo "files/pkg/hertz/biz/model/api/photo"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// GetPhotoInfo .
+// @router /api/photos/info/*path [GET]
+func GetPhotoInfo(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req photo.GetPhotoInfoReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	contextArg, _, ok := bizhandler.ResolveFileHandler(ctx, c, "/api/photos/info", "", consts.StatusBadRequest)
+	if !ok {
+		return
+	}
+	fileParam := contextArg.FileParam
+	if !photoStorage(fileParam.FileType) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": errStorage.Error()})
+		return
+	}
+	if !bizhandler.Gate(ctx, c, fileParam, models.ActionPreview, true, "photo") {
+		return
+	}
+
+	fs, err := storageFs(fileParam)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	info, err := fs.Stat(fileParam.Path)
+	if err != nil || info.IsDir() {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "file not found"})
+		return
+	}
+
+	p := &photoFile{fp: fileParam, fs: fs, size: info.Size(), modTime: info.ModTime()}
+	if err = p.read(); err != nil {
+		if errors.Is(err, img.ErrUnsupportedFormat) {
+			c.AbortWithStatusJSON(consts.StatusUnsupportedMediaType, utils.H{"error": "not an image"})
+			return
+		}
+		klog.Errorf("[photo] read metadata error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(consts.StatusOK, photoInfo(p))
+}
+
+// GetTimeline .
+// @router /api/photos/timeline/ [GET]
+func GetTimeline(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req photo.GetTimelineReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	if req.Group != "" && req.Group != "day" && req.Group != "month" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "group must be day or month"})
+		return
+	}
+	if len(req.Path) == 0 || len(req.Path) > MaxTimelineFolders {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("give 1 to %d folders", MaxTimelineFolders)})
+		return
+	}
+
+	var owner = string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	var folders []*models.FileParam
+	for _, p := range req.Path {
+		if !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
+		fileParam, err := models.CreateFileParam(owner, p)
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("file param error: %v", err)})
+			return
+		}
+		if !photoStorage(fileParam.FileType) {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": errStorage.Error()})
+			return
+		}
+		if !bizhandler.Gate(ctx, c, fileParam, models.ActionPreview, false, "photo_timeline") {
+			return
+		}
+		folders = append(folders, fileParam)
+	}
+
+	var found []*photoFile
+	var seen = make(map[string]bool)
+	var walked int
+	for _, fileParam := range folders {
+		found, err = scanFolder(fileParam, found, seen, &walked)
+		if errors.Is(err, errTimelineWalk) {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("%s, at most %d", err.Error(), MaxTimelineFiles)})
+			return
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "folder not found: " + fileParam.Path})
+			return
+		}
+		if err != nil {
+			klog.Errorf("[photo] scan error: %v, path: %s", err, fileParam.Path)
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+	}
+
+	resp := &photo.GetTimelineResp{}
+	if len(found) > MaxTimelinePhotos {
+		sort.SliceStable(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+		found = found[:MaxTimelinePhotos]
+		resp.Truncated = true
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, timelineReadTimeout)
+	photos, complete := readPhotos(readCtx, found)
+	cancel()
+	resp.Partial = !complete
+
+	resp.Groups = timeline(photos, req.Group == "month")
+	if resp.Groups == nil {
+		resp.Groups = []*photo.TimelineGroup{}
+	}
+	klog.Infof("[photo] timeline of %s, folders: %d, photos: %d, groups: %d", owner, len(folders), len(photos), len(resp.Groups))
+	if resp.Partial {
+		klog.Warningf("[photo] timeline of %s is partial, metadata not read within %v", owner, timelineReadTimeout)
+	}
+	c.JSON(consts.StatusOK, resp)
+}
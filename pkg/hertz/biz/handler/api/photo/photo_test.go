@@ -0,0 +1,119 @@
+package photo
+
+import (
+	"context"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+
+	"files/pkg/img"
+	"files/pkg/models"
+
+	"github.com/spf13/afero"
+)
+
+func testPhoto(name, taken string, modTime time.Time, loc *img.Location) *photoFile {
+	return &photoFile{
+		fp:      &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/Pictures/" + name},
+		modTime: modTime,
+		md:      &img.Metadata{Width: 4000, Height: 3000, TakenAt: taken, Location: loc},
+	}
+}
+
+func TestTimelineByDay(t *testing.T) {
+	paris := &img.Location{Latitude: 48.8582, Longitude: 2.2945}
+	trocadero := &img.Location{Latitude: 48.8616, Longitude: 2.2893}
+	lyon := &img.Location{Latitude: 45.764, Longitude: 4.8357}
+	photos := []*photoFile{
+		testPhoto("a.jpg", "2024-05-01T10:00:00", time.Time{}, paris),
+		testPhoto("b.jpg", "2024-05-01T18:30:05+02:00", time.Time{}, trocadero),
+		testPhoto("c.jpg", "2024-05-01T12:00:00", time.Time{}, lyon),
+		testPhoto("d.jpg", "2024-05-02T08:00:00", time.Time{}, nil),
+		testPhoto("e.jpg", "", time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC), nil),
+	}
+
+	groups := timeline(photos, false)
+	if len(groups) != 4 {
+		t.Fatalf("groups = %d, want 4", len(groups))
+	}
+
+	if g := groups[0]; g.Period != "2024-05-02" || g.Count != 1 || g.Location != nil {
+		t.Errorf("group 0 = %s, %d photos, location %+v", g.Period, g.Count, g.Location)
+	}
+
+	// Paris photos share a cell, newest first, located at their centroid
+	g := groups[1]
+	if g.Period != "2024-05-01" || g.Count != 2 || g.Photos[0].Name != "b.jpg" || g.Photos[1].Name != "a.jpg" {
+		t.Fatalf("group 1 = %s, %d photos", g.Period, g.Count)
+	}
+	if g.Location == nil || math.Abs(g.Location.Latitude-48.8599) > 1e-3 || math.Abs(g.Location.Longitude-2.2919) > 1e-3 {
+		t.Errorf("group 1 location = %+v", g.Location)
+	}
+	if g.Photos[0].URI != "/drive/Home/Pictures/b.jpg" || !g.Photos[0].Captured || g.Photos[0].Width != 4000 {
+		t.Errorf("photo = %+v", g.Photos[0])
+	}
+
+	if g := groups[2]; g.Period != "2024-05-01" || g.Count != 1 || g.Photos[0].Name != "c.jpg" {
+		t.Errorf("group 2 = %s, %d photos", g.Period, g.Count)
+	}
+
+	// without a capture time the modification time places the photo
+	g = groups[3]
+	if g.Period != "2024-04-30" || g.Photos[0].Captured || g.Photos[0].Time != "2024-04-30T09:00:00Z" {
+		t.Errorf("group 3 = %s, photo %+v", g.Period, g.Photos[0])
+	}
+}
+
+func TestTimelineByMonth(t *testing.T) {
+	photos := []*photoFile{
+		testPhoto("a.jpg", "2024-05-01T10:00:00", time.Time{}, nil),
+		testPhoto("b.jpg", "2024-05-20T10:00:00", time.Time{}, nil),
+		testPhoto("c.jpg", "2024-04-02T10:00:00", time.Time{}, nil),
+	}
+	groups := timeline(photos, true)
+	if len(groups) != 2 || groups[0].Period != "2024-05" || groups[0].Count != 2 || groups[1].Period != "2024-04" {
+		t.Fatalf("groups = %+v", groups)
+	}
+	if groups[0].Photos[0].Name != "b.jpg" {
+		t.Errorf("first photo = %s, want b.jpg", groups[0].Photos[0].Name)
+	}
+
+	if groups := timeline(nil, true); len(groups) != 0 {
+		t.Errorf("empty timeline = %+v", groups)
+	}
+}
+
+func TestReadPhotos(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	fd, err := fs.Create("/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = jpeg.Encode(fd, image.NewGray(image.Rect(0, 0, 10, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if err = afero.WriteFile(fs, "/b.jpg", []byte("not a photo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var photos []*photoFile
+	for _, name := range []string{"/a.jpg", "/b.jpg"} {
+		photos = append(photos, &photoFile{fp: &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: name}, fs: fs})
+	}
+	read, complete := readPhotos(context.Background(), photos)
+	if !complete || len(read) != 1 || read[0].md == nil || read[0].md.Height != 20 {
+		t.Fatalf("read %d photos, complete %v", len(read), complete)
+	}
+
+	// once ctx is done the photos not read yet are kept without metadata
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	photos[0].md = nil
+	read, complete = readPhotos(ctx, photos[:1])
+	if len(read) != 1 || complete != (read[0].md != nil) {
+		t.Errorf("read %d photos, complete %v", len(read), complete)
+	}
+}
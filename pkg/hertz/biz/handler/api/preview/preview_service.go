@@ -11,8 +11,11 @@ import (
 	bizhandler "files/pkg/hertz/biz/handler"
 	preview "files/pkg/hertz/biz/model/api/preview"
 	"files/pkg/models"
+	imgpreview "files/pkg/preview"
+	"files/pkg/recent"
 	"fmt"
 	"mime"
+	"net/http"
 	"strings"
 	"time"
 
@@ -41,6 +44,11 @@ func PreviewMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	if err = imgpreview.Negotiate(contextArg.QueryParam); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
 	klog.Infof("[Incoming] preview, user: %s, fsType: %s, method: %s, args: %s", contextArg.FileParam.Owner, contextArg.FileParam.FileType, c.Method(), common.ToJson(contextArg))
 
 	if !bizhandler.Gate(ctx, c, contextArg.FileParam, models.ActionPreview, true, "preview") {
@@ -63,7 +71,7 @@ func PreviewMethod(ctx context.Context, c *app.RequestContext) {
 	if contextArg.FileParam.FileType == common.AwsS3 ||
 		contextArg.FileParam.FileType == common.DropBox ||
 		contextArg.FileParam.FileType == common.GoogleDrive {
-		if contextArg.QueryParam.PreviewSize == "thumb" {
+		if imgpreview.IsThumbnail(contextArg.QueryParam.PreviewSize) {
 			c.SetStatusCode(consts.StatusOK)
 			return
 		}
@@ -79,23 +87,35 @@ func PreviewMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	// thumbnails are drawn by listings, only a full preview opens the file
+	if !imgpreview.IsThumbnail(contextArg.QueryParam.PreviewSize) && string(c.Query("share")) != "1" {
+		recent.Opened(bizhandler.RequestUser(c), contextArg.FileParam)
+	}
+
 	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{
 		"filename": fileData.FileName,
 	}))
 
 	if !fileData.IsCloud {
-		c.Header("Last-Modified", fileData.FileModified.UTC().Format(time.RFC1123))
+		// the format of the preview follows the Accept header
+		c.Header("Vary", "Accept")
+		c.Header("Last-Modified", fileData.FileModified.UTC().Format(http.TimeFormat))
 		ifMatch := string(c.GetHeader("If-Modified-Since"))
 		if ifMatch != "" {
-			t, _ := time.Parse(time.RFC1123, ifMatch)
-			if !fileData.FileModified.After(t) {
+			// the header has a precision of a second
+			t, err := http.ParseTime(ifMatch)
+			if err == nil && !fileData.FileModified.Truncate(time.Second).After(t) {
 				c.AbortWithStatusJSON(consts.StatusNotModified, utils.H{
 					"message": "file not modified",
 				})
 				return
 			}
 		}
-		c.SetContentType(common.MimeTypeByExtension(fileData.FileName)) // todo temp
+		if fileData.ContentType != "" {
+			c.SetContentType(fileData.ContentType)
+		} else {
+			c.SetContentType(imgpreview.ContentType(fileData.Data, fileData.FileName))
+		}
 		c.SetBodyStream(bytes.NewReader(fileData.Data), len(fileData.Data))
 	} else {
 		for k, vs := range fileData.RespHeader {
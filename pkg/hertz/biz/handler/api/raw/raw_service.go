@@ -4,6 +4,7 @@ package raw
 
 import (
 	"context"
+	"files/pkg/access"
 	"files/pkg/common"
 	"files/pkg/drivers"
 	"files/pkg/drivers/base"
@@ -11,6 +12,7 @@ import (
 	bizhandler "files/pkg/hertz/biz/handler"
 	raw "files/pkg/hertz/biz/model/api/raw"
 	"files/pkg/models"
+	"files/pkg/recent"
 	"fmt"
 	"io"
 	"mime"
@@ -68,11 +70,6 @@ func RawMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	var handlerParam = &base.HandlerParam{
-		Ctx:   ctx,
-		Owner: contextArg.FileParam.Owner,
-	}
-
 	var rawInline = ""
 	if req.Inline != nil {
 		rawInline = *req.Inline
@@ -81,6 +78,23 @@ func RawMethod(ctx context.Context, c *app.RequestContext) {
 	if req.Meta != nil {
 		rawMeta = *req.Meta
 	}
+
+	serveRaw(ctx, c, contextArg, rawInline, rawMeta, share)
+
+	// share visitors open the sharer's path, which is not theirs to list
+	if share != "1" && c.Response.StatusCode() < consts.StatusBadRequest {
+		recent.Opened(bizhandler.RequestUser(c), contextArg.FileParam)
+	}
+}
+
+// serveRaw streams the file of contextArg once the caller is authorized,
+// answering range and conditional requests.
+func serveRaw(ctx context.Context, c *app.RequestContext, contextArg *models.HttpContextArgs, rawInline, rawMeta, share string) {
+	var handlerParam = &base.HandlerParam{
+		Ctx:   ctx,
+		Owner: contextArg.FileParam.Owner,
+	}
+
 	var fileType = contextArg.FileParam.FileType
 	var _, isFile = files.GetFileNameFromPath(contextArg.FileParam.Path)
 	if !isFile {
@@ -138,12 +152,14 @@ func RawMethod(ctx context.Context, c *app.RequestContext) {
 	}
 
 	if !file.IsCloud {
+		c.Header("Last-Modified", file.FileModified.UTC().Format(http.TimeFormat))
+		bizhandler.SetEditState(c, handler, contextArg.FileParam)
 		if !noCache {
-			c.Header("Last-Modified", file.FileModified.UTC().Format(time.RFC1123))
 			ifMatch := string(c.GetHeader("If-Modified-Since"))
 			if ifMatch != "" {
-				t, _ := time.Parse(time.RFC1123, ifMatch)
-				if !file.FileModified.After(t) {
+				// the header has a precision of a second
+				t, err := http.ParseTime(ifMatch)
+				if err == nil && !file.FileModified.Truncate(time.Second).After(t) {
 					c.AbortWithStatusJSON(consts.StatusNotModified, utils.H{
 						"message": "file not modified",
 					})
@@ -187,6 +203,8 @@ func RawMethod(ctx context.Context, c *app.RequestContext) {
 				c.Header(k, v)
 			}
 		}
+		// the provider's own validators are not what Edit checks
+		bizhandler.SetEditState(c, handler, contextArg.FileParam)
 
 		if rawInline == "true" {
 			c.Header("Cache-Control", "private")
@@ -266,3 +284,132 @@ func parseRangeHeader(rangeHeader string, totalSize int64) (start, end int64, ok
 
 	return start, end, true
 }
+
+// SignRawMethod .
+// @router /api/signed_url/ [POST]
+func SignRawMethod(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req raw.SignRawReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	secret, err := access.SignedURLSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := signedRawClaims(owner, &req, time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, err := models.CreateFileParam(owner, claims.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if fileParam.FileType == common.Share {
+		// share paths are resolved by the share proxy, which the signed
+		// route does not go through
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "signed urls are not supported for share paths"})
+		return
+	}
+	if _, isFile := files.GetFileNameFromPath(fileParam.Path); !isFile {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("not a file, path: %s", fileParam.Path)})
+		return
+	}
+	if !bizhandler.Gate(ctx, c, fileParam, models.ActionDownload, false, "signed_url") {
+		return
+	}
+
+	uri := access.SignedURL(signedRawPrefix, claims, access.SignURL(secret, claims))
+	if host := string(c.GetHeader("X-Forwarded-Host")); host != "" {
+		scheme := string(c.GetHeader("X-Forwarded-Proto"))
+		if scheme == "" {
+			scheme = "https"
+		}
+		uri = scheme + "://" + host + uri
+	}
+
+	klog.Infof("[Incoming] signed url, user: %s, path: %s, expires: %d, ip: %s", owner, claims.Path, claims.Expires, claims.IP)
+
+	c.JSON(consts.StatusOK, &raw.SignRawResp{
+		URL:       uri,
+		ExpiresAt: time.Unix(claims.Expires, 0).UTC().Format(time.RFC3339),
+	})
+}
+
+// SignedRawMethod .
+// @router /api/signed_raw/*path [GET]
+func SignedRawMethod(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req raw.SignedRawReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := access.SignedURLSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": err.Error()})
+		return
+	}
+
+	claims := &access.SignedURLClaims{
+		Owner:       req.Owner,
+		Path:        strings.TrimPrefix(string(c.Path()), signedRawPrefix),
+		Expires:     req.Expires,
+		IP:          req.IP,
+		Disposition: req.Disposition,
+	}
+	if err = access.VerifySignedURL(secret, claims, req.Sig, c.ClientIP(), time.Now()); err != nil {
+		klog.Warningf("signed raw rejected: %v, owner: %s, path: %s, ip: %s", err, claims.Owner, claims.Path, c.ClientIP())
+		c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": err.Error()})
+		return
+	}
+
+	// there is no session here: the signed owner replaces whatever
+	// identity the request claims
+	c.Request.Header.Set(common.REQUEST_HEADER_OWNER, claims.Owner)
+
+	contextArg, err := models.NewHttpContextArgs(ctx, c, signedRawPrefix, false, false)
+	if err != nil {
+		klog.Errorf("context args error: %v, path: %s", err, string(c.Path()))
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if contextArg.FileParam.FileType == common.Share {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "signed urls are not supported for share paths"})
+		return
+	}
+
+	klog.Infof("[Incoming] signed raw, user: %s, fsType: %s, path: %s", contextArg.FileParam.Owner, contextArg.FileParam.FileType, contextArg.FileParam.Path)
+
+	// only the signed disposition counts, not query parameters appended
+	// to the url
+	var rawInline = ""
+	if claims.Disposition == access.DispositionInline {
+		rawInline = "true"
+	}
+	contextArg.QueryParam.RawInline = rawInline
+	contextArg.QueryParam.RawMeta = ""
+
+	// the owner may have lost access since the url was minted
+	if !bizhandler.Gate(ctx, c, contextArg.FileParam, models.ActionDownload, false, "signed_raw") {
+		return
+	}
+
+	serveRaw(ctx, c, contextArg, rawInline, "", "")
+}
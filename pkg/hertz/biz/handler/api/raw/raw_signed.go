@@ -0,0 +1,47 @@
+package raw
+
+import (
+	"errors"
+	"files/pkg/access"
+	raw "files/pkg/hertz/biz/model/api/raw"
+	"net"
+	"path"
+	"time"
+)
+
+const signedRawPrefix = "/api/signed_raw"
+
+// signedRawClaims turns a SignRawMethod request into the claims to sign.
+// The path is cleaned the way the router cleans the request path it is
+// later verified against.
+func signedRawClaims(owner string, req *raw.SignRawReq, now time.Time) (*access.SignedURLClaims, error) {
+	ttl := access.SignedURLDefaultTTL
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl > access.SignedURLMaxTTL {
+		return nil, errors.New("expires_in must not exceed 7 days")
+	}
+
+	var ip string
+	if req.IP != "" {
+		parsed := net.ParseIP(req.IP)
+		if parsed == nil {
+			return nil, errors.New("invalid ip")
+		}
+		ip = parsed.String()
+	}
+
+	disposition := req.Disposition
+	if disposition == "" {
+		disposition = access.DispositionAttachment
+	}
+
+	return &access.SignedURLClaims{
+		Owner:       owner,
+		Path:        path.Clean("/" + req.Path),
+		Expires:     now.Add(ttl).Unix(),
+		IP:          ip,
+		Disposition: disposition,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package recent
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	recent "files/pkg/hertz/biz/model/api/recent"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+var errListLimit = errors.New("limit must be between 1 and 500")
+
+func viewRecentFile(r *recent.RecentFile) *recent.ViewRecentFile {
+	return &recent.ViewRecentFile{
+		FileType: r.FileType,
+		Extend:   r.Extend,
+		Path:     r.Path,
+		URI:      "/" + r.FileType + "/" + r.Extend + r.Path,
+		Name:     path.Base(strings.TrimSuffix(r.Path, "/")),
+		IsDir:    strings.HasSuffix(r.Path, "/"),
+		Kind:     r.Kind,
+		Time:     r.AccessTime,
+	}
+}
@@ -0,0 +1,126 @@
+// Code generated by hertz generator.
+
+package recent
+
+import (
+	"context"
+	"files/pkg/hertz/biz/dal/database"
+	bizhandler "files/pkg/hertz/biz/handler"
+	recentfiles "files/pkg/recent"
+	"sort"
+	"strings"
+
+	recent "files/pkg/hertz/biz/model/api/recent"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// ListRecentOpened .
+// @router /api/recent/opened/ [GET]
+func ListRecentOpened(ctx context.Context, c *app.RequestContext) {
+	listRecent(c, recentfiles.KindOpened)
+}
+
+// ListRecentChanged .
+// @router /api/recent/changed/ [GET]
+func ListRecentChanged(ctx context.Context, c *app.RequestContext) {
+	listRecent(c, recentfiles.KindChanged)
+}
+
+// ClearRecent .
+// @router /api/recent/ [DELETE]
+func ClearRecent(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req recent.ClearRecentReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	if req.Kind != "" && req.Kind != recentfiles.KindOpened && req.Kind != recentfiles.KindChanged {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "kind must be opened or changed"})
+		return
+	}
+
+	user, ok := recentUser(c)
+	if !ok {
+		return
+	}
+
+	if err = database.ClearRecentFiles(user, req.Kind); err != nil {
+		klog.Errorf("[recent] clear error: %v, user: %s", err, user)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	klog.Infof("[recent] %s cleared recent files, kind: %q", user, req.Kind)
+	c.JSON(consts.StatusOK, new(recent.ClearRecentResp))
+}
+
+func listRecent(c *app.RequestContext, kind string) {
+	var err error
+	var req recent.ListRecentReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	var fileTypes []string
+	if req.Storage != "" {
+		var known bool
+		if fileTypes, known = recentfiles.Storages[req.Storage]; !known {
+			var names []string
+			for name := range recentfiles.Storages {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "storage must be one of " + strings.Join(names, ", ")})
+			return
+		}
+	}
+
+	var limit = DefaultListLimit
+	if req.Limit != 0 {
+		if req.Limit < 0 || req.Limit > MaxListLimit {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": errListLimit.Error()})
+			return
+		}
+		limit = int(req.Limit)
+	}
+
+	user, ok := recentUser(c)
+	if !ok {
+		return
+	}
+
+	rows, err := database.ListRecentFiles(user, kind, fileTypes, limit)
+	if err != nil {
+		klog.Errorf("[recent] list %s error: %v, user: %s", kind, err, user)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := &recent.ListRecentResp{Items: make([]*recent.ViewRecentFile, 0, len(rows))}
+	for _, r := range rows {
+		resp.Items = append(resp.Items, viewRecentFile(r))
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+func recentUser(c *app.RequestContext) (string, bool) {
+	if database.DB == nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": "recent files need a database"})
+		return "", false
+	}
+	user := bizhandler.RequestUser(c)
+	if user == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return "", false
+	}
+	return user, true
+}
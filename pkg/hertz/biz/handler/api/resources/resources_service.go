@@ -5,14 +5,21 @@ package resources
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"files/pkg/common"
 	"files/pkg/drivers"
 	"files/pkg/drivers/base"
+	"files/pkg/files"
 	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/hertz/biz/handler/api/lock"
 	"files/pkg/hertz/biz/handler/api/share"
+	"files/pkg/hertz/biz/handler/api/tag"
 	resources "files/pkg/hertz/biz/model/api/resources"
 	"files/pkg/models"
+	"files/pkg/preview"
+	"files/pkg/recent"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -35,6 +42,16 @@ func GetResourcesMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	list, err := files.ParseListOptions(c.Query)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{
+			"code":    1,
+			"message": err.Error(),
+		})
+		return
+	}
+	contextArg.QueryParam.List = list
+
 	res, err := handler.List(contextArg)
 	if err != nil {
 		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{
@@ -48,6 +65,9 @@ func GetResourcesMethod(ctx context.Context, c *app.RequestContext) {
 	if !bizhandler.DecodeResponse(c, res, resp) {
 		return
 	}
+	lock.AnnotateListing(contextArg.FileParam, *resp)
+	tag.AnnotateListing(contextArg.FileParam, bizhandler.RequestUser(c), *resp)
+	bizhandler.SetEditState(c, handler, contextArg.FileParam)
 	c.JSON(consts.StatusOK, resp)
 }
 
@@ -72,6 +92,9 @@ func PostResourcesMethod(ctx context.Context, c *app.RequestContext) {
 	if !gatePermission(ctx, c, contextArg.FileParam, models.ActionWrite) {
 		return
 	}
+	if !lock.CheckWrite(c, contextArg.FileParam, bizhandler.RequestUser(c)) {
+		return
+	}
 
 	_, err = handler.Create(contextArg)
 	if err != nil {
@@ -107,6 +130,9 @@ func PatchResourcesMethod(ctx context.Context, c *app.RequestContext) {
 	if !gatePermission(ctx, c, contextArg.FileParam, models.ActionWrite) {
 		return
 	}
+	if !lock.CheckWrite(c, contextArg.FileParam, bizhandler.RequestUser(c)) {
+		return
+	}
 
 	_, err = handler.Rename(contextArg)
 	if err != nil {
@@ -126,6 +152,20 @@ func PatchResourcesMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	// the holder's own locks and everyone's tags follow the renamed path
+	if dstName, err := url.PathUnescape(contextArg.QueryParam.Destination); err == nil {
+		dstPath := files.GetPrefixPath(contextArg.FileParam.Path) + dstName
+		if strings.HasSuffix(contextArg.FileParam.Path, "/") {
+			dstPath += "/"
+		}
+		lock.MoveLocks(contextArg.FileParam, dstPath)
+		dst := *contextArg.FileParam
+		dst.Path = dstPath
+		tag.MoveTags(contextArg.FileParam, &dst)
+	}
+	recent.Forget(contextArg.FileParam)
+	preview.Invalidate(contextArg.FileParam)
+
 	resp := new(resources.PatchResourcesResp)
 	c.JSON(consts.StatusOK, resp)
 }
@@ -151,8 +191,18 @@ func PutResourcesMethod(ctx context.Context, c *app.RequestContext) {
 	if !gatePermission(ctx, c, contextArg.FileParam, models.ActionWrite) {
 		return
 	}
+	if !lock.CheckWrite(c, contextArg.FileParam, bizhandler.RequestUser(c)) {
+		return
+	}
 
 	res, err := handler.Edit(contextArg)
+	if errors.Is(err, models.ErrPreconditionFailed) {
+		c.AbortWithStatusJSON(consts.StatusPreconditionFailed, utils.H{
+			"code":    1,
+			"message": "the file was changed since it was read",
+		})
+		return
+	}
 	if err != nil {
 		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{
 			"code":    1,
@@ -161,6 +211,11 @@ func PutResourcesMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	if string(c.Query("share")) != "1" {
+		recent.Changed(bizhandler.RequestUser(c), contextArg.FileParam)
+	}
+	preview.Invalidate(contextArg.FileParam)
+
 	_ = new(resources.PutResourcesResp) // no response
 	c.Header("Etag", res.Etag)
 }
@@ -206,6 +261,11 @@ func DeleteResourcesMethod(ctx context.Context, c *app.RequestContext) {
 	if !gatePermission(ctx, c, deleteArg.FileParam, models.ActionDelete) {
 		return
 	}
+	for _, dirent := range deleteArg.Dirents {
+		if !lock.CheckWrite(c, lock.ChildParam(deleteArg.FileParam, strings.TrimSpace(dirent)), bizhandler.RequestUser(c)) {
+			return
+		}
+	}
 
 	// sync relative must be done before real delete, or else dir or repo will not be found
 	err = share.DeleteRelativeAdjustShare(deleteArg.FileParam, deleteArg.Dirents, nil)
@@ -241,10 +301,23 @@ func DeleteResourcesMethod(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	for _, dirent := range deleteArg.Dirents {
+		ForgetDeleted(lock.ChildParam(deleteArg.FileParam, strings.TrimSpace(dirent)))
+	}
+
 	resp := new(resources.DeleteResourcesResp)
 	c.JSON(consts.StatusOK, resp)
 }
 
+// ForgetDeleted drops what is kept about a deleted path besides the
+// file itself: its locks, tags, recent entries and cached previews.
+func ForgetDeleted(fp *models.FileParam) {
+	lock.DropLocks(fp)
+	tag.DropTags(fp)
+	recent.Forget(fp)
+	preview.Invalidate(fp)
+}
+
 // gatePermission is the authorization check for the writable resources
 // endpoints. It delegates to bizhandler.Gate with skipShare=true:
 // share-proxied requests (share=1, set by ShareMiddleware when it
@@ -0,0 +1,153 @@
+package share
+
+import (
+	"errors"
+	"files/pkg/access"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"files/pkg/integration"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+var builtinShareGroupNames = map[string]string{
+	common.ShareGroupAllUsers:  "All users",
+	common.ShareGroupAllAdmins: "All admins",
+}
+
+// builtinShareGroups lists all_users and all_admins as they stand in the
+// platform user list.
+func builtinShareGroups() []*share.ViewShareGroup {
+	var users, admins = []string{}, []string{}
+	if is := integration.IntegrationService; is != nil {
+		for _, u := range is.GetUsers() {
+			users = append(users, u.Name)
+			if is.IsPlatformAdmin(u.Name) {
+				admins = append(admins, u.Name)
+			}
+		}
+	}
+	sort.Strings(users)
+	sort.Strings(admins)
+
+	return []*share.ViewShareGroup{
+		{Ref: common.ShareGroupAllUsers, ID: strings.TrimPrefix(common.ShareGroupAllUsers, common.ShareGroupPrefix), Name: builtinShareGroupNames[common.ShareGroupAllUsers], Members: users, Builtin: true},
+		{Ref: common.ShareGroupAllAdmins, ID: strings.TrimPrefix(common.ShareGroupAllAdmins, common.ShareGroupPrefix), Name: builtinShareGroupNames[common.ShareGroupAllAdmins], Members: admins, Builtin: true},
+	}
+}
+
+func viewShareGroup(group *share.ShareGroup, members []string) *share.ViewShareGroup {
+	if members == nil {
+		members = []string{}
+	}
+	return &share.ViewShareGroup{
+		Ref:        common.ShareGroupPrefix + group.ID,
+		ID:         group.ID,
+		Name:       group.Name,
+		Members:    members,
+		CreateTime: group.CreateTime,
+	}
+}
+
+// normalizeShareGroupMembers trims and de-duplicates members and, when
+// the platform user list is available, rejects unknown users.
+func normalizeShareGroupMembers(members []string) ([]string, error) {
+	var res = []string{}
+	var seen = make(map[string]bool, len(members))
+	for _, m := range members {
+		m = strings.TrimSpace(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		if access.IsShareGroupRef(m) {
+			return nil, fmt.Errorf("share group cannot contain group %s", m)
+		}
+		if is := integration.IntegrationService; is != nil && !is.UserExists(m) {
+			return nil, fmt.Errorf("user %s not found", m)
+		}
+		seen[m] = true
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// checkShareGroupMembers validates the group members being added to a
+// share of owner: only internal shares take groups, static groups must
+// exist and belong to owner, and sync shares, which are mirrored to
+// seahub per user, take no groups at all.
+func checkShareGroupMembers(owner, fileType, shareType string, infos []*share.AddOrUpdateShareMemberInfo) error {
+	for _, info := range infos {
+		if !access.IsShareGroupRef(info.ShareMember) {
+			continue
+		}
+		if shareType != common.ShareTypeInternal {
+			return errors.New(common.ErrorMessageShareTypeInvalid)
+		}
+		if fileType == common.Sync {
+			return errors.New(common.ErrorMessageShareGroupSyncNotSupport)
+		}
+		if _, ok := builtinShareGroupNames[info.ShareMember]; ok {
+			continue
+		}
+		group, err := database.GetShareGroup(strings.TrimPrefix(info.ShareMember, common.ShareGroupPrefix))
+		if err != nil {
+			return err
+		}
+		if group == nil || group.Owner != owner {
+			return errors.New(common.ErrorMessageShareGroupNotExists)
+		}
+	}
+	return nil
+}
+
+// shareGroupNames maps the group references among members to display
+// names.
+func shareGroupNames(members []*share.ShareMember) map[string]string {
+	var names = make(map[string]string)
+	var ids []string
+	for _, m := range members {
+		if !access.IsShareGroupRef(m.ShareMember) {
+			continue
+		}
+		if name, ok := builtinShareGroupNames[m.ShareMember]; ok {
+			names[m.ShareMember] = name
+			continue
+		}
+		ids = append(ids, strings.TrimPrefix(m.ShareMember, common.ShareGroupPrefix))
+	}
+
+	groups, err := database.QueryShareGroupsByIds(ids)
+	if err != nil {
+		klog.Errorf("QueryShareGroupsByIds error: %v", err)
+		return names
+	}
+	for _, g := range groups {
+		names[common.ShareGroupPrefix+g.ID] = g.Name
+	}
+	return names
+}
+
+// shareGroupGrants returns, per share, the highest permission user holds
+// through a group.
+func shareGroupGrants(user string) (map[string]int32, error) {
+	refs, err := access.ShareGroupRefs(user)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := database.QueryShareMembersByRefs("", refs)
+	if err != nil {
+		return nil, err
+	}
+
+	var res = make(map[string]int32, len(grants))
+	for _, g := range grants {
+		if p, ok := res[g.PathID]; !ok || g.Permission > p {
+			res[g.PathID] = g.Permission
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package share
+
+import (
+	"testing"
+
+	"files/pkg/common"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+func TestCheckShareGroupMembers(t *testing.T) {
+	members := func(names ...string) []*share.AddOrUpdateShareMemberInfo {
+		var res []*share.AddOrUpdateShareMemberInfo
+		for _, n := range names {
+			res = append(res, &share.AddOrUpdateShareMemberInfo{ShareMember: n, Permission: 1})
+		}
+		return res
+	}
+
+	cases := []struct {
+		name      string
+		fileType  string
+		shareType string
+		members   []*share.AddOrUpdateShareMemberInfo
+		want      string
+	}{
+		{"users only", common.Sync, common.ShareTypeInternal, members("alice", "bob"), ""},
+		{"builtin groups", common.Drive, common.ShareTypeInternal, members(common.ShareGroupAllUsers, common.ShareGroupAllAdmins), ""},
+		{"group on sync", common.Sync, common.ShareTypeInternal, members(common.ShareGroupAllUsers), common.ErrorMessageShareGroupSyncNotSupport},
+		{"group on smb", common.Drive, common.ShareTypeSMB, members("alice", common.ShareGroupAllAdmins), common.ErrorMessageShareTypeInvalid},
+	}
+	for _, tc := range cases {
+		err := checkShareGroupMembers("owner", tc.fileType, tc.shareType, tc.members)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%s: error = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	sharewh "files/pkg/webhook/share"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	share "files/pkg/hertz/biz/model/api/share"
+
+	"k8s.io/klog/v2"
+)
+
+// The share lifecycle job runs from cron. It reminds owners through the
+// share webhook before an internal or external share expires and, once
+// an expired share has been left alone for a grace period, removes it
+// together with its tokens and members, leaving a share_archives record:
+//
+//	SHARE_EXPIRY_REMIND_DAYS  days ahead to remind (default 3, <= 0 disables)
+//	SHARE_EXPIRED_GRACE_DAYS  days an expired share is kept so it can still be extended (default 7)
+//	SHARE_EXPIRED_ACTION      archive (default, keeps a snapshot), delete, or keep
+const (
+	shareRemindDays      = 3
+	shareGraceDays       = 7
+	shareCleanupBatch    = 100
+	shareExtendDefault   = 7 * 24 * time.Hour
+	shareActionArchive   = "archive"
+	shareActionDelete    = "delete"
+	shareActionKeep      = "keep"
+	shareArchiveArchived = "archived"
+	shareArchiveDeleted  = "deleted"
+)
+
+// RunShareLifecycle sends the due expiry reminders and cleans up shares
+// that expired more than the grace period before now. It must run on one
+// node of the cluster only, the master.
+func RunShareLifecycle(ctx context.Context, now time.Time) {
+	if database.DB == nil {
+		return
+	}
+	remindExpiringShares(ctx, now)
+	cleanupExpiredShares(ctx, now)
+}
+
+func shareLifecycleDays(env string, def int) int {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		klog.Warningf("[share] invalid %s %q, using %d", env, v, def)
+		return def
+	}
+	return n
+}
+
+func shareLifecycleEvent(event string, p *share.SharePath, now time.Time) sharewh.Event {
+	return sharewh.Event{
+		Event:      event,
+		Owner:      p.Owner,
+		ShareId:    p.ID,
+		Name:       p.Name,
+		ShareType:  p.ShareType,
+		FileType:   p.FileType,
+		Extend:     p.Extend,
+		Path:       p.Path,
+		ExpireTime: p.ExpireTime,
+		OccurredAt: now.UnixMilli(),
+	}
+}
+
+func remindExpiringShares(ctx context.Context, now time.Time) {
+	days := shareLifecycleDays("SHARE_EXPIRY_REMIND_DAYS", shareRemindDays)
+	if days <= 0 || !sharewh.Enabled() {
+		return
+	}
+
+	paths, err := database.QueryExpiringSharePaths(now, now.AddDate(0, 0, days))
+	if err != nil {
+		klog.Errorf("[share] query expiring shares error: %v", err)
+		return
+	}
+	for _, p := range paths {
+		if err = sharewh.Notify(ctx, []sharewh.Event{shareLifecycleEvent(sharewh.EventExpiring, p, now)}); err != nil {
+			// the receiver is down; everything left is retried next run
+			klog.Errorf("[share] expiry reminder for share %s error: %v", p.ID, err)
+			return
+		}
+		if err = database.MarkSharePathReminded(p.ID); err != nil {
+			klog.Errorf("[share] mark share %s reminded error: %v", p.ID, err)
+		}
+	}
+	if len(paths) > 0 {
+		klog.Infof("[share] sent %d expiry reminders", len(paths))
+	}
+}
+
+func cleanupExpiredShares(ctx context.Context, now time.Time) {
+	action := strings.ToLower(strings.TrimSpace(os.Getenv("SHARE_EXPIRED_ACTION")))
+	switch action {
+	case shareActionKeep:
+		return
+	case shareActionArchive, shareActionDelete:
+	case "":
+		action = shareActionArchive
+	default:
+		klog.Warningf("[share] invalid SHARE_EXPIRED_ACTION %q, using %s", action, shareActionArchive)
+		action = shareActionArchive
+	}
+	grace := shareLifecycleDays("SHARE_EXPIRED_GRACE_DAYS", shareGraceDays)
+	if grace < 0 {
+		grace = 0
+	}
+
+	for {
+		paths, err := database.QueryExpiredSharePaths(now.AddDate(0, 0, -grace), shareCleanupBatch)
+		if err != nil {
+			klog.Errorf("[share] query expired shares error: %v", err)
+			return
+		}
+
+		var events []sharewh.Event
+		for _, p := range paths {
+			if err = removeExpiredShare(p, action); err != nil {
+				klog.Errorf("[share] remove expired share %s error: %v", p.ID, err)
+				continue
+			}
+			event := sharewh.EventDeleted
+			if action == shareActionArchive {
+				event = sharewh.EventArchived
+			}
+			events = append(events, shareLifecycleEvent(event, p, now))
+		}
+		if len(events) > 0 {
+			klog.Infof("[share] removed %d expired shares (%s)", len(events), action)
+			if err = sharewh.Notify(ctx, events); err != nil {
+				klog.Errorf("[share] expired share notification error: %v", err)
+			}
+		}
+
+		// shares that failed stay at the head of the next batch, so stop
+		// once a batch makes no progress
+		if len(paths) < shareCleanupBatch || len(events) == 0 {
+			return
+		}
+	}
+}
+
+// removeExpiredShare deletes an expired share with its tokens, members
+// and activity, and records it in share_archives in the same transaction.
+func removeExpiredShare(p *share.SharePath, action string) error {
+	var archive = &share.ShareArchive{
+		PathID:     p.ID,
+		Owner:      p.Owner,
+		ShareType:  p.ShareType,
+		FileType:   p.FileType,
+		Extend:     p.Extend,
+		Path:       p.Path,
+		Name:       p.Name,
+		ExpireTime: p.ExpireTime,
+		Action:     shareArchiveDeleted,
+	}
+
+	if action == shareActionArchive {
+		tokenQueryParams := &database.QueryParams{}
+		tokenQueryParams.AND = []database.Filter{}
+		database.BuildStringQueryParam(p.ID, "share_tokens.path_id", "=", &tokenQueryParams.AND, true)
+		tokens, _, err := database.QueryShareToken(tokenQueryParams, 0, 0, "share_tokens.id", "ASC", nil)
+		if err != nil {
+			return err
+		}
+		members, err := database.QueryShareMembers(p.ID)
+		if err != nil {
+			return err
+		}
+
+		var snapshot = *p
+		snapshot.PasswordMd5 = ""
+		archive.Action = shareArchiveArchived
+		archive.Snapshot = string(common.ToBytes(map[string]interface{}{
+			"share_path":    &snapshot,
+			"share_tokens":  tokens,
+			"share_members": members,
+		}))
+	}
+
+	tx := database.DB.Begin()
+	// DeleteSharePathRelations rolls tx back itself on failure
+	if err := DeleteSharePathRelations(p.ID, tx); err != nil {
+		return err
+	}
+	if err := database.CreateShareArchive(archive, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// extendedShareExpiry works out the expiry an ExtendSharePath request
+// asks for. An absolute expireTime wins; otherwise expireIn milliseconds
+// (a week when zero) are added to the later of now and the current
+// expiry, so extending an expired share starts from now.
+func extendedShareExpiry(current string, expireIn int64, expireTime string, now time.Time) (time.Time, error) {
+	if expireTime != "" {
+		var t time.Time
+		if millis, err := strconv.ParseInt(expireTime, 10, 64); err == nil {
+			t = time.UnixMilli(millis)
+		} else if parsed, ok := common.ParseRFC3339Nano(expireTime); ok {
+			t = parsed
+		} else {
+			return time.Time{}, errors.New("invalid expire_time")
+		}
+		if !t.After(now) {
+			return time.Time{}, errors.New("expire_time must be in the future")
+		}
+		return t.UTC(), nil
+	}
+
+	var d = shareExtendDefault
+	if expireIn > 0 {
+		d = time.Duration(expireIn) * time.Millisecond
+	}
+	var base = now
+	if t, ok := common.ParseRFC3339Nano(current); ok && t.After(now) {
+		base = t
+	}
+	return base.Add(d).UTC(), nil
+}
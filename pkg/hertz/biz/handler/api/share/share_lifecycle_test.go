@@ -0,0 +1,46 @@
+package share
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExtendedShareExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	future := now.Add(48 * time.Hour).Format(time.RFC3339)
+	past := now.Add(-48 * time.Hour).Format(time.RFC3339)
+
+	cases := []struct {
+		name       string
+		current    string
+		expireIn   int64
+		expireTime string
+		want       time.Time
+		wantErr    bool
+	}{
+		{name: "default week from current", current: future, want: now.Add(48*time.Hour + shareExtendDefault)},
+		{name: "expired starts from now", current: past, expireIn: time.Hour.Milliseconds(), want: now.Add(time.Hour)},
+		{name: "unparsable current starts from now", current: "", expireIn: time.Hour.Milliseconds(), want: now.Add(time.Hour)},
+		{name: "absolute rfc3339", current: past, expireTime: now.Add(24 * time.Hour).Format(time.RFC3339), want: now.Add(24 * time.Hour)},
+		{name: "absolute millis", current: future, expireTime: strconv.FormatInt(now.Add(time.Hour).UnixMilli(), 10), want: now.Add(time.Hour)},
+		{name: "absolute in the past", current: future, expireTime: past, wantErr: true},
+		{name: "absolute invalid", current: future, expireTime: "tomorrow", wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := extendedShareExpiry(tc.current, tc.expireIn, tc.expireTime, now)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error, got %v", tc.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", tc.name, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package share
+
+import (
+	"errors"
+	"files/pkg/common"
+	hertzcommon "files/pkg/hertz/common"
+	"files/pkg/nfs"
+	"strings"
+
+	share "files/pkg/hertz/biz/model/api/share"
+
+	"k8s.io/klog/v2"
+)
+
+// hasNfsOptions reports whether a CreateSharePath request sets any field
+// that only nfs shares have.
+func hasNfsOptions(req *share.CreateSharePathReq) bool {
+	return req.NfsClients != "" || req.NfsSquash != ""
+}
+
+// nfsShareSettings validates an nfs CreateSharePath request and returns
+// the normalised client list, squash option and permission to store.
+// Access is granted by client address: view exports read-only, edit
+// read-write. Only a platform admin may export with no_root_squash.
+func nfsShareSettings(req *share.CreateSharePathReq, fileType string, admin bool) (clients, squash string, permission int32, err error) {
+	if !common.ListContains([]string{common.Drive, common.Cache, common.External}, fileType) {
+		return "", "", 0, errors.New(common.ErrorMessageNfsNotSupport)
+	}
+	if len(req.ShareMembers) > 0 || len(req.Users) > 0 || req.PublicSmb || req.Password != "" {
+		return "", "", 0, errors.New("nfs shares are granted to nfs_clients, not to members or a password")
+	}
+
+	list, err := nfs.ParseClients(req.NfsClients)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if squash, err = nfs.ParseSquash(req.NfsSquash, admin); err != nil {
+		return "", "", 0, err
+	}
+
+	switch req.Permission {
+	case PERMISSION_NONE, PERMISSION_VIEW:
+		permission = PERMISSION_VIEW
+	case PERMISSION_EDIT:
+		permission = PERMISSION_EDIT
+	default:
+		return "", "", 0, errors.New("nfs share permission must be view or edit")
+	}
+	return strings.Join(list, ","), squash, permission, nil
+}
+
+// fillNfsMount sets the server:path string clients mount an nfs share
+// with.
+func fillNfsMount(view *share.ViewSharePath, sharePath *share.SharePath) {
+	if sharePath.ShareType != common.ShareTypeNFS {
+		return
+	}
+	exportPath, err := nfs.ExportPath(sharePath)
+	if err != nil {
+		klog.Errorf("nfs export path of share %s error: %v", sharePath.ID, err)
+		return
+	}
+	view.NfsMount = hertzcommon.FormatNfsMount(sharePath.FileType, sharePath.Extend, exportPath)
+}
@@ -0,0 +1,53 @@
+package share
+
+import (
+	"testing"
+
+	"files/pkg/common"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+func TestNfsShareSettings(t *testing.T) {
+	req := &share.CreateSharePathReq{
+		ShareType:  common.ShareTypeNFS,
+		NfsClients: "10.0.0.7/24, 192.168.1.20,10.0.0.0/24",
+	}
+	clients, squash, permission, err := nfsShareSettings(req, common.Drive, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients != "10.0.0.0/24,192.168.1.20" || squash != "root_squash" || permission != PERMISSION_VIEW {
+		t.Fatalf("got clients %q, squash %q, permission %d", clients, squash, permission)
+	}
+
+	req.Permission = PERMISSION_EDIT
+	req.NfsSquash = "all_squash"
+	if _, squash, permission, err = nfsShareSettings(req, common.External, false); err != nil || squash != "all_squash" || permission != PERMISSION_EDIT {
+		t.Fatalf("edit share: squash %q, permission %d, err %v", squash, permission, err)
+	}
+
+	for name, bad := range map[string]*share.CreateSharePathReq{
+		"no clients": {NfsClients: " , "},
+		"hostname":   {NfsClients: "lab.local"},
+		"wildcard":   {NfsClients: "*"},
+		"upload":     {NfsClients: "10.0.0.1", Permission: PERMISSION_UPLOAD},
+		"admin":      {NfsClients: "10.0.0.1", Permission: PERMISSION_ADMIN},
+		"members":    {NfsClients: "10.0.0.1", ShareMembers: []*share.AddOrUpdateShareMemberInfo{{ShareMember: "bob"}}},
+		"password":   {NfsClients: "10.0.0.1", Password: "secret"},
+		"bad squash": {NfsClients: "10.0.0.1", NfsSquash: "anon"},
+		"everyone":   {NfsClients: "0.0.0.0/0"},
+		"no squash":  {NfsClients: "10.0.0.1", NfsSquash: "no_root_squash"},
+	} {
+		if _, _, _, err = nfsShareSettings(bad, common.Drive, false); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, _, _, err = nfsShareSettings(&share.CreateSharePathReq{NfsClients: "10.0.0.1"}, common.Sync, true); err == nil {
+		t.Error("sync folder accepted")
+	}
+	req = &share.CreateSharePathReq{NfsClients: "10.0.0.1", NfsSquash: "no_root_squash"}
+	if _, squash, _, err = nfsShareSettings(req, common.Drive, true); err != nil || squash != "no_root_squash" {
+		t.Errorf("admin no_root_squash: squash %q, err %v", squash, err)
+	}
+}
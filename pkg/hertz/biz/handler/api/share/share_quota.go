@@ -0,0 +1,39 @@
+package share
+
+import (
+	"errors"
+	"files/pkg/access"
+	"files/pkg/common"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+// fillShareQuota sets what is left of an external link's download, byte
+// and visitor quotas; unlimited quotas stay absent.
+func fillShareQuota(view *share.ViewSharePath) {
+	if view.ShareType != common.ShareTypeExternal {
+		return
+	}
+	view.RemainingDownloads = access.ShareQuotaRemaining(view.MaxDownloads, view.DownloadCount)
+	view.RemainingBytes = access.ShareQuotaRemaining(view.MaxBytes, view.BytesServed)
+	view.RemainingVisitors = access.ShareQuotaRemaining(view.MaxVisitors, view.VisitorCount)
+}
+
+// shareQuotaUpdates collects the quota limits an UpdateSharePath request
+// sets. Counters are kept, so raising a limit reopens an exhausted link.
+func shareQuotaUpdates(req *share.UpdateSharePathReq, updates map[string]interface{}) error {
+	for column, limit := range map[string]*int64{
+		"max_downloads": req.MaxDownloads,
+		"max_bytes":     req.MaxBytes,
+		"max_visitors":  req.MaxVisitors,
+	} {
+		if limit == nil {
+			continue
+		}
+		if *limit < 0 {
+			return errors.New(column + " must not be negative")
+		}
+		updates[column] = *limit
+	}
+	return nil
+}
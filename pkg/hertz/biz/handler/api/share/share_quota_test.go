@@ -0,0 +1,55 @@
+package share
+
+import (
+	"testing"
+
+	"files/pkg/common"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+func TestFillShareQuota(t *testing.T) {
+	view := &share.ViewSharePath{
+		ShareType:     common.ShareTypeExternal,
+		MaxDownloads:  5,
+		DownloadCount: 2,
+		BytesServed:   1 << 20,
+		MaxVisitors:   1,
+		VisitorCount:  3,
+	}
+	fillShareQuota(view)
+	if view.RemainingDownloads == nil || *view.RemainingDownloads != 3 {
+		t.Fatalf("remaining downloads = %v, want 3", view.RemainingDownloads)
+	}
+	if view.RemainingBytes != nil {
+		t.Fatalf("remaining bytes = %d, want unlimited", *view.RemainingBytes)
+	}
+	if view.RemainingVisitors == nil || *view.RemainingVisitors != 0 {
+		t.Fatalf("remaining visitors = %v, want 0", view.RemainingVisitors)
+	}
+
+	internal := &share.ViewSharePath{ShareType: common.ShareTypeInternal, MaxDownloads: 5}
+	fillShareQuota(internal)
+	if internal.RemainingDownloads != nil {
+		t.Fatalf("internal share got a download quota")
+	}
+}
+
+func TestShareQuotaUpdates(t *testing.T) {
+	var zero, ten, negative = int64(0), int64(10), int64(-1)
+
+	updates := map[string]interface{}{"name": "n"}
+	if err := shareQuotaUpdates(&share.UpdateSharePathReq{MaxDownloads: &ten, MaxBytes: &zero}, updates); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if updates["max_downloads"] != ten || updates["max_bytes"] != zero {
+		t.Fatalf("updates = %v", updates)
+	}
+	if _, ok := updates["max_visitors"]; ok {
+		t.Fatalf("unset max_visitors was updated")
+	}
+
+	if err := shareQuotaUpdates(&share.UpdateSharePathReq{MaxVisitors: &negative}, map[string]interface{}{}); err == nil {
+		t.Fatalf("negative limit accepted")
+	}
+}
@@ -0,0 +1,23 @@
+package share
+
+import (
+	"errors"
+	"files/pkg/access"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+// shareRequestUpdates collects the file request restrictions an
+// UpdateSharePath request sets; they apply to uploads from then on.
+func shareRequestUpdates(req *share.UpdateSharePathReq, updates map[string]interface{}) error {
+	if req.RequestMaxSize != nil {
+		if *req.RequestMaxSize < 0 {
+			return errors.New("request_max_size must not be negative")
+		}
+		updates["request_max_size"] = *req.RequestMaxSize
+	}
+	if req.RequestFileTypes != nil {
+		updates["request_file_types"] = access.NormalizeShareRequestTypes(*req.RequestFileTypes)
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ package share
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"files/pkg/access"
 	"files/pkg/client"
 	"files/pkg/common"
 	"files/pkg/drivers"
@@ -15,12 +17,14 @@ import (
 	"files/pkg/global"
 	"files/pkg/hertz/biz/dal/database"
 	"files/pkg/hertz/biz/handler"
+	"files/pkg/integration"
 	"files/pkg/models"
 	"files/pkg/samba"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -115,12 +119,30 @@ func CreateSharePath(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	if req.ShareType != common.ShareTypeSMB && hasSmbOptions(&req) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "time_machine and smb_* options only apply to smb shares"})
+		return
+	}
+
+	if req.ShareType != common.ShareTypeNFS && hasNfsOptions(&req) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "nfs_* options only apply to nfs shares"})
+		return
+	}
+
 	if req.ShareType == common.ShareTypeSMB { // ~ create samba
 		createSambaShare(c, owner, &req, fileParam)
 		return
 	}
 
 	permission := int32(PERMISSION_ADMIN)
+	var nfsClients, nfsSquash string
+	if req.ShareType == common.ShareTypeNFS {
+		admin := integration.IntegrationService != nil && integration.IntegrationService.IsPlatformAdmin(owner)
+		if nfsClients, nfsSquash, permission, err = nfsShareSettings(&req, fileParam.FileType, admin); err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
+	}
 	// share_type detail check
 	if req.ShareType == common.ShareTypeExternal {
 		if req.Password == "" {
@@ -136,7 +158,16 @@ func CreateSharePath(ctx context.Context, c *app.RequestContext) {
 			return
 		}
 		permission = req.Permission
-	} else if req.ShareType == common.ShareTypeInternal || req.ShareType == common.ShareTypeSMB {
+		if req.FileRequest {
+			// file requests write into per-uploader folders created on
+			// the local volume, and visitors only ever upload
+			if !common.ListContains([]string{common.Drive, common.Cache, common.External}, fileParam.FileType) {
+				handler.RespBadRequest(c, common.ErrorMessageShareRequestNotSupport)
+				return
+			}
+			permission = PERMISSION_UPLOAD
+		}
+	} else if req.ShareType == common.ShareTypeInternal || req.ShareType == common.ShareTypeSMB || req.ShareType == common.ShareTypeNFS {
 		// internal forced use default ADMIN as permission, no matter req.Permission of what value
 		queryParams := &database.QueryParams{}
 		queryParams.AND = []database.Filter{}
@@ -157,6 +188,12 @@ func CreateSharePath(ctx context.Context, c *app.RequestContext) {
 		}
 	}
 
+	if err = checkShareGroupMembers(owner, fileParam.FileType, req.ShareType, req.ShareMembers); err != nil {
+		klog.Errorf("checkShareGroupMembers error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
 	expireIn, expireTime := common.AdjustExpire(req.ExpireIn, req.ExpireTime)
 
 	now := time.Now().UTC().Format(time.RFC3339Nano)
@@ -187,6 +224,20 @@ func CreateSharePath(ctx context.Context, c *app.RequestContext) {
 		CreateTime:      now,
 		UpdateTime:      now,
 	}
+	if req.ShareType == common.ShareTypeExternal {
+		sharePath.MaxDownloads = req.MaxDownloads
+		sharePath.MaxBytes = req.MaxBytes
+		sharePath.MaxVisitors = req.MaxVisitors
+		if req.FileRequest {
+			sharePath.FileRequest = 1
+			sharePath.RequestMaxSize = req.RequestMaxSize
+			sharePath.RequestFileTypes = access.NormalizeShareRequestTypes(req.RequestFileTypes)
+		}
+	}
+	if req.ShareType == common.ShareTypeNFS {
+		sharePath.NfsClients = nfsClients
+		sharePath.NfsSquash = nfsSquash
+	}
 	res, err := database.CreateSharePath([]*share.SharePath{sharePath}, tx)
 	if err != nil {
 		tx.Rollback()
@@ -246,6 +297,8 @@ func CreateSharePath(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 	result.SharedByMe = true
+	fillShareQuota(result)
+	fillNfsMount(result, res[0])
 	for _, shareMember := range addRes {
 		resMember := &share.ViewSharePathMembers{
 			ID:          shareMember.ID,
@@ -321,6 +374,7 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 	// sharedToMe
 	sharedToMeRes := []*share.SharePath{}
 	sharedToMeTotal := int64(0)
+	var groupGrants map[string]int32
 
 	if sharedToMe {
 		// only internal indeed
@@ -354,6 +408,54 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 				c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
 				return
 			}
+
+			// shares reaching me through a share group
+			groupGrants, err = shareGroupGrants(owner)
+			if err != nil {
+				klog.Errorf("shareGroupGrants error: %v", err)
+				c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+				return
+			}
+			var directIds = make(map[string]bool, len(sharedToMeRes))
+			for _, r := range sharedToMeRes {
+				directIds[r.ID] = true
+			}
+			var groupPathIds []string
+			for id := range groupGrants {
+				if !directIds[id] {
+					groupPathIds = append(groupPathIds, id)
+				}
+			}
+			if len(groupPathIds) > 0 {
+				sort.Strings(groupPathIds)
+				groupQueryParams := &database.QueryParams{}
+				groupQueryParams.AND = []database.Filter{}
+				database.BuildStringQueryParam(strings.Join(groupPathIds, ","), "share_paths.id", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.PathId, "share_paths.id", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.FileType, "share_paths.file_type", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.Extend, "share_paths.extend", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.Path, "share_paths.path", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.ShareType, "share_paths.share_type", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.Name, "share_paths.name", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.Owner, "share_paths.owner", "IN", &groupQueryParams.AND, true)
+				database.BuildStringQueryParam(req.ShareRelativeUser, "share_paths.owner", "IN", &groupQueryParams.AND, true)
+				groupRes, _, err := database.QuerySharePath(groupQueryParams, 0, 0, "", "", nil)
+				if err != nil {
+					klog.Errorf("QuerySharePath error: %v", err)
+					c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+					return
+				}
+				for _, r := range groupRes {
+					if r.Owner == owner || r.ShareType != common.ShareTypeInternal {
+						continue
+					}
+					if req.Permission != "" && !common.ListContains(strings.Split(req.Permission, ","), strconv.Itoa(int(groupGrants[r.ID]))) {
+						continue
+					}
+					sharedToMeRes = append(sharedToMeRes, r)
+					sharedToMeTotal++
+				}
+			}
 		}
 	}
 
@@ -445,11 +547,16 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 
 	for _, sharePath := range sharedToMeRes { // only shared with me possibly fit permission for member
 		if sharePath.ShareType == common.ShareTypeInternal && sharePath.Owner != owner {
+			var direct = false
 			for _, shareMember := range shareMembers {
 				if shareMember.PathID == sharePath.ID {
 					sharePath.Permission = shareMember.Permission
+					direct = true
 				}
 			}
+			if p, ok := groupGrants[sharePath.ID]; ok && (!direct || p > sharePath.Permission) {
+				sharePath.Permission = p
+			}
 		}
 
 	}
@@ -465,8 +572,25 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 	}
 
 	internalShareMembers, _ := database.QueryShareInternalMembers(queryShareIds)
+	groupNames := shareGroupNames(internalShareMembers)
 	smbShareMembers, _ := database.QueryShareSmbMembers(queryShareIds)
 
+	// Counters are only shown on the shares the caller owns.
+	var ownShareIds []string
+	for _, r := range res {
+		if r.Owner == owner && r.ShareType != common.ShareTypeSMB {
+			ownShareIds = append(ownShareIds, r.ID)
+		}
+	}
+	activityStats, err := database.QueryShareActivityStats(ownShareIds)
+	if err != nil {
+		klog.Errorf("QueryShareActivityStats error: %v", err)
+	}
+	var statsByShare = make(map[string]*share.ShareActivityStats, len(activityStats))
+	for _, st := range activityStats {
+		statsByShare[st.PathID] = st
+	}
+
 	resp := new(share.ListSharePathResp)
 	resp.Total = int32(total)
 	resp.SharePaths = []*share.ViewSharePath{}
@@ -481,6 +605,16 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 			viewPath.SharedByMe = true
 		}
 
+		fillShareQuota(viewPath)
+		fillNfsMount(viewPath, sharePath)
+
+		if st, ok := statsByShare[sharePath.ID]; ok {
+			viewPath.Views = st.Views
+			viewPath.Downloads = st.Downloads
+			viewPath.Uploads = st.Uploads
+			viewPath.LastAccess = st.LastAccess
+		}
+
 		if viewPath.FileType == common.Sync {
 			repo, err := seaserv.GlobalSeafileAPI.GetRepo(viewPath.Extend)
 			if err != nil {
@@ -501,6 +635,12 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 						Name:       u.ShareMember,
 						Permission: u.Permission,
 					}
+					if access.IsShareGroupRef(u.ShareMember) {
+						user.Group = u.ShareMember
+						if name, ok := groupNames[u.ShareMember]; ok {
+							user.Name = name
+						}
+					}
 					viewPath.Users = append(viewPath.Users, user)
 				}
 			}
@@ -508,6 +648,7 @@ func ListSharePath(ctx context.Context, c *app.RequestContext) {
 
 			viewPath.PublicSmb = sharePath.SmbSharePublic == 1
 			viewPath.SmbLink = hertzcommon.FormatSmbLink(sharePath.FileType, sharePath.Extend, viewPath.Name)
+			viewPath.SmbQuotaExceeded = sharePath.SmbQuota > 0 && sharePath.SmbUsedBytes >= sharePath.SmbQuota
 
 			if len(smbShareMembers) > 0 {
 				for _, u := range smbShareMembers {
@@ -551,7 +692,17 @@ func UpdateSharePath(ctx context.Context, c *app.RequestContext) {
 
 	req.PathId = common.TrimShareId(req.PathId, global.GlobalNode.CheckNodeExists)
 
-	err = database.UpdateSharePath(req.PathId, map[string]interface{}{"name": req.Name}, database.DB)
+	var updates = map[string]interface{}{"name": req.Name}
+	if err = shareQuotaUpdates(&req, updates); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if err = shareRequestUpdates(&req, updates); err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	err = database.UpdateSharePath(req.PathId, updates, database.DB)
 	if err != nil {
 		klog.Errorf("postgres.UpdateShareMember error: %v", err)
 		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
@@ -587,6 +738,8 @@ func UpdateSharePath(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 	resp.SharePath.SharedByMe = res[0].Owner == owner
+	fillShareQuota(resp.SharePath)
+	fillNfsMount(resp.SharePath, res[0])
 	c.JSON(consts.StatusOK, resp)
 }
 
@@ -627,6 +780,12 @@ func UpdateSharePathMembers(ctx context.Context, c *app.RequestContext) {
 	klog.Infof("share_paths: %v", sharePaths)
 	sharePath := sharePaths[0]
 
+	if err = checkShareGroupMembers(sharePath.Owner, sharePath.FileType, sharePath.ShareType, req.ShareMembers); err != nil {
+		klog.Errorf("checkShareGroupMembers error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
 	memberInfoMap := make(map[string]*share.AddOrUpdateShareMemberInfo)
 	for _, memberInfo := range req.ShareMembers {
 		// The share's owner can never be a member of their own share,
@@ -973,9 +1132,11 @@ func GenerateShareToken(ctx context.Context, c *app.RequestContext) {
 	}
 
 	shareToken := &share.ShareToken{
-		PathID:   req.PathId,
-		Token:    uuid.New().String(),
-		ExpireAt: ParseTime(req.ExpireAt),
+		PathID:       req.PathId,
+		Token:        uuid.New().String(),
+		ExpireAt:     ParseTime(req.ExpireAt),
+		MaxDownloads: req.MaxDownloads,
+		MaxBytes:     req.MaxBytes,
 	}
 	res, err := database.CreateShareToken([]*share.ShareToken{shareToken}, database.DB)
 	if err != nil {
@@ -1019,6 +1180,402 @@ func ListShareToken(ctx context.Context, c *app.RequestContext) {
 	c.JSON(consts.StatusOK, resp)
 }
 
+// ListShareActivity .
+// @router /api/share/activity/ [GET]
+func ListShareActivity(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.ListShareActivityReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	req.PathId = common.TrimShareId(req.PathId, global.GlobalNode.CheckNodeExists)
+
+	sharePath, err := database.GetSharePath(req.PathId)
+	if err != nil {
+		klog.Errorf("GetSharePath error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	if sharePath == nil {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": common.ErrorMessageShareNotExists})
+		return
+	}
+	if sharePath.Owner != owner {
+		c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return
+	}
+
+	queryParams := &database.QueryParams{}
+	queryParams.AND = []database.Filter{}
+	database.BuildStringQueryParam(req.PathId, "share_activities.path_id", "=", &queryParams.AND, true)
+	database.BuildStringQueryParam(shareActivityActions(req.Action, req.Source), "share_activities.action", "IN", &queryParams.AND, true)
+	database.BuildStringQueryParam(req.Member, "share_activities.member", "=", &queryParams.AND, true)
+	database.BuildStringQueryParam(req.Token, "share_activities.token", "=", &queryParams.AND, true)
+	database.BuildStringQueryParam(req.IP, "share_activities.ip", "=", &queryParams.AND, true)
+
+	for _, bound := range []struct {
+		value string
+		op    string
+	}{{req.Since, ">="}, {req.Until, "<"}} {
+		if bound.value == "" {
+			continue
+		}
+		t, ok := common.ParseRFC3339Nano(bound.value)
+		if !ok {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("invalid time %q", bound.value)})
+			return
+		}
+		database.BuildStringQueryParam(t.UTC().Format(time.RFC3339Nano), "share_activities.create_time", bound.op, &queryParams.AND, true)
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 1000 {
+		pageSize = 100
+	}
+
+	res, total, err := database.QueryShareActivity(queryParams, page, pageSize, "share_activities.id", "DESC", nil)
+	if err != nil {
+		klog.Errorf("QueryShareActivity error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := new(share.ListShareActivityResp)
+	resp.Total = int32(total)
+	resp.Activities = res
+	c.JSON(consts.StatusOK, resp)
+}
+
+// ExtendSharePath .
+// @router /api/share/share_extend/ [POST]
+func ExtendSharePath(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.ExtendSharePathReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	req.PathId = common.TrimShareId(req.PathId, global.GlobalNode.CheckNodeExists)
+
+	sharePath, err := database.GetSharePath(req.PathId)
+	if err != nil {
+		klog.Errorf("GetSharePath error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	if sharePath == nil {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": common.ErrorMessageShareNotExists})
+		return
+	}
+	if sharePath.Owner != owner {
+		c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return
+	}
+	if sharePath.ShareType != common.ShareTypeInternal && sharePath.ShareType != common.ShareTypeExternal {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "only internal and external shares can be extended"})
+		return
+	}
+
+	now := time.Now()
+	expireTime, err := extendedShareExpiry(sharePath.ExpireTime, req.ExpireIn, req.ExpireTime, now)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	var updates = map[string]interface{}{
+		"expire_in":   expireTime.Sub(now).Milliseconds(),
+		"expire_time": expireTime.Format(time.RFC3339Nano),
+	}
+	if err = database.UpdateSharePath(req.PathId, updates, database.DB); err != nil {
+		klog.Errorf("UpdateSharePath error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	sharePath, err = database.GetSharePath(req.PathId)
+	if err != nil || sharePath == nil {
+		klog.Errorf("GetSharePath error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": "SharePath not found"})
+		return
+	}
+
+	resp := new(share.ExtendSharePathResp)
+	resp.SharePath = new(share.ViewSharePath)
+	if err = json.Unmarshal(common.ToBytes(sharePath), &resp.SharePath); err != nil {
+		klog.Errorf("Failed to unmarshal response body: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": "Failed to unmarshal response body"})
+		return
+	}
+	resp.SharePath.SharedByMe = true
+	fillShareQuota(resp.SharePath)
+	fillNfsMount(resp.SharePath, sharePath)
+	c.JSON(consts.StatusOK, resp)
+}
+
+// ListShareArchive .
+// @router /api/share/share_archive/ [GET]
+func ListShareArchive(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.ListShareArchiveReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	if req.PathId != "" {
+		req.PathId = common.TrimShareId(req.PathId, global.GlobalNode.CheckNodeExists)
+	}
+
+	queryParams := &database.QueryParams{}
+	queryParams.AND = []database.Filter{}
+	database.BuildStringQueryParam(owner, "share_archives.owner", "=", &queryParams.AND, true)
+	database.BuildStringQueryParam(req.PathId, "share_archives.path_id", "=", &queryParams.AND, true)
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 1000 {
+		pageSize = 100
+	}
+
+	res, total, err := database.QueryShareArchive(queryParams, page, pageSize, "share_archives.id", "DESC", nil)
+	if err != nil {
+		klog.Errorf("QueryShareArchive error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := new(share.ListShareArchiveResp)
+	resp.Total = int32(total)
+	resp.Archives = res
+	c.JSON(consts.StatusOK, resp)
+}
+
+// ListShareGroup .
+// @router /api/share/share_group/ [GET]
+func ListShareGroup(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.ListShareGroupReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	groups, err := database.QueryShareGroups(owner)
+	if err != nil {
+		klog.Errorf("QueryShareGroups error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	var groupIds []string
+	for _, g := range groups {
+		groupIds = append(groupIds, g.ID)
+	}
+	members, err := database.QueryShareGroupMembers(groupIds)
+	if err != nil {
+		klog.Errorf("QueryShareGroupMembers error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	var membersByGroup = make(map[string][]string, len(groups))
+	for _, m := range members {
+		membersByGroup[m.GroupID] = append(membersByGroup[m.GroupID], m.Member)
+	}
+
+	resp := new(share.ListShareGroupResp)
+	resp.ShareGroups = builtinShareGroups()
+	for _, g := range groups {
+		resp.ShareGroups = append(resp.ShareGroups, viewShareGroup(g, membersByGroup[g.ID]))
+	}
+	resp.Total = int32(len(resp.ShareGroups))
+	c.JSON(consts.StatusOK, resp)
+}
+
+// CreateShareGroup .
+// @router /api/share/share_group/ [POST]
+func CreateShareGroup(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.CreateShareGroupReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "group name must not be empty"})
+		return
+	}
+	members, err := normalizeShareGroupMembers(req.Members)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	group := &share.ShareGroup{
+		ID:    uuid.New().String(),
+		Owner: owner,
+		Name:  req.Name,
+	}
+	if err = database.CreateShareGroupTx(group, members); err != nil {
+		klog.Errorf("CreateShareGroupTx error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := new(share.CreateShareGroupResp)
+	resp.ShareGroup = viewShareGroup(group, members)
+	c.JSON(consts.StatusOK, resp)
+}
+
+// UpdateShareGroup .
+// @router /api/share/share_group/ [PUT]
+func UpdateShareGroup(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.UpdateShareGroupReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	group, err := database.GetShareGroup(req.GroupId)
+	if err != nil {
+		klog.Errorf("GetShareGroup error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	if group == nil || group.Owner != owner {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": common.ErrorMessageShareGroupNotExists})
+		return
+	}
+
+	var members []string
+	if req.Members != nil {
+		if members, err = normalizeShareGroupMembers(req.Members); err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err = database.UpdateShareGroupTx(group.ID, req.Name, members); err != nil {
+		klog.Errorf("UpdateShareGroupTx error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != "" {
+		group.Name = req.Name
+	}
+	if members == nil {
+		current, err := database.QueryShareGroupMembers([]string{group.ID})
+		if err != nil {
+			klog.Errorf("QueryShareGroupMembers error: %v", err)
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+		for _, m := range current {
+			members = append(members, m.Member)
+		}
+	}
+
+	resp := new(share.UpdateShareGroupResp)
+	resp.ShareGroup = viewShareGroup(group, members)
+	c.JSON(consts.StatusOK, resp)
+}
+
+// DeleteShareGroup .
+// @router /api/share/share_group/ [DELETE]
+func DeleteShareGroup(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req share.DeleteShareGroupReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	var groupIds []string
+	for _, id := range strings.Split(req.GroupIds, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			groupIds = append(groupIds, id)
+		}
+	}
+	if len(groupIds) == 0 {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "group_ids must not be empty"})
+		return
+	}
+
+	if err = database.DeleteShareGroupTx(owner, groupIds); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": common.ErrorMessageShareGroupNotExists})
+			return
+		}
+		klog.Errorf("DeleteShareGroupTx error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := new(share.DeleteShareGroupResp)
+	resp.Success = true
+	c.JSON(consts.StatusOK, resp)
+}
+
 // RevokeShareToken .
 // @router /api/share/share_token/:node/ [DELETE]
 func RevokeShareToken(ctx context.Context, c *app.RequestContext) {
@@ -1088,6 +1645,11 @@ func AddShareMember(ctx context.Context, c *app.RequestContext) {
 			return
 		}
 	}
+	if err = checkShareGroupMembers(sharePath.Owner, sharePath.FileType, sharePath.ShareType, req.ShareMembers); err != nil {
+		klog.Errorf("checkShareGroupMembers error: %v", err)
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
 
 	memberQueryParams := &database.QueryParams{}
 	memberQueryParams.AND = []database.Filter{}
@@ -1612,6 +2174,13 @@ func DeleteSharePathRelations(pathId string, tx *gorm.DB) error {
 	}
 
 	for _, sharePath := range pathRes {
+		err = database.DeleteShareActivity(sharePath.ID, tx)
+		if err != nil {
+			klog.Errorf("DeleteShareActivity error: %v", err)
+			tx.Rollback()
+			return err
+		}
+
 		err = database.DeleteSharePath(sharePath.ID, tx)
 		if err != nil {
 			klog.Errorf("DeleteSharePath error: %v", err)
@@ -2282,10 +2851,39 @@ func GetToken(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	if access.ShareQuotaExhausted(sharePath.MaxDownloads, sharePath.DownloadCount, sharePath.MaxBytes, sharePath.BytesServed) {
+		handler.RespErrorExpired(c, common.CodeLinkExpired, common.ErrorMessageLinkExpired, time.Now().Unix())
+		return
+	}
+
+	var uploaderName, uploaderEmail string
+	if access.IsShareRequest(sharePath) {
+		if uploaderName, uploaderEmail, err = access.ShareUploader(req.UploaderName, req.UploaderEmail); err != nil {
+			handler.RespError(c, err.Error())
+			return
+		}
+	}
+
+	var ip = c.ClientIP()
+	admitted, err := database.AddShareVisitor(req.PathId, ip)
+	if err != nil {
+		klog.Errorf("GetToken, count visitor error: %v, shareId: %s", err, req.PathId)
+		handler.RespError(c, common.ErrorMessageGetTokenError)
+		return
+	}
+	if !admitted {
+		klog.Errorf("GetToken, visitor limit %d reached, shareId: %s", sharePath.MaxVisitors, req.PathId)
+		handler.RespError(c, common.ErrorMessageShareVisitorLimit)
+		return
+	}
+
 	// create token
 	var token = &share.ShareToken{
-		PathID:   req.PathId,
-		ExpireAt: time.Now().Add(6 * time.Hour).Format(time.RFC3339Nano),
+		PathID:        req.PathId,
+		ExpireAt:      time.Now().Add(6 * time.Hour).Format(time.RFC3339Nano),
+		IP:            ip,
+		UploaderName:  uploaderName,
+		UploaderEmail: uploaderEmail,
 	}
 
 	res, err := database.CreateShareToken([]*share.ShareToken{token}, database.DB)
@@ -2387,6 +2985,10 @@ func GetExternalSharePath(ctx context.Context, c *app.RequestContext) {
 	result["share_type"] = sharePath.ShareType
 	result["permission"] = sharePath.Permission
 	result["upload_size_limit"] = sharePath.UploadSizeLimit
+	result["file_request"] = sharePath.FileRequest
+	result["request_max_size"] = sharePath.RequestMaxSize
+	result["request_file_types"] = sharePath.RequestFileTypes
+	result["uploader_name"] = shareToken.UploaderName
 	result["expire_in"] = sharePath.ExpireIn
 	result["expire_time"] = sharePath.ExpireTime
 	result["create_time"] = sharePath.CreateTime
@@ -2623,7 +3225,7 @@ func createSambaShare(c *app.RequestContext, owner string, req *share.CreateShar
 		isSmbSharePublic = 1
 	}
 
-	result, err := samba.SambaService.CreateSambaSharePath(owner, isSmbSharePublic, req.Users, fileParam, req.ExpireIn, req.ExpireTime)
+	result, err := samba.SambaService.CreateSambaSharePath(owner, isSmbSharePublic, req.Users, fileParam, req.ExpireIn, req.ExpireTime, smbShareOptions(req))
 	if err != nil {
 		klog.Errorf("[share] CreateSharePath, create smb share failed, owner: %s, error: %v", owner, err)
 		handler.RespError(c, fmt.Sprintf("Create Smb Share Error %v", err))
@@ -2659,6 +3261,14 @@ func createSambaShare(c *app.RequestContext, owner string, req *share.CreateShar
 		UpdateTime: result.UpdateTime,
 		SharedByMe: true,
 		SmbLink:    hertzcommon.FormatSmbLink(result.FileType, result.Extend, result.Name),
+
+		SmbTimeMachine:   result.SmbTimeMachine,
+		SmbQuota:         result.SmbQuota,
+		SmbRecycle:       result.SmbRecycle,
+		SmbRecycleDays:   result.SmbRecycleDays,
+		SmbSnapshotHours: result.SmbSnapshotHours,
+		SmbSnapshotKeep:  result.SmbSnapshotKeep,
+		SmbAudit:         result.SmbAudit,
 	}
 
 	handler.RespSuccess(c, data)
@@ -2696,7 +3306,33 @@ func ModifySmbMember(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	var updates = make(map[string]interface{})
+	if err = smbOptionUpdates(&req, updates); err != nil {
+		handler.RespBadRequest(c, err.Error())
+		return
+	}
+	if len(updates) > 0 {
+		if err = database.UpdateSharePath(shared.ID, updates, database.DB); err != nil {
+			klog.Errorf("[samba] ModifySmbMember, update share options failed, owner: %s, pathId: %s, error: %v", owner, req.PathId, err)
+			handler.RespError(c, fmt.Sprintf("Modify Share Error: %v", err))
+			return
+		}
+	}
+
 	if shared.SmbSharePublic == 1 && req.PublicSmb {
+		if len(updates) > 0 {
+			// members are unchanged, only smb.conf needs regenerating
+			var crd = &share.SmbCreate{
+				Owner: owner,
+				ID:    shared.ID,
+				Path:  fmt.Sprintf("/%s/%s%s", shared.FileType, shared.Extend, shared.Path),
+			}
+			if err = samba.SambaService.CreateShareSamba([]*share.SmbCreate{crd}, "add"); err != nil {
+				klog.Errorf("[samba] ModifySmbMember, update crd failed, owner: %s, pathId: %s, error: %v", owner, req.PathId, err)
+				handler.RespError(c, fmt.Sprintf("Modify Share Error: %v", err))
+				return
+			}
+		}
 		handler.RespSuccess(c, nil)
 		return
 	}
@@ -0,0 +1,79 @@
+package share
+
+import (
+	"errors"
+	"files/pkg/common"
+	"files/pkg/samba"
+	"strings"
+
+	share "files/pkg/hertz/biz/model/api/share"
+)
+
+// hasSmbOptions reports whether a CreateSharePath request asks for any
+// feature that only smb shares have.
+func hasSmbOptions(req *share.CreateSharePathReq) bool {
+	return req.TimeMachine || req.SmbQuota > 0 || req.SmbRecycle || req.SmbRecycleDays > 0 ||
+		req.SmbSnapshotHours > 0 || req.SmbSnapshotKeep > 0 || req.SmbAudit
+}
+
+func smbShareOptions(req *share.CreateSharePathReq) samba.SambaShareOptions {
+	return samba.SambaShareOptions{
+		TimeMachine:   req.TimeMachine,
+		Quota:         req.SmbQuota,
+		Recycle:       req.SmbRecycle,
+		RecycleDays:   req.SmbRecycleDays,
+		SnapshotHours: req.SmbSnapshotHours,
+		SnapshotKeep:  req.SmbSnapshotKeep,
+		Audit:         req.SmbAudit,
+	}
+}
+
+// smbOptionUpdates collects the recycle bin, snapshot and audit settings
+// a ModifySmbMember request sets. Turning snapshots off keeps the ones
+// already taken; they are no longer shown or pruned.
+func smbOptionUpdates(req *share.ModifySmbMemberReq, updates map[string]interface{}) error {
+	for column, value := range map[string]*bool{
+		"smb_recycle": req.SmbRecycle,
+		"smb_audit":   req.SmbAudit,
+	} {
+		if value == nil {
+			continue
+		}
+		var flag int32
+		if *value {
+			flag = 1
+		}
+		updates[column] = flag
+	}
+	for column, value := range map[string]*int32{
+		"smb_recycle_days":   req.SmbRecycleDays,
+		"smb_snapshot_hours": req.SmbSnapshotHours,
+		"smb_snapshot_keep":  req.SmbSnapshotKeep,
+	} {
+		if value == nil {
+			continue
+		}
+		if *value < 0 {
+			return errors.New(column + " must not be negative")
+		}
+		updates[column] = *value
+	}
+	return nil
+}
+
+// shareActivityActions resolves the action filter of a ListShareActivity
+// request; without explicit actions, source picks http or smb activity.
+func shareActivityActions(action, source string) string {
+	if action != "" {
+		return action
+	}
+	switch source {
+	case "http":
+		return strings.Join([]string{common.ShareActivityResource, common.ShareActivityPreview, common.ShareActivityRaw,
+			common.ShareActivityDownload, common.ShareActivityUpload, common.ShareActivityPaste}, ",")
+	case "smb":
+		return strings.Join([]string{common.ShareActivitySmbMkdir, common.ShareActivitySmbWrite,
+			common.ShareActivitySmbRename, common.ShareActivitySmbDelete}, ",")
+	}
+	return ""
+}
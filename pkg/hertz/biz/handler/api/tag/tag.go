@@ -0,0 +1,184 @@
+package tag
+
+import (
+	"errors"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tag "files/pkg/hertz/biz/model/api/tag"
+
+	"k8s.io/klog/v2"
+)
+
+// Tags, favorites and labels are personal: each user sees only their
+// own, also on a sync library or a shared folder. They are keyed by the
+// storage location like file locks, and follow a path when it is
+// renamed, moved or deleted, whoever does it.
+
+const (
+	MaxFileTags  = 32
+	MaxTagLength = 64
+)
+
+// Labels are the colors a file can be labeled with.
+var Labels = []string{"red", "orange", "yellow", "green", "blue", "purple", "gray"}
+
+func validLabel(label string) bool {
+	if label == "" {
+		return true
+	}
+	for _, l := range Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags trims the tags, drops empty ones and duplicates, and
+// checks their number and length.
+func normalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", t, MaxTagLength)
+		}
+		seen[t] = true
+		res = append(res, t)
+	}
+	if len(res) > MaxFileTags {
+		return nil, fmt.Errorf("a file has at most %d tags", MaxFileTags)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+type pathKey struct {
+	fileType, extend, path string
+}
+
+// collect groups tags and marks by path, in the order they come in.
+func collect(tags []*tag.FileTag, marks []*tag.FileMark) ([]pathKey, map[pathKey]*tag.ViewTaggedFile) {
+	var keys []pathKey
+	views := make(map[pathKey]*tag.ViewTaggedFile)
+	get := func(k pathKey) *tag.ViewTaggedFile {
+		v, ok := views[k]
+		if !ok {
+			v = &tag.ViewTaggedFile{
+				FileType: k.fileType,
+				Extend:   k.extend,
+				Path:     k.path,
+				URI:      "/" + k.fileType + "/" + k.extend + k.path,
+				Tags:     []string{},
+			}
+			views[k] = v
+			keys = append(keys, k)
+		}
+		return v
+	}
+	for _, t := range tags {
+		v := get(pathKey{t.FileType, t.Extend, t.Path})
+		v.Tags = append(v.Tags, t.Tag)
+	}
+	for _, m := range marks {
+		v := get(pathKey{m.FileType, m.Extend, m.Path})
+		v.Favorite = m.Favorite
+		v.Label = m.Label
+	}
+	return keys, views
+}
+
+// listingItemKeys are where each driver puts the entries of a folder
+// listing: posix, sync and cloud.
+var listingItemKeys = []string{"items", "dirent_list", "data"}
+
+// AnnotateListing sets the tags, favorite and label user gave to fp on
+// a resources GET response: on the response itself for a file and on
+// each entry of a folder listing.
+func AnnotateListing(fp *models.FileParam, user string, resp map[string]interface{}) {
+	if database.DB == nil || resp == nil || user == "" {
+		return
+	}
+
+	dir := strings.HasSuffix(fp.Path, "/")
+	var under string
+	if dir {
+		under = fp.Path
+	}
+	tags, marks, err := database.QueryFileTags(user, fp.StorageOwner(), fp.FileType, fp.Extend, []string{fp.Path}, under)
+	if err != nil {
+		klog.Errorf("[tag] query tags error: %v, path: %s", err, fp.Path)
+		return
+	}
+	if len(tags) == 0 && len(marks) == 0 {
+		return
+	}
+	_, views := collect(tags, marks)
+
+	annotate := func(entry map[string]interface{}, p string) {
+		v, ok := views[pathKey{fp.FileType, fp.Extend, p}]
+		if !ok {
+			return
+		}
+		entry["tags"] = v.Tags
+		entry["favorite"] = v.Favorite
+		entry["label"] = v.Label
+	}
+
+	annotate(resp, fp.Path)
+	if !dir {
+		return
+	}
+	for _, key := range listingItemKeys {
+		items, ok := resp[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := entry["name"].(string)
+			if name == "" {
+				continue
+			}
+			p := fp.Path + name
+			if isDir, _ := entry["isDir"].(bool); isDir || entry["type"] == "dir" {
+				p += "/"
+			}
+			annotate(entry, p)
+		}
+	}
+}
+
+// MoveTags re-keys the tags of a renamed path; DropTags forgets those of
+// a deleted one. Failures are logged, the tags are then lost.
+func MoveTags(src, dst *models.FileParam) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.MoveFileTags(src, dst); err != nil {
+		klog.Errorf("[tag] move tags error: %v, path: %s, to: %s", err, src.Path, dst.Path)
+	}
+}
+
+func DropTags(fp *models.FileParam) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.DeleteFileTags(fp); err != nil {
+		klog.Errorf("[tag] drop tags error: %v, path: %s", err, fp.Path)
+	}
+}
+
+var errTaggedFilesQuery = errors.New("give exactly one of tag, favorite and label")
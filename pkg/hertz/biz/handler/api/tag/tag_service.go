@@ -0,0 +1,262 @@
+// Code generated by hertz generator.
+
+package tag
+
+import (
+	"context"
+	"files/pkg/hertz/biz/dal/database"
+	bizhandler "files/pkg/hertz/biz/handler"
+	"files/pkg/models"
+	"strings"
+	"time"
+
+	tag "files/pkg/hertz/biz/model/api/tag"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// ListTags .
+// @router /api/tags/ [GET]
+func ListTags(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.ListTagsReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := tagUser(c)
+	if !ok {
+		return
+	}
+
+	rows, err := database.CountUserTags(user)
+	if err != nil {
+		klog.Errorf("[tag] count tags error: %v, user: %s", err, user)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resp := &tag.ListTagsResp{Tags: rows}
+	if resp.Tags == nil {
+		resp.Tags = []*tag.ViewTag{}
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// ListTaggedFiles .
+// @router /api/tags/items/ [GET]
+func ListTaggedFiles(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.ListTaggedFilesReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	given := 0
+	for _, set := range []bool{req.Tag != "", req.Favorite, req.Label != ""} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": errTaggedFilesQuery.Error()})
+		return
+	}
+
+	user, ok := tagUser(c)
+	if !ok {
+		return
+	}
+
+	tags, marks, err := database.ListUserFileTags(user)
+	if err != nil {
+		klog.Errorf("[tag] list tags error: %v, user: %s", err, user)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	keys, views := collect(tags, marks)
+	resp := &tag.ListTaggedFilesResp{Items: []*tag.ViewTaggedFile{}}
+	for _, k := range keys {
+		v := views[k]
+		switch {
+		case req.Tag != "":
+			for _, t := range v.Tags {
+				if t == req.Tag {
+					resp.Items = append(resp.Items, v)
+					break
+				}
+			}
+		case req.Favorite:
+			if v.Favorite {
+				resp.Items = append(resp.Items, v)
+			}
+		default:
+			if v.Label == req.Label {
+				resp.Items = append(resp.Items, v)
+			}
+		}
+	}
+	c.JSON(consts.StatusOK, resp)
+}
+
+// GetFileTags .
+// @router /api/tags/files/*path [GET]
+func GetFileTags(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.GetFileTagsReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	fileParam, user, ok := tagFileParam(ctx, c)
+	if !ok {
+		return
+	}
+
+	view, err := fileTags(fileParam, user)
+	if err != nil {
+		klog.Errorf("[tag] query tags error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	c.JSON(consts.StatusOK, view)
+}
+
+// SetFileTags .
+// @router /api/tags/files/*path [PUT]
+func SetFileTags(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req tag.SetFileTagsReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(req.Tags); err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Label != nil && !validLabel(*req.Label) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "label must be one of " + strings.Join(Labels, ", ")})
+		return
+	}
+
+	fileParam, user, ok := tagFileParam(ctx, c)
+	if !ok {
+		return
+	}
+
+	current, err := fileTags(fileParam, user)
+	if err != nil {
+		klog.Errorf("[tag] query tags error: %v, path: %s", err, fileParam.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if req.Tags != nil {
+		if err = database.SetFileTags(user, fileParam.StorageOwner(), fileParam.FileType, fileParam.Extend, fileParam.Path, tags, now); err != nil {
+			klog.Errorf("[tag] set tags error: %v, path: %s", err, fileParam.Path)
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+		current.Tags = tags
+	}
+	if req.Favorite != nil || req.Label != nil {
+		if req.Favorite != nil {
+			current.Favorite = *req.Favorite
+		}
+		if req.Label != nil {
+			current.Label = *req.Label
+		}
+		if err = database.SetFileMark(&tag.FileMark{
+			UserName: user,
+			Owner:    fileParam.StorageOwner(),
+			FileType: fileParam.FileType,
+			Extend:   fileParam.Extend,
+			Path:     fileParam.Path,
+			Favorite: current.Favorite,
+			Label:    current.Label,
+		}, now); err != nil {
+			klog.Errorf("[tag] set mark error: %v, path: %s", err, fileParam.Path)
+			c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+			return
+		}
+	}
+
+	klog.Infof("[tag] %s tagged %s, tags: %v, favorite: %v, label: %q", user, current.URI, current.Tags, current.Favorite, current.Label)
+	c.JSON(consts.StatusOK, current)
+}
+
+func tagUser(c *app.RequestContext) (string, bool) {
+	if database.DB == nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": "tags need a database"})
+		return "", false
+	}
+	user := bizhandler.RequestUser(c)
+	if user == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return "", false
+	}
+	return user, true
+}
+
+// tagFileParam resolves the path of a /api/tags/files request. A folder
+// is always keyed with its trailing slash, as listings annotate it.
+func tagFileParam(ctx context.Context, c *app.RequestContext) (*models.FileParam, string, bool) {
+	user, ok := tagUser(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	contextArg, handler, ok := bizhandler.ResolveFileHandler(ctx, c, "/api/tags/files", "", consts.StatusBadRequest)
+	if !ok {
+		return nil, "", false
+	}
+	fileParam := contextArg.FileParam
+	if !bizhandler.Gate(ctx, c, fileParam, models.ActionRead, false, "tag") {
+		return nil, "", false
+	}
+
+	exists, isDir, err := handler.CheckPathExists(fileParam)
+	if err != nil || !exists {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "file not found"})
+		return nil, "", false
+	}
+	if isDir && !strings.HasSuffix(fileParam.Path, "/") {
+		fileParam.Path += "/"
+	}
+	return fileParam, user, true
+}
+
+func fileTags(fp *models.FileParam, user string) (*tag.ViewTaggedFile, error) {
+	tags, marks, err := database.QueryFileTags(user, fp.StorageOwner(), fp.FileType, fp.Extend, []string{fp.Path}, "")
+	if err != nil {
+		return nil, err
+	}
+	_, views := collect(tags, marks)
+	if v, ok := views[pathKey{fp.FileType, fp.Extend, fp.Path}]; ok {
+		return v, nil
+	}
+	return &tag.ViewTaggedFile{
+		FileType: fp.FileType,
+		Extend:   fp.Extend,
+		Path:     fp.Path,
+		URI:      "/" + fp.FileType + "/" + fp.Extend + fp.Path,
+		Tags:     []string{},
+	}, nil
+}
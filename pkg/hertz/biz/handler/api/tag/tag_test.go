@@ -0,0 +1,173 @@
+package tag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+
+	tag "files/pkg/hertz/biz/model/api/tag"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTagTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open in-memory sqlite: %v", err)
+	}
+	ddl := []string{
+		`CREATE TABLE file_tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_name TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			extend TEXT NOT NULL,
+			path TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			create_time TEXT
+		)`,
+		`CREATE UNIQUE INDEX idx_file_tag_path ON file_tags (user_name, owner, file_type, extend, path, tag)`,
+		`CREATE TABLE file_marks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_name TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			extend TEXT NOT NULL,
+			path TEXT NOT NULL,
+			favorite BOOLEAN NOT NULL DEFAULT false,
+			label TEXT NOT NULL DEFAULT '',
+			update_time TEXT
+		)`,
+		`CREATE UNIQUE INDEX idx_file_mark_path ON file_marks (user_name, owner, file_type, extend, path)`,
+	}
+	for _, stmt := range ddl {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create tag tables: %v", err)
+		}
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = saved })
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := normalizeTags([]string{" work ", "2024", "", "work", "Work"})
+	if err != nil || !reflect.DeepEqual(got, []string{"2024", "Work", "work"}) {
+		t.Errorf("normalizeTags = %v, %v", got, err)
+	}
+	if _, err := normalizeTags([]string{strings.Repeat("é", MaxTagLength+1)}); err == nil {
+		t.Error("an over-long tag should be refused")
+	}
+	many := make([]string, MaxFileTags+1)
+	for i := range many {
+		many[i] = strings.Repeat("x", i+1)
+	}
+	if _, err := normalizeTags(many); err == nil {
+		t.Error("too many tags should be refused")
+	}
+}
+
+func setTags(t *testing.T, user string, fp *models.FileParam, favorite bool, label string, tags ...string) {
+	t.Helper()
+	now := time.Now()
+	if err := database.SetFileTags(user, fp.StorageOwner(), fp.FileType, fp.Extend, fp.Path, tags, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetFileMark(&tag.FileMark{
+		UserName: user,
+		Owner:    fp.StorageOwner(),
+		FileType: fp.FileType,
+		Extend:   fp.Extend,
+		Path:     fp.Path,
+		Favorite: favorite,
+		Label:    label,
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnnotateListing(t *testing.T) {
+	newTagTestDB(t)
+	param := func(owner, p string) *models.FileParam {
+		return &models.FileParam{Owner: owner, FileType: common.Sync, Extend: "repo-1", Path: p}
+	}
+	setTags(t, "alice", param("alice", "/Docs/a.txt"), true, "red", "work")
+	setTags(t, "alice", param("alice", "/Docs/Old/"), false, "", "archive")
+	setTags(t, "bob", param("bob", "/Docs/a.txt"), false, "blue", "mine")
+	// a favorite taken back leaves no mark
+	setTags(t, "alice", param("alice", "/Docs/b.txt"), true, "")
+	setTags(t, "alice", param("alice", "/Docs/b.txt"), false, "")
+
+	resp := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a.txt", "isDir": false},
+			map[string]interface{}{"name": "Old", "isDir": true},
+			map[string]interface{}{"name": "b.txt", "isDir": false},
+		},
+	}
+	AnnotateListing(param("alice", "/Docs/"), "alice", resp)
+
+	items := resp["items"].([]interface{})
+	a := items[0].(map[string]interface{})
+	if !reflect.DeepEqual(a["tags"], []string{"work"}) || a["favorite"] != true || a["label"] != "red" {
+		t.Errorf("a.txt = %v", a)
+	}
+	if old := items[1].(map[string]interface{}); !reflect.DeepEqual(old["tags"], []string{"archive"}) || old["favorite"] != false {
+		t.Errorf("Old = %v", old)
+	}
+	if b := items[2].(map[string]interface{}); b["tags"] != nil || b["favorite"] != nil {
+		t.Errorf("b.txt = %v", b)
+	}
+}
+
+func TestMoveAndDropTags(t *testing.T) {
+	newTagTestDB(t)
+	home := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: p}
+	}
+	library := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Sync, Extend: "repo-1", Path: p}
+	}
+	setTags(t, "alice", home("/Papers/"), true, "", "research")
+	setTags(t, "alice", home("/Papers/draft.pdf"), false, "green", "todo")
+	setTags(t, "alice", home("/Papersmith.txt"), false, "", "other")
+	setTags(t, "alice", library("/Shared/Papers/old.pdf"), false, "", "stale")
+
+	// a rename within the storage, then a move to a library over an
+	// existing folder whose tags go with it
+	MoveTags(home("/Papers/"), home("/Articles/"))
+	MoveTags(home("/Articles"), library("/Shared/Papers"))
+
+	tags, marks, err := database.ListUserFileTags("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, views := collect(tags, marks)
+	var got []string
+	for _, k := range keys {
+		v := views[k]
+		got = append(got, v.URI+" "+strings.Join(v.Tags, ",")+" "+v.Label)
+	}
+	want := []string{
+		"/drive/Home/Papersmith.txt other ",
+		"/sync/repo-1/Shared/Papers/ research ",
+		"/sync/repo-1/Shared/Papers/draft.pdf todo green",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after moves:\n%v\nwant\n%v", got, want)
+	}
+
+	DropTags(library("/Shared/Papers"))
+	tags, marks, _ = database.ListUserFileTags("alice")
+	if len(tags) != 1 || len(marks) != 0 || tags[0].Path != "/Papersmith.txt" {
+		t.Errorf("after delete: %+v %+v", tags, marks)
+	}
+}
@@ -0,0 +1,93 @@
+package wopi
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// WOPI sends and expects file names in headers UTF-7 encoded (RFC 2152),
+// so they stay ASCII.
+
+var errInvalidUTF7 = errors.New("invalid utf-7")
+
+const utf7Base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func decodeUTF7(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		ch := s[i]
+		if ch >= 0x80 {
+			return "", errInvalidUTF7
+		}
+		if ch != '+' {
+			b.WriteByte(ch)
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && strings.IndexByte(utf7Base64Chars, s[j]) >= 0 {
+			j++
+		}
+		run := s[i+1 : j]
+		// a '-' ends the run and is absorbed, any other char ends it
+		// and is kept
+		if j < len(s) && s[j] == '-' {
+			j++
+		}
+		i = j
+		if run == "" {
+			b.WriteByte('+')
+			continue
+		}
+
+		data, err := base64.RawStdEncoding.DecodeString(run)
+		if err != nil || len(data)%2 != 0 {
+			return "", errInvalidUTF7
+		}
+		units := make([]uint16, len(data)/2)
+		for k := range units {
+			units[k] = uint16(data[2*k])<<8 | uint16(data[2*k+1])
+		}
+		b.WriteString(string(utf16.Decode(units)))
+	}
+	return b.String(), nil
+}
+
+// encodeUTF7 keeps printable ASCII as is and shifts everything else into
+// base64 runs, each closed with a '-'.
+func encodeUTF7(s string) string {
+	var b strings.Builder
+	var run []rune
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		units := utf16.Encode(run)
+		data := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			data = append(data, byte(u>>8), byte(u))
+		}
+		b.WriteByte('+')
+		b.WriteString(base64.RawStdEncoding.EncodeToString(data))
+		b.WriteByte('-')
+		run = run[:0]
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '+':
+			flush()
+			b.WriteString("+-")
+		case r >= 0x20 && r < 0x7f && r != '\\' && r != '~':
+			flush()
+			b.WriteRune(r)
+		default:
+			run = append(run, r)
+		}
+	}
+	flush()
+	return b.String()
+}
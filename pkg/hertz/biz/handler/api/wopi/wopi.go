@@ -0,0 +1,340 @@
+package wopi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"files/pkg/access"
+	"files/pkg/common"
+	"files/pkg/drivers"
+	"files/pkg/drivers/base"
+	"files/pkg/drivers/sync/seahub"
+	"files/pkg/drivers/sync/seahub/seaserv"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/handler/api/lock"
+	"files/pkg/models"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	lockmodel "files/pkg/hertz/biz/model/api/lock"
+	wopi "files/pkg/hertz/biz/model/api/wopi"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// The WOPI host. An office server opens a file by its WOPISrc,
+// /api/wopi/files/<file_id>, with the access token IssueWopiToken gave
+// the user. Those requests come from the office server, not from the
+// user's browser, so the identity is the token's; the file is read with
+// the driver's Raw and saved with its Edit, the same path and lock
+// checks as PUT /api/resources. WOPI locks are file locks whose token is
+// the lock value the office server chose.
+
+const (
+	wopiFilesPrefix = "/api/wopi/files/"
+
+	// WOPI locks run out after 30 minutes unless refreshed
+	wopiLockTTL = 30 * time.Minute
+	// the longest lock value WOPI clients send
+	wopiLockMaxLength = 1024
+
+	headerOverride            = "X-WOPI-Override"
+	headerLock                = "X-WOPI-Lock"
+	headerOldLock             = "X-WOPI-OldLock"
+	headerLockFailureReason   = "X-WOPI-LockFailureReason"
+	headerItemVersion         = "X-WOPI-ItemVersion"
+	headerMaxExpectedSize     = "X-WOPI-MaxExpectedSize"
+	headerSuggestedTarget     = "X-WOPI-SuggestedTarget"
+	headerRelativeTarget      = "X-WOPI-RelativeTarget"
+	headerOverwriteRelative   = "X-WOPI-OverwriteRelativeTarget"
+	headerValidRelativeTarget = "X-WOPI-ValidRelativeTarget"
+
+	// tries at a free name for PutRelativeFile
+	relativeTargetMaxAttempts = 100
+)
+
+// wopiFetchClient fetches sync files from the seafile file server on
+// behalf of GetFile. Files may be large, so only the wait for the
+// response headers is bounded.
+var wopiFetchClient = &http.Client{
+	Transport: &http.Transport{
+		IdleConnTimeout:       90 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	},
+}
+
+// supported reports whether files of fp's storage can be opened over
+// WOPI: posix storages and sync libraries.
+func supported(fp *models.FileParam) bool {
+	return fp.IsSync() || common.ListContains(common.PosixFileTypes, fp.FileType)
+}
+
+// fileID is the WOPI file id of fp. It is the same for every user seeing
+// the same file, so an office server puts their sessions together.
+func fileID(fp *models.FileParam) string {
+	sum := sha256.Sum256([]byte(lock.Scope(fp) + "\x00" + fp.FileType + "\x00" + fp.Extend + "\x00" + fp.Path))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// wopiSrc is the url an office server reaches the file with id at.
+func wopiSrc(c *app.RequestContext, id string) string {
+	scheme, host := string(c.GetHeader("X-Forwarded-Proto")), string(c.GetHeader("X-Forwarded-Host"))
+	if host == "" {
+		scheme, host = "http", string(c.Host())
+	} else if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + host + wopiFilesPrefix + id
+}
+
+type fileState struct {
+	Size    int64
+	ModTime time.Time
+	Version string
+}
+
+// stat returns the current state of the file fp points at, an
+// os.ErrNotExist error when it is missing and common.ErrIsDirectory for
+// folders. Version changes whenever the content does.
+func stat(fp *models.FileParam) (*fileState, error) {
+	if fp.IsSync() {
+		dirent, err := seaserv.GlobalSeafileAPI.GetDirentByPath(fp.Extend, filepath.Clean(fp.Path))
+		if err != nil {
+			return nil, err
+		}
+		if dirent == nil || dirent["obj_id"] == "" {
+			return nil, os.ErrNotExist
+		}
+		if isDir, _ := seahub.IsDirectory(dirent["mode"]); isDir {
+			return nil, common.ErrIsDirectory
+		}
+		state := &fileState{Version: dirent["obj_id"]}
+		state.Size, _ = strconv.ParseInt(dirent["size"], 10, 64)
+		if mtime, err := strconv.ParseInt(dirent["mtime"], 10, 64); err == nil {
+			state.ModTime = time.Unix(mtime, 0)
+		}
+		return state, nil
+	}
+
+	uri, err := fp.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(uri + fp.Path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, common.ErrIsDirectory
+	}
+	return &fileState{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Version: fmt.Sprintf("%x%x", info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// session is an authorized WOPI request: the token's claims, the file
+// and the Level the request acts with.
+type session struct {
+	claims  *access.WOPIClaims
+	fp      *models.FileParam
+	level   models.Level
+	handler base.Execute
+}
+
+// authorize checks the access token of a WOPI request. The token must be
+// for the file in the url, and the user must still have access: the
+// Level used is the lower of the one in the token and the current one.
+// It aborts with 401, as WOPI expects for a bad token, and returns
+// false otherwise.
+func authorize(ctx context.Context, c *app.RequestContext, req *wopi.WopiFileReq) (*session, bool) {
+	secret, err := access.SignedURLSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": err.Error()})
+		return nil, false
+	}
+
+	claims, err := access.ParseWOPIToken(secret, req.AccessToken, time.Now())
+	if err != nil {
+		klog.Warningf("[wopi] token rejected: %v, file: %s", err, req.FileID)
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": err.Error()})
+		return nil, false
+	}
+
+	fp, err := models.CreateFileParam(claims.Owner, claims.Path)
+	if err != nil || fileID(fp) != req.FileID {
+		klog.Warningf("[wopi] token of %s is not for file %s, err: %v", claims.Path, req.FileID, err)
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": access.ErrWOPITokenInvalid.Error()})
+		return nil, false
+	}
+
+	level, err := access.CheckAccessParam(ctx, claims.Owner, fp)
+	if err != nil || !level.Allow(models.ActionDownload) {
+		klog.Warningf("[wopi] permission denied: owner=%s, path=%s, level=%v, err=%v", claims.Owner, claims.Path, level, err)
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return nil, false
+	}
+	if claims.Level < level {
+		level = claims.Level
+	}
+
+	handler := drivers.Adaptor.NewFileHandler(fp.FileType, &base.HandlerParam{Ctx: ctx, Owner: claims.Owner})
+	if handler == nil {
+		c.AbortWithStatusJSON(consts.StatusNotImplemented, utils.H{"error": fmt.Sprintf("handler not found, type: %s", fp.FileType)})
+		return nil, false
+	}
+
+	return &session{claims: claims, fp: fp, level: level, handler: handler}, true
+}
+
+// allow aborts with 401 when the session's Level does not permit action.
+func (s *session) allow(c *app.RequestContext, action models.Action) bool {
+	if !s.level.Allow(action) {
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return false
+	}
+	return true
+}
+
+// contextArgs builds the driver arguments of a read or write of fp by
+// the session's user, with body as the new content.
+func (s *session) contextArgs(ctx context.Context, fp *models.FileParam, body []byte) *models.HttpContextArgs {
+	header := make(http.Header)
+	header.Set(common.REQUEST_HEADER_OWNER, s.claims.Owner)
+	param := *fp
+	return &models.HttpContextArgs{
+		FileParam: &param,
+		QueryParam: &models.QueryParam{
+			Ctx:    ctx,
+			Owner:  s.claims.Owner,
+			Header: header,
+			Body:   io.NopCloser(bytes.NewReader(body)),
+		},
+	}
+}
+
+// relative returns the resource path and FileParam of name in the folder
+// of the session's file.
+func (s *session) relative(name string) (string, *models.FileParam, error) {
+	resource := strings.TrimSuffix(s.claims.Path, "/")
+	resource = resource[:strings.LastIndex(resource, "/")+1] + name
+	fp, err := models.CreateFileParam(s.claims.Owner, resource)
+	return resource, fp, err
+}
+
+// validName reports whether name can be created next to the session's
+// file.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// currentLock returns the live lock on fp itself, nil when there is none.
+func currentLock(fp *models.FileParam) (*lockmodel.FileLock, error) {
+	rows, err := database.QueryFileLocks(lock.Scope(fp), fp.FileType, fp.Extend, []string{fp.Path}, "", time.Now())
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// lockMismatch answers 409 Conflict with the current lock value. The
+// lock of another user is not given away, its value proves holding it.
+func lockMismatch(c *app.RequestContext, current *lockmodel.FileLock, user, reason string) {
+	var value string
+	if current != nil && current.Holder == user {
+		value = current.Token
+	}
+	c.Response.Header.Set(headerLock, value)
+	if reason != "" {
+		c.Response.Header.Set(headerLockFailureReason, reason)
+	}
+	c.AbortWithStatus(consts.StatusConflict)
+}
+
+// lockConflict is lockMismatch for another user's lock.
+func lockConflict(c *app.RequestContext, row *lockmodel.FileLock) {
+	lockMismatch(c, nil, "", "locked by "+row.Holder)
+}
+
+// checkLock is the lock check of a write by user to fp with the lock
+// value the office server sent. fp must be locked with exactly that
+// value by user, or not locked and empty, and not be below a folder
+// someone else locked. Without a database there are no locks.
+func checkLock(c *app.RequestContext, fp *models.FileParam, user, value string, size int64) bool {
+	if database.DB == nil {
+		return true
+	}
+	rows, err := lock.Conflicts(fp, user, time.Now())
+	if err != nil {
+		return lockQueryFailed(c, fp, err)
+	}
+	if len(rows) > 0 {
+		lockConflict(c, rows[0])
+		return false
+	}
+
+	held, err := currentLock(fp)
+	if err != nil {
+		return lockQueryFailed(c, fp, err)
+	}
+	switch {
+	case held == nil && size > 0:
+		lockMismatch(c, nil, user, "file is not locked")
+		return false
+	case held != nil && held.Token != value:
+		lockMismatch(c, held, user, "file is locked with another lock")
+		return false
+	}
+	return true
+}
+
+func lockQueryFailed(c *app.RequestContext, fp *models.FileParam, err error) bool {
+	klog.Errorf("[wopi] query locks error: %v, path: %s", err, fp.Path)
+	c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+	return false
+}
+
+// lockValue returns the X-WOPI-Lock of the request, aborting with 400
+// when it is missing or too long.
+func lockValue(c *app.RequestContext, header string) (string, bool) {
+	value := string(c.GetHeader(header))
+	if value == "" || len(value) > wopiLockMaxLength {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "invalid " + header})
+		return "", false
+	}
+	return value, true
+}
+
+// freeName returns name, or the first "name (n).ext" that does not exist
+// in the folder of fp.
+func (s *session) freeName(name string) (string, error) {
+	base, ext := common.SplitNameExt(name)
+	candidate := name
+	for n := 1; n <= relativeTargetMaxAttempts; n++ {
+		_, fp, err := s.relative(candidate)
+		if err != nil {
+			return "", err
+		}
+		exists, _, err := s.handler.CheckPathExists(fp)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	return "", errors.New("no free file name for " + name)
+}
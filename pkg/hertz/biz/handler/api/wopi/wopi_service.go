@@ -0,0 +1,492 @@
+// Code generated by hertz generator.
+
+package wopi
+
+import (
+	"context"
+	"errors"
+	"files/pkg/access"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/handler/api/lock"
+	"files/pkg/models"
+	"files/pkg/preview"
+	"files/pkg/recent"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	lockmodel "files/pkg/hertz/biz/model/api/lock"
+	wopi "files/pkg/hertz/biz/model/api/wopi"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"k8s.io/klog/v2"
+)
+
+// IssueWopiToken .
+// @router /api/wopi/token/ [POST]
+func IssueWopiToken(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req wopi.IssueWopiTokenReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	owner := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+	if owner == "" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "user not found"})
+		return
+	}
+
+	secret, err := access.SignedURLSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": err.Error()})
+		return
+	}
+
+	resource := path.Clean("/" + req.Path)
+	fileParam, err := models.CreateFileParam(owner, resource)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	if !supported(fileParam) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("wopi not supported on %s", fileParam.FileType)})
+		return
+	}
+	if _, isFile := fileParam.IsFile(); !isFile {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": fmt.Sprintf("not a file, path: %s", fileParam.Path)})
+		return
+	}
+
+	level, err := access.CheckAccessParam(ctx, owner, fileParam)
+	if err != nil || !level.Allow(models.ActionDownload) {
+		klog.Warningf("[wopi] permission denied: owner=%s, path=%s, level=%v, err=%v", owner, resource, level, err)
+		c.AbortWithStatusJSON(consts.StatusForbidden, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return
+	}
+	if req.ReadOnly && level > models.LevelRead {
+		level = models.LevelRead
+	}
+
+	claims := &access.WOPIClaims{
+		Owner:   owner,
+		Path:    resource,
+		Level:   level,
+		Expires: time.Now().Add(access.WOPITokenTTL).UnixMilli(),
+	}
+	id := fileID(fileParam)
+
+	klog.Infof("[Incoming] wopi token, user: %s, path: %s, level: %d", owner, resource, level)
+
+	c.JSON(consts.StatusOK, &wopi.IssueWopiTokenResp{
+		AccessToken:    access.SignWOPIToken(secret, claims),
+		AccessTokenTTL: claims.Expires,
+		FileID:         id,
+		WopiSrc:        wopiSrc(c, id),
+	})
+}
+
+// CheckFileInfo .
+// @router /api/wopi/files/:file_id [GET]
+func CheckFileInfo(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req wopi.WopiFileReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	s, ok := authorize(ctx, c, &req)
+	if !ok {
+		return
+	}
+	state, ok := s.stat(c)
+	if !ok {
+		return
+	}
+
+	name, _ := s.fp.IsFile()
+	canWrite := s.level.Allow(models.ActionWrite)
+	locks := database.DB != nil
+	c.JSON(consts.StatusOK, &wopi.CheckFileInfoResp{
+		BaseFileName:               name,
+		OwnerId:                    s.fp.Owner,
+		Size:                       state.Size,
+		UserId:                     s.claims.Owner,
+		UserFriendlyName:           s.claims.Owner,
+		Version:                    state.Version,
+		LastModifiedTime:           state.ModTime.UTC().Format(time.RFC3339),
+		ReadOnly:                   !canWrite,
+		UserCanWrite:               canWrite,
+		UserCanNotWriteRelative:    !canWrite,
+		SupportsUpdate:             true,
+		SupportsLocks:              locks,
+		SupportsGetLock:            locks,
+		SupportsExtendedLockLength: true,
+	})
+}
+
+// GetFile .
+// @router /api/wopi/files/:file_id/contents [GET]
+func GetFile(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req wopi.WopiFileReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	s, ok := authorize(ctx, c, &req)
+	if !ok {
+		return
+	}
+	state, ok := s.stat(c)
+	if !ok {
+		return
+	}
+	if limit := string(c.GetHeader(headerMaxExpectedSize)); limit != "" {
+		if n, err := strconv.ParseInt(limit, 10, 64); err == nil && state.Size > n {
+			c.AbortWithStatusJSON(consts.StatusPreconditionFailed, utils.H{"error": "file is larger than " + limit + " bytes"})
+			return
+		}
+	}
+
+	file, err := s.handler.Raw(s.contextArgs(ctx, s.fp, nil))
+	if err != nil {
+		klog.Errorf("[wopi] raw error: %v, user: %s, path: %s", err, s.claims.Owner, s.claims.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	c.Header(headerItemVersion, state.Version)
+	c.SetContentType("application/octet-stream")
+	if !file.Redirect {
+		c.SetBodyStream(file.Reader, int(file.FileLength))
+		return
+	}
+
+	// sync hands out a file server url; the office server cannot follow
+	// it, so the content is fetched here
+	body, length, err := fetchSyncFile(ctx, file.FileName)
+	if err != nil {
+		klog.Errorf("[wopi] fetch error: %v, user: %s, path: %s", err, s.claims.Owner, s.claims.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	c.SetBodyStream(body, int(length))
+}
+
+// PutFile .
+// @router /api/wopi/files/:file_id/contents [POST]
+func PutFile(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req wopi.WopiFileReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	if override := string(c.GetHeader(headerOverride)); override != "" && override != "PUT" {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "unexpected " + headerOverride + ": " + override})
+		return
+	}
+
+	s, ok := authorize(ctx, c, &req)
+	if !ok || !s.allow(c, models.ActionWrite) {
+		return
+	}
+	state, ok := s.stat(c)
+	if !ok {
+		return
+	}
+	if !checkLock(c, s.fp, s.claims.Owner, string(c.GetHeader(headerLock)), state.Size) {
+		return
+	}
+
+	klog.Infof("[Incoming] wopi put file, user: %s, path: %s, size: %d", s.claims.Owner, s.claims.Path, len(c.Request.Body()))
+
+	if _, err = s.handler.Edit(s.contextArgs(ctx, s.fp, c.Request.Body())); err != nil {
+		klog.Errorf("[wopi] edit error: %v, user: %s, path: %s", err, s.claims.Owner, s.claims.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+	recent.Changed(s.claims.Owner, s.fp)
+	preview.Invalidate(s.fp)
+
+	if state, err = stat(s.fp); err == nil {
+		c.Header(headerItemVersion, state.Version)
+	}
+	c.Status(consts.StatusOK)
+}
+
+// FileOperation .
+// @router /api/wopi/files/:file_id [POST]
+func FileOperation(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req wopi.WopiFileReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+
+	override := string(c.GetHeader(headerOverride))
+	switch override {
+	case "LOCK", "UNLOCK", "REFRESH_LOCK", "GET_LOCK", "PUT_RELATIVE":
+	default:
+		c.AbortWithStatusJSON(consts.StatusNotImplemented, utils.H{"error": "unsupported " + headerOverride + ": " + override})
+		return
+	}
+
+	s, ok := authorize(ctx, c, &req)
+	if !ok {
+		return
+	}
+
+	klog.Infof("[Incoming] wopi %s, user: %s, path: %s", override, s.claims.Owner, s.claims.Path)
+
+	if override == "PUT_RELATIVE" {
+		putRelativeFile(ctx, c, s)
+		return
+	}
+
+	// lock operations
+	if database.DB == nil {
+		c.AbortWithStatusJSON(consts.StatusNotImplemented, utils.H{"error": "file locks need a database"})
+		return
+	}
+	if override == "GET_LOCK" {
+		held, err := currentLock(s.fp)
+		if err != nil {
+			lockQueryFailed(c, s.fp, err)
+			return
+		}
+		var value string
+		if held != nil && held.Holder == s.claims.Owner {
+			value = held.Token
+		}
+		// an empty value, not a missing header, means unlocked
+		c.Response.Header.Set(headerLock, value)
+		c.Status(consts.StatusOK)
+		return
+	}
+
+	if !s.allow(c, models.ActionWrite) {
+		return
+	}
+	value, ok := lockValue(c, headerLock)
+	if !ok {
+		return
+	}
+
+	var fp, user, now = s.fp, s.claims.Owner, time.Now()
+	switch {
+	case override == "LOCK" && len(c.GetHeader(headerOldLock)) == 0:
+		var rows []*lockmodel.FileLock
+		if rows, err = lock.Conflicts(fp, user, now); err != nil {
+			lockQueryFailed(c, fp, err)
+			return
+		}
+		if len(rows) > 0 {
+			lockConflict(c, rows[0])
+			return
+		}
+		// taking a lock the user has extends it, with its value
+		var row *lockmodel.FileLock
+		row, err = database.AcquireFileLock(&lockmodel.FileLock{
+			Owner:    lock.Scope(fp),
+			FileType: fp.FileType,
+			Extend:   fp.Extend,
+			Path:     fp.Path,
+			Holder:   user,
+			Token:    value,
+			ExpireAt: database.FormatLockTime(now.Add(wopiLockTTL)),
+		}, now)
+		if errors.Is(err, database.ErrFileLocked) {
+			lockConflict(c, row)
+			return
+		}
+		if err == nil && row.Token != value {
+			lockMismatch(c, row, user, "file is locked with another lock")
+			return
+		}
+
+	case override == "LOCK":
+		oldValue, ok := lockValue(c, headerOldLock)
+		if !ok {
+			return
+		}
+		_, err = database.RelockFileLock(lock.Scope(fp), fp.FileType, fp.Extend, fp.Path, user, oldValue, value, now, now.Add(wopiLockTTL))
+
+	case override == "REFRESH_LOCK":
+		_, err = database.RefreshFileLock(lock.Scope(fp), fp.FileType, fp.Extend, fp.Path, user, value, now, now.Add(wopiLockTTL))
+
+	case override == "UNLOCK":
+		err = database.ReleaseFileLock(lock.Scope(fp), fp.FileType, fp.Extend, fp.Path, user, value)
+	}
+
+	if errors.Is(err, database.ErrFileLockNotHeld) {
+		held, qerr := currentLock(fp)
+		if qerr != nil {
+			lockQueryFailed(c, fp, qerr)
+			return
+		}
+		lockMismatch(c, held, user, "file is not locked with this lock")
+		return
+	}
+	if err != nil {
+		klog.Errorf("[wopi] %s error: %v, path: %s", override, err, fp.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	if state, err := stat(fp); err == nil {
+		c.Header(headerItemVersion, state.Version)
+	}
+	c.Status(consts.StatusOK)
+}
+
+// fetchSyncFile opens a seafile file server download url, as sync Raw
+// returns it, through the loopback.
+func fetchSyncFile(ctx context.Context, uri string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1"+uri, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := wopiFetchClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("file server status %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// stat is stat for the session's file, aborting with 404 when it is
+// missing or not a file.
+func (s *session) stat(c *app.RequestContext) (*fileState, bool) {
+	state, err := stat(s.fp)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, common.ErrIsDirectory) {
+		c.AbortWithStatusJSON(consts.StatusNotFound, utils.H{"error": "file not found"})
+		return nil, false
+	}
+	if err != nil {
+		klog.Errorf("[wopi] stat error: %v, path: %s", err, s.claims.Path)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return nil, false
+	}
+	return state, true
+}
+
+// putRelativeFile saves the body as a new file next to the session's
+// one: under a name the host may change (X-WOPI-SuggestedTarget, an
+// extension alone keeping the current name) or under exactly the given
+// one (X-WOPI-RelativeTarget), replacing an existing file only when
+// asked to.
+func putRelativeFile(ctx context.Context, c *app.RequestContext, s *session) {
+	suggested, relative := string(c.GetHeader(headerSuggestedTarget)), string(c.GetHeader(headerRelativeTarget))
+	if (suggested == "") == (relative == "") {
+		c.AbortWithStatusJSON(consts.StatusNotImplemented, utils.H{"error": "exactly one of " + headerSuggestedTarget + " and " + headerRelativeTarget + " is needed"})
+		return
+	}
+
+	target, err := decodeUTF7(suggested + relative)
+	if err == nil && suggested != "" && strings.HasPrefix(target, ".") {
+		name, _ := s.fp.IsFile()
+		base, _ := common.SplitNameExt(name)
+		target = base + target
+	}
+	if err != nil || !validName(target) {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": "invalid target name"})
+		return
+	}
+
+	var name = target
+	var overwrite bool
+	if suggested != "" {
+		name, err = s.freeName(target)
+	} else {
+		var fp *models.FileParam
+		if _, fp, err = s.relative(target); err == nil {
+			var exists, isDir bool
+			if exists, isDir, err = s.handler.CheckPathExists(fp); err == nil && exists {
+				overwrite = !isDir && strings.EqualFold(string(c.GetHeader(headerOverwriteRelative)), "true")
+				if !overwrite {
+					if free, ferr := s.freeName(target); ferr == nil {
+						c.Header(headerValidRelativeTarget, encodeUTF7(free))
+					}
+					c.AbortWithStatusJSON(consts.StatusConflict, utils.H{"error": "file exists"})
+					return
+				}
+			}
+		}
+	}
+	if err != nil {
+		klog.Errorf("[wopi] relative target error: %v, path: %s, target: %s", err, s.claims.Path, target)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	resource, fp, err := s.relative(name)
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusBadRequest, utils.H{"error": err.Error()})
+		return
+	}
+	level, err := access.CheckAccessParam(ctx, s.claims.Owner, fp)
+	if err != nil || !level.Allow(models.ActionWrite) || !s.level.Allow(models.ActionWrite) {
+		klog.Warningf("[wopi] permission denied: owner=%s, path=%s, level=%v, err=%v", s.claims.Owner, resource, level, err)
+		c.AbortWithStatusJSON(consts.StatusUnauthorized, utils.H{"error": common.ErrorMessagePermissionDenied})
+		return
+	}
+
+	if overwrite {
+		// no lock value matches "", so a replaced file must not be
+		// locked by anyone, the user included
+		if !checkLock(c, fp, s.claims.Owner, "", 0) {
+			return
+		}
+		_, err = s.handler.Edit(s.contextArgs(ctx, fp, c.Request.Body()))
+	} else {
+		_, err = s.handler.Create(s.contextArgs(ctx, fp, c.Request.Body()))
+	}
+	if err != nil {
+		klog.Errorf("[wopi] put relative error: %v, user: %s, path: %s", err, s.claims.Owner, resource)
+		c.AbortWithStatusJSON(consts.StatusInternalServerError, utils.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := access.SignedURLSecret()
+	if err != nil {
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, utils.H{"error": err.Error()})
+		return
+	}
+	if s.claims.Level < level {
+		level = s.claims.Level
+	}
+	token := access.SignWOPIToken(secret, &access.WOPIClaims{
+		Owner:   s.claims.Owner,
+		Path:    resource,
+		Level:   level,
+		Expires: s.claims.Expires,
+	})
+
+	c.JSON(consts.StatusOK, &wopi.PutRelativeFileResp{
+		Name: name,
+		Url:  wopiSrc(c, fileID(fp)) + "?access_token=" + token,
+	})
+}
@@ -0,0 +1,127 @@
+package wopi
+
+import (
+	"testing"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/handler/api/lock"
+	"files/pkg/models"
+
+	lockmodel "files/pkg/hertz/biz/model/api/lock"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUTF7(t *testing.T) {
+	for encoded, want := range map[string]string{
+		"plan.docx":          "plan.docx",
+		"+ZeVnLIqe-.docx":    "日本語.docx",
+		"Hi Mom -+Jjo--!":    "Hi Mom -☺-!",
+		"A+ImIDkQ.":          "A≢Α.",
+		"1 +- 1":             "1 + 1",
+		"+2D3eAA-.xlsx":      "😀.xlsx",
+		"Bericht f+APw-r Q3": "Bericht für Q3",
+	} {
+		got, err := decodeUTF7(encoded)
+		if err != nil || got != want {
+			t.Errorf("decodeUTF7(%q) = %q, %v, want %q", encoded, got, err, want)
+		}
+		if back, err := decodeUTF7(encodeUTF7(want)); err != nil || back != want {
+			t.Errorf("round trip of %q = %q, %v", want, back, err)
+		}
+	}
+
+	for _, bad := range []string{"café", "+Z-"} {
+		if got, err := decodeUTF7(bad); err == nil {
+			t.Errorf("decodeUTF7(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestFileID(t *testing.T) {
+	param := func(owner, fileType, extend, path string) *models.FileParam {
+		return &models.FileParam{Owner: owner, FileType: fileType, Extend: extend, Path: path}
+	}
+
+	// one library file is one office session, whoever opens it
+	if fileID(param("alice", common.Sync, "repo-1", "/a.docx")) != fileID(param("bob", common.Sync, "repo-1", "/a.docx")) {
+		t.Error("sync file id differs between users")
+	}
+	for _, other := range []*models.FileParam{
+		param("bob", common.Drive, "Home", "/a.docx"),
+		param("alice", common.Drive, "Home", "/b.docx"),
+		param("alice", common.Sync, "repo-2", "/a.docx"),
+	} {
+		if fileID(param("alice", common.Drive, "Home", "/a.docx")) == fileID(other) {
+			t.Errorf("file id collides with %+v", other)
+		}
+	}
+}
+
+func newWopiTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open in-memory sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&lockmodel.FileLock{}); err != nil {
+		t.Fatalf("create file_locks: %v", err)
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = saved })
+}
+
+func TestCheckLock(t *testing.T) {
+	newWopiTestDB(t)
+	now := time.Now()
+	param := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Sync, Extend: "repo-1", Path: p}
+	}
+	take := func(fp *models.FileParam, holder, value string) {
+		t.Helper()
+		if _, err := database.AcquireFileLock(&lockmodel.FileLock{
+			Owner:    lock.Scope(fp),
+			FileType: fp.FileType,
+			Extend:   fp.Extend,
+			Path:     fp.Path,
+			Holder:   holder,
+			Token:    value,
+			ExpireAt: database.FormatLockTime(now.Add(wopiLockTTL)),
+		}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	take(param("/Docs/a.docx"), "alice", "office-lock-1")
+	take(param("/Shared/"), "bob", "bob-lock")
+
+	for name, tc := range map[string]struct {
+		path, user, value string
+		size              int64
+		status            int
+		lock              string
+	}{
+		"matching lock":         {path: "/Docs/a.docx", user: "alice", value: "office-lock-1", size: 10, status: 200},
+		"other lock value":      {path: "/Docs/a.docx", user: "alice", value: "office-lock-2", size: 10, status: 409, lock: "office-lock-1"},
+		"locked by alice":       {path: "/Docs/a.docx", user: "carol", value: "office-lock-1", size: 10, status: 409},
+		"unlocked, empty":       {path: "/Docs/new.docx", user: "alice", status: 200},
+		"unlocked, not empty":   {path: "/Docs/b.docx", user: "alice", value: "x", size: 10, status: 409},
+		"folder locked by bob":  {path: "/Shared/c.docx", user: "alice", status: 409},
+		"folder locked by self": {path: "/Shared/c.docx", user: "bob", status: 200},
+	} {
+		c := app.NewContext(0)
+		ok := checkLock(c, param(tc.path), tc.user, tc.value, tc.size)
+		if status := c.Response.StatusCode(); ok != (tc.status == 200) || status != tc.status {
+			t.Errorf("%s: ok %v, status %d, want %d", name, ok, status, tc.status)
+			continue
+		}
+		if got := string(c.Response.Header.Peek(headerLock)); got != tc.lock {
+			t.Errorf("%s: X-WOPI-Lock %q, want %q", name, got, tc.lock)
+		}
+	}
+}
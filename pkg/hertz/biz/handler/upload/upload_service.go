@@ -16,6 +16,7 @@ import (
 	"files/pkg/hertz/biz/handler"
 	upload "files/pkg/hertz/biz/model/upload"
 	"files/pkg/models"
+	"files/pkg/recent"
 	"files/pkg/tasks"
 	"fmt"
 	"io"
@@ -582,6 +583,13 @@ func SyncUploadChunksMethod(ctx context.Context, c *app.RequestContext) {
 			return
 		}
 		seahub.DeleteAccessToken(originalUid)
+
+		if uploadReq.Share == "" {
+			p := "/" + filepath.Join(uploadReq.DriveType, uploadReq.RepoId, strings.Trim(uploadReq.ParentDir, "/"), uploadReq.ResumableRelativePath)
+			if fileParam, err := models.CreateFileParam(owner, p); err == nil {
+				recent.Changed(owner, fileParam)
+			}
+		}
 	} else {
 		result.Items = nil
 		result.Success = new(upload.UploadChunksSuccess)
@@ -11,6 +11,7 @@ import (
 	"files/pkg/models"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/common/utils"
@@ -83,6 +84,17 @@ func RequireInternalShareToken(c *app.RequestContext, tag string) bool {
 	return false
 }
 
+// RequestUser returns the user a request acts for: the share visitor on
+// a share-proxied request, whose owner header names the grantor, and
+// the owner header otherwise.
+func RequestUser(c *app.RequestContext) string {
+	if visitor := string(c.GetHeader(common.HeaderShareVisitor)); visitor != "" &&
+		common.EqualInternalShareToken(string(c.GetHeader(common.HeaderInternalShareToken))) {
+		return visitor
+	}
+	return string(c.GetHeader(common.REQUEST_HEADER_OWNER))
+}
+
 // Gate is the unified authorization check for the file handlers. It
 // resolves fp's owner Level via access.CheckAccessParam and aborts with a
 // 403 when the level does not permit action; tag identifies the caller in
@@ -131,3 +143,26 @@ func ResolveFileHandler(ctx context.Context, c *app.RequestContext, routePrefix
 
 	return contextArg, fileHandler, true
 }
+
+// SetEditState sets the ETag and Last-Modified headers of a file read
+// from a handler whose Edit checks preconditions, so a client can send
+// them back as If-Match or If-Unmodified-Since. Directories and handlers
+// without an edit state get neither.
+func SetEditState(c *app.RequestContext, handler base.Execute, fp *models.FileParam) {
+	stater, ok := handler.(base.EditStater)
+	if !ok || strings.HasSuffix(fp.Path, "/") {
+		return
+	}
+	etag, modTime, err := stater.EditState(fp)
+	if err != nil {
+		klog.Warningf("edit state error: %v, path: %s", err, fp.Path)
+		return
+	}
+	if etag == "" {
+		return
+	}
+	c.Header("Etag", etag)
+	if !modTime.IsZero() {
+		c.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+}
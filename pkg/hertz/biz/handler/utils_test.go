@@ -4,8 +4,10 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"files/pkg/common"
+	"files/pkg/drivers/base"
 	"files/pkg/models"
 
 	"github.com/cloudwego/hertz/pkg/app"
@@ -86,3 +88,44 @@ func TestGate_ShareShortCircuit(t *testing.T) {
 		}
 	})
 }
+
+type editStateHandler struct {
+	base.Execute
+	etag    string
+	modTime time.Time
+}
+
+func (h *editStateHandler) EditState(*models.FileParam) (string, time.Time, error) {
+	return h.etag, h.modTime, nil
+}
+
+// The validators a read hands out are accepted back by Edit's check.
+func TestSetEditStateRoundTrip(t *testing.T) {
+	fp := &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/a.txt"}
+	h := &editStateHandler{etag: `"18a2b3c4d5e6f70012"`, modTime: time.Date(2026, 10, 19, 8, 0, 0, 500, time.Local)}
+
+	c := newReqCtx("", "")
+	SetEditState(c, h, fp)
+	etag := string(c.Response.Header.Peek("Etag"))
+	lastModified := string(c.Response.Header.Peek("Last-Modified"))
+	if etag != h.etag || lastModified == "" {
+		t.Fatalf("Etag = %q, Last-Modified = %q", etag, lastModified)
+	}
+
+	for name, header := range map[string]string{"If-Match": etag, "If-Unmodified-Since": lastModified} {
+		req := http.Header{}
+		req.Set(name, header)
+		if err := models.CheckEditPrecondition(req, h.etag, h.modTime); err != nil {
+			t.Errorf("%s: %q: %v", name, header, err)
+		}
+		if err := models.CheckEditPrecondition(req, `"other"`, h.modTime.Add(time.Second)); err == nil {
+			t.Errorf("%s: %q: accepted after a change", name, header)
+		}
+	}
+
+	c = newReqCtx("", "")
+	SetEditState(c, h, &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/dir/"})
+	if v := c.Response.Header.Peek("Etag"); len(v) != 0 {
+		t.Errorf("directory Etag = %q", v)
+	}
+}
@@ -0,0 +1,32 @@
+// Code generated by hertz generator.
+
+package cache
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _cacheMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _purgecacheMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _getcacheusageMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
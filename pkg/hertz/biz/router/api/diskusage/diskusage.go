@@ -0,0 +1,23 @@
+// Package diskusage registers the /api/disk_usage/:node/ routes.
+package diskusage
+
+import (
+	usagehandler "files/pkg/hertz/biz/handler/api/diskusage"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+)
+
+func Register(r *server.Hertz) {
+	root := r.Group("/", rootMw()...)
+	{
+		api := root.Group("/api", _apiMw()...)
+		{
+			usage := api.Group("/disk_usage", _diskUsageMw()...)
+			{
+				node := usage.Group("/:node", _nodeMw()...)
+				node.POST("/", append(_scanDiskUsageMethodMw(), usagehandler.ScanDiskUsageMethod)...)
+				node.GET("/", append(_getDiskUsageMethodMw(), usagehandler.GetDiskUsageMethod)...)
+			}
+		}
+	}
+}
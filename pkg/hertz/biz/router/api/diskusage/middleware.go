@@ -0,0 +1,14 @@
+package diskusage
+
+import (
+	bizhandler "files/pkg/hertz/biz/handler"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc                 { return nil }
+func _apiMw() []app.HandlerFunc                 { return nil }
+func _diskUsageMw() []app.HandlerFunc           { return nil }
+func _nodeMw() []app.HandlerFunc                { return []app.HandlerFunc{bizhandler.NodeGuard()} }
+func _scanDiskUsageMethodMw() []app.HandlerFunc { return nil }
+func _getDiskUsageMethodMw() []app.HandlerFunc  { return nil }
@@ -0,0 +1,24 @@
+// Package duplicates registers the /api/duplicates/:node/... routes.
+package duplicates
+
+import (
+	duphandler "files/pkg/hertz/biz/handler/api/duplicates"
+
+	"github.com/cloudwego/hertz/pkg/app/server"
+)
+
+func Register(r *server.Hertz) {
+	root := r.Group("/", rootMw()...)
+	{
+		api := root.Group("/api", _apiMw()...)
+		{
+			dup := api.Group("/duplicates", _duplicatesMw()...)
+			{
+				node := dup.Group("/:node", _nodeMw()...)
+				node.POST("/", append(_findDuplicatesMethodMw(), duphandler.FindDuplicatesMethod)...)
+				node.GET("/", append(_getDuplicatesMethodMw(), duphandler.GetDuplicatesMethod)...)
+				node.POST("/resolve", append(_resolveDuplicatesMethodMw(), duphandler.ResolveDuplicatesMethod)...)
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package duplicates
+
+import (
+	bizhandler "files/pkg/hertz/biz/handler"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc                     { return nil }
+func _apiMw() []app.HandlerFunc                     { return nil }
+func _duplicatesMw() []app.HandlerFunc              { return nil }
+func _nodeMw() []app.HandlerFunc                    { return []app.HandlerFunc{bizhandler.NodeGuard()} }
+func _findDuplicatesMethodMw() []app.HandlerFunc    { return nil }
+func _getDuplicatesMethodMw() []app.HandlerFunc     { return nil }
+func _resolveDuplicatesMethodMw() []app.HandlerFunc { return nil }
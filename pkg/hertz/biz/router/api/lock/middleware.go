@@ -0,0 +1,42 @@
+// Code generated by hertz generator.
+
+package lock
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _locksMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _acquirefilelockMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listfilelockMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _refreshfilelockMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _releasefilelockMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
@@ -19,6 +19,10 @@ func Register(r *server.Hertz) {
 	root := r.Group("/", rootMw()...)
 	{
 		_api := root.Group("/api", _apiMw()...)
+		{
+			_hash := _api.Group("/hash", _hashMw()...)
+			_hash.GET("/*path", append(_hashmethodMw(), md5.HashMethod)...)
+		}
 		{
 			_md5 := _api.Group("/md5", _md5Mw()...)
 			_md5.GET("/*path", append(_md5methodMw(), md5.Md5Method)...)
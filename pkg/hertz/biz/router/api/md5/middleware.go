@@ -25,3 +25,13 @@ func _md5methodMw() []app.HandlerFunc {
 	// your code...
 	return nil
 }
+
+func _hashMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _hashmethodMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
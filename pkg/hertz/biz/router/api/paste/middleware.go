@@ -55,8 +55,19 @@ func _gettaskmethodMw() []app.HandlerFunc {
 
 func _nodeMw() []app.HandlerFunc  { return []app.HandlerFunc{bizhandler.NodeGuard()} }
 func _node0Mw() []app.HandlerFunc { return []app.HandlerFunc{bizhandler.NodeGuard()} }
+func _node1Mw() []app.HandlerFunc { return []app.HandlerFunc{bizhandler.NodeGuard()} }
 
 func _pauseresumetaskmethodMw() []app.HandlerFunc {
 	// your code...
 	return nil
 }
+
+func _verifyMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _verifymethodMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
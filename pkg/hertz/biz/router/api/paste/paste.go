@@ -35,5 +35,12 @@ func Register(r *server.Hertz) {
 				_node0.POST("/", append(_pauseresumetaskmethodMw(), paste.PauseResumeTaskMethod)...)
 			}
 		}
+		{
+			_verify := _api.Group("/verify", _verifyMw()...)
+			{
+				_node1 := _verify.Group("/:node", _node1Mw()...)
+				_node1.POST("/", append(_verifymethodMw(), paste.VerifyMethod)...)
+			}
+		}
 	}
 }
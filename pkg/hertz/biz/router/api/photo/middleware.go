@@ -0,0 +1,42 @@
+// Code generated by hertz generator.
+
+package photo
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _photosMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _infoMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _getphotoinfoMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _timelineMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _gettimelineMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
@@ -25,3 +25,23 @@ func _rawmethodMw() []app.HandlerFunc {
 	// your code...
 	return nil
 }
+
+func _signed_rawMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _signedrawmethodMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _signed_urlMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _signrawmethodMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
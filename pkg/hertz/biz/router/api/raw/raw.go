@@ -23,5 +23,13 @@ func Register(r *server.Hertz) {
 			_raw := _api.Group("/raw", _rawMw()...)
 			_raw.GET("/*path", append(_rawmethodMw(), raw.RawMethod)...)
 		}
+		{
+			_signed_raw := _api.Group("/signed_raw", _signed_rawMw()...)
+			_signed_raw.GET("/*path", append(_signedrawmethodMw(), raw.SignedRawMethod)...)
+		}
+		{
+			_signed_url := _api.Group("/signed_url", _signed_urlMw()...)
+			_signed_url.POST("/", append(_signrawmethodMw(), raw.SignRawMethod)...)
+		}
 	}
 }
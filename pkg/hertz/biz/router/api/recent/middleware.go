@@ -0,0 +1,47 @@
+// Code generated by hertz generator.
+
+package recent
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _recentMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _clearrecentMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _changedMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listrecentchangedMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _openedMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listrecentopenedMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
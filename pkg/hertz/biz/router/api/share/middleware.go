@@ -170,3 +170,58 @@ func _updatesharepathmembersMw() []app.HandlerFunc {
 	// your code...
 	return nil
 }
+
+func _activityMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listshareactivityMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _share_groupMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _deletesharegroupMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listsharegroupMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _createsharegroupMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _updatesharegroupMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _share_archiveMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listsharearchiveMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _share_extendMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _extendsharepathMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
@@ -21,6 +21,10 @@ func Register(r *server.Hertz) {
 		_api := root.Group("/api", _apiMw()...)
 		{
 			_share := _api.Group("/share", _shareMw()...)
+			{
+				_activity := _share.Group("/activity", _activityMw()...)
+				_activity.GET("/", append(_listshareactivityMw(), share.ListShareActivity)...)
+			}
 			{
 				_get_share := _share.Group("/get_share", _get_shareMw()...)
 				_get_share.GET("/", append(_getexternalsharepathMw(), share.GetExternalSharePath)...)
@@ -33,6 +37,21 @@ func Register(r *server.Hertz) {
 				_get_token := _share.Group("/get_token", _get_tokenMw()...)
 				_get_token.POST("/", append(_gettokenMw(), share.GetToken)...)
 			}
+			{
+				_share_archive := _share.Group("/share_archive", _share_archiveMw()...)
+				_share_archive.GET("/", append(_listsharearchiveMw(), share.ListShareArchive)...)
+			}
+			{
+				_share_extend := _share.Group("/share_extend", _share_extendMw()...)
+				_share_extend.POST("/", append(_extendsharepathMw(), share.ExtendSharePath)...)
+			}
+			{
+				_share_group := _share.Group("/share_group", _share_groupMw()...)
+				_share_group.DELETE("/", append(_deletesharegroupMw(), share.DeleteShareGroup)...)
+				_share_group.GET("/", append(_listsharegroupMw(), share.ListShareGroup)...)
+				_share_group.POST("/", append(_createsharegroupMw(), share.CreateShareGroup)...)
+				_share_group.PUT("/", append(_updatesharegroupMw(), share.UpdateShareGroup)...)
+			}
 			{
 				_share_member := _share.Group("/share_member", _share_memberMw()...)
 				_share_member.DELETE("/", append(_removesharememberMw(), share.RemoveShareMember)...)
@@ -0,0 +1,52 @@
+// Code generated by hertz generator.
+
+package tag
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _tagsMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listtagsMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _filesMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _getfiletagsMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _setfiletagsMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _itemsMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _listtaggedfilesMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
@@ -0,0 +1,62 @@
+// Code generated by hertz generator.
+
+package wopi
+
+import (
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func rootMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _apiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _wopiMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _filesMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _checkfileinfoMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _fileoperationMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _file_idMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _getfileMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _putfileMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _tokenMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
+
+func _issuewopitokenMw() []app.HandlerFunc {
+	// your code...
+	return nil
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/klog/v2"
+)
+
+// trustedProxiesEnv lists, comma separated, the addresses or CIDRs of the
+// proxies in front of the server whose X-Forwarded-For and X-Real-IP are
+// believed. Any other peer is known by its remote address, so a client
+// cannot name itself another IP: signed URLs are bound to it and share
+// visitors counted by it.
+const trustedProxiesEnv = "TRUSTED_PROXIES"
+
+// defaultTrustedProxies is the ingress proxy sharing the pod's loopback.
+const defaultTrustedProxies = "127.0.0.0/8,::1/128"
+
+// ClientIP resolves the IP of the client of a request, trusting the
+// forwarding headers of the proxies of TRUSTED_PROXIES only.
+func ClientIP() app.ClientIP {
+	return clientIP(os.Getenv(trustedProxiesEnv))
+}
+
+func clientIP(trusted string) app.ClientIP {
+	if strings.TrimSpace(trusted) == "" {
+		trusted = defaultTrustedProxies
+	}
+	var cidrs []*net.IPNet
+	for _, s := range strings.Split(trusted, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, cidr, err := net.ParseCIDR(s)
+		if err != nil {
+			klog.Warningf("[client ip] skip trusted proxy %q: %v", s, err)
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	klog.Infof("[client ip] trusted proxies: %v", cidrs)
+
+	return app.ClientIPWithOption(app.ClientIPOptions{
+		RemoteIPHeaders: []string{"X-Forwarded-For", "X-Real-IP"},
+		TrustedCIDRs:    cidrs,
+	})
+}
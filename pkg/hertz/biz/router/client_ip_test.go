@@ -0,0 +1,53 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/test/mock"
+)
+
+type remoteConn struct {
+	*mock.Conn
+	addr net.Addr
+}
+
+func (c *remoteConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestClientIP(t *testing.T) {
+	resolve := clientIP("10.0.0.5, 192.168.1.0/24")
+	cases := []struct {
+		name, remote, xff, want string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"forged header", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:443", "198.51.100.1", "198.51.100.1"},
+		// the proxy appends the peer it saw, entries before it are the client's
+		{"forged through proxy", "10.0.0.5:443", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"chained proxies", "10.0.0.5:443", "203.0.113.7, 192.168.1.20", "203.0.113.7"},
+	}
+	for _, c := range cases {
+		addr, err := net.ResolveTCPAddr("tcp", c.remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := app.NewContext(0)
+		ctx.SetConn(&remoteConn{Conn: mock.NewConn(""), addr: addr})
+		if c.xff != "" {
+			ctx.Request.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if got := resolve(ctx); got != c.want {
+			t.Errorf("%s: client ip = %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	// by default only the loopback proxy is trusted
+	ctx := app.NewContext(0)
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:8080")
+	ctx.SetConn(&remoteConn{Conn: mock.NewConn(""), addr: addr})
+	ctx.Request.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := clientIP("")(ctx); got != "198.51.100.1" {
+		t.Errorf("loopback proxy: client ip = %s", got)
+	}
+}
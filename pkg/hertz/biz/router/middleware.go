@@ -41,6 +41,9 @@ var (
 		"/api/mounted_states",
 		"/api/smb_history",
 		"/api/search",
+		"/api/signed_raw",
+		"/api/signed_url",
+		"/api/wopi",
 		"/videos/",
 	}
 	syncUploadChunks  = "/seafhttp/"
@@ -139,6 +142,13 @@ func TimingMiddleware() app.HandlerFunc {
 
 func CookieMiddleware() app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
+		// signed raw urls and wopi files are fetched without a session,
+		// so their headers must not replace the owner's cached cookie
+		if p := string(c.Request.Path()); strings.HasPrefix(p, "/api/signed_raw/") || strings.HasPrefix(p, "/api/wopi/files/") {
+			c.Next(ctx)
+			return
+		}
+
 		bflName := string(c.GetHeader(common.REQUEST_HEADER_OWNER))
 		newCookie := string(c.GetHeader("Cookie"))
 
@@ -178,7 +188,7 @@ func ShareMiddleware() app.HandlerFunc {
 			shareAccess.FromShare = true
 		}
 
-		var pasteAction, pasteDst string
+		var pasteAction, pasteDst, pasteConflict string
 		// if Paste, it's Destination
 		var pasteDstParam *models.FileParam
 
@@ -209,6 +219,7 @@ func ShareMiddleware() app.HandlerFunc {
 			}
 
 			pasteAction = req.Action
+			pasteConflict = req.Conflict
 			paramPath = req.Source
 			pasteDst = req.Destination
 
@@ -250,7 +261,7 @@ func ShareMiddleware() app.HandlerFunc {
 		}
 
 		if shareAccess.Paste {
-			proxySharePaste(ctx, c, bflName, pasteAction, shareParam, pasteDstParam)
+			proxySharePaste(ctx, c, bflName, pasteAction, pasteConflict, shareParam, pasteDstParam)
 			return
 		}
 
@@ -404,6 +415,7 @@ func ShareMiddleware() app.HandlerFunc {
 		// downstream handler's Gate trusts the share=1 query. See
 		// pkg/common/internal_auth.go.
 		req.Header.Set(common.HeaderInternalShareToken, common.InternalShareToken())
+		req.Header.Set(common.HeaderShareVisitor, bflName)
 
 		body := c.Request.Body()
 		req.Body = io.NopCloser(bytes.NewReader(body))
@@ -415,6 +427,17 @@ func ShareMiddleware() app.HandlerFunc {
 			return
 		}
 
+		if (shareAccess.Raw || shareAccess.Download) && resp.StatusCode < http.StatusMultipleChoices && access.ShareMetered(shared, shareAccess) {
+			body, err := meterShareRead(c, shared, shareAccess.Download, resp)
+			if err != nil {
+				resp.Body.Close()
+				klog.Errorf("[share] quota charge refused: %v, shareId: %s", err, shared.ID)
+				handler.RespErrorExpired(c, common.CodeLinkExpired, common.ErrorMessageLinkExpired, time.Now().Unix())
+				return
+			}
+			resp.Body = body
+		}
+
 		for k, vv := range resp.Header {
 			for _, v := range vv {
 				c.Header(k, v)
@@ -422,6 +445,16 @@ func ShareMiddleware() app.HandlerFunc {
 		}
 		c.Status(resp.StatusCode)
 
+		// Upload links and progress queries are bookkeeping; the upload
+		// itself is recorded by ShareUpload once its last chunk lands.
+		if !shareAccess.Upload {
+			var sentBytes int64
+			if (shareAccess.Raw || shareAccess.Download) && resp.ContentLength > 0 {
+				sentBytes = resp.ContentLength
+			}
+			recordShareActivity(c, shared, bflName, shareAccess.FromShare, shareAccess.Action(), shareParam.Path, sentBytes, resp.StatusCode)
+		}
+
 		if shareAccess.Raw || shareAccess.Download || shareAccess.Preview {
 			contentLength := int(resp.ContentLength)
 			if contentLength < 0 {
@@ -466,7 +499,7 @@ func checkNonSharedPath(path string) bool {
 	return true
 }
 
-func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, action string, src, dst *models.FileParam) {
+func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, action string, conflict string, src, dst *models.FileParam) {
 	var isSrcShare, isDstShare = src.FileType == common.Share, dst.FileType == common.Share
 
 	klog.Infof("[share] Paste, owner: %s, src: %s, dst: %s", owner, common.ParseString(src), common.ParseString(dst))
@@ -481,6 +514,7 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 
 	var srcDriveParam, dstDriveParam *models.FileParam
 	var srcShareType, dstShareType string
+	var srcShared, dstShared *share.SharePath
 
 	if isSrcShare {
 		shared, err := access.ShareCheckPaste(owner, src.Extend, false)
@@ -498,6 +532,7 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 			return
 		}
 
+		srcShared = shared
 		srcShareType = shared.ShareType
 		srcDriveParam = &models.FileParam{
 			Owner:    shared.Owner,
@@ -530,6 +565,7 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 			return
 		}
 
+		dstShared = shared
 		dstShareType = shared.ShareType
 		dstDriveParam = &models.FileParam{
 			Owner:    shared.Owner,
@@ -557,6 +593,7 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 		DstOwner:     dstDriveParam.Owner,
 		SrcSharePath: fmt.Sprintf("/%s/%s/%s", src.FileType, src.Extend, strings.TrimPrefix(src.Path, "/")),
 		DstSharePath: fmt.Sprintf("/%s/%s/%s", dst.FileType, dst.Extend, strings.TrimPrefix(dst.Path, "/")),
+		Conflict:     conflict,
 	}
 
 	var masterNodeName = global.GlobalNode.GetMasterNode()
@@ -593,6 +630,7 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 	// handler trusts SrcOwner/DstOwner only when this header matches
 	// the process-local secret. See pkg/common/internal_auth.go.
 	req.Header.Set(common.HeaderInternalShareToken, common.InternalShareToken())
+	req.Header.Set(common.HeaderShareVisitor, owner)
 
 	resp, err := shareProxyClient.Do(req)
 	if err != nil {
@@ -607,6 +645,8 @@ func proxySharePaste(ctx context.Context, c *app.RequestContext, owner string, a
 		}
 	}
 	c.Status(resp.StatusCode)
+	recordShareActivity(c, srcShared, owner, false, common.ShareActivityPaste, src.Path, 0, resp.StatusCode)
+	recordShareActivity(c, dstShared, owner, false, common.ShareActivityPaste, dst.Path, 0, resp.StatusCode)
 	bodyRes, _ := io.ReadAll(resp.Body)
 	// see notes above: Hertz response buffer; can't surface err.
 	_, _ = c.Write(bodyRes)
@@ -687,6 +727,23 @@ func ShareUpload() app.HandlerFunc {
 			return
 		}
 
+		uploader, err := shareRequestUploader(ctx, shared, fromShare, &uploadReq, fp)
+		if err == nil && uploader != nil {
+			err = reserveShareUpload(shared, uploader, &uploadReq)
+		}
+		if err != nil {
+			klog.Errorf("[share] uploadChunks file request error: %v, shareId: %s", err, shared.ID)
+			switch {
+			case errors.Is(err, access.ErrShareUploaderRequired):
+				handler.RespForbidden(ctx, err.Error())
+			case errors.Is(err, access.ErrShareRequestFileType), errors.Is(err, access.ErrShareRequestSize):
+				handler.RespBadRequest(ctx, err.Error())
+			default:
+				handler.RespError(ctx, common.ErrorMessageWrongShare)
+			}
+			return
+		}
+
 		mf, err := ctx.MultipartForm()
 		if err != nil {
 			klog.Errorf("Sync uploadChunks, parse multipart error: %v", err)
@@ -744,6 +801,8 @@ func ShareUpload() app.HandlerFunc {
 				}
 			case "driveType":
 				err = createPart(name, []string{shared.FileType}, mw)
+			case "uploader", "uploaderEmail":
+				// only ever set from a file request token below
 			default:
 				err = createPart(name, vals, mw)
 			}
@@ -772,6 +831,12 @@ func ShareUpload() app.HandlerFunc {
 		if err == nil {
 			err = createPart("sharebyPath", []string{uploadReq.ParentDir}, mw)
 		}
+		if err == nil && uploader != nil {
+			err = createPart("uploader", []string{uploader.UploaderName}, mw)
+			if err == nil && uploader.UploaderEmail != "" {
+				err = createPart("uploaderEmail", []string{uploader.UploaderEmail}, mw)
+			}
+		}
 
 		if err != nil {
 			klog.Errorf("Sync uploadChunks, create MultipartForm error: %v", err)
@@ -822,6 +887,14 @@ func ShareUpload() app.HandlerFunc {
 		ctx.Request.Header.Set("Content-Length", strconv.FormatInt(bodySize, 10))
 
 		ctx.Next(c)
+
+		if uploader != nil {
+			settleShareUpload(uploader, &uploadReq, ctx.Response.StatusCode() == http.StatusOK)
+		}
+		if uploadReq.ResumableChunkNumber == uploadReq.ResumableTotalChunks {
+			var uploadPath = strings.TrimSuffix(fp.Path, "/") + "/" + strings.TrimPrefix(uploadReq.ResumableRelativePath, "/")
+			recordShareActivity(ctx, shared, owner, fromShare, common.ShareActivityUpload, uploadPath, uploadReq.ResumableTotalSize, ctx.Response.StatusCode())
+		}
 	}
 
 }
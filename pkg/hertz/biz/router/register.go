@@ -4,20 +4,28 @@ package router
 
 import (
 	api_archive "files/pkg/hertz/biz/router/api/archive"
+	api_cache "files/pkg/hertz/biz/router/api/cache"
+	api_diskusage "files/pkg/hertz/biz/router/api/diskusage"
+	api_duplicates "files/pkg/hertz/biz/router/api/duplicates"
 	api_external "files/pkg/hertz/biz/router/api/external"
+	api_lock "files/pkg/hertz/biz/router/api/lock"
 	api_md5 "files/pkg/hertz/biz/router/api/md5"
 	api_media "files/pkg/hertz/biz/router/api/media"
 	api_nodes "files/pkg/hertz/biz/router/api/nodes"
 	api_paste "files/pkg/hertz/biz/router/api/paste"
 	api_permission "files/pkg/hertz/biz/router/api/permission"
+	api_photo "files/pkg/hertz/biz/router/api/photo"
 	api_preview "files/pkg/hertz/biz/router/api/preview"
 	api_raw "files/pkg/hertz/biz/router/api/raw"
+	api_recent "files/pkg/hertz/biz/router/api/recent"
 	api_repos "files/pkg/hertz/biz/router/api/repos"
 	api_resources "files/pkg/hertz/biz/router/api/resources"
 	api_search "files/pkg/hertz/biz/router/api/search"
 	api_share "files/pkg/hertz/biz/router/api/share"
+	api_tag "files/pkg/hertz/biz/router/api/tag"
 	api_tree "files/pkg/hertz/biz/router/api/tree"
 	api_users "files/pkg/hertz/biz/router/api/users"
+	api_wopi "files/pkg/hertz/biz/router/api/wopi"
 	callback "files/pkg/hertz/biz/router/callback"
 	upload "files/pkg/hertz/biz/router/upload"
 
@@ -27,6 +35,22 @@ import (
 // GeneratedRegister registers routers generated by IDL.
 func GeneratedRegister(r *server.Hertz) {
 	//INSERT_POINT: DO NOT DELETE THIS LINE!
+	api_cache.Register(r)
+
+	api_photo.Register(r)
+
+	api_recent.Register(r)
+
+	api_tag.Register(r)
+
+	api_wopi.Register(r)
+
+	api_lock.Register(r)
+
+	api_diskusage.Register(r)
+
+	api_duplicates.Register(r)
+
 	api_media.Register(r)
 
 	api_search.Register(r)
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/klog/v2"
+)
+
+// Share activity rows are written off the request path: the middleware
+// only queues them, and a single writer inserts them in batches. When
+// the queue is full the row is dropped rather than slowing a download.
+const (
+	shareActivityQueueSize     = 1024
+	shareActivityBatchSize     = 100
+	shareActivityFlushInterval = 2 * time.Second
+	shareActivityPruneInterval = 24 * time.Hour
+	shareActivityRetentionDays = 90
+)
+
+var (
+	shareActivityQueue chan *share.ShareActivity
+	shareActivityOnce  sync.Once
+)
+
+// recordShareActivity queues one access to shared for the activity log.
+// Visitors arriving through a share link are anonymous and identified by
+// their token; everyone else is recorded by name.
+func recordShareActivity(c *app.RequestContext, shared *share.SharePath, visitor string, fromShare bool, action, path string, bytes int64, status int) {
+	if database.DB == nil || shared == nil || action == "" {
+		return
+	}
+
+	var activity = &share.ShareActivity{
+		PathID: shared.ID,
+		IP:     c.ClientIP(),
+		Action: action,
+		Path:   path,
+		Bytes:  bytes,
+		Status: int32(status),
+	}
+	if !fromShare {
+		activity.Member = visitor
+	}
+	if shared.ShareType == common.ShareTypeExternal {
+		activity.Token = c.Query("token")
+	}
+
+	shareActivityOnce.Do(func() {
+		shareActivityQueue = make(chan *share.ShareActivity, shareActivityQueueSize)
+		go runShareActivityWriter()
+	})
+
+	select {
+	case shareActivityQueue <- activity:
+	default:
+		klog.Warningf("[share] activity queue full, drop %s on share %s", action, shared.ID)
+	}
+}
+
+func runShareActivityWriter() {
+	var flush = time.NewTicker(shareActivityFlushInterval)
+	defer flush.Stop()
+	var prune = time.NewTicker(shareActivityPruneInterval)
+	defer prune.Stop()
+
+	var batch []*share.ShareActivity
+	var write = func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := database.CreateShareActivity(batch, database.DB); err != nil {
+			klog.Errorf("[share] write %d activity rows error: %v", len(batch), err)
+		}
+		batch = nil
+	}
+
+	pruneShareActivity()
+
+	for {
+		select {
+		case a := <-shareActivityQueue:
+			batch = append(batch, a)
+			if len(batch) >= shareActivityBatchSize {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case <-prune.C:
+			pruneShareActivity()
+		}
+	}
+}
+
+// pruneShareActivity applies SHARE_ACTIVITY_RETENTION_DAYS (default 90);
+// zero or a negative value keeps the log forever.
+func pruneShareActivity() {
+	var days = shareActivityRetentionDays
+	if v := os.Getenv("SHARE_ACTIVITY_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			klog.Warningf("[share] invalid SHARE_ACTIVITY_RETENTION_DAYS %q, using %d", v, days)
+		} else {
+			days = n
+		}
+	}
+	if days <= 0 {
+		return
+	}
+
+	removed, err := database.PruneShareActivity(time.Now().AddDate(0, 0, -days), database.DB)
+	if err != nil {
+		klog.Errorf("[share] prune activity error: %v", err)
+		return
+	}
+	if removed > 0 {
+		klog.Infof("[share] pruned %d activity rows older than %d days", removed, days)
+	}
+}
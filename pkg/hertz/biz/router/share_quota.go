@@ -0,0 +1,196 @@
+package router
+
+import (
+	"files/pkg/access"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/klog/v2"
+)
+
+// meterShareRead charges a raw read of an external link before it is
+// streamed. A known length is charged up front, so concurrent readers
+// cannot overrun the byte limit together; otherwise the download is
+// charged at once and the stream is cut at the remaining byte quota and
+// charged for what it actually sent when it closes.
+func meterShareRead(c *app.RequestContext, shared *share.SharePath, download bool, resp *http.Response) (io.ReadCloser, error) {
+	var token = strings.TrimSpace(c.Query("token"))
+	var visitor = shareDownloadKey(shared, token, c.ClientIP())
+	var now = time.Now()
+	download = download && countsAsDownload(visitor, string(c.GetHeader("Range")), now)
+
+	if resp.ContentLength >= 0 {
+		if err := access.ShareChargeQuota(shared, token, download, resp.ContentLength); err != nil {
+			return nil, err
+		}
+		if download {
+			markShareDownload(visitor, now)
+		}
+		return resp.Body, nil
+	}
+
+	left, err := access.ShareBytesRemaining(shared, token)
+	if err != nil {
+		return nil, err
+	}
+	if err = access.ShareChargeQuota(shared, token, download, 0); err != nil {
+		return nil, err
+	}
+	if download {
+		markShareDownload(visitor, now)
+	}
+
+	return &meteredBody{
+		body:  resp.Body,
+		limit: left,
+		charge: func(n int64) {
+			if err := database.AddShareBytesServed(shared.ID, token, n); err != nil {
+				klog.Errorf("[share] charge %d bytes to share %s error: %v", n, shared.ID, err)
+			}
+		},
+	}, nil
+}
+
+// shareDownloadWindow is how long after a download was counted a ranged
+// read of the same visitor resumes it instead of counting anew.
+const shareDownloadWindow = time.Hour
+
+// shareDownloads are when a download was last counted, by share, token
+// and client IP.
+var shareDownloads = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+func shareDownloadKey(shared *share.SharePath, token, ip string) string {
+	return shared.ID + "/" + token + "/" + ip
+}
+
+// countsAsDownload reports whether a read by visitor with rangeHeader is
+// a new download: anything but a range past the first byte of a file
+// whose download was counted for visitor within shareDownloadWindow.
+func countsAsDownload(visitor, rangeHeader string, now time.Time) bool {
+	shareDownloads.Lock()
+	defer shareDownloads.Unlock()
+	for k, at := range shareDownloads.m {
+		if now.Sub(at) > shareDownloadWindow {
+			delete(shareDownloads.m, k)
+		}
+	}
+	if !isRangeContinuation(rangeHeader) {
+		return true
+	}
+	_, ok := shareDownloads.m[visitor]
+	return !ok
+}
+
+func markShareDownload(visitor string, now time.Time) {
+	shareDownloads.Lock()
+	defer shareDownloads.Unlock()
+	shareDownloads.m[visitor] = now
+}
+
+// isRangeContinuation reports whether a Range header asks only for
+// bytes past the first of the file. A header that does not parse is
+// ignored by the server, which then sends the whole file.
+func isRangeContinuation(rangeHeader string) bool {
+	start, ok := rangeStart(rangeHeader)
+	return ok && start > 0
+}
+
+// rangeStart parses a Range header of byte ranges and returns the lowest
+// offset its ranges start at. A suffix range may cover the whole file,
+// so it starts at 0.
+func rangeStart(rangeHeader string) (int64, bool) {
+	unit, set, found := strings.Cut(rangeHeader, "=")
+	if !found || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return 0, false
+	}
+
+	var start int64 = -1
+	for _, spec := range strings.Split(set, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		first, last, found := strings.Cut(spec, "-")
+		if !found || (first == "" && last == "") {
+			return 0, false
+		}
+		if first == "" {
+			if !isDigits(last) {
+				return 0, false
+			}
+			start = 0
+			continue
+		}
+		if !isDigits(first) || (last != "" && !isDigits(last)) {
+			return 0, false
+		}
+		from, err := strconv.ParseInt(first, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		if last != "" {
+			to, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || to < from {
+				return 0, false
+			}
+		}
+		if start < 0 || from < start {
+			start = from
+		}
+	}
+	if start < 0 {
+		return 0, false
+	}
+	return start, true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// meteredBody counts what is read from body, stops at limit when it is
+// not negative, and hands the count to charge once on Close.
+type meteredBody struct {
+	body   io.ReadCloser
+	limit  int64
+	read   int64
+	closed bool
+	charge func(n int64)
+}
+
+func (m *meteredBody) Read(p []byte) (int, error) {
+	if m.limit >= 0 {
+		if m.read >= m.limit {
+			return 0, io.EOF
+		}
+		if left := m.limit - m.read; int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := m.body.Read(p)
+	m.read += int64(n)
+	return n, err
+}
+
+func (m *meteredBody) Close() error {
+	if !m.closed {
+		m.closed = true
+		m.charge(m.read)
+	}
+	return m.body.Close()
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeteredBody(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit int64
+		want  string
+	}{
+		{name: "unlimited", limit: -1, want: "0123456789"},
+		{name: "cut at limit", limit: 4, want: "0123"},
+		{name: "nothing left", limit: 0, want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var charged []int64
+			m := &meteredBody{
+				body:   io.NopCloser(strings.NewReader("0123456789")),
+				limit:  tc.limit,
+				charge: func(n int64) { charged = append(charged, n) },
+			}
+			got, err := io.ReadAll(m)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("read %q, want %q", got, tc.want)
+			}
+			_ = m.Close()
+			_ = m.Close()
+			if len(charged) != 1 || charged[0] != int64(len(tc.want)) {
+				t.Fatalf("charged %v, want [%d]", charged, len(tc.want))
+			}
+		})
+	}
+}
+
+func TestIsRangeContinuation(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                 false,
+		"bytes=0-":         false,
+		"bytes=0-1023":     false,
+		"bytes=00-":        false,
+		"bytes= 0-":        false,
+		"bytes=0-,":        false,
+		"bytes=-500":       false,
+		"bytes=1-,0-":      false,
+		"bytes=500-,-10":   false,
+		"bytes=+1-":        false,
+		"bytes=5-3":        false,
+		"bytes=1 -":        false,
+		"items=1024-":      false,
+		"bytes=1-":         true,
+		"bytes=1024-":      true,
+		"BYTES=10-":        true,
+		" bytes=500-999 ":  true,
+		"bytes=500-, 900-": true,
+	} {
+		if got := isRangeContinuation(header); got != want {
+			t.Errorf("isRangeContinuation(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestCountsAsDownload(t *testing.T) {
+	var now = time.Now()
+	var visitor = "share/token/10.0.0.1"
+	defer func() {
+		shareDownloads.Lock()
+		delete(shareDownloads.m, visitor)
+		shareDownloads.Unlock()
+	}()
+
+	if !countsAsDownload(visitor, "bytes=1-", now) {
+		t.Fatal("a range with no download counted before must count")
+	}
+	markShareDownload(visitor, now)
+
+	if countsAsDownload(visitor, "bytes=1024-", now.Add(time.Minute)) {
+		t.Error("resuming a counted download must not count")
+	}
+	if !countsAsDownload(visitor, "bytes=0-", now.Add(time.Minute)) {
+		t.Error("a read from the first byte must count")
+	}
+	if !countsAsDownload("share/token/10.0.0.2", "bytes=1024-", now.Add(time.Minute)) {
+		t.Error("another visitor's range must count")
+	}
+	if !countsAsDownload(visitor, "bytes=1024-", now.Add(shareDownloadWindow+time.Minute)) {
+		t.Error("a range after the window must count")
+	}
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"files/pkg/access"
+	"files/pkg/common"
+	"files/pkg/files"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"files/pkg/hertz/biz/model/upload"
+	"files/pkg/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/klog/v2"
+)
+
+// shareRequestUploader applies a file request to one upload chunk: it
+// checks the uploader's restrictions, creates their folder under the
+// share, and moves fp into it. It returns the uploader's token, or nil
+// when the upload is not made through a file request link.
+func shareRequestUploader(ctx *app.RequestContext, shared *share.SharePath, fromShare bool, uploadReq *upload.UploadChunksReq, fp *models.FileParam) (*share.ShareToken, error) {
+	if !fromShare || !access.IsShareRequest(shared) {
+		return nil, nil
+	}
+
+	token, err := database.QueryShareExternalById(shared.ID, strings.TrimSpace(ctx.Query("token")))
+	if err != nil {
+		return nil, err
+	}
+	var filename = uploadReq.ResumableFilename
+	if filename == "" {
+		filename = filepath.Base(uploadReq.ResumableRelativePath)
+	}
+	if err = access.ShareRequestCheckUpload(shared, token, filename, uploadReq.ResumableTotalSize); err != nil {
+		return nil, err
+	}
+
+	folder := access.ShareRequestFolder(token.UploaderName, token.UploaderEmail)
+	root := &models.FileParam{Owner: shared.Owner, FileType: shared.FileType, Extend: shared.Extend, Path: shared.Path}
+	uri, err := root.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(uri, shared.Path, folder)
+	if !common.PathExists(dir) {
+		if err = files.MkdirAllWithChown(nil, dir, os.ModePerm, false, -1, -1); err != nil {
+			klog.Errorf("[share] create uploader folder %s error: %v", dir, err)
+			return nil, err
+		}
+	}
+
+	fp.Path = "/" + folder + "/" + strings.TrimPrefix(fp.Path, "/")
+	return token, nil
+}
+
+// shareUploadIdle is how long an upload through a file request may go
+// without a chunk before its reservation is given back.
+const shareUploadIdle = time.Hour
+
+// shareUploadReservation is the budget an upload in flight through a
+// file request holds.
+type shareUploadReservation struct {
+	token *share.ShareToken
+	bytes int64
+	seen  time.Time
+}
+
+// shareUploads are the reservations of the uploads in flight, by token
+// and upload identifier.
+var shareUploads = struct {
+	sync.Mutex
+	m map[string]*shareUploadReservation
+}{m: make(map[string]*shareUploadReservation)}
+
+func shareUploadKey(token *share.ShareToken, uploadReq *upload.UploadChunksReq) string {
+	return fmt.Sprintf("%d/%s/%s", token.ID, uploadReq.ResumableIdentifier, uploadReq.ResumableRelativePath)
+}
+
+// reserveShareUpload holds the size of the upload uploadReq is a chunk
+// of against the uploader's budget, on its first chunk to arrive. The
+// reservations of uploads left idle are given back meanwhile.
+func reserveShareUpload(shared *share.SharePath, token *share.ShareToken, uploadReq *upload.UploadChunksReq) error {
+	var key = shareUploadKey(token, uploadReq)
+	var now = time.Now()
+
+	shareUploads.Lock()
+	defer shareUploads.Unlock()
+	for k, r := range shareUploads.m {
+		if now.Sub(r.seen) > shareUploadIdle {
+			delete(shareUploads.m, k)
+			releaseShareReservation(r)
+		}
+	}
+	if r, ok := shareUploads.m[key]; ok {
+		r.seen = now
+		return nil
+	}
+	if err := access.ShareRequestReserve(shared, token, uploadReq.ResumableTotalSize); err != nil {
+		return err
+	}
+	shareUploads.m[key] = &shareUploadReservation{token: token, bytes: uploadReq.ResumableTotalSize, seen: now}
+	return nil
+}
+
+// settleShareUpload ends the reservation of an upload once a chunk was
+// handled: a failed chunk aborts the upload and gives its bytes back,
+// its last chunk landing keeps them charged.
+func settleShareUpload(token *share.ShareToken, uploadReq *upload.UploadChunksReq, ok bool) {
+	if ok && uploadReq.ResumableChunkNumber != uploadReq.ResumableTotalChunks {
+		return
+	}
+	var key = shareUploadKey(token, uploadReq)
+
+	shareUploads.Lock()
+	defer shareUploads.Unlock()
+	r, found := shareUploads.m[key]
+	if !found {
+		return
+	}
+	delete(shareUploads.m, key)
+	if !ok {
+		releaseShareReservation(r)
+	}
+}
+
+func releaseShareReservation(r *shareUploadReservation) {
+	if err := access.ShareRequestRelease(r.token, r.bytes); err != nil {
+		klog.Errorf("[share] release %d reserved bytes of token %d error: %v", r.bytes, r.token.ID, err)
+	}
+}
@@ -14,3 +14,12 @@ func FormatSmbLink(fileType string, extend string, smbName string) string {
 	var masterNodeName = global.GlobalNode.GetMasterNode()
 	return fmt.Sprintf("smb://%s/%s", global.GlobalNode.GetNodeIp(masterNodeName), smbName)
 }
+
+func FormatNfsMount(fileType string, extend string, exportPath string) string {
+	if fileType == common.External || fileType == common.Cache {
+		return fmt.Sprintf("%s:%s", global.GlobalNode.GetNodeIp(extend), exportPath)
+	}
+
+	var masterNodeName = global.GlobalNode.GetMasterNode()
+	return fmt.Sprintf("%s:%s", global.GlobalNode.GetNodeIp(masterNodeName), exportPath)
+}
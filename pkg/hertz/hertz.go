@@ -59,6 +59,7 @@ func HertzServer(cfg *common.Server, coord *lifecycle.Coordinator) {
 		server.WithExitWaitTime(hertzExitWait),
 	)
 
+	h.SetClientIPFunc(router.ClientIP())
 	h.Use(router.Options())
 	h.Use(router.Cors())
 	h.Use(router.TimingMiddleware())
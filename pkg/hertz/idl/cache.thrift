@@ -0,0 +1,41 @@
+namespace go api.cache
+
+// api models
+struct CacheOwnerUsage {
+    1: required string owner
+    2: required i64 bytes
+    3: required i32 entries
+    /* bytes by tag: thumb, metadata */
+    4: required map<string, i64> tags
+}
+
+/* the preview cache of the node serving the request */
+struct GetCacheUsageReq {
+}
+
+struct GetCacheUsageResp {
+    1: required string node
+    /* the byte budget, 0 when eviction is off */
+    2: required i64 limit
+    3: required i64 bytes
+    4: required i32 entries
+    /* false until the entries stored before the start are counted */
+    5: required bool scanned
+    6: required list<CacheOwnerUsage> owners
+}
+
+/* owner and tag narrow the purge, empty for all */
+struct PurgeCacheReq {
+    1: string owner (api.query="owner");
+    2: string tag (api.query="tag");
+}
+
+struct PurgeCacheResp {
+    1: required i32 entries
+    2: required i64 bytes
+}
+
+service CacheService {
+    GetCacheUsageResp GetCacheUsage(1: GetCacheUsageReq request) (api.get="/api/cache/");
+    PurgeCacheResp PurgeCache(1: PurgeCacheReq request) (api.delete="/api/cache/");
+}
@@ -0,0 +1,25 @@
+namespace go api.diskusage
+
+struct ScanDiskUsageReq {
+    1: required string Path (api.body="path");
+    2: bool Refresh         (api.body="refresh");
+}
+
+struct ScanDiskUsageResp {
+    1: required i32 code,
+    2: string message,
+    3: string task_id
+}
+
+struct GetDiskUsageReq {
+    1: required string Path (api.query="path");
+    2: i32 Top              (api.query="top");
+}
+
+struct GetDiskUsageResp {
+}
+
+service DiskUsageService {
+    ScanDiskUsageResp ScanDiskUsageMethod (1: ScanDiskUsageReq r) (api.post="/api/disk_usage/:node/");
+    GetDiskUsageResp  GetDiskUsageMethod  (1: GetDiskUsageReq r)  (api.get="/api/disk_usage/:node/");
+}
@@ -0,0 +1,35 @@
+namespace go api.duplicates
+
+struct FindDuplicatesReq {
+    1: required list<string> Roots (api.body="roots");
+    2: i64 MinSize                 (api.body="minSize");
+}
+
+struct FindDuplicatesResp {
+    1: required i32 code,
+    2: string message,
+    3: string task_id
+}
+
+struct GetDuplicatesReq {
+    1: required string TaskId (api.query="task_id");
+}
+
+struct GetDuplicatesResp {
+}
+
+struct ResolveDuplicatesReq {
+    1: required string TaskId      (api.body="task_id");
+    2: required string Mode        (api.body="mode");
+    3: required string Keep        (api.body="keep");
+    4: required list<string> Files (api.body="files");
+}
+
+struct ResolveDuplicatesResp {
+}
+
+service DuplicatesService {
+    FindDuplicatesResp    FindDuplicatesMethod    (1: FindDuplicatesReq r)    (api.post="/api/duplicates/:node/");
+    GetDuplicatesResp     GetDuplicatesMethod     (1: GetDuplicatesReq r)     (api.get="/api/duplicates/:node/");
+    ResolveDuplicatesResp ResolveDuplicatesMethod (1: ResolveDuplicatesReq r) (api.post="/api/duplicates/:node/resolve");
+}
@@ -0,0 +1,59 @@
+namespace go api.lock
+
+// gorm models
+struct FileLock {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    /* owner of the storage, empty for storages all users see alike (sync, external, drive/Common) */
+    2: string owner (go.tag = 'gorm:"column:owner;type:text;not null;uniqueIndex:idx_file_lock_path"')
+    3: required string file_type (go.tag = 'gorm:"column:file_type;type:varchar(10);not null;uniqueIndex:idx_file_lock_path"')
+    4: required string extend (go.tag = 'gorm:"column:extend;type:text;not null;uniqueIndex:idx_file_lock_path"')
+    /* a folder lock ends with a slash and covers everything below it */
+    5: required string path (go.tag = 'gorm:"column:path;type:text;not null;uniqueIndex:idx_file_lock_path"')
+    /* the user holding the lock */
+    6: required string holder (go.tag = 'gorm:"column:holder;type:text;not null"')
+    /* proof of holding, needed to refresh or release */
+    7: required string token (go.tag = 'gorm:"column:token;type:text;not null"')
+    8: required string expire_at (go.tag = 'gorm:"column:expire_at;type:timestamptz;not null;index:idx_file_lock_expire"')
+    9: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime:milli"')
+}
+
+// api models
+struct ViewFileLock {
+    1: required string path
+    2: required string holder
+    3: required string expire_at
+    /* only returned to the holder */
+    4: optional string token (go.tag = 'json:"token,omitempty"')
+}
+
+struct ListFileLockReq {
+}
+
+struct ListFileLockResp {
+    1: list<ViewFileLock> locks;
+}
+
+struct AcquireFileLockReq {
+    /* seconds, default 300, at most 3600 */
+    1: i64 ttl (api.body="ttl", api.vd="$>=0");
+}
+
+struct RefreshFileLockReq {
+    1: required string token (api.body="token", api.vd="len($)>0");
+    2: i64 ttl (api.body="ttl", api.vd="$>=0");
+}
+
+struct ReleaseFileLockReq {
+    1: required string token (api.query="token", api.vd="len($)>0");
+}
+
+struct ReleaseFileLockResp {
+    1: bool success;
+}
+
+service LockService {
+    ListFileLockResp ListFileLock(1: ListFileLockReq request) (api.get="/api/locks/*path");
+    ViewFileLock AcquireFileLock(1: AcquireFileLockReq request) (api.post="/api/locks/*path");
+    ViewFileLock RefreshFileLock(1: RefreshFileLockReq request) (api.put="/api/locks/*path");
+    ReleaseFileLockResp ReleaseFileLock(1: ReleaseFileLockReq request) (api.delete="/api/locks/*path");
+}
@@ -4,6 +4,19 @@ struct Md5Resp {
     1: required string md5;
 }
 
+struct HashReq {
+    1: string Algo (api.query="algo");
+}
+
+struct HashResp {
+    1: required string algo;
+    2: optional string hash;
+    3: i64 size;
+    4: bool cached;
+    5: optional string task_id;
+}
+
 service Md5Service {
     Md5Resp Md5Method() (api.get="/api/md5/*path");
+    HashResp HashMethod(1: HashReq request) (api.get="/api/hash/*path");
 }
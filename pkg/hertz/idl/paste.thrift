@@ -11,6 +11,8 @@ struct PasteReq {
     8: string DstOwner (api.body="dstOwner");
     9: string SrcSharePath (api.body="srcSharePath");
     10: string DstSharePath (api.body="dstSharePath");
+    11: bool Verify (api.body="verify");
+    12: string Conflict (api.body="conflict");
 }
 
 struct PasteResp {
@@ -23,6 +25,32 @@ struct GetTaskReq {
     3: optional i32 LogView (api.query="log_view");
 }
 
+struct VerifyReport {
+    1: string algo,
+    2: i32 checked,
+    3: i32 matched,
+    4: list<string> missing (go.tag='json:"missing,omitempty"'),
+    5: list<string> extra (go.tag='json:"extra,omitempty"'),
+    6: list<string> different (go.tag='json:"different,omitempty"'),
+    7: string skipped (go.tag='json:"skipped,omitempty"'),
+    8: bool ok
+}
+
+struct HashResult {
+    1: string algo,
+    2: string hash
+}
+
+struct PasteConflict {
+    1: string name,
+    2: string dst,
+    3: bool is_dir,
+    4: i64 src_size,
+    5: i64 src_mtime,
+    6: i64 dst_size,
+    7: i64 dst_mtime
+}
+
 struct TaskInfo {
     1: string id,
     2: string action,
@@ -39,7 +67,10 @@ struct TaskInfo {
     13: bool tidy_dirs,
     14: string status,
     15: string failed_reason,
-    16: bool pause_able
+    16: bool pause_able,
+    17: optional VerifyReport verify
+    18: optional HashResult hash
+    19: optional PasteConflict conflict
 }
 
 struct GetTaskResp {
@@ -60,9 +91,20 @@ struct DeleteTaskResp {
     2: optional string msg
 }
 
+struct VerifyReq {
+    1: required string Source (api.body="source");
+    2: required string Destination (api.body="destination");
+}
+
+struct VerifyResp {
+    1: string task_id
+}
+
 struct PauseResumeTaskReq {
     1: string TaskId (api.query="task_id");
     2: string Op (api.query="op");
+    3: string Resolution (api.query="resolution");
+    4: i32 ApplyAll (api.query="apply_all");
 }
 
 struct PauseResumeTaskResp {
@@ -75,4 +117,5 @@ service PasteService {
     GetTaskResp GetTaskMethod(1: GetTaskReq request) (api.get="/api/task/:node/");
     DeleteTaskResp DeleteTaskMethod(1: DeleteTaskReq request) (api.delete="/api/task/:node/");
     PauseResumeTaskResp PauseResumeTaskMethod(1: PauseResumeTaskReq request) (api.post="/api/task/:node/");
+    VerifyResp VerifyMethod(1: VerifyReq request) (api.post="/api/verify/:node/");
 }
@@ -0,0 +1,83 @@
+namespace go api.photo
+
+// Photos are read from drive, cache and external storages, whose files
+// the server reads directly.
+
+// api models
+struct PhotoLocation {
+    1: required double latitude
+    2: required double longitude
+    /* meters above sea level */
+    3: i32 altitude
+}
+
+struct PhotoInfo {
+    1: required string file_type
+    2: required string extend
+    3: required string path
+    /* /<file_type>/<extend><path>, as the resources API takes it */
+    4: required string uri
+    5: required string name
+    6: required i64 size
+    /* unix milliseconds */
+    7: required i64 modified
+    8: string format
+    9: i32 width
+    10: i32 height
+    /* EXIF orientation, 1 to 8, width and height are before it applies */
+    11: i32 orientation
+    /* the camera's clock, RFC 3339 when it recorded its time zone */
+    12: string taken_at
+    13: string make
+    14: string model
+    15: string lens
+    16: string exposure_time
+    17: double f_number
+    18: i32 iso
+    19: double focal_length
+    20: optional PhotoLocation location
+}
+
+struct GetPhotoInfoReq {
+}
+
+/* path is repeated, one folder URI each, at most 20 */
+struct GetTimelineReq {
+    1: list<string> path (api.query="path");
+    /* day or month, day when left out */
+    2: string group (api.query="group");
+}
+
+struct TimelinePhoto {
+    1: required string uri
+    2: required string name
+    /* taken_at, or the modification time when the photo has none */
+    3: required string time
+    4: required bool captured
+    5: i32 width
+    6: i32 height
+    7: optional PhotoLocation location
+}
+
+/* the photos of one day or month taken around one place, or without a
+   location */
+struct TimelineGroup {
+    1: required string period
+    /* the center of the photos of the group */
+    2: optional PhotoLocation location
+    3: required i32 count
+    4: list<TimelinePhoto> photos
+}
+
+struct GetTimelineResp {
+    1: list<TimelineGroup> groups
+    /* more than 5000 photos were found, the most recently modified were kept */
+    2: bool truncated
+    /* some metadata was not read in time, those photos are placed by their modification time */
+    3: bool partial
+}
+
+service PhotoService {
+    PhotoInfo GetPhotoInfo(1: GetPhotoInfoReq request) (api.get="/api/photos/info/*path");
+    GetTimelineResp GetTimeline(1: GetTimelineReq request) (api.get="/api/photos/timeline/");
+}
@@ -6,6 +6,11 @@ struct PreviewReq {
     3: string Key (api.query="key");
     4: optional string Size (api.query="size");
     5: optional string Thumb (api.query="thumb");
+    6: optional string View (api.query="view");
+    7: optional string Lines (api.query="lines");
+    8: optional string Encoding (api.query="encoding");
+    9: optional string Page (api.query="page");
+    10: optional string Limit (api.query="limit");
 }
 
 struct PreviewResp {
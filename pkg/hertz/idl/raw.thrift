@@ -11,6 +11,30 @@ struct RawReq {
 struct RawResp {
 }
 
+struct SignRawReq {
+    1: required string Path (api.body="path", api.vd="len($)>0");
+    /* seconds, default 3600, at most 7 days */
+    2: i64 ExpiresIn (api.body="expires_in", api.vd="$>=0");
+    /* only this client ip may fetch the url */
+    3: string IP (api.body="ip");
+    4: string Disposition (api.body="disposition", api.vd="($ == ''||$ == 'inline'||$ == 'attachment')");
+}
+
+struct SignRawResp {
+    1: string url
+    2: string expires_at
+}
+
+struct SignedRawReq {
+    1: required string Owner (api.query="owner", api.vd="len($)>0");
+    2: required i64 Expires (api.query="expires");
+    3: string IP (api.query="ip");
+    4: string Disposition (api.query="disposition");
+    5: required string Sig (api.query="sig", api.vd="len($)>0");
+}
+
 service RawService {
     RawResp RawMethod(1: RawReq request) (api.get="/api/raw/*path");
+    SignRawResp SignRawMethod(1: SignRawReq request) (api.post="/api/signed_url/");
+    RawResp SignedRawMethod(1: SignedRawReq request) (api.get="/api/signed_raw/*path");
 }
\ No newline at end of file
@@ -0,0 +1,57 @@
+namespace go api.recent
+
+// gorm models
+struct RecentFile {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    /* the user who opened or changed the file, the feed is personal */
+    2: required string user_name (go.tag = 'gorm:"column:user_name;type:text;not null;uniqueIndex:idx_recent_file_path;index:idx_recent_file_time"')
+    /* opened or changed */
+    3: required string kind (go.tag = 'gorm:"column:kind;type:varchar(16);not null;uniqueIndex:idx_recent_file_path;index:idx_recent_file_time"')
+    /* owner of the storage, empty for storages all users see alike (sync, external, drive/Common) */
+    4: string owner (go.tag = 'gorm:"column:owner;type:text;not null;uniqueIndex:idx_recent_file_path"')
+    5: required string file_type (go.tag = 'gorm:"column:file_type;type:varchar(10);not null;uniqueIndex:idx_recent_file_path"')
+    6: required string extend (go.tag = 'gorm:"column:extend;type:text;not null;uniqueIndex:idx_recent_file_path"')
+    /* a folder ends with a slash */
+    7: required string path (go.tag = 'gorm:"column:path;type:text;not null;uniqueIndex:idx_recent_file_path"')
+    /* unix milliseconds of the latest access */
+    8: required i64 access_time (go.tag = 'gorm:"column:access_time;not null;index:idx_recent_file_time"')
+}
+
+// api models
+struct ViewRecentFile {
+    1: required string file_type
+    2: required string extend
+    3: required string path
+    /* /<file_type>/<extend><path>, as the resources API takes it */
+    4: required string uri
+    5: required string name
+    6: required bool is_dir
+    7: required string kind
+    /* unix milliseconds */
+    8: required i64 time
+}
+
+/* storage is one of drive, sync, cache, external and cloud, empty for all */
+struct ListRecentReq {
+    1: string storage (api.query="storage");
+    /* at most 500, 50 when left out */
+    2: i32 limit (api.query="limit");
+}
+
+struct ListRecentResp {
+    1: list<ViewRecentFile> items;
+}
+
+/* kind is opened or changed, empty clears both */
+struct ClearRecentReq {
+    1: string kind (api.query="kind");
+}
+
+struct ClearRecentResp {
+}
+
+service RecentService {
+    ListRecentResp ListRecentOpened(1: ListRecentReq request) (api.get="/api/recent/opened/");
+    ListRecentResp ListRecentChanged(1: ListRecentReq request) (api.get="/api/recent/changed/");
+    ClearRecentResp ClearRecent(1: ClearRecentReq request) (api.delete="/api/recent/");
+}
@@ -33,6 +33,7 @@ struct DeleteResourcesResp {
 }
 
 service ResourcesService {
+    /* limit, cursor, name, kind, modified_after, modified_before, sort and order page and filter a folder listing */
     GetResourcesResp GetResourcesMethod() (api.get="/api/resources/*path");
     PostResourcesResp PostResourcesMethod(PostResourcesReq request) (api.post="/api/resources/*path");
     PatchResourcesResp PatchResourcesMethod(1: PatchResourcesReq request) (api.patch="/api/resources/*path");
@@ -18,6 +18,38 @@ struct SharePath {
     13: i64 upload_size_limit (go.tag = 'gorm:"column:upload_size_limit"')
     14: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime:milli"')
     15: required string update_time (go.tag = 'gorm:"column:update_time;type:timestamptz;not null;autoUpdateTime:milli"')
+    /* external link quotas, 0 means unlimited */
+    16: i64 max_downloads (go.tag = 'gorm:"column:max_downloads;not null;default:0"')
+    17: i64 max_bytes (go.tag = 'gorm:"column:max_bytes;not null;default:0"')
+    18: i64 max_visitors (go.tag = 'gorm:"column:max_visitors;not null;default:0"')
+    19: i64 download_count (go.tag = 'gorm:"column:download_count;not null;default:0"')
+    20: i64 bytes_served (go.tag = 'gorm:"column:bytes_served;not null;default:0"')
+    21: i64 visitor_count (go.tag = 'gorm:"column:visitor_count;not null;default:0"')
+    /* file request: an upload-only external link filing each visitor's uploads in their own folder */
+    22: i32 file_request (go.tag = 'gorm:"column:file_request;not null;default:0"')
+    /* bytes each uploader may send, 0 means unlimited */
+    23: i64 request_max_size (go.tag = 'gorm:"column:request_max_size;not null;default:0"')
+    /* accepted extensions, comma separated (e.g. ".pdf,.docx"), empty accepts any */
+    24: string request_file_types (go.tag = 'gorm:"column:request_file_types;type:text"')
+    /* the expire_time the owner was last reminded of */
+    25: string reminded_expire_time (go.tag = 'gorm:"column:reminded_expire_time;type:timestamptz"')
+    /* smb only: advertise the share as a Time Machine backup target */
+    26: i32 smb_time_machine (go.tag = 'gorm:"column:smb_time_machine;not null;default:0"')
+    /* smb only: size limit in bytes, 0 is unlimited; writes stop once smb_used_bytes reaches it */
+    27: i64 smb_quota (go.tag = 'gorm:"column:smb_quota;not null;default:0"')
+    28: i64 smb_used_bytes (go.tag = 'gorm:"column:smb_used_bytes;not null;default:0"')
+    /* smb only: deletes go to .recycle in the share, purged after smb_recycle_days (0 keeps them) */
+    29: i32 smb_recycle (go.tag = 'gorm:"column:smb_recycle;not null;default:0"')
+    30: i32 smb_recycle_days (go.tag = 'gorm:"column:smb_recycle_days;not null;default:0"')
+    /* smb only: snapshot every smb_snapshot_hours (0 disables) into .snapshots, keeping the newest smb_snapshot_keep; snapshots count toward smb_quota */
+    31: i32 smb_snapshot_hours (go.tag = 'gorm:"column:smb_snapshot_hours;not null;default:0"')
+    32: i32 smb_snapshot_keep (go.tag = 'gorm:"column:smb_snapshot_keep;not null;default:0"')
+    /* smb only: record creates, writes, renames and deletes in share_activities */
+    33: i32 smb_audit (go.tag = 'gorm:"column:smb_audit;not null;default:0"')
+    /* nfs only: clients allowed to mount, comma separated IPs or CIDRs; permission picks ro (view) or rw (edit) */
+    34: string nfs_clients (go.tag = 'gorm:"column:nfs_clients;type:text"')
+    /* nfs only: root_squash, all_squash or no_root_squash */
+    35: string nfs_squash (go.tag = 'gorm:"column:nfs_squash;type:varchar(20)"')
 }
 
 struct ShareToken {
@@ -25,6 +57,17 @@ struct ShareToken {
     2: required string path_id (go.tag = 'gorm:"column:path_id;type:uuid;not null"')
     3: required string token (go.tag = 'gorm:"column:token;type:uuid;not null;default:gen_random_uuid();uniqueIndex:idx_share_token_token"')
     4: required string expire_at (go.tag = 'gorm:"column:expire_at;type:timestamptz;not null"')
+    /* visitor address the token was issued to by GetToken */
+    5: string ip (go.tag = 'gorm:"column:ip;type:varchar(64)"')
+    /* per-token quotas, 0 means unlimited */
+    6: i64 max_downloads (go.tag = 'gorm:"column:max_downloads;not null;default:0"')
+    7: i64 max_bytes (go.tag = 'gorm:"column:max_bytes;not null;default:0"')
+    8: i64 download_count (go.tag = 'gorm:"column:download_count;not null;default:0"')
+    9: i64 bytes_served (go.tag = 'gorm:"column:bytes_served;not null;default:0"')
+    /* file request uploader, given to GetToken */
+    10: string uploader_name (go.tag = 'gorm:"column:uploader_name;type:text"')
+    11: string uploader_email (go.tag = 'gorm:"column:uploader_email;type:text"')
+    12: i64 uploaded_bytes (go.tag = 'gorm:"column:uploaded_bytes;not null;default:0"')
 }
 
 struct ShareMember {
@@ -57,6 +100,62 @@ struct ShareSmbMember {
     7: required string update_time (go.tag = 'gorm:"column:update_time;type:timestamptz;not null;autoUpdateTime"')
 }
 
+struct ShareGroup {
+    1: required string id (go.tag = 'gorm:"column:id;type:uuid;primaryKey;default:gen_random_uuid()"')
+    2: required string owner (go.tag = 'gorm:"column:owner;type:text;not null;uniqueIndex:idx_share_group_owner_name"')
+    3: required string name (go.tag = 'gorm:"column:name;type:text;not null;uniqueIndex:idx_share_group_owner_name"')
+    4: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime"')
+    5: required string update_time (go.tag = 'gorm:"column:update_time;type:timestamptz;not null;autoUpdateTime"')
+}
+
+struct ShareGroupMember {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    2: required string group_id (go.tag = 'gorm:"column:group_id;type:uuid;not null;uniqueIndex:idx_share_group_member"')
+    3: required string member (go.tag = 'gorm:"column:member;type:text;not null;uniqueIndex:idx_share_group_member"')
+    4: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime"')
+}
+
+struct ShareActivity {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    2: required string path_id (go.tag = 'gorm:"column:path_id;type:uuid;not null;index:idx_share_activity_path"')
+    /* internal share member, the share owner, or the smb user */
+    3: string member (go.tag = 'gorm:"column:member;type:text"')
+    /* external share token */
+    4: string token (go.tag = 'gorm:"column:token;type:text"')
+    5: string ip (go.tag = 'gorm:"column:ip;type:varchar(64)"')
+    /* resource, preview, raw, download, upload, paste; smb_mkdir, smb_write, smb_rename, smb_delete for audited smb shares */
+    6: required string action (go.tag = 'gorm:"column:action;type:varchar(16);not null"')
+    7: string path (go.tag = 'gorm:"column:path;type:text"')
+    8: i64 bytes (go.tag = 'gorm:"column:bytes;not null;default:0"')
+    9: i32 status (go.tag = 'gorm:"column:status;not null;default:0"')
+    10: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime:milli;index:idx_share_activity_time"')
+}
+
+/* audit record of a share removed by the expiry cleanup job */
+struct ShareArchive {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    2: required string path_id (go.tag = 'gorm:"column:path_id;type:uuid;not null;index:idx_share_archive_path"')
+    3: required string owner (go.tag = 'gorm:"column:owner;type:text;not null;index:idx_share_archive_owner"')
+    4: required string share_type (go.tag = 'gorm:"column:share_type;type:varchar(10);not null"')
+    5: required string file_type (go.tag = 'gorm:"column:file_type;type:varchar(10);not null"')
+    6: required string extend (go.tag = 'gorm:"column:extend;type:text;not null"')
+    7: required string path (go.tag = 'gorm:"column:path;type:text;not null"')
+    8: string name (go.tag = 'gorm:"column:name;type:text"')
+    9: required string expire_time (go.tag = 'gorm:"column:expire_time;type:timestamptz;not null"')
+    /* archived keeps a snapshot of the share, its tokens and members; deleted does not */
+    10: required string action (go.tag = 'gorm:"column:action;type:varchar(16);not null"')
+    11: string snapshot (go.tag = 'gorm:"column:snapshot;type:text"')
+    12: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime"')
+}
+
+struct ShareActivityStats {
+    1: string path_id (go.tag = 'gorm:"column:path_id"')
+    2: i64 views (go.tag = 'gorm:"column:views"')
+    3: i64 downloads (go.tag = 'gorm:"column:downloads"')
+    4: i64 uploads (go.tag = 'gorm:"column:uploads"')
+    5: string last_access (go.tag = 'gorm:"column:last_access"')
+}
+
 // api models
 struct ViewSharePath {
     1: required string id
@@ -78,6 +177,35 @@ struct ViewSharePath {
     17: list<ViewSharePathUsers> users (go.tag='json:"users,omitempty"')
     18: string smb_link (go.tag='json:"smb_link,omitempty"')
     19: string sync_repo_name (go.tag='json:"sync_repo_name,omitempty"')
+    20: i64 views
+    21: i64 downloads
+    22: i64 uploads
+    23: string last_access (go.tag='json:"last_access,omitempty"')
+    24: i64 max_downloads
+    25: i64 max_bytes
+    26: i64 max_visitors
+    27: i64 download_count
+    28: i64 bytes_served
+    29: i64 visitor_count
+    /* absent when the quota is unlimited */
+    30: optional i64 remaining_downloads
+    31: optional i64 remaining_bytes
+    32: optional i64 remaining_visitors
+    33: i32 file_request
+    34: i64 request_max_size
+    35: string request_file_types
+    36: i32 smb_time_machine
+    37: i64 smb_quota
+    38: i64 smb_used_bytes
+    39: bool smb_quota_exceeded
+    40: i32 smb_recycle
+    41: i32 smb_recycle_days
+    42: i32 smb_snapshot_hours
+    43: i32 smb_snapshot_keep
+    44: i32 smb_audit
+    45: string nfs_clients
+    46: string nfs_squash
+    47: string nfs_mount (go.tag='json:"nfs_mount,omitempty"')
 }
 
 struct ViewSharePathMembers {
@@ -90,11 +218,13 @@ struct ViewSharePathUsers {
     1: string id
     2: string name
     3: i32 permission
+    /* the group: member reference when the member is a group */
+    4: string group (go.tag='json:"group,omitempty"')
 }
 
 struct CreateSharePathReq {
     // path is in the URL
-    1: required string share_type (api.body="share_type", api.vd="($ == 'internal'||$ == 'external'||$ == 'smb')");
+    1: required string share_type (api.body="share_type", api.vd="($ == 'internal'||$ == 'external'||$ == 'smb'||$ == 'nfs')");
     2: string name (api.body="name");
     3: string password (api.body="password");
     4: i64 expire_in (api.body="expire_in");
@@ -104,6 +234,24 @@ struct CreateSharePathReq {
     8: bool public_smb (api.body="public_smb");
     9: list<AddOrUpdateShareMemberInfo> share_members (api.body="share_members");
     10: list<CreateSmbSharePathMembers> users (api.body="users");
+    11: i64 max_downloads (api.body="max_downloads", api.vd="$>=0");
+    12: i64 max_bytes (api.body="max_bytes", api.vd="$>=0");
+    13: i64 max_visitors (api.body="max_visitors", api.vd="$>=0");
+    14: bool file_request (api.body="file_request");
+    15: i64 request_max_size (api.body="request_max_size", api.vd="$>=0");
+    16: string request_file_types (api.body="request_file_types");
+    17: bool time_machine (api.body="time_machine");
+    /* bytes, smb only */
+    18: i64 smb_quota (api.body="smb_quota", api.vd="$>=0");
+    19: bool smb_recycle (api.body="smb_recycle");
+    20: i32 smb_recycle_days (api.body="smb_recycle_days", api.vd="$>=0");
+    21: i32 smb_snapshot_hours (api.body="smb_snapshot_hours", api.vd="$>=0");
+    22: i32 smb_snapshot_keep (api.body="smb_snapshot_keep", api.vd="$>=0");
+    23: bool smb_audit (api.body="smb_audit");
+    /* nfs only: comma separated IPs or CIDRs, no broader than /8 (IPv4) or /32 (IPv6) */
+    24: string nfs_clients (api.body="nfs_clients");
+    /* nfs only: no_root_squash is for platform admins */
+    25: string nfs_squash (api.body="nfs_squash", api.vd="($ == ''||$ == 'root_squash'||$ == 'all_squash'||$ == 'no_root_squash')");
 }
 
 struct CreateSmbSharePathMembers {
@@ -148,6 +296,11 @@ struct GetInternalSmbSharePathResp {}
 struct UpdateSharePathReq {
     1: required string PathId (api.body="path_id");
     2: required string Name (api.body="name");
+    3: optional i64 MaxDownloads (api.body="max_downloads");
+    4: optional i64 MaxBytes (api.body="max_bytes");
+    5: optional i64 MaxVisitors (api.body="max_visitors");
+    6: optional i64 RequestMaxSize (api.body="request_max_size");
+    7: optional string RequestFileTypes (api.body="request_file_types");
 }
 
 struct UpdateSharePathResp {
@@ -170,6 +323,8 @@ struct DeleteSharePathResp {
 struct GenerateShareTokenReq {
     1: required string PathId (api.body="path_id");
     2: required string ExpireAt (api.body="expire_at");
+    3: i64 MaxDownloads (api.body="max_downloads", api.vd="$>=0");
+    4: i64 MaxBytes (api.body="max_bytes", api.vd="$>=0");
 }
 
 struct GenerateShareTokenResp {
@@ -185,6 +340,48 @@ struct ListShareTokenResp {
     2: list<ShareToken> share_tokens;
 }
 
+struct ListShareActivityReq {
+    1: required string PathId (api.query="path_id");
+    2: string Action (api.query="action");  // for multi-filtering
+    3: string Member (api.query="member");
+    4: string Token (api.query="token");
+    5: string IP (api.query="ip");
+    6: string Since (api.query="since");
+    7: string Until (api.query="until");
+    8: i64 Page (api.query="page");
+    9: i64 PageSize (api.query="page_size");
+    /* http or smb, ignored when action is set */
+    10: string Source (api.query="source", api.vd="($ == ''||$ == 'http'||$ == 'smb')");
+}
+
+struct ListShareActivityResp {
+    1: i32 total;
+    2: list<ShareActivity> activities;
+}
+
+struct ExtendSharePathReq {
+    1: required string PathId (api.body="path_id");
+    /* millisecond, added to the later of now and the current expiry */
+    2: i64 ExpireIn (api.body="expire_in", api.vd="$>=0");
+    /* absolute expiry, overrides expire_in */
+    3: string ExpireTime (api.body="expire_time");
+}
+
+struct ExtendSharePathResp {
+    1: ViewSharePath share_path;
+}
+
+struct ListShareArchiveReq {
+    1: string PathId (api.query="path_id");
+    2: i64 Page (api.query="page");
+    3: i64 PageSize (api.query="page_size");
+}
+
+struct ListShareArchiveResp {
+    1: i32 total;
+    2: list<ShareArchive> archives;
+}
+
 struct RevokeShareTokenReq {
     1: required string Token (api.query="token");
 }
@@ -196,11 +393,60 @@ struct RevokeShareTokenResp {
 struct GetTokenReq {
     1: required string PathId (api.body="id");
     2: required string Password (api.body="pass");
+    /* required by file request links, names the uploader's folder */
+    3: string UploaderName (api.body="uploader_name");
+    4: string UploaderEmail (api.body="uploader_email");
 }
 
 struct GetTokenResp {
 }
 
+struct ViewShareGroup {
+    /* the value to use as share_member, e.g. group:<id> */
+    1: string ref
+    2: string id
+    3: string name
+    4: list<string> members
+    /* resolved from the platform user list, cannot be edited */
+    5: bool builtin
+    6: string create_time (go.tag='json:"create_time,omitempty"')
+}
+
+struct ListShareGroupReq {}
+
+struct ListShareGroupResp {
+    1: i32 total;
+    2: list<ViewShareGroup> share_groups;
+}
+
+struct CreateShareGroupReq {
+    1: required string Name (api.body="name", api.vd="len($)>0");
+    2: list<string> Members (api.body="members");
+}
+
+struct CreateShareGroupResp {
+    1: ViewShareGroup share_group;
+}
+
+struct UpdateShareGroupReq {
+    1: required string GroupId (api.body="group_id");
+    2: string Name (api.body="name");
+    /* replaces the member list when present */
+    3: optional list<string> Members (api.body="members");
+}
+
+struct UpdateShareGroupResp {
+    1: ViewShareGroup share_group;
+}
+
+struct DeleteShareGroupReq {
+    1: required string GroupIds (api.query="group_ids");
+}
+
+struct DeleteShareGroupResp {
+    1: bool success;
+}
+
 struct AddOrUpdateShareMemberInfo {
     1: required string ShareMember (api.body="share_member");
     2: required i32 Permission (api.body="permission", api.vd="$>=0 && $<=4");
@@ -289,6 +535,11 @@ struct ModifySmbMemberReq {
     1: required string PathId (api.body="path_id");
     2: bool public_smb (api.body="public_smb");
     3: list<CreateSmbSharePathMembers> Users (api.body="users");
+    4: optional bool SmbRecycle (api.body="smb_recycle");
+    5: optional i32 SmbRecycleDays (api.body="smb_recycle_days");
+    6: optional i32 SmbSnapshotHours (api.body="smb_snapshot_hours");
+    7: optional i32 SmbSnapshotKeep (api.body="smb_snapshot_keep");
+    8: optional bool SmbAudit (api.body="smb_audit");
 }
 struct ModifySmbMemberResp {}
 
@@ -308,6 +559,14 @@ struct SmbShareView {
     13: string userName (go.tag = 'gorm:"column:user_name"');
     14: string password (go.tag = 'gorm:"column:password"');
     15: i32 permission (go.tag = 'gorm:"column:permission"');
+    16: i32 smbTimeMachine (go.tag = 'gorm:"column:smb_time_machine"');
+    17: i64 smbQuota (go.tag = 'gorm:"column:smb_quota"');
+    18: i64 smbUsedBytes (go.tag = 'gorm:"column:smb_used_bytes"');
+    19: i32 smbRecycle (go.tag = 'gorm:"column:smb_recycle"');
+    20: i32 smbRecycleDays (go.tag = 'gorm:"column:smb_recycle_days"');
+    21: i32 smbSnapshotHours (go.tag = 'gorm:"column:smb_snapshot_hours"');
+    22: i32 smbSnapshotKeep (go.tag = 'gorm:"column:smb_snapshot_keep"');
+    23: i32 smbAudit (go.tag = 'gorm:"column:smb_audit"');
 }
 
 // services
@@ -325,6 +584,14 @@ service ShareService {
     ListShareTokenResp ListShareToken(1: ListShareTokenReq request) (api.get="/api/share/share_token/");
     RevokeShareTokenResp RevokeShareToken(1: RevokeShareTokenReq request) (api.delete="/api/share/share_token/");
     GetTokenResp GetToken(1: GetTokenReq request) (api.post="/api/share/get_token/");
+    ListShareActivityResp ListShareActivity(1: ListShareActivityReq request) (api.get="/api/share/activity/");
+    ExtendSharePathResp ExtendSharePath(1: ExtendSharePathReq request) (api.post="/api/share/share_extend/");
+    ListShareArchiveResp ListShareArchive(1: ListShareArchiveReq request) (api.get="/api/share/share_archive/");
+
+    ListShareGroupResp ListShareGroup(1: ListShareGroupReq request) (api.get="/api/share/share_group/");
+    CreateShareGroupResp CreateShareGroup(1: CreateShareGroupReq request) (api.post="/api/share/share_group/");
+    UpdateShareGroupResp UpdateShareGroup(1: UpdateShareGroupReq request) (api.put="/api/share/share_group/");
+    DeleteShareGroupResp DeleteShareGroup(1: DeleteShareGroupReq request) (api.delete="/api/share/share_group/");
 
     AddShareMemberResp AddShareMember(1: AddShareMemberReq request) (api.post="/api/share/share_member/");
     ListShareMemberResp ListShareMember(1: ListShareMemberReq request) (api.get="/api/share/share_member/");
@@ -0,0 +1,83 @@
+namespace go api.tag
+
+// gorm models
+struct FileTag {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    /* the user who set the tag, tags are personal */
+    2: required string user_name (go.tag = 'gorm:"column:user_name;type:text;not null;uniqueIndex:idx_file_tag_path;index:idx_file_tag_name"')
+    /* owner of the storage, empty for storages all users see alike (sync, external, drive/Common) */
+    3: string owner (go.tag = 'gorm:"column:owner;type:text;not null;uniqueIndex:idx_file_tag_path"')
+    4: required string file_type (go.tag = 'gorm:"column:file_type;type:varchar(10);not null;uniqueIndex:idx_file_tag_path"')
+    5: required string extend (go.tag = 'gorm:"column:extend;type:text;not null;uniqueIndex:idx_file_tag_path"')
+    /* a folder ends with a slash */
+    6: required string path (go.tag = 'gorm:"column:path;type:text;not null;uniqueIndex:idx_file_tag_path"')
+    7: required string tag (go.tag = 'gorm:"column:tag;type:varchar(64);not null;uniqueIndex:idx_file_tag_path;index:idx_file_tag_name"')
+    8: required string create_time (go.tag = 'gorm:"column:create_time;type:timestamptz;not null;autoCreateTime:milli"')
+}
+
+struct FileMark {
+    1: required i64 id (go.tag = 'gorm:"column:id;primaryKey;autoIncrement"')
+    2: required string user_name (go.tag = 'gorm:"column:user_name;type:text;not null;uniqueIndex:idx_file_mark_path"')
+    3: string owner (go.tag = 'gorm:"column:owner;type:text;not null;uniqueIndex:idx_file_mark_path"')
+    4: required string file_type (go.tag = 'gorm:"column:file_type;type:varchar(10);not null;uniqueIndex:idx_file_mark_path"')
+    5: required string extend (go.tag = 'gorm:"column:extend;type:text;not null;uniqueIndex:idx_file_mark_path"')
+    6: required string path (go.tag = 'gorm:"column:path;type:text;not null;uniqueIndex:idx_file_mark_path"')
+    7: required bool favorite (go.tag = 'gorm:"column:favorite;not null;default:false"')
+    /* one of red, orange, yellow, green, blue, purple, gray, or empty */
+    8: required string label (go.tag = 'gorm:"column:label;type:varchar(16);not null"')
+    9: required string update_time (go.tag = 'gorm:"column:update_time;type:timestamptz;not null;autoUpdateTime:milli"')
+}
+
+// api models
+struct ViewTaggedFile {
+    1: required string file_type
+    2: required string extend
+    3: required string path
+    /* /<file_type>/<extend><path>, as the resources API takes it */
+    4: required string uri
+    5: list<string> tags
+    6: bool favorite
+    7: string label
+}
+
+struct ViewTag {
+    1: required string name
+    2: required i64 count
+}
+
+struct ListTagsReq {
+}
+
+struct ListTagsResp {
+    1: list<ViewTag> tags;
+}
+
+/* exactly one of tag, favorite and label */
+struct ListTaggedFilesReq {
+    1: string tag (api.query="tag");
+    2: bool favorite (api.query="favorite");
+    3: string label (api.query="label");
+}
+
+struct ListTaggedFilesResp {
+    1: list<ViewTaggedFile> items;
+}
+
+struct GetFileTagsReq {
+}
+
+/* a field left out is kept as it is */
+struct SetFileTagsReq {
+    /* replaces the tags of the file, at most 32 */
+    1: optional list<string> tags (api.body="tags");
+    2: optional bool favorite (api.body="favorite");
+    /* empty clears the label */
+    3: optional string label (api.body="label");
+}
+
+service TagService {
+    ListTagsResp ListTags(1: ListTagsReq request) (api.get="/api/tags/");
+    ListTaggedFilesResp ListTaggedFiles(1: ListTaggedFilesReq request) (api.get="/api/tags/items/");
+    ViewTaggedFile GetFileTags(1: GetFileTagsReq request) (api.get="/api/tags/files/*path");
+    ViewTaggedFile SetFileTags(1: SetFileTagsReq request) (api.put="/api/tags/files/*path");
+}
@@ -0,0 +1,58 @@
+namespace go api.wopi
+
+struct IssueWopiTokenReq {
+    1: required string path (api.body="path", api.vd="len($)>0");
+    /* cap the token at reading, for viewers */
+    2: bool read_only (api.body="read_only");
+}
+
+struct IssueWopiTokenResp {
+    1: string access_token
+    /* expiry in unix milliseconds */
+    2: i64 access_token_ttl
+    3: string file_id
+    /* the WOPISrc to hand to the office server */
+    4: string wopi_src
+}
+
+struct WopiFileReq {
+    1: required string file_id (api.path="file_id");
+    2: required string access_token (api.query="access_token");
+}
+
+struct CheckFileInfoResp {
+    1: string BaseFileName (go.tag = 'json:"BaseFileName"')
+    2: string OwnerId (go.tag = 'json:"OwnerId"')
+    3: i64 Size (go.tag = 'json:"Size"')
+    4: string UserId (go.tag = 'json:"UserId"')
+    5: string UserFriendlyName (go.tag = 'json:"UserFriendlyName"')
+    6: string Version (go.tag = 'json:"Version"')
+    7: string LastModifiedTime (go.tag = 'json:"LastModifiedTime"')
+    8: bool ReadOnly (go.tag = 'json:"ReadOnly"')
+    9: bool UserCanWrite (go.tag = 'json:"UserCanWrite"')
+    10: bool UserCanNotWriteRelative (go.tag = 'json:"UserCanNotWriteRelative"')
+    11: bool UserCanRename (go.tag = 'json:"UserCanRename"')
+    12: bool SupportsUpdate (go.tag = 'json:"SupportsUpdate"')
+    13: bool SupportsLocks (go.tag = 'json:"SupportsLocks"')
+    14: bool SupportsGetLock (go.tag = 'json:"SupportsGetLock"')
+    15: bool SupportsExtendedLockLength (go.tag = 'json:"SupportsExtendedLockLength"')
+    16: bool SupportsRename (go.tag = 'json:"SupportsRename"')
+    17: bool SupportsDeleteFile (go.tag = 'json:"SupportsDeleteFile"')
+}
+
+struct PutRelativeFileResp {
+    1: string Name (go.tag = 'json:"Name"')
+    2: string Url (go.tag = 'json:"Url"')
+}
+
+struct WopiFileResp {
+}
+
+service WopiService {
+    IssueWopiTokenResp IssueWopiToken(1: IssueWopiTokenReq request) (api.post="/api/wopi/token/");
+    CheckFileInfoResp CheckFileInfo(1: WopiFileReq request) (api.get="/api/wopi/files/:file_id");
+    /* LOCK, UNLOCK, REFRESH_LOCK, GET_LOCK and PUT_RELATIVE, by X-WOPI-Override */
+    WopiFileResp FileOperation(1: WopiFileReq request) (api.post="/api/wopi/files/:file_id");
+    WopiFileResp GetFile(1: WopiFileReq request) (api.get="/api/wopi/files/:file_id/contents");
+    WopiFileResp PutFile(1: WopiFileReq request) (api.post="/api/wopi/files/:file_id/contents");
+}
@@ -0,0 +1,216 @@
+package img
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// exifTimeLayout is how EXIF writes dates, in the camera's wall clock.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// tagOffsetTimeOriginal is the time zone of DateTimeOriginal, an EXIF
+// 2.31 tag go-exif does not know by name.
+const tagOffsetTimeOriginal = 0x9011
+
+// Metadata is what a photo tells about itself.
+type Metadata struct {
+	Format string `json:"format,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Orientation is the EXIF orientation, 1 to 8; Width and Height are
+	// as stored, before it is applied.
+	Orientation int `json:"orientation,omitempty"`
+	// TakenAt is the capture time as the camera's clock read it:
+	// RFC 3339 when the camera recorded its time zone, without a zone
+	// otherwise.
+	TakenAt      string    `json:"taken_at,omitempty"`
+	Make         string    `json:"make,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Lens         string    `json:"lens,omitempty"`
+	ExposureTime string    `json:"exposure_time,omitempty"`
+	FNumber      float64   `json:"f_number,omitempty"`
+	ISO          int       `json:"iso,omitempty"`
+	FocalLength  float64   `json:"focal_length,omitempty"`
+	Location     *Location `json:"location,omitempty"`
+}
+
+// Location is where a photo was taken, in decimal degrees and meters.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  int     `json:"altitude,omitempty"`
+}
+
+// ReadMetadata reads the dimensions and EXIF of the image in, seeking to
+// and reading only the parts of the file that hold them. An image without
+// EXIF has its dimensions only; ErrUnsupportedFormat means in is not an
+// image at all.
+func ReadMetadata(in io.ReadSeeker) (*Metadata, error) {
+	head, heif, err := metadataHead(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var md = &Metadata{}
+	if heif != nil {
+		md.Format, md.Width, md.Height = heif.format, heif.width, heif.height
+	} else if cfg, format, e := image.DecodeConfig(bytes.NewReader(head)); e == nil {
+		md.Format, md.Width, md.Height = format, cfg.Width, cfg.Height
+	}
+
+	if err = readExif(head, md); err != nil && md.Format == "" {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrUnsupportedFormat)
+	}
+	return md, nil
+}
+
+func readExif(head []byte, md *Metadata) (err error) {
+	// go-exif reports most errors by panicking
+	defer func() {
+		if state := recover(); state != nil {
+			err = fmt.Errorf("exif: %v", state)
+		}
+	}()
+
+	raw, err := exif.SearchAndExtractExif(head)
+	if err != nil {
+		return err
+	}
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return err
+	}
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), raw)
+	if err != nil {
+		return err
+	}
+
+	var root = index.RootIfd
+	md.Make = tagString(root, "Make")
+	md.Model = tagString(root, "Model")
+	md.Orientation = tagInt(root, "Orientation")
+	var taken = tagString(root, "DateTime")
+
+	if sub := index.Lookup["IFD/Exif"]; sub != nil {
+		if t := tagString(sub, "DateTimeOriginal"); t != "" {
+			taken = t
+		}
+		md.TakenAt = formatTakenAt(taken, tagStringWithID(sub, tagOffsetTimeOriginal))
+		md.Lens = strings.TrimSpace(tagString(sub, "LensMake") + " " + tagString(sub, "LensModel"))
+		md.ExposureTime = exposureTime(sub)
+		md.FNumber = tagFloat(sub, "FNumber")
+		md.ISO = tagInt(sub, "ISOSpeedRatings")
+		md.FocalLength = tagFloat(sub, "FocalLength")
+		if md.Width == 0 || md.Height == 0 {
+			md.Width, md.Height = tagInt(sub, "PixelXDimension"), tagInt(sub, "PixelYDimension")
+		}
+	} else {
+		md.TakenAt = formatTakenAt(taken, "")
+	}
+
+	if gps := index.Lookup["IFD/GPSInfo"]; gps != nil {
+		if gi, e := gps.GpsInfo(); e == nil {
+			lat, lon := gi.Latitude.Decimal(), gi.Longitude.Decimal()
+			// a zeroed position is a receiver without a fix
+			if !math.IsNaN(lat) && !math.IsNaN(lon) && (lat != 0 || lon != 0) {
+				md.Location = &Location{Latitude: lat, Longitude: lon, Altitude: gi.Altitude}
+			}
+		}
+	}
+	return nil
+}
+
+// formatTakenAt turns an EXIF date and offset into TakenAt, "" when the
+// date is missing or blanked out.
+func formatTakenAt(value, offset string) string {
+	t, err := time.Parse(exifTimeLayout, strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+	if o, e := time.Parse("-07:00", strings.TrimSpace(offset)); e == nil {
+		_, secs := o.Zone()
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", secs)).Format(time.RFC3339)
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+func tagValue(ifd *exif.Ifd, name string) interface{} {
+	entries, err := ifd.FindTagWithName(name)
+	return entryValue(entries, err)
+}
+
+func entryValue(entries []*exif.IfdTagEntry, err error) interface{} {
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	v, err := entries[0].Value()
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func tagString(ifd *exif.Ifd, name string) string {
+	return trimASCII(tagValue(ifd, name))
+}
+
+func tagStringWithID(ifd *exif.Ifd, id uint16) string {
+	return trimASCII(entryValue(ifd.FindTagWithId(id)))
+}
+
+func trimASCII(v interface{}) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+func tagInt(ifd *exif.Ifd, name string) int {
+	switch v := tagValue(ifd, name).(type) {
+	case []uint16:
+		if len(v) > 0 {
+			return int(v[0])
+		}
+	case []uint32:
+		if len(v) > 0 {
+			return int(v[0])
+		}
+	}
+	return 0
+}
+
+func tagRational(ifd *exif.Ifd, name string) (exifcommon.Rational, bool) {
+	v, ok := tagValue(ifd, name).([]exifcommon.Rational)
+	if !ok || len(v) == 0 || v[0].Denominator == 0 {
+		return exifcommon.Rational{}, false
+	}
+	return v[0], true
+}
+
+func tagFloat(ifd *exif.Ifd, name string) float64 {
+	r, ok := tagRational(ifd, name)
+	if !ok {
+		return 0
+	}
+	return math.Round(float64(r.Numerator)/float64(r.Denominator)*100) / 100
+}
+
+// exposureTime writes the shutter speed the way cameras show it: a
+// fraction of a second below one, seconds above.
+func exposureTime(ifd *exif.Ifd) string {
+	r, ok := tagRational(ifd, "ExposureTime")
+	if !ok || r.Numerator == 0 {
+		return ""
+	}
+	if r.Numerator >= r.Denominator {
+		return fmt.Sprintf("%g", math.Round(float64(r.Numerator)/float64(r.Denominator)*10)/10)
+	}
+	return fmt.Sprintf("1/%g", math.Round(float64(r.Denominator)/float64(r.Numerator)))
+}
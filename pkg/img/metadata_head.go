@@ -0,0 +1,436 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ReadMetadata reads only the parts of a file that hold its metadata.
+const (
+	// jpegHeadLimit bounds the segments read before the image data; each
+	// is at most 64 KiB, the EXIF in APP1 included.
+	jpegHeadLimit = 1 << 20
+	// headLimit is read of other files, TIFF among them: its IFDs follow
+	// the header in the files cameras write.
+	headLimit = 256 << 10
+	// heifMetaLimit bounds the meta box of HEIC and AVIF files, and
+	// heifExifLimit their Exif item.
+	heifMetaLimit = 1 << 20
+	heifExifLimit = 1 << 20
+)
+
+var errHeif = fmt.Errorf("invalid heif meta box: %w", ErrUnsupportedFormat)
+
+// heifInfo is what the meta box of a HEIC or AVIF file tells about its
+// primary image.
+type heifInfo struct {
+	format string
+	width  int
+	height int
+}
+
+// metadataHead returns the bytes of in ReadMetadata decodes: the segments
+// of a JPEG up to its image data, the Exif item of a HEIC or AVIF file,
+// whose dimensions come from its meta box instead, and the first
+// headLimit bytes of anything else.
+func metadataHead(in io.ReadSeeker) ([]byte, *heifInfo, error) {
+	var sig [12]byte
+	n, err := io.ReadFull(in, sig[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case n >= 2 && sig[0] == 0xff && sig[1] == 0xd8:
+		head, err := jpegHead(in)
+		return head, nil, err
+	case n == len(sig) && string(sig[4:8]) == "ftyp":
+		return heifHead(in)
+	}
+	head, err := io.ReadAll(io.LimitReader(in, headLimit))
+	return head, nil, err
+}
+
+// jpegHead reads the segments of the JPEG in up to and including the
+// start of scan header, which image/jpeg needs to tell a frame's color
+// model; the entropy-coded data after it is never read.
+func jpegHead(in io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	var soi [2]byte
+	if _, err := io.ReadFull(in, soi[:]); err != nil {
+		return nil, err
+	}
+	buf.Write(soi[:])
+
+	for buf.Len() < jpegHeadLimit {
+		var m [2]byte
+		if _, err := io.ReadFull(in, m[:]); err != nil || m[0] != 0xff {
+			break
+		}
+		// fill bytes may precede a marker
+		for m[1] == 0xff {
+			if _, err := io.ReadFull(in, m[1:]); err != nil {
+				return buf.Bytes(), nil
+			}
+		}
+		var marker = m[1]
+		if marker == 0xd9 {
+			break
+		}
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			buf.Write(m[:])
+			continue
+		}
+
+		var l [2]byte
+		if _, err := io.ReadFull(in, l[:]); err != nil {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(l[:]))
+		if length < 2 {
+			break
+		}
+		seg := make([]byte, length-2)
+		if _, err := io.ReadFull(in, seg); err != nil {
+			break
+		}
+		buf.Write(m[:])
+		buf.Write(l[:])
+		buf.Write(seg)
+		if marker == 0xda {
+			break
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// heifHead reads the meta box of the ISO BMFF file in, then the Exif
+// item it points to. The image data is never read.
+func heifHead(in io.ReadSeeker) ([]byte, *heifInfo, error) {
+	var info = &heifInfo{format: "heic"}
+	var meta []byte
+	for meta == nil {
+		var hdr [8]byte
+		if _, err := io.ReadFull(in, hdr[:]); err != nil {
+			return nil, nil, errHeif
+		}
+		size, typ := uint64(binary.BigEndian.Uint32(hdr[:4])), string(hdr[4:])
+		var hl uint64 = 8
+		if size == 1 {
+			var ext [8]byte
+			if _, err := io.ReadFull(in, ext[:]); err != nil {
+				return nil, nil, errHeif
+			}
+			size, hl = binary.BigEndian.Uint64(ext[:]), 16
+		}
+		// a box running to the end of the file is the image data
+		if size == 0 || size < hl {
+			return nil, nil, errHeif
+		}
+
+		var body = size - hl
+		switch typ {
+		case "ftyp", "meta":
+			if body > heifMetaLimit {
+				return nil, nil, errHeif
+			}
+			b := make([]byte, body)
+			if _, err := io.ReadFull(in, b); err != nil {
+				return nil, nil, errHeif
+			}
+			if typ == "meta" {
+				meta = b
+			} else if len(b) >= 4 && (string(b[:4]) == "avif" || string(b[:4]) == "avis") {
+				info.format = "avif"
+			}
+		default:
+			if _, err := in.Seek(int64(body), io.SeekCurrent); err != nil {
+				return nil, nil, errHeif
+			}
+		}
+	}
+
+	m, err := parseHeifMeta(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.width, info.height = m.size()
+
+	exifData, err := m.readItem(in, m.exifItem())
+	if err != nil {
+		return nil, nil, err
+	}
+	return exifData, info, nil
+}
+
+// heifMeta is the part of a meta box ReadMetadata uses.
+type heifMeta struct {
+	primary   uint32
+	itemTypes map[uint32]string
+	locations map[uint32]heifLocation
+	props     [][]byte         // ispe bodies by property index - 1, nil for other properties
+	assoc     map[uint32][]int // property indexes of an item
+	idat      []byte
+}
+
+type heifLocation struct {
+	method  uint64
+	extents [][2]uint64 // offset, length
+}
+
+// beReader reads big-endian integers of any width, recording a short
+// read instead of failing.
+type beReader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *beReader) uint(n int) uint64 {
+	if len(r.b) < n {
+		r.bad = true
+		r.b = nil
+		return 0
+	}
+	var v uint64
+	for _, c := range r.b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+type heifBox struct {
+	typ  string
+	body []byte
+}
+
+func parseBoxes(b []byte) []heifBox {
+	var res []heifBox
+	for len(b) >= 8 {
+		size, hl := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		typ := string(b[4:8])
+		if size == 1 {
+			if len(b) < 16 {
+				break
+			}
+			size, hl = binary.BigEndian.Uint64(b[8:]), 16
+		} else if size == 0 {
+			size = uint64(len(b))
+		}
+		if size < hl || size > uint64(len(b)) {
+			break
+		}
+		res = append(res, heifBox{typ, b[hl:size]})
+		b = b[size:]
+	}
+	return res
+}
+
+func parseHeifMeta(meta []byte) (*heifMeta, error) {
+	// meta is a full box: version and flags come first
+	if len(meta) < 4 {
+		return nil, errHeif
+	}
+	var m = &heifMeta{itemTypes: map[uint32]string{}, locations: map[uint32]heifLocation{}, assoc: map[uint32][]int{}}
+	for _, box := range parseBoxes(meta[4:]) {
+		r := &beReader{b: box.body}
+		switch box.typ {
+		case "pitm":
+			if r.uint(1) == 0 {
+				r.uint(3)
+				m.primary = uint32(r.uint(2))
+			} else {
+				r.uint(3)
+				m.primary = uint32(r.uint(4))
+			}
+		case "iinf":
+			version := r.uint(1)
+			r.uint(3)
+			if version == 0 {
+				r.uint(2)
+			} else {
+				r.uint(4)
+			}
+			for _, infe := range parseBoxes(r.b) {
+				if infe.typ != "infe" {
+					continue
+				}
+				ir := &beReader{b: infe.body}
+				v := ir.uint(1)
+				ir.uint(3)
+				var id uint32
+				switch v {
+				case 2:
+					id = uint32(ir.uint(2))
+				case 3:
+					id = uint32(ir.uint(4))
+				default:
+					continue
+				}
+				ir.uint(2) // protection index
+				if typ := ir.b; !ir.bad && len(typ) >= 4 {
+					m.itemTypes[id] = string(typ[:4])
+				}
+			}
+			r.b = nil
+		case "iloc":
+			m.parseIloc(r)
+		case "iprp":
+			m.parseIprp(box.body)
+		case "idat":
+			m.idat = box.body
+		}
+		if r.bad {
+			return nil, errHeif
+		}
+	}
+	return m, nil
+}
+
+func (m *heifMeta) parseIloc(r *beReader) {
+	version := r.uint(1)
+	r.uint(3)
+	sizes := r.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xf), int(sizes>>8&0xf)
+	baseOffsetSize, indexSize := int(sizes>>4&0xf), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0
+	}
+	var count uint64
+	if version < 2 {
+		count = r.uint(2)
+	} else {
+		count = r.uint(4)
+	}
+	for i := uint64(0); i < count && !r.bad; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(r.uint(2))
+		} else {
+			id = uint32(r.uint(4))
+		}
+		var loc heifLocation
+		if version > 0 {
+			loc.method = r.uint(2) & 0xf
+		}
+		r.uint(2) // data reference index
+		base := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+		for e := uint64(0); e < extents && !r.bad; e++ {
+			r.uint(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			loc.extents = append(loc.extents, [2]uint64{base + offset, length})
+		}
+		m.locations[id] = loc
+	}
+}
+
+func (m *heifMeta) parseIprp(iprp []byte) {
+	for _, box := range parseBoxes(iprp) {
+		switch box.typ {
+		case "ipco":
+			for _, prop := range parseBoxes(box.body) {
+				if prop.typ == "ispe" {
+					m.props = append(m.props, prop.body)
+				} else {
+					m.props = append(m.props, nil)
+				}
+			}
+		case "ipma":
+			r := &beReader{b: box.body}
+			version := r.uint(1)
+			flags := r.uint(3)
+			count := r.uint(4)
+			for i := uint64(0); i < count && !r.bad; i++ {
+				var id uint32
+				if version < 1 {
+					id = uint32(r.uint(2))
+				} else {
+					id = uint32(r.uint(4))
+				}
+				n := r.uint(1)
+				for a := uint64(0); a < n && !r.bad; a++ {
+					var index int
+					if flags&1 != 0 {
+						index = int(r.uint(2) & 0x7fff)
+					} else {
+						index = int(r.uint(1) & 0x7f)
+					}
+					m.assoc[id] = append(m.assoc[id], index)
+				}
+			}
+		}
+	}
+}
+
+// size is the width and height of the primary image, from its ispe
+// property.
+func (m *heifMeta) size() (int, int) {
+	for _, index := range m.assoc[m.primary] {
+		if index < 1 || index > len(m.props) || m.props[index-1] == nil {
+			continue
+		}
+		r := &beReader{b: m.props[index-1]}
+		r.uint(4) // version and flags
+		w, h := r.uint(4), r.uint(4)
+		if !r.bad {
+			return int(w), int(h)
+		}
+	}
+	return 0, 0
+}
+
+// exifItem is the id of the Exif item, 0 when there is none.
+func (m *heifMeta) exifItem() uint32 {
+	for id, typ := range m.itemTypes {
+		if typ == "Exif" {
+			return id
+		}
+	}
+	return 0
+}
+
+// readItem reads the extents of the item id, from the file or from the
+// meta box's idat. An item that does not exist reads as nothing.
+func (m *heifMeta) readItem(in io.ReadSeeker, id uint32) ([]byte, error) {
+	loc, ok := m.locations[id]
+	if id == 0 || !ok {
+		return nil, nil
+	}
+	var total uint64
+	for _, e := range loc.extents {
+		total += e[1]
+	}
+	if total > heifExifLimit {
+		return nil, errHeif
+	}
+
+	var data = make([]byte, 0, total)
+	for _, e := range loc.extents {
+		switch loc.method {
+		case 0:
+			if _, err := in.Seek(int64(e[0]), io.SeekStart); err != nil {
+				return nil, err
+			}
+			chunk := make([]byte, e[1])
+			if _, err := io.ReadFull(in, chunk); err != nil {
+				return nil, errHeif
+			}
+			data = append(data, chunk...)
+		case 1:
+			if e[0]+e[1] > uint64(len(m.idat)) {
+				return nil, errHeif
+			}
+			data = append(data, m.idat[e[0]:e[0]+e[1]]...)
+		default:
+			return nil, errHeif
+		}
+	}
+	return data, nil
+}
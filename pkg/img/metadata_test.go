@@ -0,0 +1,212 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/dsoprea/go-exif/v3"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// exifBlock returns the EXIF set by build, from its TIFF header on.
+func exifBlock(t *testing.T, build func(root *exif.IfdBuilder)) []byte {
+	t.Helper()
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := exif.NewIfdBuilder(im, exif.NewTagIndex(), exifcommon.IfdStandardIfdIdentity, binary.BigEndian)
+	build(root)
+	raw, err := exif.NewIfdByteEncoder().EncodeToExif(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// exifJPEG returns a 64x48 JPEG carrying the EXIF set by build.
+func exifJPEG(t *testing.T, build func(root *exif.IfdBuilder)) []byte {
+	t.Helper()
+	raw := exifBlock(t, build)
+
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// SOI, then an APP1 segment with the EXIF, then the rest of the image
+	app1 := append([]byte("Exif\x00\x00"), raw...)
+	var out bytes.Buffer
+	out.Write([]byte{0xff, 0xd8, 0xff, 0xe1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(plain.Bytes()[2:])
+	return out.Bytes()
+}
+
+func set(t *testing.T, ib *exif.IfdBuilder, name string, value interface{}) {
+	t.Helper()
+	if err := ib.AddStandardWithName(name, value); err != nil {
+		t.Fatalf("set %s: %v", name, err)
+	}
+}
+
+func child(t *testing.T, root *exif.IfdBuilder, path string) *exif.IfdBuilder {
+	t.Helper()
+	ib, err := exif.GetOrCreateIbFromRootIb(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ib
+}
+
+func TestReadMetadata(t *testing.T) {
+	data := exifJPEG(t, func(root *exif.IfdBuilder) {
+		set(t, root, "Make", "Canon")
+		set(t, root, "Model", "EOS R6")
+		set(t, root, "Orientation", []uint16{6})
+		set(t, root, "DateTime", "2024:05:02 09:00:00")
+
+		sub := child(t, root, "IFD/Exif")
+		set(t, sub, "DateTimeOriginal", "2024:05:01 18:30:05")
+		set(t, sub, "LensModel", "RF24-105mm F4 L IS USM")
+		set(t, sub, "ExposureTime", []exifcommon.Rational{{Numerator: 1, Denominator: 250}})
+		set(t, sub, "FNumber", []exifcommon.Rational{{Numerator: 40, Denominator: 10}})
+		set(t, sub, "ISOSpeedRatings", []uint16{400})
+		set(t, sub, "FocalLength", []exifcommon.Rational{{Numerator: 50, Denominator: 1}})
+
+		gps := child(t, root, "IFD/GPSInfo")
+		set(t, gps, "GPSVersionID", []byte{2, 2, 0, 0})
+		set(t, gps, "GPSLatitudeRef", "N")
+		set(t, gps, "GPSLatitude", []exifcommon.Rational{{Numerator: 48, Denominator: 1}, {Numerator: 51, Denominator: 1}, {Numerator: 2964, Denominator: 100}})
+		set(t, gps, "GPSLongitudeRef", "W")
+		set(t, gps, "GPSLongitude", []exifcommon.Rational{{Numerator: 2, Denominator: 1}, {Numerator: 17, Denominator: 1}, {Numerator: 4008, Denominator: 100}})
+	})
+
+	md, err := ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Format != "jpeg" || md.Width != 64 || md.Height != 48 || md.Orientation != 6 {
+		t.Errorf("image = %s %dx%d orientation %d", md.Format, md.Width, md.Height, md.Orientation)
+	}
+	if md.TakenAt != "2024-05-01T18:30:05" {
+		t.Errorf("taken at = %q", md.TakenAt)
+	}
+	if md.Make != "Canon" || md.Model != "EOS R6" || md.Lens != "RF24-105mm F4 L IS USM" {
+		t.Errorf("camera = %q %q %q", md.Make, md.Model, md.Lens)
+	}
+	if md.ExposureTime != "1/250" || md.FNumber != 4 || md.ISO != 400 || md.FocalLength != 50 {
+		t.Errorf("exposure = %s f/%g ISO %d %gmm", md.ExposureTime, md.FNumber, md.ISO, md.FocalLength)
+	}
+	if md.Location == nil || math.Abs(md.Location.Latitude-48.8582) > 1e-3 || math.Abs(md.Location.Longitude+2.2945) > 1e-3 {
+		t.Errorf("location = %+v", md.Location)
+	}
+}
+
+func TestReadMetadataWithoutExif(t *testing.T) {
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 10, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+	md, err := ReadMetadata(bytes.NewReader(plain.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Width != 10 || md.Height != 20 || md.TakenAt != "" || md.Location != nil {
+		t.Errorf("metadata = %+v", md)
+	}
+
+	if _, err = ReadMetadata(bytes.NewReader([]byte("plain text"))); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("text: err = %v", err)
+	}
+}
+
+func TestJpegHeadStopsAtScan(t *testing.T) {
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 300, 200)), nil); err != nil {
+		t.Fatal(err)
+	}
+	head, err := jpegHead(bytes.NewReader(plain.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head) >= plain.Len() {
+		t.Errorf("head is %d of %d bytes", len(head), plain.Len())
+	}
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(head)); err != nil || cfg.Width != 300 || cfg.Height != 200 {
+		t.Errorf("config = %+v, %v", cfg, err)
+	}
+}
+
+// box returns an ISO BMFF box; a full box has its version and flags
+// leading body.
+func box(typ string, body ...[]byte) []byte {
+	var b = bytes.Join(body, nil)
+	var out = binary.BigEndian.AppendUint32(nil, uint32(len(b)+8))
+	return append(append(out, typ...), b...)
+}
+
+func TestReadMetadataHeic(t *testing.T) {
+	raw := exifBlock(t, func(root *exif.IfdBuilder) {
+		set(t, root, "Make", "Apple")
+		set(t, root, "Orientation", []uint16{6})
+		set(t, child(t, root, "IFD/Exif"), "DateTimeOriginal", "2024:05:01 18:30:05")
+	})
+	// the Exif item: the offset of the TIFF header, then the header
+	item := append([]byte{0, 0, 0, 0}, raw...)
+
+	u16 := func(v int) []byte { return binary.BigEndian.AppendUint16(nil, uint16(v)) }
+	u32 := func(v int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(v)) }
+	full := []byte{0, 0, 0, 0}
+
+	ftyp := box("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+	meta := func(offset int) []byte {
+		return box("meta", full,
+			box("hdlr", full, u32(0), []byte("pict"), make([]byte, 13)),
+			box("pitm", full, u16(1)),
+			box("iinf", full, u16(2),
+				box("infe", []byte{2, 0, 0, 0}, u16(1), u16(0), []byte("hvc1"), []byte{0}),
+				box("infe", []byte{2, 0, 0, 0}, u16(2), u16(0), []byte("Exif"), []byte{0})),
+			// 4 byte offsets and lengths, no base offset
+			box("iloc", full, []byte{0x44, 0x00}, u16(2),
+				u16(1), u16(0), u16(1), u32(offset+len(item)), u32(16),
+				u16(2), u16(0), u16(1), u32(offset), u32(len(item))),
+			box("iprp",
+				box("ipco", box("ispe", full, u32(4032), u32(3024))),
+				box("ipma", full, u32(1), u16(1), []byte{1, 0x81})))
+	}
+	// the mdat offset is only known once the meta box is
+	offset := len(ftyp) + len(meta(0)) + 8
+	data := bytes.Join([][]byte{ftyp, meta(offset), box("mdat", item, make([]byte, 16))}, nil)
+
+	md, err := ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Format != "heic" || md.Width != 4032 || md.Height != 3024 || md.Orientation != 6 {
+		t.Errorf("image = %s %dx%d orientation %d", md.Format, md.Width, md.Height, md.Orientation)
+	}
+	if md.Make != "Apple" || md.TakenAt != "2024-05-01T18:30:05" {
+		t.Errorf("metadata = %+v", md)
+	}
+}
+
+func TestFormatTakenAt(t *testing.T) {
+	for _, c := range []struct{ value, offset, want string }{
+		{"2024:05:01 18:30:05", "+02:00", "2024-05-01T18:30:05+02:00"},
+		{"2024:05:01 18:30:05", "", "2024-05-01T18:30:05"},
+		{"0000:00:00 00:00:00", "", ""},
+		{"", "", ""},
+	} {
+		if got := formatTakenAt(c.value, c.offset); got != c.want {
+			t.Errorf("formatTakenAt(%q, %q) = %q, want %q", c.value, c.offset, got, c.want)
+		}
+	}
+}
@@ -9,10 +9,14 @@ import (
 	"image"
 	_ "image/png"
 	"io"
+	"strings"
+	"sync/atomic"
 
 	"github.com/disintegration/imaging"
 	"github.com/dsoprea/go-exif/v3"
+	"github.com/gen2brain/avif"
 	_ "github.com/gen2brain/heic"
+	"github.com/gen2brain/webp"
 	"github.com/marusama/semaphore/v2"
 	"k8s.io/klog/v2"
 
@@ -27,6 +31,9 @@ var ErrUnsupportedFormat = errors.New("unsupported image format")
 // Service
 type Service struct {
 	sem semaphore.Semaphore
+	// interactive counts the resizes of requests someone waits for,
+	// running or waiting for a worker.
+	interactive atomic.Int32
 }
 
 func New(workers int) *Service {
@@ -40,6 +47,20 @@ func GetImageService() *Service {
 	return imgSvc
 }
 
+type backgroundKey struct{}
+
+// Background marks ctx as background work: its resizes are not counted
+// by Busy, so background work can wait for the interactive ones.
+func Background(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backgroundKey{}, true)
+}
+
+// Busy tells whether resizes of interactive requests are running or
+// waiting for a worker.
+func (s *Service) Busy() bool {
+	return s.interactive.Load() > 0
+}
+
 // Format is an image file format.
 /*
 ENUM(
@@ -48,10 +69,25 @@ png
 gif
 tiff
 bmp
+webp
+avif
 )
 */
 type Format int
 
+// Encoding parameters of the formats imaging cannot write. AVIF trades
+// some size for speed, a thumbnail is encoded while the client waits.
+const (
+	webpQuality = 80
+	avifQuality = 60
+	avifSpeed   = 8
+)
+
+// MimeType is the media type of images in format x.
+func (x Format) MimeType() string {
+	return "image/" + x.String()
+}
+
 func (x Format) toImaging() imaging.Format {
 	switch x {
 	case FormatJpeg:
@@ -100,6 +136,12 @@ fill
 type ResizeMode int
 
 func (s *Service) FormatFromExtension(ext string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "webp":
+		return FormatWebp, nil
+	case "avif":
+		return FormatAvif, nil
+	}
 	format, err := imaging.FormatFromExtension(ext)
 	if err != nil {
 		return -1, ErrUnsupportedFormat
@@ -146,6 +188,10 @@ func WithQuality(quality Quality) Option {
 }
 
 func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...Option) error {
+	if ctx.Value(backgroundKey{}) == nil {
+		s.interactive.Add(1)
+		defer s.interactive.Add(-1)
+	}
 	if err := s.sem.Acquire(ctx, 1); err != nil {
 		return err
 	}
@@ -167,7 +213,8 @@ func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, o
 		option(&config)
 	}
 
-	if config.quality == QualityLow && format == FormatJpeg {
+	// the embedded thumbnail is a JPEG, served as is only when one is asked for
+	if config.quality == QualityLow && format == FormatJpeg && config.format == FormatJpeg {
 		thm, newWrappedReader, errThm := getEmbeddedThumbnail(wrappedReader)
 		wrappedReader = newWrappedReader
 		if errThm == nil {
@@ -194,7 +241,18 @@ func (s *Service) Resize(ctx context.Context, in io.Reader, width, height int, o
 		img = imaging.Fit(img, width, height, config.quality.resampleFilter())
 	}
 
-	return imaging.Encode(out, img, config.format.toImaging())
+	return encode(out, img, config.format)
+}
+
+func encode(out io.Writer, img image.Image, format Format) error {
+	switch format {
+	case FormatWebp:
+		return webp.Encode(out, img, webp.Options{Quality: webpQuality, Method: webp.DefaultMethod})
+	case FormatAvif:
+		return avif.Encode(out, img, avif.Options{Quality: avifQuality, QualityAlpha: avifQuality, Speed: avifSpeed, ChromaSubsampling: image.YCbCrSubsampleRatio420})
+	default:
+		return imaging.Encode(out, img, format.toImaging())
+	}
 }
 
 func (s *Service) detectFormat(in io.Reader) (Format, io.Reader, error) {
@@ -0,0 +1,96 @@
+package img
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			m.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResizeFormats(t *testing.T) {
+	svc := New(1)
+	src := testPNG(t, 200, 100)
+
+	for _, c := range []struct {
+		format Format
+		mode   ResizeMode
+		want   image.Point
+	}{
+		{FormatJpeg, ResizeModeFit, image.Pt(64, 32)},
+		{FormatWebp, ResizeModeFit, image.Pt(64, 32)},
+		{FormatAvif, ResizeModeFill, image.Pt(64, 64)},
+	} {
+		var out bytes.Buffer
+		err := svc.Resize(context.Background(), bytes.NewReader(src), 64, 64, &out, WithFormat(c.format), WithMode(c.mode))
+		if err != nil {
+			t.Fatalf("%s: %v", c.format, err)
+		}
+		cfg, name, err := image.DecodeConfig(&out)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", c.format, err)
+		}
+		if name != c.format.String() || cfg.Width != c.want.X || cfg.Height != c.want.Y {
+			t.Errorf("%s: got %s %dx%d, want %v", c.format, name, cfg.Width, cfg.Height, c.want)
+		}
+	}
+}
+
+func TestFormatFromExtension(t *testing.T) {
+	svc := New(1)
+	for ext, want := range map[string]Format{".jpg": FormatJpeg, ".PNG": FormatPng, ".webp": FormatWebp, ".avif": FormatAvif} {
+		if got, err := svc.FormatFromExtension(ext); err != nil || got != want {
+			t.Errorf("FormatFromExtension(%q) = %s, %v", ext, got, err)
+		}
+	}
+	if _, err := svc.FormatFromExtension(".heic"); err != ErrUnsupportedFormat {
+		t.Errorf("heic: err = %v", err)
+	}
+}
+
+func TestBusy(t *testing.T) {
+	svc := New(1)
+	src := testPNG(t, 64, 32)
+
+	if err := svc.Resize(Background(context.Background()), bytes.NewReader(src), 16, 16, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Busy() {
+		t.Error("busy after a background resize")
+	}
+
+	// an interactive resize waiting for the only worker counts as busy
+	if err := svc.sem.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- svc.Resize(context.Background(), bytes.NewReader(src), 16, 16, io.Discard) }()
+	for !svc.Busy() {
+		time.Sleep(time.Millisecond)
+	}
+	svc.sem.Release(1)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if svc.Busy() {
+		t.Error("busy after the interactive resize")
+	}
+}
@@ -46,6 +46,11 @@ type integration struct {
 	cancelWatcher context.CancelFunc
 	watcherDone   chan struct{}
 
+	// usersChanged hooks run after a user watcher event; hooksMu is
+	// separate so hooks may call back into GetUsers.
+	hooksMu      sync.Mutex
+	usersChanged []UsersChangedFunc
+
 	sync.RWMutex
 }
 
@@ -138,15 +143,11 @@ func (i *integration) HandlerEvent() cache.ResourceEventHandler {
 		Handler: cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				// klog.Infof("ingegrateion add func: %s", common.ToJson(obj))
-				if err := i.GetIntegrations(); err != nil {
-					klog.Errorf("get users error: %v", err)
-				}
+				i.refreshUsers()
 			},
 			DeleteFunc: func(obj interface{}) {
 				// klog.Infof("ingegrateion delete func: %s", common.ToJson(obj))
-				if err := i.GetIntegrations(); err != nil {
-					klog.Errorf("get users error: %v", err)
-				}
+				i.refreshUsers()
 			},
 		},
 	}
@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"sort"
+
+	"k8s.io/klog/v2"
+)
+
+// UsersChangedFunc is told which platform users appeared and which went
+// away after the user watcher refreshed the user list.
+type UsersChangedFunc func(added, removed []string)
+
+// OnUsersChanged registers fn to run after every user watcher event
+// that changed the user list. Hooks run on the watcher goroutine and
+// should return quickly.
+func (i *integration) OnUsersChanged(fn UsersChangedFunc) {
+	if fn == nil {
+		return
+	}
+	i.hooksMu.Lock()
+	defer i.hooksMu.Unlock()
+	i.usersChanged = append(i.usersChanged, fn)
+}
+
+// refreshUsers reloads the integrations on a user watcher event and
+// reports the user list difference to the registered hooks.
+func (i *integration) refreshUsers() {
+	var before = i.userNames()
+	if err := i.GetIntegrations(); err != nil {
+		klog.Errorf("get users error: %v", err)
+	}
+
+	added, removed := diffUsers(before, i.userNames())
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	klog.Infof("[integration] users changed, added: %v, removed: %v", added, removed)
+
+	i.hooksMu.Lock()
+	var hooks = append([]UsersChangedFunc(nil), i.usersChanged...)
+	i.hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn(added, removed)
+	}
+}
+
+func (i *integration) userNames() map[string]struct{} {
+	i.RLock()
+	defer i.RUnlock()
+	var names = make(map[string]struct{}, len(i.users))
+	for _, u := range i.users {
+		names[u.Name] = struct{}{}
+	}
+	return names
+}
+
+func diffUsers(before, after map[string]struct{}) (added, removed []string) {
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,25 @@
+package integration
+
+import "testing"
+
+func TestDiffUsers(t *testing.T) {
+	set := func(names ...string) map[string]struct{} {
+		m := make(map[string]struct{}, len(names))
+		for _, n := range names {
+			m[n] = struct{}{}
+		}
+		return m
+	}
+
+	added, removed := diffUsers(set("alice", "bob"), set("bob", "dave", "carol"))
+	if len(added) != 2 || added[0] != "carol" || added[1] != "dave" {
+		t.Errorf("added = %v, want [carol dave]", added)
+	}
+	if len(removed) != 1 || removed[0] != "alice" {
+		t.Errorf("removed = %v, want [alice]", removed)
+	}
+
+	if added, removed := diffUsers(set("alice"), set("alice")); added != nil || removed != nil {
+		t.Errorf("unchanged list reported added=%v removed=%v", added, removed)
+	}
+}
@@ -39,6 +39,10 @@ type CloudListResponse struct {
 	FileExtend string               `json:"fileExtend"`
 	FilePath   string               `json:"filePath"`
 	Name       string               `json:"name"`
+	// set on a paged listing, counted over the filtered entries
+	NumDirs    int    `json:"numDirs,omitempty"`
+	NumFiles   int    `json:"numFiles,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
 	sync.Mutex
 }
 
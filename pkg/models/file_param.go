@@ -267,6 +267,21 @@ func (r *FileParam) IsDriveCommon() bool {
 	return r != nil && r.FileType == common.Drive && r.Extend == common.Common
 }
 
+// StorageOwner is the owner per-path records on r (locks, tags) are
+// keyed by. Sync libraries, external devices and drive/Common look the
+// same to every user, so they are keyed by no owner.
+func (r *FileParam) StorageOwner() string {
+	switch r.FileType {
+	case common.Sync, common.External:
+		return ""
+	case common.Drive:
+		if r.Extend == common.Common {
+			return ""
+		}
+	}
+	return r.Owner
+}
+
 func (r *FileParam) IsSystem() bool {
 	klog.Infof("judging if %s is system", r.FileType)
 
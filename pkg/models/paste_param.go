@@ -16,6 +16,14 @@ type PasteParam struct {
 	SrcSharePath            *FileParam
 	DstSharePath            *FileParam
 
+	// Verify asks the task to hash source and destination after the
+	// transfer and record any mismatch in the task details.
+	Verify bool `json:"verify"`
+
+	// Conflict is one of common.PasteConflict* and decides what happens
+	// when the destination already exists; empty means rename.
+	Conflict string `json:"conflict"`
+
 	// Srcs is populated for ActionCompress only, carrying the list of
 	// sources to archive together.
 	Srcs []*FileParam `json:"-"`
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed is returned by a driver's Edit when the request's
+// If-Match or If-Unmodified-Since does not hold for the file as it is
+// now; the resources handler answers 412.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// HasEditPrecondition reports whether the request makes its write
+// conditional, so drivers can skip the extra stat otherwise.
+func HasEditPrecondition(header http.Header) bool {
+	return header.Get("If-Match") != "" || header.Get("If-Unmodified-Since") != ""
+}
+
+// CheckEditPrecondition evaluates If-Match, or If-Unmodified-Since when
+// If-Match is absent (RFC 9110 13.2.2), against the current etag and
+// modification time of the file. An empty etag means the file does not
+// exist, which only "If-Match: *" fails on purpose; a zero modTime means
+// the backend cannot tell, and If-Unmodified-Since is then ignored.
+func CheckEditPrecondition(header http.Header, etag string, modTime time.Time) error {
+	if ifMatch := header.Get("If-Match"); ifMatch != "" {
+		if etag == "" {
+			return ErrPreconditionFailed
+		}
+		for _, tag := range strings.Split(ifMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			// strong comparison: a weak tag never matches
+			if tag == "*" || tag == etag && !strings.HasPrefix(tag, "W/") {
+				return nil
+			}
+		}
+		return ErrPreconditionFailed
+	}
+
+	if since := header.Get("If-Unmodified-Since"); since != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(since)
+		if err != nil {
+			return nil
+		}
+		if modTime.Truncate(time.Second).After(t) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckEditPrecondition(t *testing.T) {
+	mod := time.Date(2026, 10, 19, 8, 0, 0, 500, time.UTC)
+	etag := `"18a2b3c4d5e6f70012"`
+
+	for name, tc := range map[string]struct {
+		header  map[string]string
+		missing bool
+		noMtime bool
+		fail    bool
+	}{
+		"unconditional":        {},
+		"match":                {header: map[string]string{"If-Match": etag}},
+		"match in list":        {header: map[string]string{"If-Match": `"x", ` + etag}},
+		"mismatch":             {header: map[string]string{"If-Match": `"x"`}, fail: true},
+		"weak":                 {header: map[string]string{"If-Match": "W/" + etag}, fail: true},
+		"any":                  {header: map[string]string{"If-Match": "*"}},
+		"any on missing":       {header: map[string]string{"If-Match": "*"}, missing: true, fail: true},
+		"unmodified":           {header: map[string]string{"If-Unmodified-Since": mod.Format(http.TimeFormat)}},
+		"modified since":       {header: map[string]string{"If-Unmodified-Since": mod.Add(-time.Second).Format(http.TimeFormat)}, fail: true},
+		"unknown mtime":        {header: map[string]string{"If-Unmodified-Since": mod.Add(-time.Hour).Format(http.TimeFormat)}, noMtime: true},
+		"bad date ignored":     {header: map[string]string{"If-Unmodified-Since": "yesterday"}},
+		"if-match wins":        {header: map[string]string{"If-Match": etag, "If-Unmodified-Since": mod.Add(-time.Hour).Format(http.TimeFormat)}},
+		"if-match wins (fail)": {header: map[string]string{"If-Match": `"x"`, "If-Unmodified-Since": mod.Format(http.TimeFormat)}, fail: true},
+	} {
+		header := http.Header{}
+		for k, v := range tc.header {
+			header.Set(k, v)
+		}
+		current, at := etag, mod
+		if tc.missing {
+			current = ""
+		}
+		if tc.noMtime {
+			at = time.Time{}
+		}
+		err := CheckEditPrecondition(header, current, at)
+		if tc.fail != errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("%s: err = %v, want fail %v", name, err, tc.fail)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"files/pkg/files"
 	"io"
 	"net/http"
 	"strings"
@@ -15,6 +16,14 @@ type QueryParam struct {
 	Ctx                     context.Context `json:"-"`
 	Owner                   string          `json:"owner"`
 	PreviewSize             string          `json:"previewSize"`
+	PreviewDPR              string          `json:"previewDPR,omitempty"`
+	PreviewWidth            int             `json:"previewWidth,omitempty"`    // set by preview.Negotiate
+	PreviewFormat           string          `json:"previewFormat,omitempty"`   // set by preview.Negotiate, "" keeps the source format
+	PreviewView             string          `json:"previewView,omitempty"`     // text: head or tail
+	PreviewLines            string          `json:"previewLines,omitempty"`    // text: line range, like 10-50
+	PreviewEncoding         string          `json:"previewEncoding,omitempty"` // text: "" detects it
+	PreviewPage             string          `json:"previewPage,omitempty"`     // csv: page of rows, from 1
+	PreviewLimit            string          `json:"previewLimit,omitempty"`    // csv: rows per page
 	PreviewEnableThumbnails bool            `json:"previewEnableThumbnails"`
 	PreviewResizePreview    bool            `json:"previewResizePreview"`
 	RawInline               string          `json:"rawInline,omitempty"`
@@ -34,6 +43,8 @@ type QueryParam struct {
 	ShareByType             string          `json:"shareByType,omitempty"`
 	Header                  http.Header     `json:"-"`
 	Body                    io.ReadCloser   `json:"-"`
+	// List pages and filters a List call, nil for a full listing.
+	List *files.ListOptions `json:"-"`
 }
 
 func CreateQueryParam(owner string, ctx context.Context, c *app.RequestContext, enableThumbnails bool, resizePreview bool) *QueryParam {
@@ -55,6 +66,12 @@ func CreateQueryParam(owner string, ctx context.Context, c *app.RequestContext,
 		Ctx:                     ctx,
 		Owner:                   owner,
 		PreviewSize:             sizeStr,
+		PreviewDPR:              strings.TrimSpace(c.Query("dpr")),
+		PreviewView:             strings.TrimSpace(c.Query("view")),
+		PreviewLines:            strings.TrimSpace(c.Query("lines")),
+		PreviewEncoding:         strings.TrimSpace(c.Query("encoding")),
+		PreviewPage:             strings.TrimSpace(c.Query("page")),
+		PreviewLimit:            strings.TrimSpace(c.Query("limit")),
 		PreviewEnableThumbnails: enableThumbnails, // todo
 		PreviewResizePreview:    resizePreview,    // todo
 		RawInline:               strings.TrimSpace(c.Query("inline")),
@@ -14,6 +14,8 @@ type PreviewHandlerResponse struct {
 	FileName     string    `json:"file_name"`
 	FileModified time.Time `json:"file_modified"`
 	Data         []byte    `json:"-"`
+	// ContentType of Data, sniffed from it when empty.
+	ContentType string `json:"content_type,omitempty"`
 
 	IsCloud    bool          `json:"is_cloud"`
 	RespHeader http.Header   `json:"header"`
@@ -8,18 +8,26 @@ type SambaMemberUserView struct {
 }
 
 type SambaShares struct {
-	Id          string               `json:"id"`
-	Owner       string               `json:"owner"`
-	FileType    string               `json:"fileType"`
-	Extend      string               `json:"extend"`
-	Path        string               `json:"path"`
-	ShareType   string               `json:"shareType"`
-	Name        string               `json:"name"`
-	ExpireIn    int64                `json:"expireIn"`
-	ExpireTime  string               `json:"expireTime"`
-	Permission  int32                `json:"permission"`
-	PublicShare bool                 `json:"publicShare"`
-	Members     []*SambaShareMembers `json:"members"`
+	Id            string               `json:"id"`
+	Owner         string               `json:"owner"`
+	FileType      string               `json:"fileType"`
+	Extend        string               `json:"extend"`
+	Path          string               `json:"path"`
+	ShareType     string               `json:"shareType"`
+	Name          string               `json:"name"`
+	ExpireIn      int64                `json:"expireIn"`
+	ExpireTime    string               `json:"expireTime"`
+	Permission    int32                `json:"permission"`
+	PublicShare   bool                 `json:"publicShare"`
+	TimeMachine   bool                 `json:"timeMachine"`
+	Quota         int64                `json:"quota"`
+	UsedBytes     int64                `json:"usedBytes"`
+	Recycle       bool                 `json:"recycle"`
+	RecycleDays   int32                `json:"recycleDays"`
+	SnapshotHours int32                `json:"snapshotHours"`
+	SnapshotKeep  int32                `json:"snapshotKeep"`
+	Audit         bool                 `json:"audit"`
+	Members       []*SambaShareMembers `json:"members"`
 }
 
 type SambaShareMembers struct {
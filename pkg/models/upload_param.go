@@ -27,6 +27,8 @@ type ResumableInfo struct {
 	ShareType                 string                `json:"sharetype" form:"sharetype"`
 	Shareby                   string                `json:"shareby" form:"shareby"`
 	SharebyPath               string                `json:"sharebyPath" form:"sharebyPath"`
+	Uploader                  string                `json:"uploader,omitempty" form:"uploader"` // file request uploader, set by the share proxy
+	UploaderEmail             string                `json:"uploaderEmail,omitempty" form:"uploaderEmail"`
 }
 
 type FileUploadArgs struct {
@@ -0,0 +1,49 @@
+package nfs
+
+import (
+	"fmt"
+	"os/exec"
+
+	"k8s.io/klog/v2"
+)
+
+type commands struct{}
+
+func (c *commands) Run() error {
+	var cmd = exec.Command("/usr/bin/nfs.sh")
+
+	output, _ := cmd.CombinedOutput()
+	klog.Infof("start nfsd output: %s", string(output))
+
+	return nil
+}
+
+// Reload syncs the kernel export table with /etc/exports.
+func (c *commands) Reload() error {
+	var cmd = exec.Command("exportfs", "-ra")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("exportfs -ra: %v, output: %s", err, string(output))
+	}
+	klog.Infof("reload exports output: %s", string(output))
+
+	return nil
+}
+
+// Shutdown withdraws every export and stops the nfsd threads, which
+// would otherwise keep serving after the container is gone.
+func (c *commands) Shutdown() error {
+	var cmd = exec.Command("exportfs", "-ua")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exportfs -ua: %v, output: %s", err, string(output))
+	}
+
+	cmd = exec.Command("rpc.nfsd", "0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rpc.nfsd 0: %v, output: %s", err, string(output))
+	}
+
+	klog.Info("nfs exports withdrawn, nfsd stopped")
+	return nil
+}
@@ -0,0 +1,312 @@
+package nfs
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"files/pkg/models"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// refreshInterval is how often the exports are rebuilt from the share
+	// rows; it bounds how long a new, changed, deleted or expired share
+	// takes to show up in /etc/exports.
+	refreshInterval = 30 * time.Second
+
+	SquashRoot   = "root_squash"
+	SquashAll    = "all_squash"
+	SquashNoRoot = "no_root_squash"
+
+	// minPrefixV4 and minPrefixV6 are the shortest CIDR prefixes a
+	// client may have, broader networks are too close to everyone.
+	minPrefixV4 = 8
+	minPrefixV6 = 32
+
+	// permissionEdit is PERMISSION_EDIT of the share api, the lowest
+	// permission exported read-write.
+	permissionEdit = 3
+)
+
+//go:embed template/exports.tmpl
+var exportsTemplateContent string
+
+type NfsExports struct {
+	Exports []NfsExport
+}
+
+type NfsExport struct {
+	Id string `json:"id"`
+	// Path is the local folder, escaped for /etc/exports.
+	Path    string   `json:"path"`
+	Comment string   `json:"comment"`
+	Clients []string `json:"clients"`
+	Options string   `json:"options"`
+}
+
+var NfsService *nfs
+
+type nfs struct {
+	commands *commands
+	// exports is the content last written to /etc/exports
+	exports []byte
+
+	cancelRefresh context.CancelFunc
+	refreshDone   chan struct{}
+
+	sync.Mutex
+}
+
+func NewNfsManager() {
+	NfsService = &nfs{
+		commands: new(commands),
+	}
+}
+
+func (n *nfs) Start() {
+	if err := n.commands.Run(); err != nil {
+		klog.Errorf("nfs start: nfsd run failed: %v", err)
+	}
+	n.generateExports()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancelRefresh = cancel
+	n.refreshDone = make(chan struct{})
+	go func() {
+		defer close(n.refreshDone)
+		n.refreshExports(ctx)
+	}()
+}
+
+// Stop ends the refresh loop, waits for it to exit (or returns ctx.Err()
+// on shutdown deadline) and withdraws every export. Safe to call multiple
+// times.
+func (n *nfs) Stop(ctx context.Context) error {
+	if n == nil || n.cancelRefresh == nil {
+		return nil
+	}
+	n.cancelRefresh()
+	select {
+	case <-n.refreshDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return n.commands.Shutdown()
+}
+
+func (n *nfs) refreshExports(ctx context.Context) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n.generateExports()
+	}
+}
+
+// generateExports renders /etc/exports from the nfs share rows and has
+// exportfs apply it. Nothing is reloaded while the content is unchanged.
+func (n *nfs) generateExports() {
+	n.Lock()
+	defer n.Unlock()
+
+	rows, err := database.QueryNfsShares()
+	if err != nil {
+		klog.Errorf("nfs get shares data error: %v", err)
+		return
+	}
+
+	exports := buildExports(rows, time.Now(), os.Getenv("NODE_NAME"), ExportPath)
+	content, err := renderExports(exports)
+	if err != nil {
+		klog.Errorf("nfs template generate error: %v", err)
+		return
+	}
+	if n.exports != nil && bytes.Equal(n.exports, content) {
+		return
+	}
+
+	klog.Infof("nfs exports content: \n%s\n", string(content))
+
+	if err = os.WriteFile(common.NfsExportsPath, content, 0644); err != nil {
+		klog.Errorf("nfs write exports error: %v", err)
+		return
+	}
+	if err = n.commands.Reload(); err != nil {
+		klog.Errorf("nfs reload error: %v", err)
+		return
+	}
+	n.exports = content
+
+	klog.Infof("nfs exports update done, exports: %d", len(exports.Exports))
+}
+
+// buildExports picks the shares this node exports: unexpired ones whose
+// folder is local, external and cache folders only on the node holding
+// them.
+func buildExports(rows []*share.SharePath, now time.Time, node string, resolve func(*share.SharePath) (string, error)) NfsExports {
+	var exports NfsExports
+	for _, item := range rows {
+		if item.FileType == common.External || item.FileType == common.Cache {
+			if item.Extend != node {
+				continue
+			}
+		}
+		expire, err := time.Parse(time.RFC3339Nano, item.ExpireTime)
+		if err != nil {
+			klog.Errorf("nfs sharePath time invalid, error: %v, time: %s, id: %s", err, item.ExpireTime, item.ID)
+			continue
+		}
+		if now.After(expire) { // exclude expired shares
+			continue
+		}
+
+		clients, err := ParseClients(item.NfsClients)
+		if err != nil {
+			klog.Errorf("nfs sharePath clients invalid, error: %v, id: %s", err, item.ID)
+			continue
+		}
+		// no_root_squash was only accepted from a platform admin
+		squash, err := ParseSquash(item.NfsSquash, true)
+		if err != nil {
+			klog.Errorf("nfs sharePath squash invalid, error: %v, id: %s", err, item.ID)
+			continue
+		}
+		dir, err := resolve(item)
+		if err != nil {
+			klog.Errorf("nfs resolve share %s path error: %v", item.ID, err)
+			continue
+		}
+
+		mode := "ro"
+		if Writable(item.Permission) {
+			mode = "rw"
+		}
+		exports.Exports = append(exports.Exports, NfsExport{
+			Id:      item.ID,
+			Path:    escapeExportPath(dir),
+			Comment: fmt.Sprintf("%s_%s_%s", item.Owner, item.ID, strings.Join(strings.Fields(item.Name), "_")),
+			Clients: clients,
+			// the share id is a stable fsid, needed to export folders
+			// on overlay and fuse mounts
+			Options: fmt.Sprintf("%s,sync,no_subtree_check,%s,fsid=%s", mode, squash, item.ID),
+		})
+	}
+	return exports
+}
+
+func renderExports(exports NfsExports) ([]byte, error) {
+	tmpl, err := template.New("exports").Parse(exportsTemplateContent)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, exports); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportPath is the local folder an nfs share exports, which is also the
+// path clients mount.
+func ExportPath(item *share.SharePath) (string, error) {
+	fp, err := models.CreateFileParam(item.Owner, fmt.Sprintf("/%s/%s%s", item.FileType, item.Extend, item.Path))
+	if err != nil {
+		return "", err
+	}
+	uri, err := fp.GetResourceUri()
+	if err != nil {
+		return "", err
+	}
+	return uri + strings.TrimSuffix(fp.Path, "/"), nil
+}
+
+// ParseClients normalises a comma separated list of client IPs and CIDRs,
+// dropping duplicates. At least one client is required; nfs shares are
+// never exported to everyone, nor to networks broader than /8 for IPv4
+// or /32 for IPv6.
+func ParseClients(s string) ([]string, error) {
+	var clients []string
+	var seen = make(map[string]bool)
+	for _, c := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		var client string
+		if strings.Contains(c, "/") {
+			_, ipNet, err := net.ParseCIDR(c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nfs client %q", c)
+			}
+			ones, bits := ipNet.Mask.Size()
+			if (bits == 8*net.IPv4len && ones < minPrefixV4) || (bits == 8*net.IPv6len && ones < minPrefixV6) {
+				return nil, fmt.Errorf("nfs client network %q is too broad", c)
+			}
+			client = ipNet.String()
+		} else if ip := net.ParseIP(c); ip != nil {
+			client = ip.String()
+		} else {
+			return nil, fmt.Errorf("invalid nfs client %q", c)
+		}
+		if !seen[client] {
+			seen[client] = true
+			clients = append(clients, client)
+		}
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("nfs share must have at least one client")
+	}
+	return clients, nil
+}
+
+// ParseSquash validates an nfs squash option, root_squash when empty.
+// no_root_squash lets the root of a client act as root on the host
+// folder; it is only allowed when allowNoRoot is set, for platform
+// admins.
+func ParseSquash(s string, allowNoRoot bool) (string, error) {
+	switch s {
+	case "":
+		return SquashRoot, nil
+	case SquashRoot, SquashAll:
+		return s, nil
+	case SquashNoRoot:
+		if allowNoRoot {
+			return s, nil
+		}
+		return "", errors.New("no_root_squash is only allowed to platform admins")
+	}
+	return "", fmt.Errorf("invalid nfs squash %q", s)
+}
+
+// Writable reports whether a share with the given permission is exported
+// read-write; view only shares are exported read-only.
+func Writable(permission int32) bool {
+	return permission >= permissionEdit
+}
+
+// escapeExportPath octal-escapes the characters /etc/exports would read
+// as separators, comments or quotes.
+func escapeExportPath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c <= ' ' || c == 0x7f || c == '\\' || c == '#' || c == '"' {
+			fmt.Fprintf(&b, "\\%03o", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
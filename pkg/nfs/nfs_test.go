@@ -0,0 +1,80 @@
+package nfs
+
+import (
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/model/api/share"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildExports(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour).Format(time.RFC3339Nano)
+	rows := []*share.SharePath{
+		{ID: "11111111-1111-1111-1111-111111111111", Owner: "alice", FileType: common.Drive, Extend: "Home", Path: "/Lab Data/",
+			Name: "Lab Data", ExpireTime: future, Permission: 3, NfsClients: "10.0.0.0/24,192.168.1.20", NfsSquash: "all_squash"},
+		{ID: "22222222-2222-2222-2222-222222222222", Owner: "alice", FileType: common.External, Extend: "node-1", Path: "/usb/",
+			Name: "usb", ExpireTime: future, Permission: 1, NfsClients: "10.0.0.9"},
+		// expired
+		{ID: "33333333-3333-3333-3333-333333333333", Owner: "alice", FileType: common.Drive, Extend: "Home", Path: "/old/",
+			Name: "old", ExpireTime: now.Add(-time.Minute).Format(time.RFC3339Nano), Permission: 3, NfsClients: "10.0.0.9"},
+		// on another node
+		{ID: "44444444-4444-4444-4444-444444444444", Owner: "alice", FileType: common.Cache, Extend: "node-2", Path: "/c/",
+			Name: "c", ExpireTime: future, Permission: 3, NfsClients: "10.0.0.9"},
+		// unresolvable
+		{ID: "55555555-5555-5555-5555-555555555555", Owner: "gone", FileType: common.Drive, Extend: "Home", Path: "/x/",
+			Name: "x", ExpireTime: future, Permission: 3, NfsClients: "10.0.0.9"},
+	}
+	resolve := func(item *share.SharePath) (string, error) {
+		if item.Owner == "gone" {
+			return "", errors.New("pvc user not found")
+		}
+		return "/data/" + item.Extend + strings.TrimSuffix(item.Path, "/"), nil
+	}
+
+	exports := buildExports(rows, now, "node-1", resolve)
+	if len(exports.Exports) != 2 {
+		t.Fatalf("exports = %+v, want 2", exports.Exports)
+	}
+	content, err := renderExports(exports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := string(content)
+	for _, want := range []string{
+		`/data/Home/Lab\040Data 10.0.0.0/24(rw,sync,no_subtree_check,all_squash,fsid=11111111-1111-1111-1111-111111111111) 192.168.1.20(rw,`,
+		"/data/node-1/usb 10.0.0.9(ro,sync,no_subtree_check,root_squash,fsid=22222222-2222-2222-2222-222222222222)\n",
+		"# alice_11111111-1111-1111-1111-111111111111_Lab_Data\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("exports missing %q:\n%s", want, conf)
+		}
+	}
+}
+
+func TestParseClients(t *testing.T) {
+	got, err := ParseClients("10.0.0.5/24 fd00::1, 10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "10.0.0.0/24,fd00::1" {
+		t.Fatalf("clients = %v", got)
+	}
+	if got, err = ParseClients("10.0.0.0/8,fd00::/32"); err != nil || len(got) != 2 {
+		t.Errorf("broadest networks: %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "*", "host.lan", "10.0.0.0/33", "0.0.0.0/0", "::/0", "10.0.0.0/7", "fd00::/31", "10.0.0.1,0.0.0.0/0"} {
+		if _, err = ParseClients(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestEscapeExportPath(t *testing.T) {
+	if got := escapeExportPath("/data/a b/#c\\d\"e"); got != `/data/a\040b/\043c\134d\042e` {
+		t.Fatalf("escapeExportPath = %s", got)
+	}
+}
@@ -0,0 +1,67 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"files/pkg/common"
+	"files/pkg/diskcache"
+	"files/pkg/img"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
+)
+
+// photoExtensions are the files ImageMetadata reads EXIF from.
+var photoExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true,
+	".heic": true, ".heif": true,
+	".tif": true, ".tiff": true,
+	".avif": true,
+}
+
+func IsPhoto(name string) bool {
+	return photoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// ImageMetadata returns the metadata of the image at name in fs. It is
+// kept in owner's preview cache under the path and modification time,
+// so it is read again only once the file changes.
+func ImageMetadata(owner string, fs afero.Fs, fileType, extend, name string, modTime time.Time) (*img.Metadata, error) {
+	var fileCache = diskcache.GetFileCache()
+	var key = diskcache.GenerateSourceCacheKey(fileType, extend, name, modTime.String(), common.CacheMetadata)
+
+	if fileCache != nil {
+		data, ok, err := fileCache.Load(context.Background(), owner, key, common.CacheMetadata)
+		if err != nil {
+			klog.Errorf("[preview] load metadata cache error: %v, file: %s", err, name)
+		} else if ok {
+			var md img.Metadata
+			if err = json.Unmarshal(data, &md); err == nil {
+				return &md, nil
+			}
+		}
+	}
+
+	fd, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	md, err := img.ReadMetadata(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileCache != nil {
+		if data, e := json.Marshal(md); e == nil {
+			if cerr := fileCache.Store(context.Background(), owner, key, common.CacheMetadata, data); cerr != nil {
+				klog.Errorf("[preview] store metadata cache error: %v, file: %s", cerr, name)
+			}
+		}
+	}
+	return md, nil
+}
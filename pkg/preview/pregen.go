@@ -0,0 +1,317 @@
+package preview
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"files/pkg/common"
+	"files/pkg/files"
+	"files/pkg/img"
+	"files/pkg/models"
+
+	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
+)
+
+// Pre-generation renders the thumbnails and big previews of images as
+// soon as they are uploaded or pasted, so the folder they land in opens
+// from the cache. A single worker drains a bounded queue and steps back
+// while previews someone waits for are being rendered.
+//
+// Only the local storages are covered; the external mounts may hang and
+// are left to the previews asked for.
+
+const (
+	pregenLogPrefix = "[preview pregen]"
+	pregenQueueCap  = 4096
+	// pregenMaxFiles bounds the images rendered for one pasted folder.
+	pregenMaxFiles = 10000
+	// pregenBackoff is how long the worker waits before looking again
+	// whether interactive previews are done.
+	pregenBackoff = 500 * time.Millisecond
+)
+
+var (
+	pregenFileTypes = []string{common.Drive, common.Cache}
+	pregenSizes     = []PreviewSize{PreviewSizeThumb, PreviewSizeBig}
+	// pregenDPRs are the device pixel ratios of the screens the web
+	// client asks previews for, the most common first.
+	pregenDPRs = []string{"1", "2", "3"}
+	// pregenAccepts are the Accept headers of the browsers the web client
+	// runs in: current ones, negotiating WebP, then those taking the
+	// source format or JPEG only.
+	pregenAccepts = []string{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", "image/*"}
+)
+
+type pregenWorker struct {
+	mu      sync.Mutex
+	queue   chan *models.FileParam
+	pending map[string]bool
+	stop    chan struct{}
+	done    chan struct{}
+	dropped uint64
+	// render pre-generates the previews of one file or folder.
+	render func(fileParam *models.FileParam, stop <-chan struct{})
+}
+
+var pregen atomic.Pointer[pregenWorker]
+
+// StartPregen launches the pre-generation worker. Idempotent.
+func StartPregen() {
+	w := newPregenWorker(pregenerate)
+	if pregen.CompareAndSwap(nil, w) {
+		go w.run()
+		klog.Infof("%s started, queueCap=%d", pregenLogPrefix, pregenQueueCap)
+	}
+}
+
+// StopPregen stops the worker once the file it renders is done; the
+// files still queued are dropped. ctx bounds the wait.
+func StopPregen(ctx context.Context) error {
+	w := pregen.Load()
+	if w == nil {
+		return nil
+	}
+	return w.shutdown(ctx)
+}
+
+// Pregenerate queues the previews of the file or folder fileParam for
+// the background worker. It never blocks: paths already queued are
+// skipped and, when the queue is full, the path is dropped.
+func Pregenerate(fileParam *models.FileParam) {
+	if w := pregen.Load(); w != nil {
+		w.enqueue(fileParam)
+	}
+}
+
+func newPregenWorker(render func(*models.FileParam, <-chan struct{})) *pregenWorker {
+	return &pregenWorker{
+		queue:   make(chan *models.FileParam, pregenQueueCap),
+		pending: make(map[string]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		render:  render,
+	}
+}
+
+func pregenKey(fileParam *models.FileParam) string {
+	return fileParam.Owner + ":" + filepath.Join(fileParam.FileType, fileParam.Extend, fileParam.Path)
+}
+
+func (w *pregenWorker) enqueue(fileParam *models.FileParam) {
+	if fileParam == nil || !slices.Contains(pregenFileTypes, fileParam.FileType) {
+		return
+	}
+	key := pregenKey(fileParam)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending[key] {
+		return
+	}
+	select {
+	case <-w.stop:
+		return
+	default:
+	}
+	select {
+	case w.queue <- fileParam:
+		w.pending[key] = true
+	default:
+		w.dropped++
+		if w.dropped == 1 || w.dropped%100 == 0 {
+			klog.Warningf("%s queue full (cap=%d), dropped path=%s total_dropped=%d",
+				pregenLogPrefix, pregenQueueCap, fileParam.Path, w.dropped)
+		}
+	}
+}
+
+func (w *pregenWorker) run() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case fileParam := <-w.queue:
+			w.mu.Lock()
+			delete(w.pending, pregenKey(fileParam))
+			w.mu.Unlock()
+			w.safeRender(fileParam)
+		}
+	}
+}
+
+func (w *pregenWorker) safeRender(fileParam *models.FileParam) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("%s panic on %s: %v", pregenLogPrefix, fileParam.Path, r)
+		}
+	}()
+	w.render(fileParam, w.stop)
+}
+
+func (w *pregenWorker) shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitIdle waits until no interactive preview is being rendered. It is
+// false when the worker was stopped meanwhile.
+func waitIdle(busy func() bool, stop <-chan struct{}) bool {
+	for {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		if !busy() {
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-time.After(pregenBackoff):
+		}
+	}
+}
+
+// pregenEligible tells whether previews of the file named name are
+// rendered and cached, the images whose format is resized; the others
+// are served as they are.
+func pregenEligible(name string) bool {
+	format, err := img.GetImageService().FormatFromExtension(filepath.Ext(name))
+	return err == nil && format != img.FormatGif
+}
+
+func pregenerate(fileParam *models.FileParam, stop <-chan struct{}) {
+	uri, err := fileParam.GetResourceUri()
+	if err != nil {
+		klog.Warningf("%s resource uri of %s: %v", pregenLogPrefix, fileParam.Path, err)
+		return
+	}
+	fsys := afero.NewBasePathFs(afero.NewOsFs(), uri)
+
+	info, err := fsys.Stat(fileParam.Path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		pregenerateFile(fsys, fileParam, stop)
+		return
+	}
+
+	count := 0
+	_ = afero.Walk(fsys, fileParam.Path, func(p string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") && p != fileParam.Path {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !pregenEligible(fi.Name()) {
+			return nil
+		}
+		count++
+		if count > pregenMaxFiles {
+			return filepath.SkipAll
+		}
+		child := &models.FileParam{
+			Owner:    fileParam.Owner,
+			FileType: fileParam.FileType,
+			Extend:   fileParam.Extend,
+			Path:     p,
+		}
+		if !pregenerateFile(fsys, child, stop) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+// pregenVariants are the previews pre-generated of each image: those of
+// pregenSizes the web client asks for on the screens of pregenDPRs in
+// the browsers of pregenAccepts, each rendition once.
+func pregenVariants(owner string) []*models.QueryParam {
+	var variants []*models.QueryParam
+	var seen = make(map[string]bool)
+	for _, accept := range pregenAccepts {
+		for _, size := range pregenSizes {
+			for _, dpr := range pregenDPRs {
+				queryParam := &models.QueryParam{
+					Ctx:         img.Background(context.Background()),
+					Owner:       owner,
+					PreviewSize: size.String(),
+					PreviewDPR:  dpr,
+					Header:      http.Header{"Accept": {accept}},
+				}
+				if err := Negotiate(queryParam); err != nil {
+					continue
+				}
+				if v := variant(queryParam); !seen[v] {
+					seen[v] = true
+					variants = append(variants, queryParam)
+				}
+			}
+		}
+	}
+	return variants
+}
+
+// pregenerateFile renders the missing previews of the file fileParam.
+// It is false when the worker was stopped.
+func pregenerateFile(fsys afero.Fs, fileParam *models.FileParam, stop <-chan struct{}) bool {
+	if !pregenEligible(fileParam.Path) {
+		return true
+	}
+	busy := img.GetImageService().Busy
+
+	for _, queryParam := range pregenVariants(fileParam.Owner) {
+		if !waitIdle(busy, stop) {
+			return false
+		}
+
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:       fsys,
+			FsType:   fileParam.FileType,
+			FsExtend: fileParam.Extend,
+			Path:     fileParam.Path,
+			Expand:   true,
+			Content:  true,
+		})
+		if err != nil || file == nil || file.Type != "image" {
+			return true
+		}
+
+		key := CacheKey(fileParam, file.ModTime.String(), queryParam)
+		if _, ok, _ := GetPreviewCache(fileParam.Owner, key, common.CacheThumb); ok {
+			continue
+		}
+		if _, err := CreatePreview(fileParam.Owner, key, file, queryParam); err != nil {
+			klog.Warningf("%s %s size %s width %d: %v", pregenLogPrefix, fileParam.Path, queryParam.PreviewSize, queryParam.PreviewWidth, err)
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"files/pkg/models"
+)
+
+func TestPregenQueue(t *testing.T) {
+	var mu sync.Mutex
+	var rendered []string
+	release := make(chan struct{})
+	w := newPregenWorker(func(fp *models.FileParam, stop <-chan struct{}) {
+		<-release
+		mu.Lock()
+		rendered = append(rendered, fp.Path)
+		mu.Unlock()
+	})
+
+	photo := &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/Pictures/a.jpg"}
+	w.enqueue(photo)
+	w.enqueue(&models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/Pictures/a.jpg"})
+	w.enqueue(&models.FileParam{Owner: "alice", FileType: "sync", Extend: "repo", Path: "/b.jpg"})
+	w.enqueue(&models.FileParam{Owner: "alice", FileType: "external", Extend: "usb", Path: "/c.jpg"})
+	w.enqueue(nil)
+	if n := len(w.queue); n != 1 {
+		t.Fatalf("queued = %d, want 1", n)
+	}
+
+	go w.run()
+	release <- struct{}{}
+	// once taken off the queue, the path can be queued again
+	deadline := time.Now().Add(time.Second)
+	for {
+		w.mu.Lock()
+		pending := len(w.pending)
+		w.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("path still pending")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	w.enqueue(photo)
+	release <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for {
+		mu.Lock()
+		n := len(rendered)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("rendered = %d, want 2", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stopped worker takes nothing more
+	w.enqueue(photo)
+	if n := len(w.queue); n != 0 {
+		t.Errorf("queued after stop = %d", n)
+	}
+}
+
+func TestPregenQueueFull(t *testing.T) {
+	w := newPregenWorker(func(*models.FileParam, <-chan struct{}) {})
+	for i := 0; i < pregenQueueCap+10; i++ {
+		w.enqueue(&models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: fmt.Sprintf("/%d.jpg", i)})
+	}
+	if len(w.queue) != pregenQueueCap || w.dropped != 10 || len(w.pending) != pregenQueueCap {
+		t.Errorf("queued %d, pending %d, dropped %d", len(w.queue), len(w.pending), w.dropped)
+	}
+}
+
+func TestWaitIdle(t *testing.T) {
+	stop := make(chan struct{})
+	calls := 0
+	busy := func() bool {
+		calls++
+		return calls < 2
+	}
+	if !waitIdle(busy, stop) || calls != 2 {
+		t.Errorf("waitIdle after %d calls", calls)
+	}
+
+	close(stop)
+	if waitIdle(func() bool { return true }, stop) {
+		t.Error("waitIdle true on a stopped worker")
+	}
+}
+
+func TestPregenVariants(t *testing.T) {
+	var got []string
+	for _, qp := range pregenVariants("alice") {
+		got = append(got, variant(qp))
+	}
+	want := []string{
+		"thumb|256|webp", "thumb|512|webp", "thumb|1000|webp",
+		"big|1000|webp", "big|2048|webp",
+		"thumb|256|jpeg", "thumb|512|jpeg", "thumb|1000|jpeg",
+		"big|1000|", "big|2048|",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("variants = %v, want %v", got, want)
+	}
+}
+
+func TestPregenEligible(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.jpg":  true,
+		"b.PNG":  true,
+		"c.webp": true,
+		"d.gif":  false,
+		"e.heic": false,
+		"f.svg":  false,
+		"g.txt":  false,
+	} {
+		if got := pregenEligible(name); got != want {
+			t.Errorf("pregenEligible(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"files/pkg/models"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"strconv"
 	"strings"
 
 	"k8s.io/klog/v2"
@@ -19,6 +22,9 @@ import (
 ENUM(
 thumb
 big
+small
+medium
+large
 )
 */
 type PreviewSize int
@@ -28,13 +34,162 @@ type ImgService interface {
 	Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...img.Option) error
 }
 
-var (
-	W1000 = 1000
-	H1000 = 1000
+// sizePixels is the box a preview of each size fits in on a screen of
+// one device pixel per CSS pixel.
+var sizePixels = map[PreviewSize]int{
+	PreviewSizeSmall:  128,
+	PreviewSizeThumb:  256,
+	PreviewSizeMedium: 512,
+	PreviewSizeBig:    1000,
+	PreviewSizeLarge:  2048,
+}
 
-	W256 = 256
-	H256 = 256
-)
+// pixelSteps are the boxes previews are rendered to. A size scaled by
+// the DPR of the screen is rounded up to one of them, so a file has few
+// renditions in the cache whatever screens ask for it.
+var pixelSteps = []int{128, 256, 512, 1000, 2048}
+
+// MaxDPR is the highest device pixel ratio previews are scaled for.
+const MaxDPR = 3
+
+// Thumbnail tells whether previews of size x are thumbnails: cropped to
+// a square and drawn at low quality.
+func (x PreviewSize) Thumbnail() bool {
+	return x == PreviewSizeThumb || x == PreviewSizeSmall
+}
+
+// IsThumbnail tells whether the preview size named size is a thumbnail.
+func IsThumbnail(size string) bool {
+	x, err := ParsePreviewSize(size)
+	return err == nil && x.Thumbnail()
+}
+
+// Negotiate settles the box and the format of the preview queryParam
+// asks for, from its size, the DPR of the screen and the formats the
+// client accepts. Without a size, the preview is big.
+func Negotiate(queryParam *models.QueryParam) error {
+	if queryParam.PreviewSize == "" {
+		queryParam.PreviewSize = PreviewSizeBig.String()
+	}
+	size, err := ParsePreviewSize(queryParam.PreviewSize)
+	if err != nil {
+		return err
+	}
+	queryParam.PreviewWidth = scaledPixels(size, parseDPR(queryParam.PreviewDPR))
+	queryParam.PreviewFormat = acceptedFormat(queryParam.Header.Get("Accept"), size)
+	return nil
+}
+
+func parseDPR(s string) float64 {
+	dpr, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(dpr) || dpr < 1 {
+		return 1
+	}
+	return math.Min(dpr, MaxDPR)
+}
+
+func scaledPixels(size PreviewSize, dpr float64) int {
+	need := int(math.Ceil(float64(sizePixels[size]) * dpr))
+	for _, p := range pixelSteps {
+		if p >= need {
+			return p
+		}
+	}
+	return pixelSteps[len(pixelSteps)-1]
+}
+
+// acceptedFormat picks the format a preview is encoded to from the
+// Accept header: AVIF or WebP when the client names them, the one with
+// the higher q value winning and WebP, faster to encode, breaking ties.
+// Otherwise thumbnails are JPEG and other sizes keep the source format,
+// "".
+func acceptedFormat(accept string, size PreviewSize) string {
+	var qWebp, qAvif float64
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		q := 1.0
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case "image/webp":
+			qWebp = q
+		case "image/avif":
+			qAvif = q
+		}
+	}
+
+	switch {
+	case qAvif > qWebp:
+		return img.FormatAvif.String()
+	case qWebp > 0:
+		return img.FormatWebp.String()
+	case size.Thumbnail():
+		return img.FormatJpeg.String()
+	}
+	return ""
+}
+
+// CacheKey is the key of the preview queryParam asks for of the file
+// fileParam in its version.
+func CacheKey(fileParam *models.FileParam, version string, queryParam *models.QueryParam) string {
+	if queryParam.PreviewWidth == 0 {
+		// not negotiated, an unknown size fails in CreatePreview
+		_ = Negotiate(queryParam)
+	}
+	return diskcache.GenerateSourceCacheKey(fileParam.FileType, fileParam.Extend, fileParam.Path, version, variant(queryParam))
+}
+
+// variant names the rendition of a negotiated preview: requests naming
+// the same one share it in the cache.
+func variant(queryParam *models.QueryParam) string {
+	return fmt.Sprintf("%s|%d|%s", queryParam.PreviewSize, queryParam.PreviewWidth, queryParam.PreviewFormat)
+}
+
+// Invalidate drops what the preview cache keeps of the file or folder
+// fileParam, once it is deleted or changed.
+func Invalidate(fileParam *models.FileParam) {
+	if fileParam == nil {
+		return
+	}
+	if fileCache := diskcache.GetFileCache(); fileCache != nil {
+		fileCache.Invalidate(fileParam.Owner, diskcache.SourceKey(fileParam.FileType, fileParam.Extend, fileParam.Path))
+	}
+}
+
+// ContentType is the media type of a preview. It is told from the bytes,
+// as a preview may be encoded in another format than the file it shows.
+func ContentType(data []byte, name string) string {
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "avif", "avis":
+			return img.FormatAvif.MimeType()
+		case "heic", "heix", "mif1":
+			return "image/heic"
+		}
+	}
+	if t := http.DetectContentType(data); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return common.MimeTypeByExtension(name)
+}
+
+func renderOptions(size PreviewSize, format string) []img.Option {
+	var options []img.Option
+	if size.Thumbnail() {
+		options = append(options, img.WithMode(img.ResizeModeFill), img.WithQuality(img.QualityLow))
+	} else {
+		options = append(options, img.WithMode(img.ResizeModeFit), img.WithQuality(img.QualityMedium))
+	}
+	if f, err := img.ParseFormat(format); err == nil {
+		options = append(options, img.WithFormat(f))
+	}
+	return options
+}
 
 func GetPreviewCache(owner string, key string, tag string) ([]byte, bool, error) {
 	var fileCache = diskcache.GetFileCache()
@@ -48,8 +203,19 @@ func CreatePreview(owner string, key string,
 	var fileCache = diskcache.GetFileCache()
 	var imgSvc = img.GetImageService()
 	var size = queryParam.PreviewSize
+	var ctx = queryParam.Ctx
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+
+	if queryParam.PreviewWidth == 0 {
+		if err := Negotiate(queryParam); err != nil {
+			return nil, err
+		}
+	}
+	var width = queryParam.PreviewWidth
 
-	klog.Infof("[preview] file: %s, key: %s, size: %s", bufferFile.Path, key, size)
+	klog.Infof("[preview] file: %s, key: %s, size: %s, width: %d, format: %s", bufferFile.Path, key, size, width, queryParam.PreviewFormat)
 
 	var previewSize, err = ParsePreviewSize(size)
 	if err != nil {
@@ -71,11 +237,10 @@ func CreatePreview(owner string, key string,
 		}
 
 		ext := strings.ToLower(bufferFile.Extension)
-		if (ext == ".heic" || ext == ".heif") && previewSize == PreviewSizeThumb {
+		if (ext == ".heic" || ext == ".heif") && previewSize.Thumbnail() {
 			buf := &bytes.Buffer{}
-			if e3 := imgSvc.Resize(context.TODO(), bytes.NewReader(data), W256, H256, buf,
-				img.WithMode(img.ResizeModeFill), img.WithQuality(img.QualityLow),
-				img.WithFormat(img.FormatJpeg)); e3 == nil {
+			if e3 := imgSvc.Resize(ctx, bytes.NewReader(data), width, width, buf,
+				renderOptions(previewSize, queryParam.PreviewFormat)...); e3 == nil {
 				return buf.Bytes(), nil
 			}
 		}
@@ -94,27 +259,8 @@ func CreatePreview(owner string, key string,
 	}
 	defer fd.Close()
 
-	var (
-		width   int
-		height  int
-		options []img.Option
-	)
-
-	switch {
-	case previewSize == PreviewSizeBig:
-		width = W1000
-		height = H1000
-		options = append(options, img.WithMode(img.ResizeModeFit), img.WithQuality(img.QualityMedium))
-	case previewSize == PreviewSizeThumb:
-		width = W256
-		height = H256
-		options = append(options, img.WithMode(img.ResizeModeFill), img.WithQuality(img.QualityLow), img.WithFormat(img.FormatJpeg))
-	default:
-		return nil, img.ErrUnsupportedFormat
-	}
-
 	buf := &bytes.Buffer{}
-	if err = imgSvc.Resize(context.TODO(), fd, width, height, buf, options...); err != nil {
+	if err = imgSvc.Resize(ctx, fd, width, width, buf, renderOptions(previewSize, queryParam.PreviewFormat)...); err != nil {
 		return nil, err
 	}
 
@@ -125,7 +271,7 @@ func CreatePreview(owner string, key string,
 	// error reference (the previous code shared `err` across Store /
 	// Chown / return, making it easy to lose information silently in
 	// future edits).
-	if cerr := fileCache.Store(context.TODO(), owner, key, common.CacheThumb, buf.Bytes()); cerr != nil {
+	if cerr := fileCache.Store(ctx, owner, key, common.CacheThumb, buf.Bytes()); cerr != nil {
 		klog.Errorf("preview store failed, user: %s, key: %s, error: %v", owner, key, cerr)
 	}
 
@@ -14,13 +14,22 @@ const (
 	PreviewSizeThumb PreviewSize = iota
 	// PreviewSizeBig is a PreviewSize of type Big
 	PreviewSizeBig
+	// PreviewSizeSmall is a PreviewSize of type Small
+	PreviewSizeSmall
+	// PreviewSizeMedium is a PreviewSize of type Medium
+	PreviewSizeMedium
+	// PreviewSizeLarge is a PreviewSize of type Large
+	PreviewSizeLarge
 )
 
-const _PreviewSizeName = "thumbbig"
+const _PreviewSizeName = "thumbbigsmallmediumlarge"
 
 var _PreviewSizeNames = []string{
 	_PreviewSizeName[0:5],
 	_PreviewSizeName[5:8],
+	_PreviewSizeName[8:13],
+	_PreviewSizeName[13:19],
+	_PreviewSizeName[19:24],
 }
 
 // PreviewSizeNames returns a list of possible string values of PreviewSize.
@@ -33,6 +42,9 @@ func PreviewSizeNames() []string {
 var _PreviewSizeMap = map[PreviewSize]string{
 	0: _PreviewSizeName[0:5],
 	1: _PreviewSizeName[5:8],
+	2: _PreviewSizeName[8:13],
+	3: _PreviewSizeName[13:19],
+	4: _PreviewSizeName[19:24],
 }
 
 // String implements the Stringer interface.
@@ -44,8 +56,11 @@ func (x PreviewSize) String() string {
 }
 
 var _PreviewSizeValue = map[string]PreviewSize{
-	_PreviewSizeName[0:5]: 0,
-	_PreviewSizeName[5:8]: 1,
+	_PreviewSizeName[0:5]:   0,
+	_PreviewSizeName[5:8]:   1,
+	_PreviewSizeName[8:13]:  2,
+	_PreviewSizeName[13:19]: 3,
+	_PreviewSizeName[19:24]: 4,
 }
 
 // ParsePreviewSize attempts to convert a string to a PreviewSize
@@ -0,0 +1,87 @@
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"strings"
+	"testing"
+
+	"files/pkg/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, c := range []struct {
+		size, dpr, accept string
+		width             int
+		format            string
+	}{
+		{"thumb", "", "", 256, "jpeg"},
+		{"big", "", "", 1000, ""},
+		{"", "", "", 1000, ""},
+		{"small", "2", "image/avif,image/webp,image/*,*/*;q=0.8", 256, "webp"},
+		{"thumb", "1.5", "image/webp;q=0.5, image/avif", 512, "avif"},
+		{"medium", "3", "image/avif", 2048, "avif"},
+		{"big", "1.25", "image/png", 2048, ""},
+		{"large", "0.5", "image/webp;q=0", 2048, ""},
+		{"thumb", "bad", "*/*", 256, "jpeg"},
+	} {
+		qp := &models.QueryParam{PreviewSize: c.size, PreviewDPR: c.dpr, Header: http.Header{"Accept": {c.accept}}}
+		if err := Negotiate(qp); err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+		if qp.PreviewWidth != c.width || qp.PreviewFormat != c.format {
+			t.Errorf("%s@%s %q: got %d %q, want %d %q", c.size, c.dpr, c.accept, qp.PreviewWidth, qp.PreviewFormat, c.width, c.format)
+		}
+	}
+
+	if err := Negotiate(&models.QueryParam{PreviewSize: "huge"}); err == nil {
+		t.Error("unknown size negotiated")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	fp := &models.FileParam{Owner: "alice", FileType: "drive", Extend: "Home", Path: "/Pictures/a.jpg"}
+	keys := map[string]bool{}
+	for _, qp := range []*models.QueryParam{
+		{PreviewSize: "thumb"},
+		{PreviewSize: "thumb", PreviewDPR: "2"},
+		{PreviewSize: "medium"},
+		{PreviewSize: "thumb", Header: http.Header{"Accept": {"image/webp"}}},
+	} {
+		key := CacheKey(fp, "v1", qp)
+		if !strings.HasPrefix(key, "drive/Home/Pictures/a.jpg/") {
+			t.Errorf("key %s is not under the file", key)
+		}
+		keys[key] = true
+	}
+	if len(keys) != 4 {
+		t.Errorf("renditions share keys: %d keys for 4", len(keys))
+	}
+	if CacheKey(fp, "v2", &models.QueryParam{PreviewSize: "thumb"}) == CacheKey(fp, "v1", &models.QueryParam{PreviewSize: "thumb"}) {
+		t.Error("versions share a key")
+	}
+}
+
+func TestContentType(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	avif := append([]byte{0, 0, 0, 0x1c}, []byte("ftypavif")...)
+	for _, c := range []struct {
+		data []byte
+		name string
+		want string
+	}{
+		{buf.Bytes(), "photo.png", "image/jpeg"},
+		{avif, "photo.jpg", "image/avif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "photo.jpg", "image/webp"},
+		{[]byte("<svg></svg>"), "logo.svg", "image/svg+xml"},
+	} {
+		if got := ContentType(c.data, c.name); got != c.want {
+			t.Errorf("ContentType(%s) = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
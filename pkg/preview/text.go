@@ -0,0 +1,530 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"files/pkg/files"
+	"files/pkg/models"
+	"files/pkg/textenc"
+
+	"github.com/spf13/afero"
+)
+
+// Text previews show text and code files decoded to UTF-8, as JSON the
+// client renders: a window of the text, its head by default, its tail or
+// a range of lines; markdown with its outline, CSV a page of rows and
+// JSON pretty-printed. Code is named by its language for highlighting.
+
+const (
+	// TextMaxBytes bounds the text of a preview; longer files are cut
+	// at a line break.
+	TextMaxBytes = 256 << 10
+	// textScanMaxBytes bounds how far into a file line ranges and CSV
+	// pages are looked for.
+	textScanMaxBytes = 64 << 20
+	// jsonMaxBytes bounds the JSON files pretty-printed, larger ones are
+	// shown as text.
+	jsonMaxBytes = 4 << 20
+	// textSniffBytes is how much of a file its encoding is detected from.
+	textSniffBytes = 64 << 10
+
+	csvDefaultLimit = 100
+	csvMaxLimit     = 1000
+)
+
+// TextContentType is the content type of text previews.
+const TextContentType = "application/json; charset=utf-8"
+
+// Kinds of text previews.
+const (
+	TextKindText     = "text"
+	TextKindMarkdown = "markdown"
+	TextKindCSV      = "csv"
+	TextKindJSON     = "json"
+)
+
+// Views of the text of a preview.
+const (
+	TextViewHead  = "head"
+	TextViewTail  = "tail"
+	TextViewLines = "lines"
+)
+
+var errThumbnailText = errors.New("text files have no thumbnail")
+
+// TextPreview is the preview of a text file.
+type TextPreview struct {
+	Kind string `json:"kind"`
+	// Language names the syntax of code, for highlighting.
+	Language string `json:"language,omitempty"`
+	Encoding string `json:"encoding"`
+	Size     int64  `json:"size"`
+	View     string `json:"view"`
+	// StartLine and EndLine number the lines of Content from 1, they are
+	// 0 when unknown: in the tail of a cut file.
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Truncated bool   `json:"truncated"`
+	Content   string `json:"content,omitempty"`
+
+	// Headings outlines markdown.
+	Headings []Heading `json:"headings,omitempty"`
+
+	// Header, Rows, Page, Limit and HasMore page CSV.
+	Header  []string   `json:"header,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+	Page    int        `json:"page,omitempty"`
+	Limit   int        `json:"limit,omitempty"`
+	HasMore bool       `json:"has_more,omitempty"`
+}
+
+// Heading is a markdown heading, Line numbered in the file.
+type Heading struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	Line  int    `json:"line"`
+}
+
+var textLanguages = map[string]string{
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp",
+	".cs": "csharp", ".css": "css", ".scss": "scss", ".less": "less",
+	".dart": "dart", ".diff": "diff", ".patch": "diff",
+	".go": "go", ".mod": "go", ".graphql": "graphql", ".gradle": "groovy", ".groovy": "groovy",
+	".html": "html", ".htm": "html", ".xml": "xml", ".svg": "xml", ".vue": "vue",
+	".ini": "ini", ".cfg": "ini", ".conf": "ini", ".properties": "properties", ".toml": "toml",
+	".java": "java", ".kt": "kotlin", ".kts": "kotlin", ".scala": "scala",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".tsx": "tsx", ".json": "json", ".jsonc": "json",
+	".lua": "lua", ".md": "markdown", ".markdown": "markdown", ".m": "objectivec",
+	".php": "php", ".pl": "perl", ".proto": "protobuf", ".py": "python", ".r": "r", ".rb": "ruby",
+	".rs": "rust", ".sh": "bash", ".bash": "bash", ".zsh": "bash", ".ps1": "powershell",
+	".sql": "sql", ".swift": "swift", ".tex": "latex", ".thrift": "thrift",
+	".yaml": "yaml", ".yml": "yaml", ".csv": "csv", ".tsv": "csv",
+	".txt": "", ".log": "", ".text": "", ".env": "",
+}
+
+var textFileNames = map[string]string{
+	"dockerfile": "dockerfile", "makefile": "makefile", "cmakelists.txt": "cmake",
+	".gitignore": "", ".dockerignore": "", "license": "", "readme": "",
+}
+
+func textLanguage(name string) (string, bool) {
+	if lang, ok := textFileNames[strings.ToLower(name)]; ok {
+		return lang, true
+	}
+	lang, ok := textLanguages[strings.ToLower(filepath.Ext(name))]
+	return lang, ok
+}
+
+// IsTextName tells whether the file named name is text by its name.
+func IsTextName(name string) bool {
+	if _, ok := textLanguage(name); ok {
+		return true
+	}
+	return strings.HasPrefix(mime.TypeByExtension(filepath.Ext(name)), "text/")
+}
+
+// IsText tells whether file is previewed as text: its type is text, or
+// its name or its first bytes are. Type detection leaves files over
+// 10 MB to blob, the logs text previews are made for.
+func IsText(file *files.FileInfo) bool {
+	switch file.Type {
+	case "text", "textImmutable":
+		return true
+	case "blob":
+		if !file.Mode.IsRegular() {
+			return false
+		}
+		if IsTextName(file.Name) {
+			return true
+		}
+		fd, err := file.Fs.Open(file.Path)
+		if err != nil {
+			return false
+		}
+		defer fd.Close()
+		sample := make([]byte, 8<<10)
+		n, _ := io.ReadFull(fd, sample)
+		return n > 0 && sniffText(sample[:n])
+	}
+	return false
+}
+
+// sniffText tells whether sample is text: UTF-16 with its byte order
+// mark, else bytes without NUL.
+func sniffText(sample []byte) bool {
+	if bytes.HasPrefix(sample, []byte{0xFE, 0xFF}) || bytes.HasPrefix(sample, []byte{0xFF, 0xFE}) {
+		return true
+	}
+	return bytes.IndexByte(sample, 0) < 0
+}
+
+// CreateTextPreview renders the preview queryParam asks for of the text
+// file file, as JSON.
+func CreateTextPreview(file *files.FileInfo, queryParam *models.QueryParam) ([]byte, error) {
+	if IsThumbnail(queryParam.PreviewSize) {
+		return nil, errThumbnailText
+	}
+	p, err := textPreview(file.Fs, file.Path, file.Name, queryParam)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(p)
+}
+
+// TextRanges returns the byte ranges, start inclusive and end exclusive,
+// the preview queryParam asks for reads of the file name of size bytes.
+// Storages that fetch files over the network fetch only those.
+func TextRanges(name string, size int64, queryParam *models.QueryParam) [][2]int64 {
+	var head int64 = TextMaxBytes
+	var whole = queryParam.PreviewLines == "" && queryParam.PreviewView == ""
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case queryParam.PreviewLines != "":
+		head = textScanMaxBytes
+	case queryParam.PreviewView == TextViewTail:
+		head = textSniffBytes
+		if tail := size - TextMaxBytes; tail > head {
+			return [][2]int64{{0, head}, {tail, size}}
+		}
+	case whole && (ext == ".csv" || ext == ".tsv"):
+		head = textScanMaxBytes
+	case whole && ext == ".json" && size <= jsonMaxBytes:
+		head = jsonMaxBytes
+	}
+	return [][2]int64{{0, min(head, size)}}
+}
+
+func textPreview(fsys afero.Fs, path, name string, queryParam *models.QueryParam) (*TextPreview, error) {
+	fd, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	lang, _ := textLanguage(name)
+	p := &TextPreview{Kind: TextKindText, Language: lang, Size: info.Size(), Encoding: queryParam.PreviewEncoding}
+	if p.Encoding == "" {
+		sample := make([]byte, min(info.Size(), textSniffBytes))
+		if _, err := io.ReadFull(fd, sample); err != nil {
+			return nil, err
+		}
+		if p.Encoding, err = textenc.DetectSample(sample); err != nil {
+			return nil, err
+		}
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".csv" || ext == ".tsv":
+		if queryParam.PreviewLines == "" && queryParam.PreviewView == "" {
+			p.Kind = TextKindCSV
+			if err := p.readCSV(fd, ext == ".tsv", queryParam); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+	case ext == ".json" && info.Size() <= jsonMaxBytes:
+		if queryParam.PreviewLines == "" && queryParam.PreviewView == "" {
+			if ok, err := p.readJSON(fd); err != nil {
+				return nil, err
+			} else if ok {
+				return p, nil
+			}
+		}
+	case lang == TextKindMarkdown:
+		p.Kind = TextKindMarkdown
+	}
+
+	switch view := queryParam.PreviewView; {
+	case queryParam.PreviewLines != "":
+		first, last, e := parseLines(queryParam.PreviewLines)
+		if e != nil {
+			return nil, e
+		}
+		err = p.readLines(fd, first, last)
+	case view == "" || view == TextViewHead:
+		err = p.readHead(fd)
+	case view == TextViewTail:
+		err = p.readTail(fd)
+	default:
+		return nil, fmt.Errorf("invalid view %q", view)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Kind == TextKindMarkdown {
+		p.Headings = markdownHeadings(p.Content, p.StartLine)
+	}
+	return p, nil
+}
+
+// decoder reads fd from offset in the encoding of the preview, at most
+// limit bytes of it.
+func (p *TextPreview) decoder(fd afero.File, offset, limit int64) (io.Reader, error) {
+	if _, err := fd.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return textenc.NewReader(io.LimitReader(fd, limit), p.Encoding)
+}
+
+func (p *TextPreview) readHead(fd afero.File) error {
+	p.View = TextViewHead
+	r, err := p.decoder(fd, 0, TextMaxBytes)
+	if err != nil {
+		return err
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p.Truncated = p.Size > TextMaxBytes
+	if p.Truncated {
+		// the last line and maybe a character are cut
+		if i := bytes.LastIndexByte(text, '\n'); i >= 0 {
+			text = text[:i+1]
+		}
+	}
+	p.setContent(text, 1)
+	return nil
+}
+
+func (p *TextPreview) readTail(fd afero.File) error {
+	p.View = TextViewTail
+	offset := max(p.Size-TextMaxBytes, 0)
+	if strings.HasPrefix(p.Encoding, "utf-16") {
+		// keep the code units whole
+		offset += offset % 2
+	}
+	r, err := p.decoder(fd, offset, TextMaxBytes)
+	if err != nil {
+		return err
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	p.Truncated = offset > 0
+	start := 1
+	if p.Truncated {
+		// the first line and maybe a character are cut
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+		start = 0
+	}
+	p.setContent(text, start)
+	return nil
+}
+
+// readLines reads the lines first to last, last 0 reading to the end.
+func (p *TextPreview) readLines(fd afero.File, first, last int) error {
+	p.View = TextViewLines
+	r, err := p.decoder(fd, 0, textScanMaxBytes)
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	var text []byte
+	n := 0
+	for last == 0 || n < last {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			n++
+		}
+		if len(line) > 0 && n >= first {
+			if len(text)+len(line) > TextMaxBytes {
+				if len(text) == 0 {
+					// a line too long is cut
+					text = bytes.ToValidUTF8(line[:TextMaxBytes], nil)
+				}
+				p.Truncated = true
+				break
+			}
+			text = append(text, line...)
+		}
+		if err == io.EOF {
+			// or the end of the scan
+			p.Truncated = p.Truncated || p.Size > textScanMaxBytes
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if n < first {
+		return fmt.Errorf("line %d not found", first)
+	}
+	p.setContent(text, first)
+	return nil
+}
+
+func (p *TextPreview) setContent(text []byte, start int) {
+	if start == 1 {
+		text = bytes.TrimPrefix(text, []byte("\uFEFF"))
+	}
+	p.Content = string(text)
+	if start > 0 && len(text) > 0 {
+		p.StartLine = start
+		p.EndLine = start + bytes.Count(text, []byte{'\n'}) - 1
+		if text[len(text)-1] != '\n' {
+			p.EndLine++
+		}
+	}
+}
+
+// parseLines parses a line range: first-last, first- to the end or a
+// single line.
+func parseLines(s string) (first, last int, err error) {
+	from, to, isRange := strings.Cut(s, "-")
+	if first, err = strconv.Atoi(from); err != nil || first < 1 {
+		return 0, 0, fmt.Errorf("invalid lines %q", s)
+	}
+	switch {
+	case !isRange:
+		return first, first, nil
+	case to == "":
+		return first, 0, nil
+	}
+	if last, err = strconv.Atoi(to); err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid lines %q", s)
+	}
+	return first, last, nil
+}
+
+// readCSV reads the page of rows queryParam asks for, the header apart.
+func (p *TextPreview) readCSV(fd afero.File, tsv bool, queryParam *models.QueryParam) error {
+	p.Page, p.Limit = 1, csvDefaultLimit
+	if queryParam.PreviewPage != "" {
+		page, err := strconv.Atoi(queryParam.PreviewPage)
+		if err != nil || page < 1 {
+			return fmt.Errorf("invalid page %q", queryParam.PreviewPage)
+		}
+		p.Page = page
+	}
+	if queryParam.PreviewLimit != "" {
+		limit, err := strconv.Atoi(queryParam.PreviewLimit)
+		if err != nil || limit < 1 {
+			return fmt.Errorf("invalid limit %q", queryParam.PreviewLimit)
+		}
+		p.Limit = min(limit, csvMaxLimit)
+	}
+
+	r, err := p.decoder(fd, 0, textScanMaxBytes)
+	if err != nil {
+		return err
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if tsv {
+		cr.Comma = '\t'
+	}
+
+	skip := (p.Page - 1) * p.Limit
+	for row := -1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case row == -1:
+			if len(record) > 0 {
+				record[0] = strings.TrimPrefix(record[0], "\uFEFF")
+			}
+			p.Header = record
+		case row < skip:
+		case row < skip+p.Limit:
+			p.Rows = append(p.Rows, record)
+		default:
+			p.HasMore = true
+			return nil
+		}
+	}
+	// the scan stopped at its bound rather than the end of the file
+	p.Truncated = p.Size > textScanMaxBytes
+	return nil
+}
+
+// readJSON pretty-prints the file. It is false when the file is not
+// valid JSON, to be shown as text.
+func (p *TextPreview) readJSON(fd afero.File) (bool, error) {
+	r, err := p.decoder(fd, 0, jsonMaxBytes)
+	if err != nil {
+		return false, err
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\uFEFF"))
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return false, nil
+	}
+	out.WriteByte('\n')
+	text := out.Bytes()
+	if len(text) > TextMaxBytes {
+		text = text[:TextMaxBytes]
+		if i := bytes.LastIndexByte(text, '\n'); i >= 0 {
+			text = text[:i+1]
+		}
+		p.Truncated = true
+	}
+	p.Kind = TextKindJSON
+	p.View = TextViewHead
+	p.setContent(text, 1)
+	return true, nil
+}
+
+// markdownHeadings outlines the ATX headings of content, whose first
+// line is start, fenced code left out. Without a known start the lines
+// are numbered from 0.
+func markdownHeadings(content string, start int) []Heading {
+	var headings []Heading
+	var fence string
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+			continue
+		}
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		if level < 1 || level > 6 || (len(trimmed) > level && trimmed[level] != ' ' && trimmed[level] != '\t') {
+			continue
+		}
+		title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(trimmed[level:]), "#"))
+		if title == "" {
+			continue
+		}
+		headings = append(headings, Heading{Level: level, Title: title, Line: start + i})
+	}
+	return headings
+}
@@ -0,0 +1,194 @@
+package preview
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"files/pkg/models"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func testTextPreview(t *testing.T, name string, content []byte, qp *models.QueryParam) *TextPreview {
+	t.Helper()
+	fsys := afero.NewMemMapFs()
+	if err := afero.WriteFile(fsys, "/"+name, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := textPreview(fsys, "/"+name, name, qp)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return p
+}
+
+func testLog(lines int) []byte {
+	var b strings.Builder
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&b, "%06d 2024-05-01T10:00:00Z INFO request served in 12ms\n", i)
+	}
+	return []byte(b.String())
+}
+
+func TestTextHeadTail(t *testing.T) {
+	log := testLog(10000) // about 520 KiB
+
+	p := testTextPreview(t, "app.log", log, &models.QueryParam{})
+	if p.View != TextViewHead || !p.Truncated || p.StartLine != 1 || p.Encoding != "utf-8" {
+		t.Fatalf("head = %s, truncated %v, start %d, %s", p.View, p.Truncated, p.StartLine, p.Encoding)
+	}
+	if len(p.Content) > TextMaxBytes || !strings.HasSuffix(p.Content, "\n") || !strings.HasPrefix(p.Content, "000001 ") {
+		t.Errorf("head content of %d bytes", len(p.Content))
+	}
+	if want := strings.Count(p.Content, "\n"); p.EndLine != want {
+		t.Errorf("head end line = %d, want %d", p.EndLine, want)
+	}
+
+	p = testTextPreview(t, "app.log", log, &models.QueryParam{PreviewView: "tail"})
+	if p.View != TextViewTail || !p.Truncated || p.StartLine != 0 {
+		t.Fatalf("tail = %s, truncated %v, start %d", p.View, p.Truncated, p.StartLine)
+	}
+	if !strings.HasSuffix(p.Content, "010000 2024-05-01T10:00:00Z INFO request served in 12ms\n") || !strings.HasPrefix(p.Content, "00") {
+		t.Errorf("tail content starts %q", p.Content[:20])
+	}
+
+	p = testTextPreview(t, "small.txt", []byte("one\ntwo"), &models.QueryParam{PreviewView: "tail"})
+	if p.Truncated || p.Content != "one\ntwo" || p.StartLine != 1 || p.EndLine != 2 {
+		t.Errorf("small tail = %+v", p)
+	}
+}
+
+func TestTextLines(t *testing.T) {
+	log := testLog(100)
+
+	p := testTextPreview(t, "app.log", log, &models.QueryParam{PreviewLines: "10-12"})
+	if p.View != TextViewLines || p.StartLine != 10 || p.EndLine != 12 || p.Truncated {
+		t.Fatalf("lines = %+v", p)
+	}
+	if !strings.HasPrefix(p.Content, "000010 ") || strings.Count(p.Content, "\n") != 3 {
+		t.Errorf("lines content = %q", p.Content)
+	}
+
+	p = testTextPreview(t, "app.log", log, &models.QueryParam{PreviewLines: "99-"})
+	if p.StartLine != 99 || p.EndLine != 100 {
+		t.Errorf("open range = %d-%d", p.StartLine, p.EndLine)
+	}
+
+	fsys := afero.NewMemMapFs()
+	_ = afero.WriteFile(fsys, "/app.log", log, 0644)
+	for _, lines := range []string{"0-2", "5-3", "x", "101"} {
+		if _, err := textPreview(fsys, "/app.log", "app.log", &models.QueryParam{PreviewLines: lines}); err == nil {
+			t.Errorf("lines %q: no error", lines)
+		}
+	}
+	if _, err := textPreview(fsys, "/app.log", "app.log", &models.QueryParam{PreviewView: "middle"}); err == nil {
+		t.Error("invalid view: no error")
+	}
+}
+
+func TestTextEncoding(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("第一行：你好，世界。\n第二行：这是一个用来测试编码检测的中文文本文件。\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testTextPreview(t, "notes.txt", gbk, &models.QueryParam{})
+	if !strings.Contains(p.Content, "你好，世界") || p.EndLine != 2 {
+		t.Errorf("gbk = %q, %s", p.Content, p.Encoding)
+	}
+
+	p = testTextPreview(t, "notes.txt", []byte("caf\xe9\n"), &models.QueryParam{PreviewEncoding: "iso-8859-1"})
+	if p.Content != "café\n" || p.Encoding != "iso-8859-1" {
+		t.Errorf("latin-1 = %q, %s", p.Content, p.Encoding)
+	}
+
+	p = testTextPreview(t, "utf16.txt", []byte("\xff\xfeh\x00i\x00\n\x00"), &models.QueryParam{})
+	if p.Content != "hi\n" || p.Encoding != "utf-16le" {
+		t.Errorf("utf-16 = %q, %s", p.Content, p.Encoding)
+	}
+}
+
+func TestTextStructured(t *testing.T) {
+	md := "# Title\n\nSome text.\n\n```sh\n# not a heading\n```\n\n## Usage ##\n#hashtag\n"
+	p := testTextPreview(t, "README.md", []byte(md), &models.QueryParam{})
+	if p.Kind != TextKindMarkdown || len(p.Headings) != 2 {
+		t.Fatalf("markdown = %s, headings %+v", p.Kind, p.Headings)
+	}
+	if h := p.Headings[1]; h.Level != 2 || h.Title != "Usage" || h.Line != 9 {
+		t.Errorf("heading = %+v", h)
+	}
+
+	var csv strings.Builder
+	csv.WriteString("\uFEFFid,name\n")
+	for i := 1; i <= 250; i++ {
+		fmt.Fprintf(&csv, "%d,\"item, %d\"\n", i, i)
+	}
+	p = testTextPreview(t, "items.csv", []byte(csv.String()), &models.QueryParam{PreviewPage: "3"})
+	if p.Kind != TextKindCSV || p.Header[0] != "id" || len(p.Rows) != 50 || p.HasMore || p.Page != 3 || p.Limit != csvDefaultLimit {
+		t.Fatalf("csv = %s, header %v, %d rows, more %v", p.Kind, p.Header, len(p.Rows), p.HasMore)
+	}
+	if p.Rows[0][0] != "201" || p.Rows[0][1] != "item, 201" {
+		t.Errorf("first row = %v", p.Rows[0])
+	}
+	p = testTextPreview(t, "items.csv", []byte(csv.String()), &models.QueryParam{PreviewLimit: "10"})
+	if len(p.Rows) != 10 || !p.HasMore {
+		t.Errorf("csv page of 10 = %d rows, more %v", len(p.Rows), p.HasMore)
+	}
+
+	p = testTextPreview(t, "data.json", []byte(`{"a":[1,2],"b":{"c":true}}`), &models.QueryParam{})
+	if p.Kind != TextKindJSON || p.Content != "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {\n    \"c\": true\n  }\n}\n" {
+		t.Errorf("json = %s, %q", p.Kind, p.Content)
+	}
+	p = testTextPreview(t, "broken.json", []byte(`{"a":`), &models.QueryParam{})
+	if p.Kind != TextKindText || p.Language != "json" || p.Content != `{"a":` {
+		t.Errorf("invalid json = %s, %q", p.Kind, p.Content)
+	}
+
+	p = testTextPreview(t, "main.go", []byte("package main\n"), &models.QueryParam{})
+	if p.Kind != TextKindText || p.Language != "go" {
+		t.Errorf("code = %s, %s", p.Kind, p.Language)
+	}
+}
+
+func TestTextRanges(t *testing.T) {
+	log := testLog(10000)
+	csv := []byte("name,size\n" + strings.Repeat("a.txt,1\n", 50000))
+	for _, c := range []struct {
+		name    string
+		content []byte
+		qp      *models.QueryParam
+	}{
+		{"app.log", log, &models.QueryParam{}},
+		{"app.log", log, &models.QueryParam{PreviewView: TextViewTail}},
+		{"app.log", log, &models.QueryParam{PreviewLines: "9000-9010"}},
+		{"short.log", testLog(10), &models.QueryParam{PreviewView: TextViewTail}},
+		{"list.csv", csv, &models.QueryParam{PreviewPage: "3"}},
+	} {
+		// the file as a storage fetching only the ranges has it
+		var sparse = make([]byte, len(c.content))
+		for _, r := range TextRanges(c.name, int64(len(c.content)), c.qp) {
+			copy(sparse[r[0]:r[1]], c.content[r[0]:r[1]])
+		}
+		want := testTextPreview(t, c.name, c.content, c.qp)
+		got := testTextPreview(t, c.name, sparse, c.qp)
+		if got.Content != want.Content || len(got.Rows) != len(want.Rows) || got.Truncated != want.Truncated {
+			t.Errorf("%s %+v: preview of the ranges differs", c.name, c.qp)
+		}
+	}
+}
+
+func TestIsTextName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"app.log":    true,
+		"Dockerfile": true,
+		"main.GO":    true,
+		"notes.txt":  true,
+		"photo.jpg":  false,
+		"archive":    false,
+	} {
+		if got := IsTextName(name); got != want {
+			t.Errorf("IsTextName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package recent keeps, for each user, a feed of the files they recently
+// opened and one of the files they recently changed.
+//
+// Like the share activity log, the feeds are written off the request
+// path: producers only queue an access, and a single writer stores them
+// in batches, a path accessed several times in a batch once. When the
+// queue is full the access is dropped rather than slowing a download.
+// Forgetting a deleted or moved path goes through the same queue, so it
+// also drops accesses queued before it.
+//
+// Each feed keeps the newest RECENT_FILES_LIMIT (default 500) paths of a
+// user; zero or a negative value keeps them all.
+package recent
+
+import (
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"files/pkg/hertz/biz/model/api/recent"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	KindOpened  = "opened"
+	KindChanged = "changed"
+)
+
+const (
+	queueSize     = 1024
+	batchSize     = 100
+	flushInterval = 2 * time.Second
+	defaultLimit  = 500
+)
+
+// Storages maps the storage filter of the feeds to the file types it
+// covers; a file type outside of them is not recorded.
+var Storages = map[string][]string{
+	common.Drive:    {common.Drive},
+	common.Sync:     {common.Sync},
+	common.Cache:    {common.Cache},
+	common.External: {common.External, common.Internal, common.Usb, common.Hdd, common.Smb},
+	common.Cloud:    {common.AwsS3, common.GoogleDrive, common.DropBox, common.TencentCos},
+}
+
+func recorded(fileType string) bool {
+	for _, types := range Storages {
+		for _, t := range types {
+			if t == fileType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// op is one queued access, or a path to forget when forget is set.
+type op struct {
+	row    *recent.RecentFile
+	forget *models.FileParam
+}
+
+var (
+	queue     chan op
+	queueOnce sync.Once
+)
+
+// Opened records that user opened fp, Changed that they wrote it.
+func Opened(user string, fp *models.FileParam) {
+	record(user, KindOpened, fp, time.Now())
+}
+
+func Changed(user string, fp *models.FileParam) {
+	record(user, KindChanged, fp, time.Now())
+}
+
+// Forget drops fp and everything below it from the feeds of every user.
+func Forget(fp *models.FileParam) {
+	if database.DB == nil || fp == nil || fp.Path == "" {
+		return
+	}
+	enqueue(op{forget: fp})
+}
+
+func record(user, kind string, fp *models.FileParam, at time.Time) {
+	if database.DB == nil || user == "" || fp == nil || fp.Path == "" || fp.Path == "/" || !recorded(fp.FileType) {
+		return
+	}
+	enqueue(op{row: &recent.RecentFile{
+		UserName:   user,
+		Kind:       kind,
+		Owner:      fp.StorageOwner(),
+		FileType:   fp.FileType,
+		Extend:     fp.Extend,
+		Path:       fp.Path,
+		AccessTime: at.UnixMilli(),
+	}})
+}
+
+func enqueue(o op) {
+	queueOnce.Do(func() {
+		queue = make(chan op, queueSize)
+		go runWriter()
+	})
+
+	select {
+	case queue <- o:
+	default:
+		if o.row != nil {
+			klog.Warningf("[recent] queue full, drop %s of %s", o.row.Kind, o.row.Path)
+		} else {
+			klog.Warningf("[recent] queue full, drop forgetting %s", o.forget.Path)
+		}
+	}
+}
+
+func runWriter() {
+	var flush = time.NewTicker(flushInterval)
+	defer flush.Stop()
+
+	var batch []op
+	for {
+		select {
+		case o := <-queue:
+			batch = append(batch, o)
+			if len(batch) >= batchSize {
+				apply(batch)
+				batch = nil
+			}
+		case <-flush.C:
+			apply(batch)
+			batch = nil
+		}
+	}
+}
+
+// apply stores a batch in order: the accesses queued before a forget are
+// written before it.
+func apply(batch []op) {
+	var rows []*recent.RecentFile
+	for _, o := range batch {
+		if o.row != nil {
+			rows = append(rows, o.row)
+			continue
+		}
+		write(rows)
+		rows = nil
+		if err := database.ForgetRecentFiles(o.forget); err != nil {
+			klog.Errorf("[recent] forget error: %v, path: %s", err, o.forget.Path)
+		}
+	}
+	write(rows)
+}
+
+type feedKey struct {
+	user, kind string
+}
+
+type rowKey struct {
+	user, kind, owner, fileType, extend, path string
+}
+
+// write upserts rows, the latest access of a path only, and trims the
+// feeds they went to.
+func write(rows []*recent.RecentFile) {
+	if len(rows) == 0 {
+		return
+	}
+
+	var unique []*recent.RecentFile
+	var index = make(map[rowKey]int, len(rows))
+	var feeds []feedKey
+	var seen = make(map[feedKey]bool)
+	for _, r := range rows {
+		k := rowKey{r.UserName, r.Kind, r.Owner, r.FileType, r.Extend, r.Path}
+		if i, ok := index[k]; ok {
+			if r.AccessTime > unique[i].AccessTime {
+				unique[i].AccessTime = r.AccessTime
+			}
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, r)
+		if f := (feedKey{r.UserName, r.Kind}); !seen[f] {
+			seen[f] = true
+			feeds = append(feeds, f)
+		}
+	}
+
+	if err := database.UpsertRecentFiles(unique); err != nil {
+		klog.Errorf("[recent] write %d rows error: %v", len(unique), err)
+		return
+	}
+
+	var keep = limit()
+	if keep <= 0 {
+		return
+	}
+	for _, f := range feeds {
+		if _, err := database.TrimRecentFiles(f.user, f.kind, keep); err != nil {
+			klog.Errorf("[recent] trim %s feed of %s error: %v", f.kind, f.user, err)
+		}
+	}
+}
+
+// limit applies RECENT_FILES_LIMIT.
+func limit() int {
+	var n = defaultLimit
+	if v := os.Getenv("RECENT_FILES_LIMIT"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			klog.Warningf("[recent] invalid RECENT_FILES_LIMIT %q, using %d", v, n)
+		} else {
+			n = i
+		}
+	}
+	return n
+}
@@ -0,0 +1,150 @@
+package recent
+
+import (
+	"reflect"
+	"testing"
+
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+
+	"files/pkg/hertz/biz/model/api/recent"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRecentTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open in-memory sqlite: %v", err)
+	}
+	ddl := []string{
+		`CREATE TABLE recent_files (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_name TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			owner TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			extend TEXT NOT NULL,
+			path TEXT NOT NULL,
+			access_time INTEGER NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX idx_recent_file_path ON recent_files (user_name, kind, owner, file_type, extend, path)`,
+	}
+	for _, stmt := range ddl {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create recent table: %v", err)
+		}
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = saved })
+}
+
+func access(user, kind string, fp *models.FileParam, at int64) op {
+	return op{row: &recent.RecentFile{
+		UserName:   user,
+		Kind:       kind,
+		Owner:      fp.StorageOwner(),
+		FileType:   fp.FileType,
+		Extend:     fp.Extend,
+		Path:       fp.Path,
+		AccessTime: at,
+	}}
+}
+
+func feed(t *testing.T, user, kind string, fileTypes ...string) []string {
+	t.Helper()
+	rows, err := database.ListRecentFiles(user, kind, fileTypes, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, r := range rows {
+		res = append(res, "/"+r.FileType+"/"+r.Extend+r.Path)
+	}
+	return res
+}
+
+func TestApply(t *testing.T) {
+	newRecentTestDB(t)
+	home := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Drive, Extend: "Home", Path: p}
+	}
+	library := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Sync, Extend: "repo-1", Path: p}
+	}
+
+	apply([]op{
+		access("alice", KindOpened, home("/a.txt"), 1),
+		access("alice", KindOpened, library("/Docs/b.txt"), 2),
+		access("alice", KindOpened, home("/a.txt"), 3),
+		access("alice", KindChanged, home("/a.txt"), 4),
+		access("bob", KindOpened, library("/Docs/b.txt"), 5),
+	})
+	// opened again later, in another batch
+	apply([]op{access("alice", KindOpened, library("/Docs/b.txt"), 6)})
+
+	if got, want := feed(t, "alice", KindOpened), []string{"/sync/repo-1/Docs/b.txt", "/drive/Home/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("opened = %v, want %v", got, want)
+	}
+	if got, want := feed(t, "alice", KindOpened, Storages[common.Drive]...), []string{"/drive/Home/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("opened on drive = %v, want %v", got, want)
+	}
+	if got, want := feed(t, "alice", KindChanged), []string{"/drive/Home/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed = %v, want %v", got, want)
+	}
+
+	// a library is the same for everyone: deleting a folder of it drops
+	// it from every feed, also an access queued before the delete but
+	// not one queued after it
+	apply([]op{
+		access("alice", KindOpened, library("/Docs/c.txt"), 7),
+		{forget: library("/Docs")},
+		access("alice", KindOpened, library("/Docs/d.txt"), 8),
+	})
+	if got, want := feed(t, "alice", KindOpened), []string{"/sync/repo-1/Docs/d.txt", "/drive/Home/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("opened after delete = %v, want %v", got, want)
+	}
+	if got := feed(t, "bob", KindOpened); got != nil {
+		t.Errorf("bob opened after delete = %v", got)
+	}
+}
+
+func TestApplyLimit(t *testing.T) {
+	newRecentTestDB(t)
+	t.Setenv("RECENT_FILES_LIMIT", "2")
+	fp := func(p string) *models.FileParam {
+		return &models.FileParam{Owner: "alice", FileType: common.Cache, Extend: "node-1", Path: p}
+	}
+
+	apply([]op{
+		access("alice", KindChanged, fp("/1"), 1),
+		access("alice", KindChanged, fp("/2"), 2),
+		access("alice", KindChanged, fp("/3"), 3),
+		access("alice", KindOpened, fp("/1"), 4),
+	})
+	if got, want := feed(t, "alice", KindChanged), []string{"/cache/node-1/3", "/cache/node-1/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed = %v, want %v", got, want)
+	}
+	if got, want := feed(t, "alice", KindOpened), []string{"/cache/node-1/1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("opened = %v, want %v", got, want)
+	}
+}
+
+func TestRecorded(t *testing.T) {
+	for fileType, want := range map[string]bool{
+		common.Drive:       true,
+		common.Usb:         true,
+		common.GoogleDrive: true,
+		common.Share:       false,
+		"":                 false,
+	} {
+		if got := recorded(fileType); got != want {
+			t.Errorf("recorded(%q) = %v, want %v", fileType, got, want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package samba
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// Audited shares load vfs_full_audit with the prefix
+// "smbaudit|<share id>|%u|%I", and smb.conf sends the full_audit debug
+// class to common.SambaAuditLogPath. Every record is written as a
+// header line followed by the record itself:
+//
+//	[2026/10/19 06:30:06.123456,  1] ../../source3/modules/vfs_full_audit.c:1234(do_log)
+//	  smbaudit|<share id>|alice|192.168.1.20|renameat|ok|docs/a.txt|docs/b.txt
+//
+// Renames are stored as "/docs/a.txt//docs/b.txt" (auditRenameTarget).
+// The AuditCollector tails that file and stores the records in
+// share_activities, next to the http activity of the same share.
+const (
+	auditPrefix       = "smbaudit|"
+	auditPollInterval = 10 * time.Second
+	auditBatchSize    = 500
+)
+
+var auditHeaderLayouts = []string{"2006/01/02 15:04:05.000000", "2006/01/02 15:04:05"}
+
+type AuditCollector struct {
+	path   string
+	inode  uint64
+	offset int64
+	// header is the time of the last header line read
+	header time.Time
+}
+
+func NewAuditCollector(path string) *AuditCollector {
+	return &AuditCollector{path: path, offset: -1}
+}
+
+// Run collects new audit records until ctx is done. Records already in
+// the log when Run starts are skipped, they were collected before the
+// restart or predate auditing.
+func (a *AuditCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(auditPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.collect(); err != nil {
+			klog.Errorf("samba audit, collect error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AuditCollector) collect() error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// nothing audited yet, or samba has not written the file
+			if a.offset < 0 {
+				a.offset = 0
+			}
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var inode = fileInode(info)
+
+	switch {
+	case a.offset < 0:
+		a.offset = info.Size()
+	case inode != a.inode:
+		// samba rotated the log to .old; what it wrote there since the
+		// last poll is read before the new log
+		if err = a.drainRotated(); err != nil {
+			return err
+		}
+		a.offset = 0
+	case info.Size() < a.offset:
+		a.offset = 0
+	}
+	a.inode = inode
+	if info.Size() == a.offset {
+		return nil
+	}
+
+	return a.read(f)
+}
+
+// drainRotated reads the rest of the log samba rotated away. When it
+// was rotated again meanwhile, or removed, its last records are lost.
+func (a *AuditCollector) drainRotated() error {
+	f, err := os.Open(a.path + ".old")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			klog.Warningf("samba audit, rotated log %s.old is gone, records after offset %d lost", a.path, a.offset)
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fileInode(info) != a.inode {
+		klog.Warningf("samba audit, log %s rotated more than once since the last poll, records lost", a.path)
+		return nil
+	}
+	if info.Size() <= a.offset {
+		return nil
+	}
+	return a.read(f)
+}
+
+// read stores the records of f from the offset on. The offset only
+// moves past records once they are stored, so a failed insert is
+// retried on the next poll.
+func (a *AuditCollector) read(f *os.File) error {
+	if _, err := f.Seek(a.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var activities []*share.ShareActivity
+	var pos = a.offset
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// a partial line is read again once samba finishes it
+			break
+		}
+		pos += int64(len(line))
+
+		if t, ok := parseAuditHeader(line); ok {
+			a.header = t
+			continue
+		}
+		if activity, ok := parseAuditRecord(line, a.header); ok {
+			activities = append(activities, activity)
+		}
+		if len(activities) >= auditBatchSize {
+			if err = database.CreateShareActivity(activities, database.DB); err != nil {
+				return err
+			}
+			activities = nil
+			a.offset = pos
+		}
+	}
+
+	if len(activities) > 0 {
+		if err := database.CreateShareActivity(activities, database.DB); err != nil {
+			return err
+		}
+	}
+	a.offset = pos
+	return nil
+}
+
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+func parseAuditHeader(line string) (time.Time, bool) {
+	if !strings.HasPrefix(line, "[") {
+		return time.Time{}, false
+	}
+	end := strings.IndexByte(line, ',')
+	if end < 0 {
+		return time.Time{}, false
+	}
+	for _, layout := range auditHeaderLayouts {
+		if t, err := time.ParseInLocation(layout, line[1:end], time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAuditRecord turns a full_audit record into a share activity.
+// Failed operations, reads and alternate data streams are dropped.
+// full_audit does not escape the "|" it separates fields with, so the
+// path of a record is the rest of the line joined back.
+func parseAuditRecord(line string, at time.Time) (*share.ShareActivity, bool) {
+	idx := strings.Index(line, auditPrefix)
+	if idx < 0 {
+		return nil, false
+	}
+	fields := strings.Split(strings.TrimRight(line[idx+len(auditPrefix):], "\r\n"), "|")
+	// id, user, ip, operation, result, message...
+	if len(fields) < 6 || fields[4] != "ok" {
+		return nil, false
+	}
+	if _, err := uuid.Parse(fields[0]); err != nil {
+		return nil, false
+	}
+
+	var action, target string
+	msg := fields[5:]
+	switch fields[3] {
+	case "mkdirat":
+		action, target = common.ShareActivitySmbMkdir, auditPath(strings.Join(msg, "|"))
+	case "unlinkat":
+		action, target = common.ShareActivitySmbDelete, auditPath(strings.Join(msg, "|"))
+	case "openat":
+		if len(msg) < 2 || msg[0] != "w" {
+			return nil, false
+		}
+		action, target = common.ShareActivitySmbWrite, auditPath(strings.Join(msg[1:], "|"))
+	case "renameat":
+		from, to, ok := auditRename(msg)
+		if !ok {
+			return nil, false
+		}
+		action, target = common.ShareActivitySmbRename, auditRenameTarget(from, to)
+	default:
+		return nil, false
+	}
+	if target == "" {
+		return nil, false
+	}
+
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return &share.ShareActivity{
+		PathID:     fields[0],
+		Member:     fields[1],
+		IP:         fields[2],
+		Action:     action,
+		Path:       target,
+		CreateTime: at.UTC().Format(time.RFC3339Nano),
+	}, true
+}
+
+// auditRenameTarget is the path of a rename activity. Both paths are
+// cleaned and rooted, so the "//" where to begins cannot be part of
+// either, unlike " -> " or "|" which are valid in file names.
+func auditRenameTarget(from, to string) string {
+	return from + "/" + to
+}
+
+// auditRename splits the message of a renameat record into its source
+// and destination. With a "|" in a name the split is ambiguous; smb
+// clients rename in place, so the one split keeping both in the same
+// folder is taken, and a record that still has several is dropped.
+func auditRename(msg []string) (string, string, bool) {
+	if len(msg) < 2 {
+		return "", "", false
+	}
+	var from, to string
+	var matches int
+	for k := 1; k < len(msg); k++ {
+		f, t := auditPath(strings.Join(msg[:k], "|")), auditPath(strings.Join(msg[k:], "|"))
+		if f == "" || t == "" {
+			continue
+		}
+		if len(msg) == 2 || path.Dir(f) == path.Dir(t) {
+			from, to = f, t
+			matches++
+		}
+	}
+	if matches != 1 {
+		if len(msg) > 2 {
+			klog.Warningf("samba audit, ambiguous rename record dropped: %s", strings.Join(msg, "|"))
+		}
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// auditPath roots a path full_audit logged relative to the share at "/".
+// Streams ("file:stream") and the share's own recycle and snapshot
+// folders yield "".
+func auditPath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" || strings.Contains(path.Base(p), ":") {
+		return ""
+	}
+	p = path.Clean("/" + p)
+	for _, hidden := range []string{recycleDir, snapshotDir} {
+		if p == "/"+hidden || strings.HasPrefix(p, "/"+hidden+"/") {
+			return ""
+		}
+	}
+	return p
+}
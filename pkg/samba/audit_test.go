@@ -0,0 +1,167 @@
+package samba
+
+import (
+	"bytes"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/hertz/biz/model/api/share"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseAuditRecord(t *testing.T) {
+	const id = "0b0f6c4e-8e0b-4c55-9a43-7d0c3f5b1a10"
+	at := time.Date(2026, 10, 19, 6, 30, 6, 0, time.UTC)
+
+	cases := []struct {
+		line   string
+		action string
+		path   string
+	}{
+		{"  smbaudit|" + id + "|alice|10.0.0.2|mkdirat|ok|docs/new\n", common.ShareActivitySmbMkdir, "/docs/new"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|openat|ok|w|docs/a.txt\n", common.ShareActivitySmbWrite, "/docs/a.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|renameat|ok|docs/a.txt|docs/b.txt\n", common.ShareActivitySmbRename, "/docs/a.txt//docs/b.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|renameat|ok|docs/a.txt|old/a -> b.txt\n", common.ShareActivitySmbRename, "/docs/a.txt//old/a -> b.txt"},
+		// "|" in names: joined back, renames split in the folder they happen in
+		{"  smbaudit|" + id + "|alice|10.0.0.2|unlinkat|ok|docs/a|b.txt\n", common.ShareActivitySmbDelete, "/docs/a|b.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|mkdirat|ok|docs/x|y\n", common.ShareActivitySmbMkdir, "/docs/x|y"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|openat|ok|w|docs/a|b.txt\n", common.ShareActivitySmbWrite, "/docs/a|b.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|renameat|ok|docs/a|b.txt|docs/c.txt\n", common.ShareActivitySmbRename, "/docs/a|b.txt//docs/c.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|renameat|ok|docs/a.txt|docs/c|d.txt\n", common.ShareActivitySmbRename, "/docs/a.txt//docs/c|d.txt"},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|unlinkat|ok|b.txt\n", common.ShareActivitySmbDelete, "/b.txt"},
+		// reads, failures, streams, recycle bin and foreign lines are dropped
+		{"  smbaudit|" + id + "|alice|10.0.0.2|openat|ok|r|docs/a.txt\n", "", ""},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|unlinkat|fail (Permission denied)|b.txt\n", "", ""},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|openat|ok|w|a.txt:AFP_AfpInfo\n", "", ""},
+		{"  smbaudit|" + id + "|alice|10.0.0.2|mkdirat|ok|.recycle/alice\n", "", ""},
+		{"  smbaudit|not-a-share|alice|10.0.0.2|mkdirat|ok|x\n", "", ""},
+		// a rename that splits in place two ways is ambiguous
+		{"  smbaudit|" + id + "|alice|10.0.0.2|renameat|ok|a|b.txt|c.txt\n", "", ""},
+		{"  some other samba debug output\n", "", ""},
+	}
+	for _, tc := range cases {
+		got, ok := parseAuditRecord(tc.line, at)
+		if tc.action == "" {
+			if ok {
+				t.Errorf("%q: want dropped, got %+v", tc.line, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: dropped", tc.line)
+			continue
+		}
+		if got.PathID != id || got.Member != "alice" || got.IP != "10.0.0.2" || got.Action != tc.action || got.Path != tc.path {
+			t.Errorf("%q: got %+v", tc.line, got)
+		}
+		if got.CreateTime != at.Format(time.RFC3339Nano) {
+			t.Errorf("%q: create time %s", tc.line, got.CreateTime)
+		}
+	}
+}
+
+// TestCollectRotatedLog pins that records samba wrote to the log after
+// the last poll are still collected once it rotated the log to .old.
+func TestCollectRotatedLog(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open in-memory sqlite: %v", err)
+	}
+	if err = db.AutoMigrate(&share.ShareActivity{}); err != nil {
+		t.Fatalf("create share_activities: %v", err)
+	}
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = saved })
+
+	const id = "0b0f6c4e-8e0b-4c55-9a43-7d0c3f5b1a10"
+	record := func(name string) string {
+		return "  smbaudit|" + id + "|alice|10.0.0.2|mkdirat|ok|" + name + "\n"
+	}
+	appendLog := func(path, line string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err = f.WriteString(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	appendLog(logPath, record("before"))
+	a := NewAuditCollector(logPath)
+	if err = a.collect(); err != nil {
+		t.Fatal(err)
+	}
+
+	appendLog(logPath, record("a"))
+	if err = os.Rename(logPath, logPath+".old"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(logPath+".old", record("b"))
+	appendLog(logPath, record("c"))
+	if err = a.collect(); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(logPath, record("d"))
+	if err = a.collect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	if err = db.Model(&share.ShareActivity{}).Order("id").Pluck("path", &got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/a", "/b", "/c", "/d"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+}
+
+func TestParseAuditHeader(t *testing.T) {
+	got, ok := parseAuditHeader("[2026/10/19 06:30:06.123456,  1] ../../source3/modules/vfs_full_audit.c:1234(do_log)\n")
+	if !ok {
+		t.Fatal("header not parsed")
+	}
+	want := time.Date(2026, 10, 19, 6, 30, 6, 123456000, time.Local)
+	if !got.Equal(want) {
+		t.Fatalf("header time = %v, want %v", got, want)
+	}
+	if _, ok = parseAuditHeader("  smbaudit|x\n"); ok {
+		t.Fatal("record parsed as header")
+	}
+}
+
+func TestConfTemplateAudit(t *testing.T) {
+	tmpl := template.Must(template.New("samba.conf").Parse(sambaConfTemplateContent))
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, SambaShares{
+		AuditLog: common.SambaAuditLogPath,
+		Paths: []SambaShare{
+			{Id: "abc", Name: "docs", Path: "/data/docs", Writable: "yes", ReadOnly: "no", Anonymous: true, Audit: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := buf.String()
+	for _, want := range []string{
+		"log level = 0 full_audit:1@" + common.SambaAuditLogPath,
+		"vfs objects = full_audit catia fruit streams_xattr acl_xattr",
+		"full_audit:prefix = smbaudit|abc|%u|%I",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("conf missing %q:\n%s", want, conf)
+		}
+	}
+}
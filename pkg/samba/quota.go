@@ -0,0 +1,153 @@
+package samba
+
+import (
+	"context"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"files/pkg/models"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Samba has no per-share quota of its own, so shares with a quota are
+// measured periodically: the folder size is stored in smb_used_bytes
+// (surfaced by ListSharePath) and smb.conf is regenerated whenever a
+// share crosses its quota, turning it read-only until space is freed.
+const quotaScanInterval = 5 * time.Minute
+
+// quotaMiB converts a quota in bytes to the MiB smb.conf size options
+// expect, rounding down but never to zero for a limited share.
+func quotaMiB(quota int64) int64 {
+	if quota <= 0 {
+		return 0
+	}
+	if mib := quota >> 20; mib > 0 {
+		return mib
+	}
+	return 1
+}
+
+func quotaExceeded(quota, used int64) bool {
+	return quota > 0 && used >= quota
+}
+
+func (s *samba) monitorShareQuotas(ctx context.Context) {
+	ticker := time.NewTicker(quotaScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if s.refreshShareUsage(ctx) {
+			klog.Info("samba share quota state changed, regenerating conf")
+			s.generateConf()
+		}
+	}
+}
+
+// refreshShareUsage measures every local smb share that has a quota and
+// reports whether any of them crossed its quota in either direction.
+func (s *samba) refreshShareUsage(ctx context.Context) bool {
+	smbShareData, err := database.QuerySmbShares("", common.ShareTypeSMB, nil, nil)
+	if err != nil {
+		klog.Errorf("samba quota, get shares data error: %v", err)
+		return false
+	}
+
+	var changed bool
+	for _, item := range FormatSharePathViews(smbShareData) {
+		if ctx.Err() != nil {
+			return changed
+		}
+		if item.Quota <= 0 {
+			continue
+		}
+		if !isLocalShare(item.FileType, item.Extend) {
+			continue
+		}
+		if expire, e := time.Parse(timeFormat, item.ExpireTime); e == nil && time.Now().UTC().After(expire) {
+			continue
+		}
+
+		dir, err := shareLocalPath(item)
+		if err != nil {
+			klog.Errorf("samba quota, resolve share %s path error: %v", item.Id, err)
+			continue
+		}
+		used, err := dirSize(ctx, dir)
+		if err != nil {
+			klog.Errorf("samba quota, measure share %s error: %v, path: %s", item.Id, err, dir)
+			continue
+		}
+		if used == item.UsedBytes {
+			continue
+		}
+		if err = database.UpdateSmbShareUsage(item.Id, used); err != nil {
+			klog.Errorf("samba quota, update share %s usage error: %v", item.Id, err)
+			continue
+		}
+		if !item.TimeMachine && quotaExceeded(item.Quota, item.UsedBytes) != quotaExceeded(item.Quota, used) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func shareLocalPath(item *models.SambaShares) (string, error) {
+	fp, err := models.CreateFileParam(item.Owner, fmt.Sprintf("/%s/%s%s", item.FileType, item.Extend, item.Path))
+	if err != nil {
+		return "", err
+	}
+	uri, err := fp.GetResourceUri()
+	if err != nil {
+		return "", err
+	}
+	return uri + strings.TrimSuffix(fp.Path, "/"), nil
+}
+
+// dirSize sums the size of the regular files under dir, snapshots and
+// recycle bin included. A file with several hard links, as unchanged
+// files are across snapshots, is counted once. Entries that vanish or
+// cannot be read while walking are skipped.
+func dirSize(ctx context.Context, dir string) (int64, error) {
+	type inode struct{ dev, ino uint64 }
+	var total int64
+	var linked = make(map[inode]bool)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+			key := inode{uint64(st.Dev), uint64(st.Ino)}
+			if linked[key] {
+				return nil
+			}
+			linked[key] = true
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
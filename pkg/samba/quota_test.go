@@ -0,0 +1,73 @@
+package samba
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestQuotaMiB(t *testing.T) {
+	for quota, want := range map[int64]int64{0: 0, -1: 0, 1: 1, 1 << 20: 1, 5<<30 + 1: 5 << 10} {
+		if got := quotaMiB(quota); got != want {
+			t.Errorf("quotaMiB(%d) = %d, want %d", quota, got, want)
+		}
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"x": 10, "a/y": 20, "a/b/z": 30} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "x"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dirSize(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("dirSize: %v", err)
+	}
+	if got != 60 {
+		t.Fatalf("dirSize = %d, want 60", got)
+	}
+
+	if _, err = dirSize(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("dirSize on a missing dir succeeded")
+	}
+}
+
+func TestConfTemplateTimeMachine(t *testing.T) {
+	tmpl := template.Must(template.New("samba.conf").Parse(sambaConfTemplateContent))
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, SambaShares{Paths: []SambaShare{
+		{Name: "backup", Path: "/data/backup", Writable: "yes", ReadOnly: "no", Anonymous: true, TimeMachine: true, MaxSize: 512},
+		{Name: "plain", Path: "/data/plain", Writable: "no", ReadOnly: "yes", Anonymous: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := buf.String()
+	backup, plainSection, _ := strings.Cut(strings.SplitN(conf, "[backup]", 2)[1], "[plain]")
+	for _, want := range []string{"fruit:time machine = yes", "fruit:time machine max size = 512M", "max disk size = 512", "writable = yes"} {
+		if !strings.Contains(backup, want) {
+			t.Errorf("backup share missing %q:\n%s", want, backup)
+		}
+	}
+	if strings.Contains(plainSection, "time machine") || strings.Contains(plainSection, "max disk size") {
+		t.Errorf("plain share got time machine or size options:\n%s", plainSection)
+	}
+	if !strings.Contains(plainSection, "read only = yes") {
+		t.Errorf("read-only share is writable:\n%s", plainSection)
+	}
+}
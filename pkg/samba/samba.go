@@ -46,9 +46,12 @@ var sambaConfTemplateContent string
 
 type SambaShares struct {
 	Paths []SambaShare
+	// AuditLog is where full_audit writes, set when any share is audited.
+	AuditLog string
 }
 
 type SambaShare struct {
+	Id         string `json:"id"`
 	Name       string `json:"name"`
 	Path       string `json:"path"`
 	Comment    string `json:"comment"`
@@ -60,6 +63,29 @@ type SambaShare struct {
 	ForceUser  string `json:"force_user"`
 	ForceGroup string `json:"force_group"`
 	Anonymous  bool   `json:"anonymous"`
+	// TimeMachine advertises the share to macOS as a backup target.
+	TimeMachine bool `json:"time_machine"`
+	// MaxSize is the share quota in MiB, 0 when unlimited. It caps the
+	// disk size reported to clients and, for Time Machine, the backup size.
+	MaxSize int64 `json:"max_size"`
+	// Recycle moves deleted files to the share's .recycle folder.
+	Recycle bool `json:"recycle"`
+	// ShadowCopy exposes the .snapshots folder as Previous Versions.
+	ShadowCopy bool `json:"shadow_copy"`
+	// Audit logs creates, writes, renames and deletes through full_audit.
+	Audit bool `json:"audit"`
+}
+
+// SambaShareOptions are the optional per-share features chosen when an
+// smb share is created.
+type SambaShareOptions struct {
+	TimeMachine   bool
+	Quota         int64
+	Recycle       bool
+	RecycleDays   int32
+	SnapshotHours int32
+	SnapshotKeep  int32
+	Audit         bool
 }
 
 type SambaSharePathAccount struct {
@@ -77,9 +103,9 @@ type samba struct {
 	runTime  time.Time
 
 	// Background-cleanup lifecycle. cancel ends the periodic
-	// deleteExpiredShares loop; <-done blocks until the goroutine
-	// has fully exited so a graceful-shutdown coordinator can wait
-	// on it.
+	// deleteExpiredShares, monitorShareQuotas and maintainShareVersions
+	// loops; <-done blocks until all of them have fully exited so a
+	// graceful-shutdown coordinator can wait on them.
 	cancelCleanup context.CancelFunc
 	cleanupDone   chan struct{}
 
@@ -102,7 +128,32 @@ func (s *samba) Start() {
 		klog.Errorf("samba start: smbd run failed: %v", err)
 	}
 
-	s.deleteExpiredShares()
+	s.startBackground()
+}
+
+func (s *samba) startBackground() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelCleanup = cancel
+	s.cleanupDone = make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		s.deleteExpiredShares(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.monitorShareQuotas(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.maintainShareVersions(ctx)
+	}()
+	go func() {
+		wg.Wait()
+		close(s.cleanupDone)
+	}()
 }
 
 func (s *samba) CreateShareSamba(sharePath []*share.SmbCreate, operator string) error {
@@ -195,60 +246,53 @@ func (s *samba) HandlerEvent() cache.ResourceEventHandler {
 	}
 }
 
-func (s *samba) deleteExpiredShares() {
-	ctx, cancel := context.WithCancel(context.Background())
-	s.cancelCleanup = cancel
-	s.cleanupDone = make(chan struct{})
+func (s *samba) deleteExpiredShares(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	go func() {
-		defer close(s.cleanupDone)
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-			}
+		klog.Info("samba delete crds with ticker")
+		cli, err := s.factory.DynamicClient()
+		if err != nil {
+			klog.Errorf("samba get dynamic client error: %v", err)
+			continue
+		}
+
+		res, err := cli.Resource(SambaGVR).Namespace(common.DefaultNamespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			klog.Errorf("samba get shares list error: %v", err)
+			continue
+		}
 
-			klog.Info("samba delete crds with ticker")
-			cli, err := s.factory.DynamicClient()
+		for _, item := range res.Items {
+			var v v1.ShareSamba
+			err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), &v)
 			if err != nil {
-				klog.Errorf("samba get dynamic client error: %v", err)
+				klog.Error("samba delete, convert to unstructured error, ", err, ", ", item)
 				continue
 			}
 
-			res, err := cli.Resource(SambaGVR).Namespace(common.DefaultNamespace).List(ctx, metav1.ListOptions{})
-			if err != nil {
-				klog.Errorf("samba get shares list error: %v", err)
+			if !metav1.Now().Time.Add(-2 * time.Minute).After(v.CreationTimestamp.Time) {
 				continue
 			}
 
-			for _, item := range res.Items {
-				var v v1.ShareSamba
-				err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), &v)
-				if err != nil {
-					klog.Error("samba delete, convert to unstructured error, ", err, ", ", item)
-					continue
-				}
-
-				if !metav1.Now().Time.Add(-2 * time.Minute).After(v.CreationTimestamp.Time) {
-					continue
-				}
-
-				if err := cli.Resource(SambaGVR).Namespace(common.DefaultNamespace).Delete(ctx, v.Name, metav1.DeleteOptions{}); err != nil {
-					klog.Errorf("samba delete, delete failed, error: %v, operate: %s", err, v.Spec.Operator)
-					continue
-				}
-
-				klog.Infof("samba delete, delete done, operate: %s", v.Spec.Operator)
+			if err := cli.Resource(SambaGVR).Namespace(common.DefaultNamespace).Delete(ctx, v.Name, metav1.DeleteOptions{}); err != nil {
+				klog.Errorf("samba delete, delete failed, error: %v, operate: %s", err, v.Spec.Operator)
+				continue
 			}
+
+			klog.Infof("samba delete, delete done, operate: %s", v.Spec.Operator)
 		}
-	}()
+	}
 }
 
-// Stop ends the background expired-share cleanup loop and waits
-// for it to exit (or returns ctx.Err() on shutdown deadline).
+// Stop ends the background cleanup, quota and versions loops and waits
+// for them to exit (or returns ctx.Err() on shutdown deadline).
 // Safe to call multiple times.
 func (s *samba) Stop(ctx context.Context) error {
 	if s == nil || s.cancelCleanup == nil {
@@ -400,6 +444,7 @@ func (s *samba) generateConf() {
 		}
 
 		var smbShare = SambaShare{
+			Id:         item.Id,
 			Name:       item.Name,
 			Path:       fileUri + strings.TrimSuffix(fp.Path, "/"),
 			Comment:    fmt.Sprintf("%s_%s_%s", item.Owner, item.Id, item.Name),
@@ -411,6 +456,23 @@ func (s *samba) generateConf() {
 			ForceUser:  strings.Join(validUser, ","),
 			ForceGroup: item.Owner,
 			Anonymous:  anonymous,
+
+			TimeMachine: item.TimeMachine,
+			MaxSize:     quotaMiB(item.Quota),
+			Recycle:     item.Recycle,
+			ShadowCopy:  item.SnapshotHours > 0,
+			Audit:       item.Audit,
+		}
+		if item.Audit {
+			shares.AuditLog = common.SambaAuditLogPath
+		}
+		if !item.TimeMachine && quotaExceeded(item.Quota, item.UsedBytes) {
+			// Time Machine prunes old backups against its max size; any
+			// other share over quota turns read-only until space is freed
+			klog.Warningf("samba share over quota, id: %s, name: %s, quota: %d, used: %d", item.Id, item.Name, item.Quota, item.UsedBytes)
+			smbShare.Writable = "no"
+			smbShare.ReadOnly = "yes"
+			smbShare.WriteList = ""
 		}
 		shares.Paths = append(shares.Paths, smbShare)
 	}
@@ -604,7 +666,7 @@ func (s *samba) recoverSharedOwner(sharedPaths []string) {
 	}
 }
 
-func (s *samba) CreateSambaSharePath(owner string, smbSharePublicLevel int32, users []*share.CreateSmbSharePathMembers, fileParam *models.FileParam, reqExpireIn int64, reqExpireTime string) (*share.SharePath, error) {
+func (s *samba) CreateSambaSharePath(owner string, smbSharePublicLevel int32, users []*share.CreateSmbSharePathMembers, fileParam *models.FileParam, reqExpireIn int64, reqExpireTime string, opts SambaShareOptions) (*share.SharePath, error) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -684,10 +746,26 @@ func (s *samba) CreateSambaSharePath(owner string, smbSharePublicLevel int32, us
 		ExpireIn:       expireIn,
 		ExpireTime:     expireTime,
 		SmbSharePublic: smbSharePublicLevel,
+		SmbQuota:       opts.Quota,
+		SmbRecycleDays: opts.RecycleDays,
 		CreateTime:     now,
 		UpdateTime:     now,
 	}
 
+	if opts.TimeMachine {
+		newSmbSharePath.SmbTimeMachine = 1
+	}
+	if opts.Recycle {
+		newSmbSharePath.SmbRecycle = 1
+	}
+	if opts.Audit {
+		newSmbSharePath.SmbAudit = 1
+	}
+	if opts.SnapshotHours > 0 {
+		newSmbSharePath.SmbSnapshotHours = opts.SnapshotHours
+		newSmbSharePath.SmbSnapshotKeep = opts.SnapshotKeep
+	}
+
 	var newSmbShareMembers []*share.ShareSmbMember
 	if smbSharePublicLevel != 1 {
 		for _, u := range users {
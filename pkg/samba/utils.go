@@ -23,18 +23,26 @@ func FormatSharePathViews(data []*share.SmbShareView) map[string]*models.SambaSh
 		val, ok := result[d.ID]
 		if !ok {
 			val = &models.SambaShares{
-				Id:          d.ID,
-				Owner:       d.Owner,
-				FileType:    d.FileType,
-				Extend:      d.Extend,
-				Path:        d.Path,
-				ShareType:   d.ShareType,
-				Name:        d.Name,
-				ExpireIn:    d.ExpireIn,
-				ExpireTime:  d.ExpireTime,
-				Permission:  d.SharePermission,
-				PublicShare: d.SmbSharePublic == 1,
-				Members:     make([]*models.SambaShareMembers, 0),
+				Id:            d.ID,
+				Owner:         d.Owner,
+				FileType:      d.FileType,
+				Extend:        d.Extend,
+				Path:          d.Path,
+				ShareType:     d.ShareType,
+				Name:          d.Name,
+				ExpireIn:      d.ExpireIn,
+				ExpireTime:    d.ExpireTime,
+				Permission:    d.SharePermission,
+				PublicShare:   d.SmbSharePublic == 1,
+				TimeMachine:   d.SmbTimeMachine == 1,
+				Quota:         d.SmbQuota,
+				UsedBytes:     d.SmbUsedBytes,
+				Recycle:       d.SmbRecycle == 1,
+				RecycleDays:   d.SmbRecycleDays,
+				SnapshotHours: d.SmbSnapshotHours,
+				SnapshotKeep:  d.SmbSnapshotKeep,
+				Audit:         d.SmbAudit == 1,
+				Members:       make([]*models.SambaShareMembers, 0),
 			}
 			if d.UserName != "" {
 				var member = &models.SambaShareMembers{
@@ -0,0 +1,332 @@
+package samba
+
+import (
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/hertz/biz/dal/database"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Shares can opt into vfs_recycle and vfs_shadow_copy2. Both keep their
+// data inside the share folder, hidden from clients:
+//
+//	.recycle/<user>/...          files deleted over smb, purged after recycle days
+//	.snapshots/@GMT-<utc time>/  point-in-time copies shown as Previous Versions
+//
+// The maintenance loop below takes the snapshots and enforces retention.
+// Snapshots are incremental: a file unchanged since the previous snapshot
+// is a hard link to it, the others are reflink clones where the
+// filesystem supports them and full copies otherwise. They count toward
+// the share's quota.
+const (
+	versionsInterval = 15 * time.Minute
+
+	recycleDir     = common.SambaRecycleDir
+	snapshotDir    = common.SambaSnapshotDir
+	snapshotLayout = "@GMT-2006.01.02-15.04.05"
+
+	defaultSnapshotKeep = 7
+)
+
+// ficlone is FICLONE from linux/fs.h, _IOW(0x94, 9, int).
+const ficlone = 0x40049409
+
+func (s *samba) maintainShareVersions(ctx context.Context) {
+	ticker := time.NewTicker(versionsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.runShareVersions(ctx, time.Now().UTC())
+	}
+}
+
+func (s *samba) runShareVersions(ctx context.Context, now time.Time) {
+	smbShareData, err := database.QuerySmbShares("", common.ShareTypeSMB, nil, nil)
+	if err != nil {
+		klog.Errorf("samba versions, get shares data error: %v", err)
+		return
+	}
+
+	for _, item := range FormatSharePathViews(smbShareData) {
+		if ctx.Err() != nil {
+			return
+		}
+		if (!item.Recycle || item.RecycleDays <= 0) && item.SnapshotHours <= 0 {
+			continue
+		}
+		if !isLocalShare(item.FileType, item.Extend) {
+			continue
+		}
+
+		dir, err := shareLocalPath(item)
+		if err != nil {
+			klog.Errorf("samba versions, resolve share %s path error: %v", item.Id, err)
+			continue
+		}
+
+		if item.Recycle && item.RecycleDays > 0 {
+			removed, err := purgeRecycle(filepath.Join(dir, recycleDir), now.AddDate(0, 0, -int(item.RecycleDays)))
+			if err != nil {
+				klog.Errorf("samba versions, purge recycle of share %s error: %v", item.Id, err)
+			} else if removed > 0 {
+				klog.Infof("samba versions, purged %d recycled files of share %s", removed, item.Id)
+			}
+		}
+
+		if item.SnapshotHours > 0 {
+			keep := int(item.SnapshotKeep)
+			if keep <= 0 {
+				keep = defaultSnapshotKeep
+			}
+			if err := snapshotShare(ctx, dir, now, time.Duration(item.SnapshotHours)*time.Hour, keep); err != nil {
+				klog.Errorf("samba versions, snapshot share %s error: %v", item.Id, err)
+			}
+		}
+	}
+}
+
+// purgeRecycle removes recycled files last touched before cutoff, then
+// any folders that became empty. vfs_recycle is told to touch mtime, so
+// mtime is the deletion time.
+func purgeRecycle(root string, cutoff time.Time) (int, error) {
+	if _, err := os.Lstat(root); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	var removed int
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err = os.Remove(path); err == nil {
+			removed++
+		}
+		return nil
+	})
+
+	// deepest first, os.Remove refuses folders that still have entries
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, d := range dirs {
+		_ = os.Remove(d)
+	}
+	return removed, err
+}
+
+// snapshotShare takes a new snapshot of dir once the newest one is
+// older than every, then drops all but the newest keep snapshots.
+func snapshotShare(ctx context.Context, dir string, now time.Time, every time.Duration, keep int) error {
+	root := filepath.Join(dir, snapshotDir)
+	snapshots, err := listSnapshots(root)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) == 0 || !now.Before(snapshots[len(snapshots)-1].Add(every)) {
+		name := now.Format(snapshotLayout)
+		// copy under a name shadow_copy2 does not parse, so clients
+		// never see a half-written snapshot
+		tmp := filepath.Join(root, ".tmp-"+name)
+		if err = os.MkdirAll(root, 0755); err != nil {
+			return err
+		}
+		_ = os.RemoveAll(tmp)
+		var prev string
+		if len(snapshots) > 0 {
+			prev = filepath.Join(root, snapshots[len(snapshots)-1].Format(snapshotLayout))
+		}
+		if err = copyTree(ctx, dir, tmp, prev); err != nil {
+			_ = os.RemoveAll(tmp)
+			return err
+		}
+		if err = os.Rename(tmp, filepath.Join(root, name)); err != nil {
+			_ = os.RemoveAll(tmp)
+			return err
+		}
+		klog.Infof("samba versions, snapshot %s taken, path: %s", name, dir)
+		snapshots = append(snapshots, now.Truncate(time.Second))
+	}
+
+	for len(snapshots) > keep {
+		name := snapshots[0].Format(snapshotLayout)
+		if err = os.RemoveAll(filepath.Join(root, name)); err != nil {
+			return fmt.Errorf("remove snapshot %s: %w", name, err)
+		}
+		snapshots = snapshots[1:]
+	}
+	return nil
+}
+
+// listSnapshots returns the times of the snapshots under root, oldest first.
+func listSnapshots(root string) ([]time.Time, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var res []time.Time
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if t, err := time.Parse(snapshotLayout, e.Name()); err == nil {
+			res = append(res, t)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Before(res[j]) })
+	return res, nil
+}
+
+// copyTree copies src into dst keeping modes, owners and mtimes. The
+// share's own .recycle and .snapshots folders are left out. A file that
+// prev, the previous copy, holds unchanged is hard linked from there.
+func copyTree(ctx context.Context, src, dst, prev string) error {
+	// folder mtimes change while their entries are copied, so they are
+	// set once everything is in place
+	var dirTimes = make(map[string]time.Time)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (rel == recycleDir || rel == snapshotDir) {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			if err = os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			copyOwner(target, info)
+			dirTimes[target] = info.ModTime()
+			return nil
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err = os.Symlink(link, target); err != nil {
+				return err
+			}
+			copyOwner(target, info)
+			return nil
+		case info.Mode().IsRegular():
+			if prev != "" && unchangedFile(filepath.Join(prev, rel), info) {
+				if err = os.Link(filepath.Join(prev, rel), target); err == nil {
+					return nil
+				}
+			}
+			if err = cloneFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		copyOwner(target, info)
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+
+	for dir, mtime := range dirTimes {
+		_ = os.Chtimes(dir, mtime, mtime)
+	}
+	return nil
+}
+
+// unchangedFile reports whether the file at path is a copy of the one
+// info describes: same size, mtime, mode and owner.
+func unchangedFile(path string, info fs.FileInfo) bool {
+	old, err := os.Lstat(path)
+	if err != nil || !old.Mode().IsRegular() {
+		return false
+	}
+	if old.Size() != info.Size() || !old.ModTime().Equal(info.ModTime()) || old.Mode() != info.Mode() {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	oldSt, oldOk := old.Sys().(*syscall.Stat_t)
+	return ok && oldOk && st.Uid == oldSt.Uid && st.Gid == oldSt.Gid
+}
+
+func copyOwner(target string, info fs.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = os.Lchown(target, int(st.Uid), int(st.Gid))
+	}
+}
+
+// cloneFile shares src's extents with dst where the filesystem can
+// (btrfs, xfs), and falls back to copying the bytes.
+func cloneFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno != 0 {
+		if _, err = io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+func isLocalShare(fileType, extend string) bool {
+	if fileType == common.External || fileType == common.Cache {
+		return extend == os.Getenv("NODE_NAME")
+	}
+	return true
+}
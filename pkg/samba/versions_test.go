@@ -0,0 +1,182 @@
+package samba
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurgeRecycle(t *testing.T) {
+	now := time.Now()
+	root := filepath.Join(t.TempDir(), recycleDir)
+	writeFile(t, filepath.Join(root, "alice", "old", "a.txt"), "a", now.AddDate(0, 0, -10))
+	writeFile(t, filepath.Join(root, "alice", "new.txt"), "b", now.AddDate(0, 0, -1))
+
+	removed, err := purgeRecycle(root, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("purgeRecycle: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed = %d, want 1", removed)
+	}
+	if _, err = os.Stat(filepath.Join(root, "alice", "old")); !os.IsNotExist(err) {
+		t.Fatalf("emptied folder was kept: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(root, "alice", "new.txt")); err != nil {
+		t.Fatalf("recent file was purged: %v", err)
+	}
+
+	if removed, err = purgeRecycle(filepath.Join(t.TempDir(), recycleDir), now); err != nil || removed != 0 {
+		t.Fatalf("missing recycle folder: removed %d, err %v", removed, err)
+	}
+}
+
+func TestSnapshotShare(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	mtime := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	writeFile(t, filepath.Join(dir, "docs", "report.txt"), "v1", mtime)
+	writeFile(t, filepath.Join(dir, recycleDir, "bob", "gone.txt"), "x", mtime)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := snapshotShare(ctx, dir, start, time.Hour, 2); err != nil {
+		t.Fatalf("first snapshot: %v", err)
+	}
+
+	first := filepath.Join(dir, snapshotDir, start.Format(snapshotLayout))
+	info, err := os.Stat(filepath.Join(first, "docs", "report.txt"))
+	if err != nil {
+		t.Fatalf("snapshot missing file: %v", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("snapshot mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if _, err = os.Stat(filepath.Join(first, recycleDir)); !os.IsNotExist(err) {
+		t.Fatalf("recycle bin was snapshotted: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(first, snapshotDir)); !os.IsNotExist(err) {
+		t.Fatalf("snapshots were snapshotted: %v", err)
+	}
+
+	// not due yet
+	if err = snapshotShare(ctx, dir, start.Add(30*time.Minute), time.Hour, 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err = snapshotShare(ctx, dir, start.Add(time.Duration(i)*time.Hour), time.Hour, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := listSnapshots(filepath.Join(dir, snapshotDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || !snapshots[0].Equal(start.Add(time.Hour)) || !snapshots[1].Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("snapshots = %v, want the two newest", snapshots)
+	}
+}
+
+func TestDirSizeCountsSnapshotsOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "12345", time.Now())
+	writeFile(t, filepath.Join(dir, snapshotDir, "@GMT-2026.01.01-00.00.00", "a.txt"), "12345", time.Now())
+	writeFile(t, filepath.Join(dir, snapshotDir, "@GMT-2026.01.01-00.00.00", "b.txt"), "123", time.Now())
+	if err := os.MkdirAll(filepath.Join(dir, snapshotDir, "@GMT-2026.01.02-00.00.00"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, snapshotDir, "@GMT-2026.01.01-00.00.00", "b.txt"), filepath.Join(dir, snapshotDir, "@GMT-2026.01.02-00.00.00", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dirSize(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 13 {
+		t.Fatalf("dirSize = %d, want 13", got)
+	}
+}
+
+func TestSnapshotShareIncremental(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	mtime := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	writeFile(t, filepath.Join(dir, "same.txt"), "same", mtime)
+	writeFile(t, filepath.Join(dir, "edited.txt"), "v1", mtime)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := snapshotShare(ctx, dir, start, time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "edited.txt"), "v2", mtime.Add(time.Minute))
+	if err := snapshotShare(ctx, dir, start.Add(time.Hour), time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	first := filepath.Join(dir, snapshotDir, start.Format(snapshotLayout))
+	second := filepath.Join(dir, snapshotDir, start.Add(time.Hour).Format(snapshotLayout))
+	same := func(name string) bool {
+		a, err := os.Stat(filepath.Join(first, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.Stat(filepath.Join(second, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return os.SameFile(a, b)
+	}
+	if !same("same.txt") {
+		t.Errorf("unchanged file was copied, not linked")
+	}
+	if same("edited.txt") {
+		t.Errorf("edited file was linked to the old version")
+	}
+	if b, _ := os.ReadFile(filepath.Join(first, "edited.txt")); string(b) != "v1" {
+		t.Errorf("first snapshot changed: %q", b)
+	}
+}
+
+func TestConfTemplateVersions(t *testing.T) {
+	tmpl := template.Must(template.New("samba.conf").Parse(sambaConfTemplateContent))
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, SambaShares{Paths: []SambaShare{
+		{Name: "docs", Path: "/data/docs", Writable: "yes", ReadOnly: "no", Anonymous: true, Recycle: true, ShadowCopy: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := buf.String()
+	for _, want := range []string{
+		"vfs objects = catia fruit streams_xattr shadow_copy2 recycle acl_xattr",
+		"shadow:mountpoint = /data/docs",
+		"shadow:snapdir = .snapshots",
+		"recycle:repository = .recycle/%U",
+		"hide files = /.recycle/.snapshots/",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("conf missing %q:\n%s", want, conf)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"files/pkg/common"
+	"files/pkg/diskcache"
 	"files/pkg/drivers/clouds/rclone"
 	"files/pkg/drivers/clouds/rclone/operations"
 	"files/pkg/drivers/sync/seahub"
@@ -146,6 +147,12 @@ func (t *taskManager) PauseTask(owner, taskId string) error {
 	if task.param.Action == common.ActionCompress || task.param.Action == common.ActionExtract {
 		return fmt.Errorf("archive tasks do not support pause")
 	}
+	if task.param.Action == common.ActionVerify {
+		return fmt.Errorf("verify tasks do not support pause")
+	}
+	if task.param.Action == common.ActionHash || task.param.Action == common.ActionDuplicates || task.param.Action == common.ActionDiskUsage {
+		return fmt.Errorf("%s tasks do not support pause", task.param.Action)
+	}
 	task.mu.Lock()
 	if task.state != common.Pending && task.state != common.Running {
 		task.mu.Unlock()
@@ -228,6 +235,10 @@ func (t *taskManager) GetTask(owner string, taskId string, status string) []*Tas
 	if task.param.Action == common.ActionCompress || task.param.Action == common.ActionExtract {
 		pauseAble = false
 	}
+	if task.param.Action == common.ActionVerify || task.param.Action == common.ActionHash || task.param.Action == common.ActionDuplicates ||
+		task.param.Action == common.ActionDiskUsage {
+		pauseAble = false
+	}
 
 	var res = &TaskInfo{
 		Id:            task.id,
@@ -246,6 +257,9 @@ func (t *taskManager) GetTask(owner string, taskId string, status string) []*Tas
 		Status:        snap.State,
 		ErrorMessage:  snap.Message,
 		PauseAble:     pauseAble,
+		Verify:        snap.Verify,
+		Hash:          snap.Hash,
+		Conflict:      snap.Conflict,
 	}
 
 	tasks = append(tasks, res)
@@ -316,6 +330,9 @@ func (t *taskManager) GetTasksByStatus(owner, status string) []*TaskInfo {
 			TidyDirs:      snap.TidyDirs,
 			Status:        snap.State,
 			ErrorMessage:  snap.Message,
+			Verify:        snap.Verify,
+			Hash:          snap.Hash,
+			Conflict:      snap.Conflict,
 		}
 
 		result = append(result, res)
@@ -324,6 +341,87 @@ func (t *taskManager) GetTasksByStatus(owner, status string) []*TaskInfo {
 	return result
 }
 
+// ResolveConflict answers the conflict an ask-policy paste is waiting
+// on with resolution (rename, overwrite, skip or keep_newer) and
+// resumes it; the paste stops again on its next conflicting entry.
+// With applyAll the answer is taken for every entry of this paste still
+// to come; other pastes of the owner keep asking.
+func (t *taskManager) ResolveConflict(owner, taskId, resolution string, applyAll bool) error {
+	klog.Infof("[Task] Id: %s, Resolve conflict, user: %s, resolution: %s, all: %v", taskId, owner, resolution, applyAll)
+	if resolution == common.PasteConflictAsk || !common.ListContains(common.PasteConflicts, resolution) {
+		return fmt.Errorf("invalid resolution: %s", resolution)
+	}
+
+	userPool := t.getOrCreateUserPool(owner)
+
+	val, ok := userPool.tasks.Load(taskId)
+	if !ok {
+		return fmt.Errorf("task %s not found", taskId)
+	}
+
+	var task = val.(*Task)
+	if !task.answerConflict(resolution, applyAll) {
+		return fmt.Errorf("task %s has no pending conflict", taskId)
+	}
+
+	return t.ResumeTask(owner, taskId)
+}
+
+// GetDuplicates returns the report of a duplicates task together with
+// its state; the report is nil until the task has completed.
+func (t *taskManager) GetDuplicates(owner, taskId string) (*DuplicatesReport, string, error) {
+	userPool := t.getOrCreateUserPool(owner)
+
+	val, ok := userPool.tasks.Load(taskId)
+	if !ok {
+		return nil, "", fmt.Errorf("task %s not found", taskId)
+	}
+	task := val.(*Task)
+	if task.param.Action != common.ActionDuplicates {
+		return nil, "", fmt.Errorf("task %s is not a duplicates task", taskId)
+	}
+
+	task.mu.RLock()
+	defer task.mu.RUnlock()
+	return task.duplicates, task.state, nil
+}
+
+// ForgetDuplicates drops resolved paths from a duplicates report, along
+// with any group that no longer holds more than one file. The report is
+// rebuilt rather than edited so earlier readers keep a consistent view.
+func (t *taskManager) ForgetDuplicates(owner, taskId string, paths []string) {
+	userPool := t.getOrCreateUserPool(owner)
+
+	val, ok := userPool.tasks.Load(taskId)
+	if !ok {
+		return
+	}
+	task := val.(*Task)
+
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	if task.duplicates == nil {
+		return
+	}
+
+	var report = &DuplicatesReport{Roots: task.duplicates.Roots, Scanned: task.duplicates.Scanned}
+	for _, g := range task.duplicates.Groups {
+		var ng = &DuplicateGroup{Algo: g.Algo, Hash: g.Hash, Size: g.Size}
+		for _, f := range g.Files {
+			if !common.ListContains(paths, f.Path) {
+				ng.Files = append(ng.Files, f)
+			}
+		}
+		if len(ng.Files) < 2 {
+			continue
+		}
+		ng.Wasted = ng.Size * int64(len(ng.Files)-1)
+		report.Wasted += ng.Wasted
+		report.Groups = append(report.Groups, ng)
+	}
+	task.duplicates = report
+}
+
 func (t *taskManager) ClearTasks() {
 	klog.Info("Task remove finished tasks")
 	t.userPools.Range(func(poolUser, poolValue any) bool {
@@ -648,6 +746,11 @@ func (t *taskManager) ClearCacheFiles() {
 	var thumbCacheExpired = time.Now().AddDate(0, 0, -30)
 	var bufferCacheExpired = time.Now().AddDate(0, 0, -5)
 
+	// previews expire by their last use, through the cache so it counts them
+	if fileCache := diskcache.GetFileCache(); fileCache != nil {
+		fileCache.Expire(thumbCacheExpired)
+	}
+
 	for _, user := range users {
 
 		// clear expired upload
@@ -695,26 +798,6 @@ func (t *taskManager) ClearCacheFiles() {
 			}
 		}
 
-		// clear expired thumb
-		files = nil
-		var thumbPath = fmt.Sprintf("%s/%s%s%s", common.CACHE_PREFIX, pvcname, common.DefaultLocalFileCachePath, common.CacheThumb)
-		if walkErr := filepath.Walk(thumbPath, func(path string, info fs.FileInfo, err error) error {
-			if info != nil && info.ModTime().Before(thumbCacheExpired) {
-				files = append(files, path)
-			}
-			return nil
-		}); walkErr != nil && !os.IsNotExist(walkErr) {
-			klog.Warningf("[gc] walk thumb cache %s: %v", thumbPath, walkErr)
-		}
-
-		if len(files) > 0 {
-			for _, f := range files {
-				if err := os.Remove(f); err != nil {
-					klog.Errorf("remove file %s error: %v", err, f)
-				}
-			}
-		}
-
 		// clear expired buffer
 		files = nil
 		var bufferPath = fmt.Sprintf("%s/%s%s%s", common.CACHE_PREFIX, pvcname, common.DefaultLocalFileCachePath, common.CacheBuffer)
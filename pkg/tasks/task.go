@@ -6,7 +6,10 @@ import (
 	"files/pkg/common"
 	"files/pkg/drivers/clouds/rclone"
 	"files/pkg/drivers/sync/seahub"
+	"files/pkg/hertz/biz/dal/database"
 	"files/pkg/models"
+	"files/pkg/preview"
+	"files/pkg/recent"
 	"fmt"
 	"strings"
 	"sync"
@@ -16,22 +19,25 @@ import (
 )
 
 type TaskInfo struct {
-	Id            string `json:"id"`
-	Action        string `json:"action"`
-	IsDir         bool   `json:"is_dir"`
-	FileName      string `json:"filename"`
-	Dst           string `json:"dest"`
-	DstPath       string `json:"dst_filename"`
-	Src           string `json:"source"`
-	CurrentPhase  int    `json:"current_phase"`
-	TotalPhases   int    `json:"total_phases"`
-	Progress      int    `json:"progress"`
-	Transferred   int64  `json:"transferred"`
-	TotalFileSize int64  `json:"total_file_size"`
-	TidyDirs      bool   `json:"tidy_dirs"`
-	Status        string `json:"status"`
-	ErrorMessage  string `json:"failed_reason"`
-	PauseAble     bool   `json:"pause_able"`
+	Id            string         `json:"id"`
+	Action        string         `json:"action"`
+	IsDir         bool           `json:"is_dir"`
+	FileName      string         `json:"filename"`
+	Dst           string         `json:"dest"`
+	DstPath       string         `json:"dst_filename"`
+	Src           string         `json:"source"`
+	CurrentPhase  int            `json:"current_phase"`
+	TotalPhases   int            `json:"total_phases"`
+	Progress      int            `json:"progress"`
+	Transferred   int64          `json:"transferred"`
+	TotalFileSize int64          `json:"total_file_size"`
+	TidyDirs      bool           `json:"tidy_dirs"`
+	Status        string         `json:"status"`
+	ErrorMessage  string         `json:"failed_reason"`
+	PauseAble     bool           `json:"pause_able"`
+	Verify        *VerifyReport  `json:"verify,omitempty"`
+	Hash          *HashResult    `json:"hash,omitempty"`
+	Conflict      *PasteConflict `json:"conflict,omitempty"`
 }
 
 type Task struct {
@@ -82,6 +88,32 @@ type Task struct {
 	endAt  time.Time
 
 	details []string
+
+	// verifySource / verifyAlgo are only touched by the worker
+	// goroutine (prepareVerify / finishVerify); verifyReport is read
+	// by the HTTP handlers and therefore guarded by mu.
+	verifySource map[string]*verifyItem
+	verifyAlgo   string
+	verifyReport *VerifyReport
+
+	// hashResult is set by the Hash phase once the checksum is known.
+	hashResult *HashResult
+
+	// duplicates is the FindDuplicates result, served separately from
+	// TaskInfo because it can be large.
+	duplicates *DuplicatesReport
+
+	// conflict is the destination entry an ask-policy paste is waiting
+	// on, conflictAnswers the client's answers for single entries and
+	// conflictDecision the one it applied to all (it overrides
+	// param.Conflict). conflictBackups are the files an overwrite moved
+	// aside, conflictMerge the plan of a folder merged into an existing
+	// one.
+	conflict         *PasteConflict
+	conflictAnswers  map[string]string
+	conflictDecision string
+	conflictBackups  []conflictBackup
+	conflictMerge    *conflictMerge
 }
 
 func (t *Task) Id() string {
@@ -107,6 +139,9 @@ type taskSnapshot struct {
 	Running      bool
 	Suspend      bool
 	WasPaused    bool
+	Verify       *VerifyReport
+	Hash         *HashResult
+	Conflict     *PasteConflict
 }
 
 func (t *Task) snapshot() taskSnapshot {
@@ -124,6 +159,9 @@ func (t *Task) snapshot() taskSnapshot {
 		Running:      t.running,
 		Suspend:      t.suspend,
 		WasPaused:    t.wasPaused,
+		Verify:       t.verifyReport,
+		Hash:         t.hashResult,
+		Conflict:     t.conflict,
 	}
 }
 
@@ -275,6 +313,9 @@ func (t *Task) Cancel() {
 			}
 		}
 
+		t.finishConflict(false)
+		t.setConflict(nil)
+
 		klog.Infof("[Task] Id: %s, Canel Final, clear result done!", t.id)
 	}
 }
@@ -322,6 +363,44 @@ func (t *Task) Execute(fs ...func() error) error {
 		t.details = nil
 		t.mu.Unlock()
 
+		switch outcome, e := t.prepareConflict(); {
+		case e != nil:
+			err = e
+			t.mu.Lock()
+			errmsg := common.RemoveBlank(e.Error())
+			t.details = append(t.details, errmsg)
+			t.message = errmsg
+			t.state = common.Failed
+			t.mu.Unlock()
+			return
+		case outcome == common.PasteConflictAsk:
+			// Parked like a pause; ResolveConflict resumes it.
+			t.mu.Lock()
+			t.state = common.Paused
+			t.mu.Unlock()
+			return
+		case outcome == common.PasteConflictSkip:
+			t.mu.Lock()
+			t.state = common.Completed
+			t.progress = 100
+			t.details = append(t.details, "successed")
+			t.mu.Unlock()
+			return
+		}
+
+		if err = t.prepareVerify(); err != nil {
+			// paused or cancelled while hashing the source
+			t.mu.Lock()
+			t.details = append(t.details, common.RemoveBlank(err.Error()))
+			if t.suspend {
+				t.state = common.Paused
+			} else {
+				t.state = common.Canceled
+			}
+			t.mu.Unlock()
+			return
+		}
+
 		for phase, f := range currentFuncs {
 			t.mu.Lock()
 			t.currentPhase = phase + 1
@@ -382,12 +461,17 @@ func (t *Task) Execute(fs ...func() error) error {
 						}
 					}
 
+					t.finishConflict(false)
+
 					klog.Infof("[Task] Id: %s, exec failed, clear result done!", t.id)
 				}
 				return
 			}
 		}
 
+		t.finishConflict(true)
+		t.finishVerify()
+
 		t.mu.Lock()
 		t.state = common.Completed
 		t.progress = 100
@@ -398,6 +482,11 @@ func (t *Task) Execute(fs ...func() error) error {
 		// 	share.UpdateMovedSharePaths(t.param.Owner, t.param.Src, t.param.Dst)
 		// }
 
+		if t.param.Action == common.ActionMove {
+			t.moveTags()
+		}
+		t.recordRecent()
+
 		return
 	})
 
@@ -408,6 +497,42 @@ func (t *Task) Execute(fs ...func() error) error {
 	return nil
 }
 
+// moveTags carries the tags and marks users set on a moved path over to
+// where it landed. Failures are logged, the tags are then lost.
+func (t *Task) moveTags() {
+	if database.DB == nil || t.param.Src == nil || t.param.Dst == nil {
+		return
+	}
+	if err := database.MoveFileTags(t.param.Src, t.param.Dst); err != nil {
+		klog.Errorf("[Task] Id: %s, move tags error: %v", t.id, err)
+	}
+}
+
+// recordRecent puts a pasted or uploaded path in the recently changed
+// feed of the task owner; a move also drops the source from every feed.
+// Pastes through a share are not recorded, their paths are the sharer's.
+// The previews of both paths are dropped, the destination may have been
+// overwritten, and those of a pasted destination are rendered again in
+// the background.
+func (t *Task) recordRecent() {
+	switch t.param.Action {
+	case common.ActionCopy, common.ActionMove, common.ActionUploadFinalize:
+	default:
+		return
+	}
+	if t.param.Action == common.ActionMove && t.param.Src != nil {
+		recent.Forget(t.param.Src)
+		preview.Invalidate(t.param.Src)
+	}
+	preview.Invalidate(t.param.Dst)
+	if t.param.Action != common.ActionUploadFinalize {
+		preview.Pregenerate(t.param.Dst)
+	}
+	if !t.isShare {
+		recent.Changed(t.param.Owner, t.param.Dst)
+	}
+}
+
 // ExecuteAsync runs the given phase functions in a bare goroutine,
 // bypassing the per-user pond pool. This allows upload-finalize tasks
 // to run concurrently with paste/copy tasks without pool contention.
@@ -480,6 +605,8 @@ func (t *Task) ExecuteAsync(fs ...func() error) {
 		t.transfer = t.totalSize
 		t.details = append(t.details, "successed")
 		t.mu.Unlock()
+
+		t.recordRecent()
 	}()
 }
 
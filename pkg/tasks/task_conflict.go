@@ -0,0 +1,606 @@
+package tasks
+
+import (
+	"errors"
+	"files/pkg/common"
+	"files/pkg/drivers/clouds/rclone"
+	"files/pkg/drivers/clouds/rclone/operations"
+	"files/pkg/drivers/sync/seahub"
+	"files/pkg/drivers/sync/seahub/seaserv"
+	"files/pkg/global"
+	"files/pkg/models"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// PasteConflict describes the existing destination a paste with the
+// ask policy stopped on. It is exposed through TaskInfo until the
+// client answers it with TaskManager.ResolveConflict.
+type PasteConflict struct {
+	Name       string `json:"name"`
+	Dst        string `json:"dst"`
+	IsDir      bool   `json:"is_dir"`
+	SrcSize    int64  `json:"src_size"`
+	SrcModTime int64  `json:"src_mtime"`
+	DstSize    int64  `json:"dst_size"`
+	DstModTime int64  `json:"dst_mtime"`
+}
+
+type pasteItemStat struct {
+	isDir   bool
+	size    int64
+	modTime int64
+}
+
+// conflictBackup is an existing destination file moved aside by an
+// overwrite. It is dropped once the paste completes and moved back
+// when the task fails or is cancelled, so a broken transfer never
+// costs the user the file it was meant to replace.
+type conflictBackup struct {
+	orig *models.FileParam
+	bak  *models.FileParam
+}
+
+// conflictMerge is how a folder is merged into the existing folder of
+// the same name: the paths, relative to both roots, rsync leaves out
+// because the destination is kept or the entry is renamed, the files
+// it overwrites, and the entries copied in under a new name.
+type conflictMerge struct {
+	srcRoot    string
+	dstRoot    string
+	skips      []string
+	overwrites []string
+	renames    []conflictRename
+}
+
+// conflictRename is a source entry copied in next to the destination
+// entry it conflicts with; paths are absolute, folders end in "/".
+type conflictRename struct {
+	src string
+	dst string
+}
+
+// conflictPolicy is the policy in effect for the task: a decision the
+// client applied to all through ResolveConflict wins over the one the
+// paste was submitted with.
+func (t *Task) conflictPolicy() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.conflictDecision != "" {
+		return t.conflictDecision
+	}
+	if t.param.Conflict == "" {
+		return common.PasteConflictRename
+	}
+	return t.param.Conflict
+}
+
+// entryPolicy is the policy for the destination entry dstUri: the
+// client's answer for that entry, else the task's policy.
+func (t *Task) entryPolicy(dstUri string) string {
+	t.mu.RLock()
+	answer, ok := t.conflictAnswers[dstUri]
+	t.mu.RUnlock()
+	if ok {
+		return answer
+	}
+	return t.conflictPolicy()
+}
+
+func (t *Task) mergePlan() *conflictMerge {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.conflictMerge
+}
+
+func (t *Task) setConflict(c *PasteConflict) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conflict = c
+}
+
+// answerConflict records resolution for the conflict the task is
+// parked on or, with applyAll, as the task's decision for every entry
+// still to come. It reports whether the task was parked on a conflict
+// and needs resuming; a task that is not takes no answer.
+func (t *Task) answerConflict(resolution string, applyAll bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conflict == nil || t.state != common.Paused {
+		return false
+	}
+
+	if applyAll {
+		t.conflictDecision = resolution
+	} else {
+		if t.conflictAnswers == nil {
+			t.conflictAnswers = make(map[string]string)
+		}
+		t.conflictAnswers[t.conflict.Dst] = resolution
+	}
+	t.conflict = nil
+	return true
+}
+
+// prepareConflict runs before the first phase of a copy or move and
+// applies the conflict policy to an existing destination. Rename
+// leaves the phases to pick a "name (1)" destination as before; a file
+// the policy overwrites is moved aside so every paste implementation
+// finds its target free. A folder pasted onto a folder is merged into
+// it, the policy then being applied to each entry both have (see
+// prepareMerge). The returned outcome is common.PasteConflictSkip when
+// the paste must not run at all and common.PasteConflictAsk when the
+// task has to wait for the client.
+func (t *Task) prepareConflict() (string, error) {
+	if t.param.Action != common.ActionCopy && t.param.Action != common.ActionMove {
+		return "", nil
+	}
+	// A resumed paste writes into the destination it already created.
+	if t.pausedSnap().WasPaused {
+		return "", nil
+	}
+
+	src, dst := t.conflictParams()
+	var dstUri = "/" + dst.FileType + "/" + dst.Extend + dst.Path
+
+	var policy = t.entryPolicy(dstUri)
+	if policy == common.PasteConflictRename {
+		return "", nil
+	}
+
+	if samePasteItem(src, dst) {
+		return "", nil
+	}
+
+	dstStat, err := statPasteItem(dst)
+	if err != nil {
+		return "", fmt.Errorf("stat dst error: %v", err)
+	}
+	if dstStat == nil {
+		return "", nil
+	}
+
+	var srcIsDir = strings.HasSuffix(src.Path, "/")
+	if srcIsDir && dstStat.isDir {
+		if !pasteMergeable(src, dst) {
+			t.appendDetail(fmt.Sprintf("conflict, keep both folders, %s cannot be merged from %s", dstUri, src.FileType))
+			return "", nil
+		}
+		return t.prepareMerge(src, dst)
+	}
+
+	// The source may live on another node (DownloadFromFiles) and
+	// cannot always be stat'ed from here; keep_newer then keeps the
+	// destination.
+	srcStat, err := statPasteItem(src)
+	if err != nil {
+		klog.Warningf("[Task] Id: %s, conflict, stat src error: %v", t.id, err)
+	}
+
+	klog.Infof("[Task] Id: %s, conflict, policy: %s, dst: %s", t.id, policy, dstUri)
+
+	switch resolveEntry(policy, srcIsDir, srcStat, dstStat) {
+	case common.PasteConflictSkip:
+		t.appendDetail(fmt.Sprintf("conflict, skip existing %s", dstUri))
+		return common.PasteConflictSkip, nil
+
+	case common.PasteConflictAsk:
+		t.setConflict(newPasteConflict(dstUri, srcStat, dstStat))
+		t.appendDetail(fmt.Sprintf("conflict, wait for decision on %s", dstUri))
+		return common.PasteConflictAsk, nil
+
+	case common.PasteConflictOverwrite:
+		var bak = *dst
+		bak.Path = dst.Path + ".bak." + t.id
+		if err = renamePasteItem(dst, &bak, false); err != nil {
+			return "", fmt.Errorf("backup %s error: %v", dstUri, err)
+		}
+		t.mu.Lock()
+		t.conflictBackups = append(t.conflictBackups, conflictBackup{orig: dst, bak: &bak})
+		t.mu.Unlock()
+		t.appendDetail(fmt.Sprintf("conflict, overwrite %s", dstUri))
+	}
+
+	return "", nil
+}
+
+// prepareMerge walks the source folder against the existing
+// destination folder of the same name. Entries only the source has are
+// simply copied in; for every entry both have the policy, or the
+// client's answer for that entry, decides: skip leaves the destination
+// entry alone, overwrite replaces a file (never a folder), rename
+// copies the source entry in next to it as "name (1)", and ask stops
+// the walk on the first undecided entry. Nothing is touched until the
+// whole tree is decided; the plan is then carried out by the rsync
+// phase.
+func (t *Task) prepareMerge(src, dst *models.FileParam) (string, error) {
+	srcUri, err := src.GetResourceUri()
+	if err != nil {
+		return "", fmt.Errorf("get src uri error: %v", err)
+	}
+	dstUri, err := dst.GetResourceUri()
+	if err != nil {
+		return "", fmt.Errorf("get dst uri error: %v", err)
+	}
+
+	var plan = &conflictMerge{srcRoot: srcUri + src.Path, dstRoot: dstUri + dst.Path}
+	outcome, err := t.walkConflicts(dst, plan, "")
+	if err != nil || outcome != "" {
+		return outcome, err
+	}
+
+	for _, rel := range plan.overwrites {
+		var orig = *dst
+		orig.Path = dst.Path + rel
+		var bak = orig
+		bak.Path = orig.Path + ".bak." + t.id
+		if err = renamePasteItem(&orig, &bak, false); err != nil {
+			t.finishConflict(false)
+			return "", fmt.Errorf("backup %s error: %v", orig.Path, err)
+		}
+		t.mu.Lock()
+		t.conflictBackups = append(t.conflictBackups, conflictBackup{orig: &orig, bak: &bak})
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	t.conflictMerge = plan
+	t.mu.Unlock()
+	t.appendDetail(fmt.Sprintf("conflict, merge into /%s/%s%s", dst.FileType, dst.Extend, dst.Path))
+	return "", nil
+}
+
+// walkConflicts records in plan what happens to the entries of the
+// source folder rel that the destination has too, descending into the
+// folders both have. It returns common.PasteConflictAsk on the first
+// entry waiting for the client.
+func (t *Task) walkConflicts(dst *models.FileParam, plan *conflictMerge, rel string) (string, error) {
+	entries, err := os.ReadDir(plan.srcRoot + rel)
+	if err != nil {
+		return "", fmt.Errorf("read src dir error: %v", err)
+	}
+
+	for _, entry := range entries {
+		if err = t.ctx.Err(); err != nil {
+			return "", err
+		}
+
+		var entryRel = rel + entry.Name()
+		dstInfo, err := os.Lstat(plan.dstRoot + entryRel)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("stat dst error: %v", err)
+		}
+
+		if entry.IsDir() && dstInfo.IsDir() {
+			if outcome, err := t.walkConflicts(dst, plan, entryRel+"/"); err != nil || outcome != "" {
+				return outcome, err
+			}
+			continue
+		}
+
+		srcInfo, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("stat src error: %v", err)
+		}
+		var srcStat, dstStat = statOfInfo(srcInfo), statOfInfo(dstInfo)
+		var dstUri = "/" + dst.FileType + "/" + dst.Extend + dst.Path + entryRel
+		if dstInfo.IsDir() {
+			dstUri += "/"
+		}
+
+		switch resolveEntry(t.entryPolicy(dstUri), entry.IsDir(), srcStat, dstStat) {
+		case common.PasteConflictAsk:
+			t.setConflict(newPasteConflict(dstUri, srcStat, dstStat))
+			t.appendDetail(fmt.Sprintf("conflict, wait for decision on %s", dstUri))
+			return common.PasteConflictAsk, nil
+
+		case common.PasteConflictSkip:
+			plan.skips = append(plan.skips, entryRel)
+			t.appendDetail(fmt.Sprintf("conflict, skip existing %s", dstUri))
+
+		case common.PasteConflictOverwrite:
+			plan.overwrites = append(plan.overwrites, entryRel)
+			t.appendDetail(fmt.Sprintf("conflict, overwrite %s", dstUri))
+
+		default:
+			name, err := freeEntryName(plan.srcRoot+rel, plan.dstRoot+rel, entry.Name(), entry.IsDir())
+			if err != nil {
+				return "", err
+			}
+			var r = conflictRename{src: plan.srcRoot + entryRel, dst: plan.dstRoot + rel + name}
+			if entry.IsDir() {
+				r.src += "/"
+				r.dst += "/"
+			}
+			plan.skips = append(plan.skips, entryRel)
+			plan.renames = append(plan.renames, r)
+			t.appendDetail(fmt.Sprintf("conflict, keep both %s as %s", dstUri, name))
+		}
+	}
+
+	return "", nil
+}
+
+// finishConflict drops the overwrite backups after a successful paste,
+// or moves them back into place otherwise. It is safe to call more than
+// once; only the first call sees the backups.
+func (t *Task) finishConflict(success bool) {
+	t.mu.Lock()
+	var backups = t.conflictBackups
+	t.conflictBackups = nil
+	t.mu.Unlock()
+
+	for _, b := range backups {
+		if success {
+			if err := removePasteItem(b.bak); err != nil {
+				klog.Errorf("[Task] Id: %s, conflict, remove backup %s error: %v", t.id, b.bak.Path, err)
+			}
+			continue
+		}
+
+		// Whatever the failed paste left at the destination has already
+		// been cleared by the caller; an entry still in the way would
+		// make the restore fail, so drop it first.
+		if exists, _ := statPasteItem(b.orig); exists != nil {
+			if err := removePasteItem(b.orig); err != nil {
+				klog.Errorf("[Task] Id: %s, conflict, clear %s before restore error: %v", t.id, b.orig.Path, err)
+			}
+		}
+		if err := renamePasteItem(b.bak, b.orig, false); err != nil {
+			klog.Errorf("[Task] Id: %s, conflict, restore backup %s error: %v", t.id, b.bak.Path, err)
+			continue
+		}
+		klog.Infof("[Task] Id: %s, conflict, backup restored: %s", t.id, b.orig.Path)
+	}
+}
+
+// conflictParams returns copies of the paste source and destination
+// carrying the owners the phases act as.
+func (t *Task) conflictParams() (*models.FileParam, *models.FileParam) {
+	var src, dst = *t.param.Src, *t.param.Dst
+	if t.param.Share == 1 {
+		src.Owner = t.param.SrcOwner
+		dst.Owner = t.param.DstOwner
+	}
+	return &src, &dst
+}
+
+// resolveEntry turns the policy for one entry both sides have into
+// what happens to it. Overwrite and keep_newer only ever replace a file
+// by a file: a folder is never replaced as a whole, and a file and a
+// folder of the same name are both kept.
+func resolveEntry(policy string, srcIsDir bool, src, dst *pasteItemStat) string {
+	if policy == common.PasteConflictOverwrite || policy == common.PasteConflictKeepNewer {
+		if srcIsDir || dst.isDir {
+			return common.PasteConflictRename
+		}
+	}
+	if policy == common.PasteConflictKeepNewer {
+		return keepNewer(src, dst)
+	}
+	return policy
+}
+
+// keepNewer turns keep_newer into overwrite or skip by comparing the
+// modification times of the two files.
+func keepNewer(src, dst *pasteItemStat) string {
+	if src != nil && src.modTime > dst.modTime {
+		return common.PasteConflictOverwrite
+	}
+	return common.PasteConflictSkip
+}
+
+func newPasteConflict(dstUri string, src, dst *pasteItemStat) *PasteConflict {
+	var c = &PasteConflict{
+		Name:       path.Base(strings.TrimSuffix(dstUri, "/")),
+		Dst:        dstUri,
+		IsDir:      dst.isDir,
+		DstSize:    dst.size,
+		DstModTime: dst.modTime,
+	}
+	if src != nil {
+		c.SrcSize = src.size
+		c.SrcModTime = src.modTime
+	}
+	return c
+}
+
+// pasteMergeable reports whether a folder pasted from src onto the
+// folder dst can be merged into it: only the rsync phase between local
+// storages merges, the other implementations write whole folders.
+func pasteMergeable(src, dst *models.FileParam) bool {
+	for _, p := range []*models.FileParam{src, dst} {
+		if p.IsSync() || p.IsCloud() {
+			return false
+		}
+		if (p.FileType == common.Cache || p.FileType == common.External) && p.Extend != global.CurrentNodeName {
+			return false
+		}
+	}
+	return true
+}
+
+// freeEntryName returns the first "name (n)" neither folder has, so the
+// renamed entry is not overwritten by a source entry of that name.
+func freeEntryName(srcDir, dstDir, name string, isDir bool) (string, error) {
+	var base, ext = name, ""
+	if !isDir {
+		base, ext = common.SplitNameExt(name)
+	}
+	for i := 1; i < 10000; i++ {
+		var cand = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		_, serr := os.Lstat(srcDir + cand)
+		_, derr := os.Lstat(dstDir + cand)
+		if errors.Is(serr, os.ErrNotExist) && errors.Is(derr, os.ErrNotExist) {
+			return cand, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", name)
+}
+
+// writeExcludes writes the paths rsync leaves out to a temporary file,
+// NUL separated for --from0 and anchored at the transfer root, and
+// returns its name; "" when there is none.
+func (m *conflictMerge) writeExcludes() (string, error) {
+	if len(m.skips) == 0 {
+		return "", nil
+	}
+	f, err := os.CreateTemp("", "paste-exclude-")
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range m.skips {
+		// a backslash only escapes in a pattern that has a wildcard
+		if strings.ContainsAny(rel, "*?[") {
+			rel = rsyncPatternEscaper.Replace(rel)
+		}
+		if _, err = f.WriteString("/" + rel + "\x00"); err != nil {
+			break
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+var rsyncPatternEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+func statOfInfo(info os.FileInfo) *pasteItemStat {
+	var s = &pasteItemStat{isDir: info.IsDir(), modTime: info.ModTime().Unix()}
+	if !s.isDir {
+		s.size = info.Size()
+	}
+	return s
+}
+
+func samePasteItem(a, b *models.FileParam) bool {
+	return a.Owner == b.Owner && a.FileType == b.FileType && a.Extend == b.Extend &&
+		strings.TrimSuffix(a.Path, "/") == strings.TrimSuffix(b.Path, "/")
+}
+
+// statPasteItem returns nil without error when p does not exist.
+func statPasteItem(p *models.FileParam) (*pasteItemStat, error) {
+	switch {
+	case p.IsSync():
+		// seafile reports a missing path as an RPC error; treating it
+		// as absent falls back to the rename flow, which never loses data.
+		dirent, err := seaserv.GlobalSeafileAPI.GetDirentByPath(p.Extend, strings.TrimSuffix(p.Path, "/"))
+		if err != nil || dirent == nil || dirent["obj_id"] == "" {
+			return nil, nil
+		}
+		var s = &pasteItemStat{}
+		s.isDir, _ = seahub.IsDirectory(dirent["mode"])
+		s.size, _ = strconv.ParseInt(dirent["size"], 10, 64)
+		s.modTime, _ = strconv.ParseInt(dirent["mtime"], 10, 64)
+		return s, nil
+
+	case p.IsCloud():
+		fs, err := cloudParentFs(p)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := rclone.Command.GetOperation().Stat(fs, path.Base(strings.TrimSuffix(p.Path, "/")), &operations.OperationsOpt{NoMimeType: true})
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil || resp.Item == nil {
+			return nil, nil
+		}
+		var s = &pasteItemStat{isDir: resp.Item.IsDir, size: resp.Item.Size}
+		if modTime, ok := common.ParseRFC3339Nano(resp.Item.ModTime); ok {
+			s.modTime = modTime.Unix()
+		}
+		return s, nil
+
+	default:
+		uri, err := p.GetResourceUri()
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(uri + p.Path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return statOfInfo(info), nil
+	}
+}
+
+// renamePasteItem renames from to to within the same folder.
+func renamePasteItem(from, to *models.FileParam, isDir bool) error {
+	var fromPath = strings.TrimSuffix(from.Path, "/")
+	var toPath = strings.TrimSuffix(to.Path, "/")
+
+	switch {
+	case from.IsSync():
+		resultCode, err := seaserv.GlobalSeafileAPI.RenameFile(from.Extend, path.Dir(fromPath), path.Base(fromPath), path.Base(toPath), from.Owner+"@auth.local")
+		if err != nil {
+			return err
+		}
+		if resultCode != 0 {
+			return fmt.Errorf("rename result code: %d", resultCode)
+		}
+		return nil
+
+	case from.IsCloud():
+		fs, err := cloudParentFs(from)
+		if err != nil {
+			return err
+		}
+		if !isDir {
+			return rclone.Command.GetOperation().MoveFile(fs, path.Base(fromPath), fs, path.Base(toPath))
+		}
+		return rclone.Command.GetOperation().Move(fs+path.Base(fromPath)+"/", fs+path.Base(toPath)+"/")
+
+	default:
+		uri, err := from.GetResourceUri()
+		if err != nil {
+			return err
+		}
+		return os.Rename(uri+fromPath, uri+toPath)
+	}
+}
+
+func removePasteItem(p *models.FileParam) error {
+	switch {
+	case p.IsSync():
+		return seahub.HandleDelete(p)
+	case p.IsCloud():
+		return rclone.Command.Clear(p)
+	default:
+		uri, err := p.GetResourceUri()
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(uri + strings.TrimSuffix(p.Path, "/"))
+	}
+}
+
+// cloudParentFs is the rclone fs of the folder holding p.
+func cloudParentFs(p *models.FileParam) (string, error) {
+	fsPrefix, err := rclone.Command.GetFsPrefix(p)
+	if err != nil {
+		return "", err
+	}
+	var parent = path.Dir(strings.TrimSuffix(p.Path, "/"))
+	if !strings.HasSuffix(parent, "/") {
+		parent += "/"
+	}
+	return fsPrefix + parent, nil
+}
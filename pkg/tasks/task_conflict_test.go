@@ -0,0 +1,228 @@
+package tasks
+
+import (
+	"context"
+	"files/pkg/common"
+	"files/pkg/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestKeepNewer pins that keep_newer only overwrites when the source
+// is strictly newer, and keeps the destination when the source could
+// not be stat'ed.
+func TestKeepNewer(t *testing.T) {
+	dst := &pasteItemStat{modTime: 100}
+	cases := []struct {
+		src  *pasteItemStat
+		want string
+	}{
+		{&pasteItemStat{modTime: 101}, common.PasteConflictOverwrite},
+		{&pasteItemStat{modTime: 100}, common.PasteConflictSkip},
+		{&pasteItemStat{modTime: 99}, common.PasteConflictSkip},
+		{nil, common.PasteConflictSkip},
+	}
+	for _, c := range cases {
+		if got := keepNewer(c.src, dst); got != c.want {
+			t.Errorf("keepNewer(%+v) = %s, want %s", c.src, got, c.want)
+		}
+	}
+}
+
+// TestSamePasteItem guards the copy-in-place case: overwriting the
+// source with itself must never move it aside.
+func TestSamePasteItem(t *testing.T) {
+	a := &models.FileParam{Owner: "u", FileType: "drive", Extend: "Home", Path: "/Docs/"}
+	b := &models.FileParam{Owner: "u", FileType: "drive", Extend: "Home", Path: "/Docs"}
+	if !samePasteItem(a, b) {
+		t.Errorf("trailing slash must not matter")
+	}
+	b.Path = "/Docs (1)/"
+	if samePasteItem(a, b) {
+		t.Errorf("different paths reported as the same item")
+	}
+}
+
+// TestAnswerConflict pins that only a task parked on a conflict takes
+// a decision, for its entry alone unless applied to all, and that a
+// task not parked is left alone even by an apply-to-all answer.
+func TestAnswerConflict(t *testing.T) {
+	parked := &Task{state: common.Paused, conflict: &PasteConflict{Name: "a", Dst: "/drive/Home/a"}, param: &models.PasteParam{Conflict: common.PasteConflictAsk}}
+	if !parked.answerConflict(common.PasteConflictSkip, false) {
+		t.Fatalf("parked task did not take the decision")
+	}
+	if parked.conflict != nil || parked.entryPolicy("/drive/Home/a") != common.PasteConflictSkip {
+		t.Errorf("answer not recorded: %+v", parked)
+	}
+	if parked.conflictPolicy() != common.PasteConflictAsk {
+		t.Errorf("a single answer must not apply to the other entries")
+	}
+	if parked.answerConflict(common.PasteConflictOverwrite, false) {
+		t.Errorf("a task without a pending conflict cannot be resolved")
+	}
+
+	parked.state, parked.conflict = common.Paused, &PasteConflict{Name: "b", Dst: "/drive/Home/b"}
+	if !parked.answerConflict(common.PasteConflictOverwrite, true) || parked.conflictPolicy() != common.PasteConflictOverwrite {
+		t.Errorf("apply-to-all decision not taken by parked task")
+	}
+	if parked.entryPolicy("/drive/Home/a") != common.PasteConflictSkip {
+		t.Errorf("earlier answer lost")
+	}
+
+	for _, state := range []string{common.Pending, common.Running, common.Completed} {
+		other := &Task{state: state, param: &models.PasteParam{Conflict: common.PasteConflictAsk}}
+		if other.answerConflict(common.PasteConflictOverwrite, true) || other.conflictPolicy() != common.PasteConflictAsk {
+			t.Errorf("%s task took an apply-to-all decision", state)
+		}
+	}
+}
+
+// TestResolveEntry pins that overwrite and keep_newer never replace a
+// folder, nor a file by a folder.
+func TestResolveEntry(t *testing.T) {
+	file := &pasteItemStat{modTime: 100}
+	newer := &pasteItemStat{modTime: 200}
+	dir := &pasteItemStat{isDir: true, modTime: 50}
+	cases := []struct {
+		policy   string
+		srcIsDir bool
+		src, dst *pasteItemStat
+		want     string
+	}{
+		{common.PasteConflictOverwrite, false, file, file, common.PasteConflictOverwrite},
+		{common.PasteConflictOverwrite, false, file, dir, common.PasteConflictRename},
+		{common.PasteConflictOverwrite, true, dir, file, common.PasteConflictRename},
+		{common.PasteConflictKeepNewer, false, newer, file, common.PasteConflictOverwrite},
+		{common.PasteConflictKeepNewer, false, file, newer, common.PasteConflictSkip},
+		{common.PasteConflictKeepNewer, false, newer, dir, common.PasteConflictRename},
+		{common.PasteConflictSkip, true, dir, file, common.PasteConflictSkip},
+		{common.PasteConflictAsk, false, file, dir, common.PasteConflictAsk},
+	}
+	for _, c := range cases {
+		if got := resolveEntry(c.policy, c.srcIsDir, c.src, c.dst); got != c.want {
+			t.Errorf("resolveEntry(%s, %v, %+v, %+v) = %s, want %s", c.policy, c.srcIsDir, c.src, c.dst, got, c.want)
+		}
+	}
+}
+
+func writeTree(t *testing.T, root string, tree map[string]time.Time) {
+	t.Helper()
+	for name, mtime := range tree {
+		p := filepath.Join(root, name)
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestWalkConflicts merges a folder into one of the same name: only the
+// entries both have are decided, per file, and ask stops on the first
+// undecided one.
+func TestWalkConflicts(t *testing.T) {
+	old, recent := time.Unix(1000, 0), time.Unix(2000, 0)
+	srcRoot, dstRoot := t.TempDir()+"/", t.TempDir()+"/"
+	writeTree(t, srcRoot, map[string]time.Time{
+		"new.txt":       recent,
+		"a.txt":         recent,
+		"b.txt":         old,
+		"sub/c.txt":     recent,
+		"sub/only.txt":  recent,
+		"clash/x.txt":   recent,
+		"clash (1)/":    {},
+		"emptysrc/":     {},
+		"sub/deep/d.md": old,
+	})
+	writeTree(t, dstRoot, map[string]time.Time{
+		"a.txt":         old,
+		"b.txt":         recent,
+		"sub/c.txt":     old,
+		"clash":         old,
+		"sub/deep/d.md": recent,
+	})
+	dst := &models.FileParam{Owner: "u", FileType: "drive", Extend: "Home", Path: "/Docs/"}
+
+	task := &Task{ctx: context.Background(), param: &models.PasteParam{Conflict: common.PasteConflictKeepNewer}}
+	plan := &conflictMerge{srcRoot: srcRoot, dstRoot: dstRoot}
+	if outcome, err := task.walkConflicts(dst, plan, ""); err != nil || outcome != "" {
+		t.Fatalf("walk = %q, %v", outcome, err)
+	}
+	sort.Strings(plan.overwrites)
+	sort.Strings(plan.skips)
+	if want := []string{"a.txt", "sub/c.txt"}; !reflect.DeepEqual(plan.overwrites, want) {
+		t.Errorf("overwrites = %v, want %v", plan.overwrites, want)
+	}
+	if want := []string{"b.txt", "clash", "sub/deep/d.md"}; !reflect.DeepEqual(plan.skips, want) {
+		t.Errorf("skips = %v, want %v", plan.skips, want)
+	}
+	// "clash (1)" is taken by the source, the folder gets the next free name
+	if want := []conflictRename{{src: srcRoot + "clash/", dst: dstRoot + "clash (2)/"}}; !reflect.DeepEqual(plan.renames, want) {
+		t.Errorf("renames = %+v, want %+v", plan.renames, want)
+	}
+
+	task = &Task{ctx: context.Background(), param: &models.PasteParam{Conflict: common.PasteConflictAsk}}
+	plan = &conflictMerge{srcRoot: srcRoot, dstRoot: dstRoot}
+	outcome, err := task.walkConflicts(dst, plan, "")
+	if err != nil || outcome != common.PasteConflictAsk || task.conflict == nil || task.conflict.Dst != "/drive/Home/Docs/a.txt" {
+		t.Fatalf("ask walk = %q, %v, %+v", outcome, err, task.conflict)
+	}
+	if task.conflict.SrcModTime != recent.Unix() || task.conflict.DstModTime != old.Unix() {
+		t.Errorf("conflict = %+v", task.conflict)
+	}
+
+	task.state = common.Paused
+	task.answerConflict(common.PasteConflictSkip, false)
+	plan = &conflictMerge{srcRoot: srcRoot, dstRoot: dstRoot}
+	if outcome, _ = task.walkConflicts(dst, plan, ""); outcome != common.PasteConflictAsk || task.conflict.Dst != "/drive/Home/Docs/b.txt" {
+		t.Errorf("second ask on %+v", task.conflict)
+	}
+	if !reflect.DeepEqual(plan.skips, []string{"a.txt"}) {
+		t.Errorf("answered entry not skipped: %v", plan.skips)
+	}
+}
+
+func TestWriteExcludes(t *testing.T) {
+	m := &conflictMerge{skips: []string{"a.txt", "sub/b[1].txt"}}
+	name, err := m.writeExcludes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(name)
+	data, _ := os.ReadFile(name)
+	if want := "/a.txt\x00/sub/b\\[1].txt\x00"; string(data) != want {
+		t.Errorf("excludes = %q, want %q", data, want)
+	}
+
+	if name, err = (&conflictMerge{}).writeExcludes(); name != "" || err != nil {
+		t.Errorf("no skips = %q, %v", name, err)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	root := t.TempDir() + "/src/"
+	writeTree(t, root, map[string]time.Time{"a/b/": {}, "c/": {}, "d/kept.txt": time.Now()})
+	if err := removeEmptyDirs(root); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"a": false, "c": false, "d/kept.txt": true, "": true} {
+		if _, err := os.Stat(root + name); (err == nil) != want {
+			t.Errorf("%q exists = %v", name, err == nil)
+		}
+	}
+}
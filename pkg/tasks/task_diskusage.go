@@ -0,0 +1,425 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/drivers/clouds/rclone"
+	"files/pkg/drivers/sync/seahub"
+	"files/pkg/drivers/sync/seahub/seaserv"
+	"files/pkg/models"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// usageChildrenLimit caps how many children are kept per cached
+	// folder; the API only ever serves the top-N of them.
+	usageChildrenLimit = 200
+	// usageCacheDepth limits how deep below the scanned root folder
+	// totals are cached, so scanning a disk with millions of folders
+	// does not pin all of them in memory.
+	usageCacheDepth = 8
+	// usageCacheTTL is how long a scan result is served before the
+	// client is expected to rescan.
+	usageCacheTTL = time.Hour
+	// usageCacheMaxEntries bounds the folder cache across all scans.
+	usageCacheMaxEntries = 100000
+)
+
+// UsageEntry is one direct child of a folder in a DiskUsage.
+type UsageEntry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+	Size  int64  `json:"size"`
+}
+
+// DiskUsage is the recursive total of a folder, with its largest
+// children and the bytes used per file type. TypesPartial is set for
+// sync and cloud folders, where subfolder sizes come from the backend
+// and only files directly inside the folder are classified.
+type DiskUsage struct {
+	Path         string           `json:"path"`
+	Size         int64            `json:"size"`
+	Files        int64            `json:"files"`
+	Dirs         int64            `json:"dirs"`
+	Types        map[string]int64 `json:"types"`
+	TypesPartial bool             `json:"types_partial,omitempty"`
+	Children     []*UsageEntry    `json:"children"`
+	ScannedAt    int64            `json:"scanned_at"`
+}
+
+// Top returns a copy of u with only the n largest children.
+func (u *DiskUsage) Top(n int) *DiskUsage {
+	var c = *u
+	if n > 0 && len(c.Children) > n {
+		c.Children = c.Children[:n]
+	}
+	return &c
+}
+
+type diskUsageCache struct {
+	mu      sync.RWMutex
+	entries map[string]*DiskUsage
+}
+
+var usageCache = &diskUsageCache{entries: make(map[string]*DiskUsage)}
+
+// GetDiskUsage returns the cached totals of the folder uri (e.g.
+// /external/node/usb/Movies/), or nil when it has not been scanned or
+// the scan is older than usageCacheTTL.
+func GetDiskUsage(uri string) *DiskUsage {
+	usageCache.mu.RLock()
+	defer usageCache.mu.RUnlock()
+
+	u, ok := usageCache.entries[usageKey(uri)]
+	if !ok || time.Since(time.Unix(u.ScannedAt, 0)) > usageCacheTTL {
+		return nil
+	}
+	return u
+}
+
+func (c *diskUsageCache) set(u *DiskUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= usageCacheMaxEntries {
+		for k, v := range c.entries {
+			if time.Since(time.Unix(v.ScannedAt, 0)) > usageCacheTTL {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= usageCacheMaxEntries {
+			return
+		}
+	}
+	c.entries[usageKey(u.Path)] = u
+}
+
+func usageKey(uri string) string {
+	return strings.TrimSuffix(uri, "/") + "/"
+}
+
+// usageFileType buckets a file name into the categories shown in the
+// type breakdown.
+func usageFileType(name string) string {
+	if common.ArchiveFormatFromName(name) != "" {
+		return "archive"
+	}
+
+	var mimetype = mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if mimetype == "" {
+		mimetype = common.MimeTypeByExtension(name)
+	}
+
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		return "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		return "image"
+	case strings.HasSuffix(mimetype, "pdf"),
+		strings.Contains(mimetype, "officedocument"),
+		strings.Contains(mimetype, "opendocument"),
+		strings.Contains(mimetype, "msword"):
+		return "document"
+	case strings.HasPrefix(mimetype, "text"):
+		return "text"
+	default:
+		return "other"
+	}
+}
+
+// usageAcc accumulates one folder while the walk is inside it.
+type usageAcc struct {
+	path     string // path relative to the storage root, no trailing slash
+	depth    int
+	size     int64
+	files    int64
+	dirs     int64
+	types    map[string]int64
+	children []*UsageEntry
+}
+
+func newUsageAcc(p string, depth int) *usageAcc {
+	return &usageAcc{path: p, depth: depth, types: make(map[string]int64)}
+}
+
+func (a *usageAcc) contains(p string) bool {
+	return a.path == "" || strings.HasPrefix(p, a.path+"/")
+}
+
+func (a *usageAcc) result(uriPrefix string, scannedAt int64, partial bool) *DiskUsage {
+	sort.SliceStable(a.children, func(i, j int) bool { return a.children[i].Size > a.children[j].Size })
+	if len(a.children) > usageChildrenLimit {
+		a.children = a.children[:usageChildrenLimit]
+	}
+	return &DiskUsage{
+		Path:         uriPrefix + a.path + "/",
+		Size:         a.size,
+		Files:        a.files,
+		Dirs:         a.dirs,
+		Types:        a.types,
+		TypesPartial: partial,
+		Children:     a.children,
+		ScannedAt:    scannedAt,
+	}
+}
+
+// DiskUsage is the Task phase function used by disk-usage requests. It
+// totals the folder root recursively and caches the result for root and
+// (on local storages) every folder below it up to usageCacheDepth.
+func (t *Task) DiskUsage(root *models.FileParam) func() error {
+	return func() error {
+		var uriPrefix = "/" + root.FileType + "/" + root.Extend
+
+		klog.Infof("[Task] Id: %s, disk usage, user: %s, root: %s", t.id, t.param.Owner, uriPrefix+root.Path)
+
+		var usage *DiskUsage
+		var err error
+		switch {
+		case root.IsSync():
+			usage, err = t.syncDiskUsage(root, uriPrefix)
+		case root.IsCloud():
+			usage, err = t.cloudDiskUsage(root, uriPrefix)
+		default:
+			usage, err = t.posixDiskUsage(root, uriPrefix)
+		}
+		if err != nil {
+			return err
+		}
+
+		usageCache.set(usage)
+		t.updateTotalSize(usage.Size)
+		t.updateProgressRsync(100, usage.Size)
+		t.appendDetail(fmt.Sprintf("disk usage: %s, size: %d, files: %d, dirs: %d", usage.Path, usage.Size, usage.Files, usage.Dirs))
+
+		return nil
+	}
+}
+
+func (t *Task) posixDiskUsage(root *models.FileParam, uriPrefix string) (*DiskUsage, error) {
+	uri, err := root.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+
+	var fs = afero.NewBasePathFs(afero.NewOsFs(), uri)
+	var rootPath = strings.TrimSuffix(root.Path, "/")
+	var scannedAt = time.Now().Unix()
+	var stack []*usageAcc
+	var result *DiskUsage
+	var counted int64
+
+	// afero.Walk is a lexical pre-order walk, so a folder is complete as
+	// soon as the walk leaves it: pop it, cache it and fold it into its
+	// parent. Only the current branch is held in memory.
+	pop := func() {
+		acc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		usage := acc.result(uriPrefix, scannedAt, false)
+		if acc.depth <= usageCacheDepth {
+			usageCache.set(usage)
+		}
+		if len(stack) == 0 {
+			result = usage
+			return
+		}
+
+		parent := stack[len(stack)-1]
+		parent.size += acc.size
+		parent.files += acc.files
+		parent.dirs += acc.dirs + 1
+		for k, v := range acc.types {
+			parent.types[k] += v
+		}
+		parent.children = append(parent.children, &UsageEntry{
+			Name:  path.Base(acc.path),
+			Path:  usage.Path,
+			IsDir: true,
+			Size:  acc.size,
+		})
+	}
+
+	walkRoot := rootPath
+	if walkRoot == "" {
+		walkRoot = "/"
+	}
+
+	err = afero.Walk(fs, walkRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsPermission(err) {
+				klog.Warningf("[Task] Id: %s, disk usage, skip %s: %v", t.id, p, err)
+				return nil
+			}
+			return err
+		}
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return ctxErr
+		}
+
+		p = strings.TrimSuffix(p, "/")
+		for len(stack) > 0 && !stack[len(stack)-1].contains(p) {
+			pop()
+		}
+
+		if info.IsDir() {
+			stack = append(stack, newUsageAcc(p, len(stack)))
+			return nil
+		}
+		if len(stack) == 0 {
+			return errors.New("not a folder")
+		}
+
+		top := stack[len(stack)-1]
+		top.size += info.Size()
+		top.files++
+		top.types[usageFileType(info.Name())] += info.Size()
+		top.children = append(top.children, &UsageEntry{
+			Name: info.Name(),
+			Path: uriPrefix + p,
+			Size: info.Size(),
+		})
+
+		counted += info.Size()
+		if top.files%1000 == 0 {
+			t.updateProgressRsync(0, counted)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for len(stack) > 0 {
+		pop()
+	}
+	if result == nil {
+		return nil, errors.New("not a folder")
+	}
+
+	return result, nil
+}
+
+func (t *Task) cloudDiskUsage(root *models.FileParam, uriPrefix string) (*DiskUsage, error) {
+	var dir = strings.TrimSuffix(root.Path, "/") + "/"
+	var dirParam = &models.FileParam{Owner: root.Owner, FileType: root.FileType, Extend: root.Extend, Path: dir}
+
+	list, err := rclone.Command.GetFilesList(dirParam, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc = newUsageAcc(strings.TrimSuffix(dir, "/"), 0)
+	if list != nil {
+		for _, item := range list.List {
+			if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+				return nil, ctxErr
+			}
+
+			if !item.IsDir {
+				acc.addFile(item.Name, uriPrefix+dir+item.Name, item.Size)
+				continue
+			}
+
+			var child = &models.FileParam{Owner: root.Owner, FileType: root.FileType, Extend: root.Extend, Path: dir + item.Name + "/"}
+			size, err := rclone.Command.GetFilesSize(child)
+			if err != nil {
+				klog.Warningf("[Task] Id: %s, disk usage, cloud size %s error: %v", t.id, child.Path, err)
+				t.appendDetail(fmt.Sprintf("skip %s: %v", child.Path, err))
+				continue
+			}
+			acc.addDir(item.Name, uriPrefix+child.Path, size)
+			t.updateProgressRsync(0, acc.size)
+		}
+	}
+
+	return acc.result(uriPrefix, time.Now().Unix(), true), nil
+}
+
+func (t *Task) syncDiskUsage(root *models.FileParam, uriPrefix string) (*DiskUsage, error) {
+	repo, err := seaserv.GlobalSeafileAPI.GetRepo(root.Extend)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, errors.New("repo not found")
+	}
+	version, err := strconv.Atoi(repo["version"])
+	if err != nil {
+		return nil, err
+	}
+
+	var dir = strings.TrimSuffix(root.Path, "/") + "/"
+	dirInfoRes, err := seahub.HandleGetRepoDir(&models.FileParam{Owner: root.Owner, FileType: root.FileType, Extend: root.Extend, Path: dir})
+	if err != nil {
+		return nil, err
+	}
+	if dirInfoRes == nil {
+		return nil, errors.New("folder not found")
+	}
+
+	var dirInfo map[string]interface{}
+	if err = json.Unmarshal(dirInfoRes, &dirInfo); err != nil {
+		return nil, err
+	}
+	direntList, err := normalizeSyncDirentList(dirInfo["dirent_list"], dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc = newUsageAcc(strings.TrimSuffix(dir, "/"), 0)
+	for _, dirent := range direntList {
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return nil, ctxErr
+		}
+
+		name, _ := dirent["name"].(string)
+		objType, _ := dirent["type"].(string)
+		if objType != "dir" {
+			size, _ := dirent["size"].(float64)
+			acc.addFile(name, uriPrefix+dir+name, int64(size))
+			continue
+		}
+
+		// Seafile totals a folder from its fs objects server side, so no
+		// recursion is needed here.
+		dirId, _ := dirent["id"].(string)
+		size, err := seaserv.GlobalSeafileAPI.GetDirSize(repo["store_id"], version, dirId)
+		if err != nil {
+			klog.Warningf("[Task] Id: %s, disk usage, sync dir size %s error: %v", t.id, dir+name, err)
+			t.appendDetail(fmt.Sprintf("skip %s: %v", dir+name, err))
+			continue
+		}
+		acc.addDir(name, uriPrefix+dir+name+"/", size)
+		t.updateProgressRsync(0, acc.size)
+	}
+
+	return acc.result(uriPrefix, time.Now().Unix(), true), nil
+}
+
+func (a *usageAcc) addFile(name, uri string, size int64) {
+	a.size += size
+	a.files++
+	a.types[usageFileType(name)] += size
+	a.children = append(a.children, &UsageEntry{Name: name, Path: uri, Size: size})
+}
+
+func (a *usageAcc) addDir(name, uri string, size int64) {
+	a.size += size
+	a.dirs++
+	a.children = append(a.children, &UsageEntry{Name: name, Path: uri, IsDir: true, Size: size})
+}
@@ -0,0 +1,34 @@
+package tasks
+
+import "testing"
+
+// TestUsageAccResult pins that children are ordered largest first,
+// file sizes are bucketed by type, and the result is keyed by the
+// storage-qualified folder path with a trailing slash.
+func TestUsageAccResult(t *testing.T) {
+	acc := newUsageAcc("/Movies", 0)
+	acc.addFile("a.mp4", "/external/node/usb/Movies/a.mp4", 300)
+	acc.addFile("notes.txt", "/external/node/usb/Movies/notes.txt", 5)
+	acc.addDir("Old", "/external/node/usb/Movies/Old/", 1000)
+
+	u := acc.result("/external/node/usb", 1, false)
+	if u.Path != "/external/node/usb/Movies/" {
+		t.Errorf("path = %s", u.Path)
+	}
+	if u.Size != 1305 || u.Files != 2 || u.Dirs != 1 {
+		t.Errorf("unexpected totals %+v", u)
+	}
+	if u.Types["video"] != 300 || u.Types["text"] != 5 {
+		t.Errorf("unexpected types %v", u.Types)
+	}
+	if u.Children[0].Name != "Old" || u.Children[2].Name != "notes.txt" {
+		t.Errorf("children not sorted by size")
+	}
+	if top := u.Top(1); len(top.Children) != 1 || len(u.Children) != 3 {
+		t.Errorf("Top must not truncate the cached result")
+	}
+
+	if !acc.contains("/Movies/Old/x") || acc.contains("/MoviesOld/x") {
+		t.Errorf("contains must match whole path segments")
+	}
+}
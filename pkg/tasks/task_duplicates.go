@@ -0,0 +1,501 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/drivers/clouds/rclone"
+	"files/pkg/drivers/clouds/rclone/operations"
+	"files/pkg/drivers/sync/seahub/seaserv"
+	"files/pkg/files"
+	"files/pkg/models"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// duplicatePartialSize is how much of every same-size candidate is
+	// hashed before committing to a full read.
+	duplicatePartialSize = 64 * 1024
+	duplicatePartialAlgo = files.HashXxhash
+	duplicateFullAlgo    = files.HashSha256
+	// duplicateSyncAlgo marks groups matched by Seafile obj_id; identical
+	// content always shares an object id, so no download is needed.
+	duplicateSyncAlgo = "seafile"
+)
+
+// DuplicateFile is one copy inside a DuplicateGroup. Path is the full
+// resource uri, e.g. /drive/Home/Pictures/a.jpg.
+type DuplicateFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modified"`
+}
+
+// DuplicateGroup is a set of files with identical content.
+type DuplicateGroup struct {
+	Algo   string           `json:"algo"`
+	Hash   string           `json:"hash"`
+	Size   int64            `json:"size"`
+	Wasted int64            `json:"wasted"`
+	Files  []*DuplicateFile `json:"files"`
+}
+
+// DuplicatesReport is the result of a FindDuplicates task, groups
+// ordered by wasted bytes.
+type DuplicatesReport struct {
+	Roots   []string          `json:"roots"`
+	Scanned int               `json:"scanned"`
+	Wasted  int64             `json:"wasted"`
+	Groups  []*DuplicateGroup `json:"groups"`
+}
+
+type duplicateEntry struct {
+	param   *models.FileParam
+	uri     string
+	size    int64
+	modTime int64
+	objId   string // sync only
+	hash    string
+}
+
+func duplicateUri(p *models.FileParam) string {
+	return "/" + p.FileType + "/" + p.Extend + p.Path
+}
+
+func (t *Task) setDuplicatesReport(r *DuplicatesReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.duplicates = r
+}
+
+// FindDuplicates is the Task phase function used by duplicate finder
+// requests. Files below roots are grouped by size, then by a hash of
+// their first duplicatePartialSize bytes, and only the survivors are
+// hashed in full. Files smaller than minSize are ignored.
+func (t *Task) FindDuplicates(roots []*models.FileParam, minSize int64) func() error {
+	return func() error {
+		if minSize < 1 {
+			minSize = 1
+		}
+
+		var report = &DuplicatesReport{}
+		var entries []*duplicateEntry
+		for _, root := range roots {
+			report.Roots = append(report.Roots, duplicateUri(root))
+			list, err := t.listDuplicateCandidates(root)
+			if err != nil {
+				return fmt.Errorf("list %s: %v", duplicateUri(root), err)
+			}
+			entries = append(entries, list...)
+		}
+		report.Scanned = len(entries)
+		t.updateProgressRsync(5, 0)
+
+		klog.Infof("[Task] Id: %s, duplicates, user: %s, roots: %v, scanned: %d", t.id, t.param.Owner, report.Roots, len(entries))
+
+		var bySize = make(map[int64][]*duplicateEntry)
+		for _, e := range entries {
+			if e.size < minSize {
+				continue
+			}
+			bySize[e.size] = append(bySize[e.size], e)
+		}
+
+		var candidates [][]*duplicateEntry
+		for _, group := range bySize {
+			if len(group) > 1 {
+				candidates = append(candidates, group)
+			}
+		}
+
+		// Seafile already addresses content by object id, so groups made
+		// only of sync files are resolved without reading a byte.
+		var pending [][]*duplicateEntry
+		for _, group := range candidates {
+			if !allSyncEntries(group) {
+				pending = append(pending, group)
+				continue
+			}
+			for _, g := range splitDuplicates(group, func(e *duplicateEntry) string { return e.objId }) {
+				report.Groups = append(report.Groups, newDuplicateGroup(duplicateSyncAlgo, g))
+			}
+		}
+
+		var partialTotal int
+		for _, group := range pending {
+			partialTotal += len(group)
+		}
+
+		var partialDone int
+		var full [][]*duplicateEntry
+		for _, group := range pending {
+			if group[0].size <= duplicatePartialSize {
+				full = append(full, group)
+				partialDone += len(group)
+				continue
+			}
+			for _, e := range group {
+				hash, err := t.hashFile(e.param, duplicatePartialAlgo, duplicatePartialSize, nil)
+				if err != nil {
+					if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+						return ctxErr
+					}
+					klog.Warningf("[Task] Id: %s, duplicates, partial hash %s error: %v", t.id, e.uri, err)
+					t.appendDetail(fmt.Sprintf("skip %s: %v", e.uri, err))
+					continue
+				}
+				e.hash = hash
+				partialDone++
+				t.updateProgressRsync(5+15*partialDone/partialTotal, 0)
+			}
+			full = append(full, splitDuplicates(group, func(e *duplicateEntry) string { return e.hash })...)
+		}
+
+		var fullTotal int64
+		for _, group := range full {
+			fullTotal += group[0].size * int64(len(group))
+		}
+		t.updateTotalSize(fullTotal)
+
+		var fullDone int64
+		for _, group := range full {
+			for _, e := range group {
+				e.hash = ""
+				hash, err := t.fullDuplicateHash(e, fullDone, fullTotal)
+				fullDone += e.size
+				if err != nil {
+					if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+						return ctxErr
+					}
+					klog.Warningf("[Task] Id: %s, duplicates, hash %s error: %v", t.id, e.uri, err)
+					t.appendDetail(fmt.Sprintf("skip %s: %v", e.uri, err))
+					continue
+				}
+				e.hash = hash
+			}
+			for _, g := range splitDuplicates(group, func(e *duplicateEntry) string { return e.hash }) {
+				report.Groups = append(report.Groups, newDuplicateGroup(duplicateFullAlgo, g))
+			}
+		}
+
+		sort.SliceStable(report.Groups, func(i, j int) bool {
+			return report.Groups[i].Wasted > report.Groups[j].Wasted
+		})
+		for _, g := range report.Groups {
+			report.Wasted += g.Wasted
+		}
+
+		t.setDuplicatesReport(report)
+		t.updateProgressRsync(100, fullTotal)
+		t.appendDetail(fmt.Sprintf("duplicates: scanned %d, groups %d, wasted %d", report.Scanned, len(report.Groups), report.Wasted))
+
+		klog.Infof("[Task] Id: %s, duplicates done, groups: %d, wasted: %d", t.id, len(report.Groups), report.Wasted)
+
+		return nil
+	}
+}
+
+// fullDuplicateHash hashes e completely, going through files.HashCache
+// so a later /api/hash call (or another scan) can reuse the result.
+func (t *Task) fullDuplicateHash(e *duplicateEntry, done, total int64) (string, error) {
+	var target = &HashTarget{File: e.param, Algo: duplicateFullAlgo, Size: e.size, ModTime: e.modTime}
+	if hash, ok := files.HashCache.Get(target.CacheKey()); ok {
+		return hash, nil
+	}
+
+	var lastProgress = -1
+	hash, err := t.hashFile(e.param, duplicateFullAlgo, 0, func(transferred int64) {
+		if total <= 0 {
+			return
+		}
+		progress := 20 + int((done+transferred)*79/total)
+		if progress != lastProgress {
+			lastProgress = progress
+			t.updateProgressRsync(progress, done+transferred)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	files.HashCache.Set(target.CacheKey(), hash)
+	return hash, nil
+}
+
+func allSyncEntries(group []*duplicateEntry) bool {
+	for _, e := range group {
+		if e.objId == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitDuplicates partitions group by key, dropping empty keys and
+// keeping only buckets that still hold more than one file.
+func splitDuplicates(group []*duplicateEntry, key func(*duplicateEntry) string) [][]*duplicateEntry {
+	var buckets = make(map[string][]*duplicateEntry)
+	var order []string
+	for _, e := range group {
+		k := key(e)
+		if k == "" {
+			continue
+		}
+		if _, ok := buckets[k]; !ok {
+			order = append(order, k)
+		}
+		buckets[k] = append(buckets[k], e)
+	}
+
+	var result [][]*duplicateEntry
+	for _, k := range order {
+		if len(buckets[k]) > 1 {
+			result = append(result, buckets[k])
+		}
+	}
+	return result
+}
+
+func newDuplicateGroup(algo string, entries []*duplicateEntry) *DuplicateGroup {
+	var g = &DuplicateGroup{
+		Algo:   algo,
+		Size:   entries[0].size,
+		Wasted: entries[0].size * int64(len(entries)-1),
+	}
+	if algo == duplicateSyncAlgo {
+		g.Hash = entries[0].objId
+	} else {
+		g.Hash = entries[0].hash
+	}
+	for _, e := range entries {
+		g.Files = append(g.Files, &DuplicateFile{Path: e.uri, Size: e.size, ModTime: e.modTime})
+	}
+	sort.Slice(g.Files, func(i, j int) bool { return g.Files[i].Path < g.Files[j].Path })
+	return g
+}
+
+func (t *Task) listDuplicateCandidates(root *models.FileParam) ([]*duplicateEntry, error) {
+	switch {
+	case root.IsSync():
+		return t.listSyncDuplicateCandidates(root)
+	case root.IsCloud():
+		return t.listCloudDuplicateCandidates(root)
+	default:
+		return t.listPosixDuplicateCandidates(root)
+	}
+}
+
+func (t *Task) listPosixDuplicateCandidates(root *models.FileParam) ([]*duplicateEntry, error) {
+	uri, err := root.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+
+	var base = filepath.Clean(uri + root.Path)
+	var result []*duplicateEntry
+	// Files that are already hard links of each other share storage, so
+	// only the first path of every inode is considered.
+	var inodes = make(map[[2]uint64]bool)
+
+	err = filepath.Walk(base, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return ctxErr
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			key := [2]uint64{uint64(st.Dev), st.Ino}
+			if inodes[key] {
+				return nil
+			}
+			inodes[key] = true
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		var p = strings.TrimSuffix(root.Path, "/")
+		if rel != "." {
+			p = p + "/" + filepath.ToSlash(rel)
+		}
+		var fp = &models.FileParam{Owner: root.Owner, FileType: root.FileType, Extend: root.Extend, Path: p}
+		result = append(result, &duplicateEntry{param: fp, uri: duplicateUri(fp), size: fi.Size(), modTime: fi.ModTime().Unix()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (t *Task) listCloudDuplicateCandidates(root *models.FileParam) ([]*duplicateEntry, error) {
+	fsPrefix, err := rclone.Command.GetFsPrefix(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var dir = strings.TrimSuffix(root.Path, "/") + "/"
+	list, err := rclone.Command.GetOperation().List(fsPrefix+dir, &operations.OperationsOpt{
+		Recurse:    true,
+		FilesOnly:  true,
+		NoMimeType: true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*duplicateEntry
+	if list == nil {
+		return result, nil
+	}
+	for _, item := range list.List {
+		if item.IsDir {
+			continue
+		}
+		var fp = &models.FileParam{Owner: root.Owner, FileType: root.FileType, Extend: root.Extend, Path: dir + item.Path}
+		var modTime int64
+		if mt, ok := common.ParseRFC3339Nano(item.ModTime); ok {
+			modTime = mt.Unix()
+		}
+		result = append(result, &duplicateEntry{param: fp, uri: duplicateUri(fp), size: item.Size, modTime: modTime})
+	}
+
+	return result, nil
+}
+
+func (t *Task) listSyncDuplicateCandidates(root *models.FileParam) ([]*duplicateEntry, error) {
+	var result []*duplicateEntry
+	err := t.walkSyncDir(root, func(fp *models.FileParam, dirent map[string]interface{}) error {
+		objId, _ := dirent["id"].(string)
+		size, _ := dirent["size"].(float64)
+		mtime, _ := dirent["mtime"].(float64)
+		result = append(result, &duplicateEntry{param: fp, uri: duplicateUri(fp), size: int64(size), modTime: int64(mtime), objId: objId})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SameContent reports whether a and b hold the same bytes right now.
+// Two sync files compare their Seafile object ids; anything else is
+// read and compared byte by byte, so a hash cached or scanned earlier
+// never decides what a resolve deletes or links.
+func SameContent(ctx context.Context, a, b *models.FileParam) (bool, error) {
+	if a.IsSync() && b.IsSync() {
+		var ids [2]string
+		for i, p := range []*models.FileParam{a, b} {
+			dirent, err := seaserv.GlobalSeafileAPI.GetDirentByPath(p.Extend, p.Path)
+			if err != nil {
+				return false, err
+			}
+			if dirent == nil || dirent["obj_id"] == "" {
+				return false, os.ErrNotExist
+			}
+			ids[i] = dirent["obj_id"]
+		}
+		return ids[0] == ids[1], nil
+	}
+
+	ra, err := openHashReader(ctx, a)
+	if err != nil {
+		return false, err
+	}
+	defer ra.Close()
+	rb, err := openHashReader(ctx, b)
+	if err != nil {
+		return false, err
+	}
+	defer rb.Close()
+
+	return sameBytes(ra, rb)
+}
+
+// sameBytes compares a and b to their ends.
+func sameBytes(a, b io.Reader) (bool, error) {
+	var bufA, bufB = make([]byte, 1024*1024), make([]byte, 1024*1024)
+	for {
+		na, errA := io.ReadFull(a, bufA)
+		nb, errB := io.ReadFull(b, bufB)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
+}
+
+// HardLinkDuplicate replaces dup with a hard link to keep. Both must be
+// local files on the same device; the link is created next to dup and
+// renamed over it so dup never disappears on failure. Callers check the
+// locks on dup first, as any write to it.
+func HardLinkDuplicate(keep, dup *models.FileParam) error {
+	if !common.ListContains(common.PosixFileTypes, keep.FileType) || !common.ListContains(common.PosixFileTypes, dup.FileType) {
+		return errors.New("hard link only supported on local storages")
+	}
+
+	keepUri, err := keep.GetResourceUri()
+	if err != nil {
+		return err
+	}
+	dupUri, err := dup.GetResourceUri()
+	if err != nil {
+		return err
+	}
+	var keepPath, dupPath = keepUri + keep.Path, dupUri + dup.Path
+
+	keepInfo, err := os.Stat(keepPath)
+	if err != nil {
+		return err
+	}
+	dupInfo, err := os.Stat(dupPath)
+	if err != nil {
+		return err
+	}
+	if !keepInfo.Mode().IsRegular() || !dupInfo.Mode().IsRegular() {
+		return errors.New("not a regular file")
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return nil
+	}
+	keepSt, ok1 := keepInfo.Sys().(*syscall.Stat_t)
+	dupSt, ok2 := dupInfo.Sys().(*syscall.Stat_t)
+	if !ok1 || !ok2 || keepSt.Dev != dupSt.Dev {
+		return errors.New("files are on different devices")
+	}
+
+	var tmp = fmt.Sprintf("%s.dup-%d", dupPath, time.Now().UnixNano())
+	if err = os.Link(keepPath, tmp); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, dupPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
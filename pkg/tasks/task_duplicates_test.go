@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSplitDuplicates pins that only buckets holding more than one file
+// survive, and that entries whose hash could not be computed are
+// dropped instead of being grouped together under an empty key.
+func TestSplitDuplicates(t *testing.T) {
+	group := []*duplicateEntry{
+		{uri: "/drive/Home/a", size: 10, hash: "x"},
+		{uri: "/drive/Home/b", size: 10, hash: "y"},
+		{uri: "/drive/Home/c", size: 10, hash: "x"},
+		{uri: "/drive/Home/d", size: 10, hash: ""},
+		{uri: "/drive/Home/e", size: 10, hash: ""},
+	}
+
+	got := splitDuplicates(group, func(e *duplicateEntry) string { return e.hash })
+	if len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("got %d buckets, want one bucket of two", len(got))
+	}
+	if got[0][0].uri != "/drive/Home/a" || got[0][1].uri != "/drive/Home/c" {
+		t.Errorf("unexpected bucket %s, %s", got[0][0].uri, got[0][1].uri)
+	}
+
+	g := newDuplicateGroup(duplicateFullAlgo, got[0])
+	if g.Hash != "x" || g.Size != 10 || g.Wasted != 10 || len(g.Files) != 2 {
+		t.Errorf("unexpected group %+v", g)
+	}
+}
+
+func TestSameBytes(t *testing.T) {
+	big := strings.Repeat("x", 3*1024*1024)
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"abc", "abcd", false},
+		{big, big, true},
+		{big, big + "y", false},
+		{big + "y", big + "z", false},
+	}
+	for i, c := range cases {
+		got, err := sameBytes(strings.NewReader(c.a), strings.NewReader(c.b))
+		if err != nil || got != c.want {
+			t.Errorf("case %d: got %v, %v, want %v", i, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package tasks
+
+import (
+	"context"
+	"encoding/hex"
+	"files/pkg/common"
+	"files/pkg/drivers/clouds/rclone"
+	"files/pkg/drivers/clouds/rclone/operations"
+	"files/pkg/drivers/sync/seahub"
+	"files/pkg/drivers/sync/seahub/seaserv"
+	"files/pkg/files"
+	"files/pkg/models"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"k8s.io/klog/v2"
+)
+
+// HashResult is the checksum produced by a hash task.
+type HashResult struct {
+	Algo string `json:"algo"`
+	Hash string `json:"hash"`
+}
+
+// HashTarget is a single file to checksum. Size and ModTime are taken
+// by StatHashTarget before hashing starts and form the cache key
+// together with the file location.
+type HashTarget struct {
+	File    *models.FileParam
+	Algo    string
+	Size    int64
+	ModTime int64
+}
+
+// rcloneHashTypes are the hash types rclone can report natively; xxhash
+// (64-bit) has no rclone equivalent and is always streamed.
+var rcloneHashTypes = []string{files.HashMd5, files.HashSha1, files.HashSha256, files.HashSha512, files.HashBlake3}
+
+// StatHashTarget resolves the size and modification time of the file p
+// points at, returning an os.ErrNotExist error when it is missing and
+// common.ErrIsDirectory for folders.
+func StatHashTarget(p *models.FileParam, algo string) (*HashTarget, error) {
+	var target = &HashTarget{File: p, Algo: algo}
+
+	switch {
+	case p.IsSync():
+		dirent, err := seaserv.GlobalSeafileAPI.GetDirentByPath(p.Extend, p.Path)
+		if err != nil {
+			return nil, err
+		}
+		if dirent == nil || dirent["obj_id"] == "" {
+			return nil, os.ErrNotExist
+		}
+		if isDir, _ := seahub.IsDirectory(dirent["mode"]); isDir {
+			return nil, common.ErrIsDirectory
+		}
+		target.Size, _ = strconv.ParseInt(dirent["size"], 10, 64)
+		target.ModTime, _ = strconv.ParseInt(dirent["mtime"], 10, 64)
+
+	case p.IsCloud():
+		fsPrefix, err := rclone.Command.GetFsPrefix(p)
+		if err != nil {
+			return nil, err
+		}
+		name, isFile := files.GetFileNameFromPath(p.Path)
+		if !isFile {
+			return nil, common.ErrIsDirectory
+		}
+		resp, err := rclone.Command.GetOperation().Stat(fsPrefix+files.GetPrefixPath(p.Path), name, &operations.OperationsOpt{
+			FilesOnly:  true,
+			NoMimeType: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// rclone returns {"item": null} when remote does not resolve to a file.
+		if resp == nil || resp.Item == nil {
+			return nil, os.ErrNotExist
+		}
+		target.Size = resp.Item.Size
+		if modTime, ok := common.ParseRFC3339Nano(resp.Item.ModTime); ok {
+			target.ModTime = modTime.Unix()
+		}
+
+	default:
+		uri, err := p.GetResourceUri()
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(uri + p.Path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, common.ErrIsDirectory
+		}
+		target.Size = info.Size()
+		target.ModTime = info.ModTime().Unix()
+	}
+
+	return target, nil
+}
+
+// CacheKey is the files.HashCache key of the target.
+func (h *HashTarget) CacheKey() string {
+	var uri = "/" + h.File.FileType + "/" + h.File.Extend + h.File.Path
+	return files.ChecksumKey(h.File.Owner, uri, h.Size, h.ModTime, h.Algo)
+}
+
+func (t *Task) setHashResult(r *HashResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hashResult = r
+}
+
+// Hash is the Task phase function used by hash requests. Cloud files
+// ask the provider for a native hash first; everything else is streamed
+// through the hasher with progress, and the task can be cancelled at
+// any point.
+func (t *Task) Hash(target *HashTarget) func() error {
+	return func() error {
+		var p = target.File
+
+		klog.Infof("[Task] Id: %s, hash, user: %s, algo: %s, file: %s, size: %d", t.id, t.param.Owner, target.Algo, common.ParseString(p), target.Size)
+
+		t.updateTotalSize(target.Size)
+
+		var hash string
+		var err error
+
+		if p.IsCloud() && common.ListContains(rcloneHashTypes, target.Algo) {
+			hash, err = rclone.Command.GetFileNativeHash(p, target.Algo)
+			if err != nil {
+				klog.Warningf("[Task] Id: %s, hash, native %s failed, fallback to stream: %v", t.id, target.Algo, err)
+			}
+		}
+
+		if hash == "" {
+			hash, err = t.hashStream(target)
+			if err != nil {
+				return err
+			}
+		}
+
+		files.HashCache.Set(target.CacheKey(), hash)
+		t.setHashResult(&HashResult{Algo: target.Algo, Hash: hash})
+		t.updateProgressRsync(100, target.Size)
+		t.appendDetail(fmt.Sprintf("%s: %s", target.Algo, hash))
+
+		return nil
+	}
+}
+
+func (t *Task) hashStream(target *HashTarget) (string, error) {
+	var lastProgress = -1
+	return t.hashFile(target.File, target.Algo, 0, func(transferred int64) {
+		if target.Size <= 0 {
+			return
+		}
+		progress := int(transferred * 99 / target.Size)
+		if progress > 99 {
+			progress = 99
+		}
+		if progress != lastProgress {
+			lastProgress = progress
+			t.updateProgressRsync(progress, transferred)
+		}
+	})
+}
+
+// hashFile streams p through algo. A positive limit hashes only the
+// first limit bytes; onRead, when set, receives the running byte count.
+func (t *Task) hashFile(p *models.FileParam, algo string, limit int64, onRead func(transferred int64)) (string, error) {
+	h, err := files.NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := openHashReader(t.ctx, p)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var reader io.Reader = r
+	if limit > 0 {
+		reader = io.LimitReader(r, limit)
+	}
+
+	var buf = make([]byte, 1024*1024)
+	var transferred int64
+	for {
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return "", ctxErr
+		}
+		n, err := reader.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			transferred += int64(n)
+			if onRead != nil {
+				onRead(transferred)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// openHashReader opens p for sequential reading: local storages from
+// disk, Sync through the Seafile fileserver (which assembles the file
+// from its blocks) and cloud through the rclone http serve.
+func openHashReader(ctx context.Context, p *models.FileParam) (io.ReadCloser, error) {
+	switch {
+	case p.IsSync():
+		return openSyncFile(ctx, p)
+
+	case p.IsCloud():
+		var configName = fmt.Sprintf("%s_%s_%s", p.Owner, p.FileType, p.Extend)
+		var name, _ = files.GetFileNameFromPath(p.Path)
+		var serve = rclone.Command.GetServe().Get(configName, files.GetPrefixPath(p.Path)+url.PathEscape(name), nil)
+		if serve == nil {
+			return nil, fmt.Errorf("cloud serve %s not found", configName)
+		}
+		if serve.Error != nil {
+			return nil, serve.Error
+		}
+		if serve.StatusCode != http.StatusOK {
+			serve.Body.Close()
+			return nil, fmt.Errorf("request failed, status code: %d", serve.StatusCode)
+		}
+		return serve.Body, nil
+
+	default:
+		uri, err := p.GetResourceUri()
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(uri + p.Path)
+	}
+}
@@ -0,0 +1,34 @@
+package tasks
+
+import (
+	"files/pkg/files"
+	"files/pkg/models"
+	"testing"
+)
+
+// TestHashTargetCacheKeyPerOwner pins that two users' files at the same
+// path, with the same size and mtime, get separate HashCache entries.
+func TestHashTargetCacheKeyPerOwner(t *testing.T) {
+	target := func(owner string) *HashTarget {
+		return &HashTarget{
+			File:    &models.FileParam{Owner: owner, FileType: "drive", Extend: "Home", Path: "/a.txt"},
+			Algo:    files.HashSha256,
+			Size:    3,
+			ModTime: 1700000000,
+		}
+	}
+	alice, bob := target("alice"), target("bob")
+
+	if alice.CacheKey() == bob.CacheKey() {
+		t.Fatalf("owners share cache key %q", alice.CacheKey())
+	}
+
+	var cache = files.NewChecksumCache(10)
+	cache.Set(alice.CacheKey(), "alice-hash")
+	if hash, ok := cache.Get(bob.CacheKey()); ok {
+		t.Errorf("bob got alice's checksum %q", hash)
+	}
+	if hash, ok := cache.Get(target("alice").CacheKey()); !ok || hash != "alice-hash" {
+		t.Errorf("alice = %q, %v", hash, ok)
+	}
+}
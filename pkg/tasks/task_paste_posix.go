@@ -4,6 +4,9 @@ import (
 	"files/pkg/common"
 	"files/pkg/files"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"k8s.io/klog/v2"
@@ -66,7 +69,9 @@ func (t *Task) Rsync() error {
 	}
 	klog.Infof("[Task] Id: %s, dstFree: %d", t.id, dstFree)
 
-	if !t.pausedSnap().WasPaused {
+	var merge = t.mergePlan()
+
+	if !t.pausedSnap().WasPaused && merge == nil {
 		generatedDstNewName, generatedDstNewPath, e := t.generateNewName(pathMeta)
 		if e != nil {
 			return fmt.Errorf("generate dst name error: %v", e)
@@ -79,6 +84,13 @@ func (t *Task) Rsync() error {
 
 	klog.Infof("[Task] Id: %s, src: %s, dst: %s", t.id, common.ToJson(t.param.Src), common.ToJson(t.param.Dst))
 
+	if merge != nil {
+		if err = t.transferRenamed(merge); err != nil {
+			klog.Errorf("[Task] Id: %s, keep both error: %v", t.id, err)
+			return err
+		}
+	}
+
 	if t.param.Action == common.ActionMove {
 		if err = t.move(); err != nil { // move
 			klog.Errorf("[Task] Id: %s, move dst error: %v", t.id, err)
@@ -95,7 +107,10 @@ func (t *Task) Rsync() error {
 	err = t.rsync() // rsync
 	if err != nil {
 		klog.Errorf("[Task] Id: %s, copy dst error: %v", t.id, err)
-		t.setPausedParam(t.param.Dst)
+		// a merged destination held the user's files before the paste
+		if merge == nil {
+			t.setPausedParam(t.param.Dst)
+		}
 		return err
 	}
 
@@ -135,10 +150,23 @@ func (t *Task) rsync() error {
 		"--no-inc-recursive",
 		// "--bwlimit=15000", // from env
 		"--info=PROGRESS2",
-		srcPath,
-		dstPath,
 	}
 
+	if merge := t.mergePlan(); merge != nil {
+		excludeFrom, err := merge.writeExcludes()
+		if err != nil {
+			return fmt.Errorf("write excludes error: %v", err)
+		}
+		if excludeFrom != "" {
+			defer os.Remove(excludeFrom)
+			args = append(args, "--from0", "--exclude-from="+excludeFrom)
+		}
+		if t.param.Action == common.ActionMove {
+			args = append(args, "--remove-source-files")
+		}
+	}
+	args = append(args, srcPath, dstPath)
+
 	_, err = common.ExecRsync(t.ctx, rsync, args, t.updateProgressRsync)
 	if err != nil {
 		klog.Errorf("exec rsync error: %v", err)
@@ -167,6 +195,10 @@ func (t *Task) rsync() error {
 
 // ~ move
 func (t *Task) move() error {
+	if t.mergePlan() != nil {
+		return t.moveMerge()
+	}
+
 	mv, err := common.GetCommand("mv")
 	if err != nil {
 		return fmt.Errorf("get command mv error: %v", err)
@@ -203,6 +235,75 @@ func (t *Task) move() error {
 	return nil
 }
 
+// moveMerge moves a folder into the existing folder of the same name,
+// which mv cannot do: rsync moves the files over, then the folders the
+// move emptied are removed. The source entries the merge left out stay
+// where they are.
+func (t *Task) moveMerge() error {
+	src, err := t.param.Src.GetResourceUri()
+	if err != nil {
+		return fmt.Errorf("get src uri error: %v", err)
+	}
+
+	if err = t.rsync(); err != nil {
+		return err
+	}
+
+	var srcPath = src + t.param.Src.Path
+	t.appendDetail(fmt.Sprintf("move merged %s", srcPath))
+	return removeEmptyDirs(srcPath)
+}
+
+// transferRenamed copies, or moves, the source entries a merge keeps
+// next to the destination entries of the same name.
+func (t *Task) transferRenamed(merge *conflictMerge) error {
+	var name, args = "rsync", []string{"-a", "--no-o", "--no-g", "--safe-links"}
+	if t.param.Action == common.ActionMove {
+		name, args = "mv", nil
+	}
+	command, err := common.GetCommand(name)
+	if err != nil {
+		return fmt.Errorf("get command %s error: %v", name, err)
+	}
+
+	for _, r := range merge.renames {
+		klog.Infof("[Task] Id: %s, keep both, %s %s -> %s", t.id, name, r.src, r.dst)
+		if _, err = common.ExecCommand(t.ctx, command, append(args, r.src, r.dst)); err != nil {
+			if cerr := t.ctx.Err(); cerr != nil {
+				return cerr
+			}
+			return fmt.Errorf("exec %s %s -> %s error: %v", name, r.src, r.dst, err)
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes root and the folders below it that hold
+// nothing, deepest first.
+func removeEmptyDirs(root string) error {
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			if err = os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (t *Task) generateNewName(srcFileInfo *files.PathMeta) (string, string, error) {
 	var dstUri, _ = t.param.Dst.GetResourceUri()
 	var dstPath = dstUri + t.param.Dst.Path
@@ -9,6 +9,7 @@ import (
 	"files/pkg/drivers/sync/seahub/seaserv"
 	"files/pkg/files"
 	"files/pkg/models"
+	"files/pkg/preview"
 	uploadwh "files/pkg/webhook/upload"
 	"fmt"
 	"io"
@@ -59,11 +60,19 @@ func (t *Task) UploadFinalizePosix(p *PosixFinalizeParams) func() error {
 		upload.FileInfoManager.DelFileInfo(p.InnerIdentifier, p.InnerIdentifier, p.UploadTempPath)
 
 		uploadwh.Enqueue(uploadwh.NewItem{
-			Path:        uploadwh.BuildFrontendPath(p.FileParam, p.ResumableInfo),
-			StoragePath: p.Info.FullPath,
-			Mime:        uploadwh.GuessMime(p.Info.FullPath),
-			UploadedAt:  uploadwh.NowMillis(),
-			Owner:       t.param.Owner,
+			Path:          uploadwh.BuildFrontendPath(p.FileParam, p.ResumableInfo),
+			StoragePath:   p.Info.FullPath,
+			Mime:          uploadwh.GuessMime(p.Info.FullPath),
+			UploadedAt:    uploadwh.NowMillis(),
+			Owner:         t.param.Owner,
+			Uploader:      p.ResumableInfo.Uploader,
+			UploaderEmail: p.ResumableInfo.UploaderEmail,
+		})
+		preview.Pregenerate(&models.FileParam{
+			Owner:    p.FileParam.Owner,
+			FileType: p.FileParam.FileType,
+			Extend:   p.FileParam.Extend,
+			Path:     p.FileParam.Path + p.ResumableInfo.ResumableRelativePath,
 		})
 
 		klog.Infof("[Task] Id: %s, UploadFinalizePosix completed", t.id)
@@ -0,0 +1,552 @@
+package tasks
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"files/pkg/common"
+	"files/pkg/drivers/clouds/rclone"
+	"files/pkg/drivers/sync/seahub"
+	"files/pkg/models"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// verifyAlgoMd5 is used whenever at least one side is not Seafile.
+	verifyAlgoMd5 = "md5"
+	// verifyAlgoSeafile compares Seafile object ids instead of content
+	// hashes; two files with identical content share the same obj_id,
+	// so sync -> sync verification needs no download at all.
+	verifyAlgoSeafile = "seafile"
+
+	// verifyRootKey is the key used when the verified root is a single
+	// file, so that a renamed destination ("a (1).txt") still pairs
+	// with its source ("a.txt").
+	verifyRootKey = "."
+)
+
+// VerifyReport is the outcome of comparing a source tree with a
+// destination tree. Paths are relative to the compared roots.
+type VerifyReport struct {
+	Algo      string   `json:"algo"`
+	Checked   int      `json:"checked"`
+	Matched   int      `json:"matched"`
+	Missing   []string `json:"missing,omitempty"`
+	Extra     []string `json:"extra,omitempty"`
+	Different []string `json:"different,omitempty"`
+	Skipped   string   `json:"skipped,omitempty"`
+	Ok        bool     `json:"ok"`
+}
+
+type verifyItem struct {
+	Size int64
+	Hash string
+}
+
+func (t *Task) setVerifyReport(r *VerifyReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.verifyReport = r
+}
+
+// prepareVerify hashes the paste source before any phase runs. Moves
+// delete the source, so the hashes have to be taken up front; copies
+// use the same path to keep the behaviour identical. The task progress
+// follows the bytes hashed. Failures never abort the paste itself -
+// they are recorded as a skipped report. A pause or cancel is returned
+// instead and leaves the verify state unset, so a resumed paste hashes
+// the source again.
+func (t *Task) prepareVerify() error {
+	if !t.param.Verify || t.verifySource != nil {
+		return nil
+	}
+
+	var src = t.verifyParam(t.param.Src, t.param.SrcOwner)
+	var dst = t.verifyParam(t.param.Dst, t.param.DstOwner)
+	var algo = verifyAlgoFor(src, dst)
+
+	items, err := t.collectVerifyItems(src, algo, &verifyMeter{t: t, last: -1})
+	t.updateProgressRsync(0, 0)
+	if err != nil {
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return ctxErr
+		}
+		klog.Errorf("[Task] Id: %s, verify, hash src error: %v", t.id, err)
+		t.appendDetail(fmt.Sprintf("verify skipped: hash source error: %v", err))
+		t.setVerifyReport(&VerifyReport{Algo: algo, Skipped: err.Error()})
+		t.verifySource = map[string]*verifyItem{}
+		t.verifyAlgo = ""
+		return nil
+	}
+
+	t.verifySource = items
+	t.verifyAlgo = algo
+	t.appendDetail(fmt.Sprintf("verify: hashed %d source files (%s)", len(items), algo))
+	return nil
+}
+
+// finishVerify hashes the destination once every phase succeeded and
+// records the comparison in the task details and report.
+func (t *Task) finishVerify() {
+	if !t.param.Verify || t.verifyAlgo == "" {
+		return
+	}
+
+	var dst = t.verifyParam(t.param.Dst, t.param.DstOwner)
+
+	items, err := t.collectVerifyItems(dst, t.verifyAlgo, nil)
+	if err != nil {
+		klog.Errorf("[Task] Id: %s, verify, hash dst error: %v", t.id, err)
+		t.appendDetail(fmt.Sprintf("verify skipped: hash destination error: %v", err))
+		t.setVerifyReport(&VerifyReport{Algo: t.verifyAlgo, Skipped: err.Error()})
+		return
+	}
+
+	var report = compareVerifyItems(t.verifyAlgo, t.verifySource, items, false)
+	t.recordVerifyReport(report)
+}
+
+// VerifyFolder is the phase of a standalone verify task: it compares
+// Src with Dst and reports files that are missing from Dst, only exist
+// in Dst, or differ in content.
+func (t *Task) VerifyFolder() error {
+	var src = t.verifyParam(t.param.Src, "")
+	var dst = t.verifyParam(t.param.Dst, "")
+	var algo = verifyAlgoFor(src, dst)
+
+	klog.Infof("[Task] Id: %s, start, verify, algo: %s, src: %s, dst: %s", t.id, algo, common.ToJson(src), common.ToJson(dst))
+
+	srcItems, err := t.collectVerifyItems(src, algo, nil)
+	if err != nil {
+		return fmt.Errorf("hash source error: %v", err)
+	}
+	t.updateProgress(50, 0)
+
+	dstItems, err := t.collectVerifyItems(dst, algo, nil)
+	if err != nil {
+		return fmt.Errorf("hash destination error: %v", err)
+	}
+
+	var report = compareVerifyItems(algo, srcItems, dstItems, true)
+	t.recordVerifyReport(report)
+
+	return nil
+}
+
+func (t *Task) recordVerifyReport(report *VerifyReport) {
+	t.setVerifyReport(report)
+
+	for _, p := range report.Missing {
+		t.appendDetail("verify missing: " + p)
+	}
+	for _, p := range report.Different {
+		t.appendDetail("verify mismatch: " + p)
+	}
+	for _, p := range report.Extra {
+		t.appendDetail("verify extra: " + p)
+	}
+	t.appendDetail(fmt.Sprintf("verify %s: checked %d, matched %d, missing %d, different %d",
+		report.Algo, report.Checked, report.Matched, len(report.Missing), len(report.Different)))
+
+	klog.Infof("[Task] Id: %s, verify done, ok: %v, checked: %d, matched: %d, missing: %d, different: %d, extra: %d",
+		t.id, report.Ok, report.Checked, report.Matched, len(report.Missing), len(report.Different), len(report.Extra))
+}
+
+// verifyParam returns a copy of p owned by the effective owner; for
+// share pastes the phases rewrite Owner in place, so we mirror that
+// here instead of mutating the shared param.
+func (t *Task) verifyParam(p *models.FileParam, shareOwner string) *models.FileParam {
+	var c = *p
+	if t.param.Share == 1 && shareOwner != "" {
+		c.Owner = shareOwner
+	}
+	return &c
+}
+
+func verifyAlgoFor(src, dst *models.FileParam) string {
+	if src.IsSync() && dst.IsSync() {
+		return verifyAlgoSeafile
+	}
+	return verifyAlgoMd5
+}
+
+func compareVerifyItems(algo string, src, dst map[string]*verifyItem, withExtra bool) *VerifyReport {
+	var report = &VerifyReport{Algo: algo}
+
+	for name, s := range src {
+		report.Checked++
+		d, ok := dst[name]
+		if !ok {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+		// A negative size means the backend did not report one
+		// (rclone hashsum), so only the hash decides.
+		sizeDiffers := s.Size >= 0 && d.Size >= 0 && s.Size != d.Size
+		if sizeDiffers || s.Hash != d.Hash {
+			report.Different = append(report.Different, name)
+			continue
+		}
+		report.Matched++
+	}
+
+	if withExtra {
+		for name := range dst {
+			if _, ok := src[name]; !ok {
+				report.Extra = append(report.Extra, name)
+			}
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Different)
+	sort.Strings(report.Extra)
+
+	report.Ok = len(report.Missing) == 0 && len(report.Different) == 0 && len(report.Extra) == 0
+
+	return report
+}
+
+// collectVerifyItems hashes every file under p, keyed by its path
+// relative to p (verifyRootKey when p is a file). The bytes streamed
+// through the hasher are reported to meter.
+func (t *Task) collectVerifyItems(p *models.FileParam, algo string, meter *verifyMeter) (map[string]*verifyItem, error) {
+	if p.IsSync() {
+		return t.collectSyncVerifyItems(p, algo, meter)
+	}
+
+	if p.IsCloud() {
+		return t.collectCloudVerifyItems(p)
+	}
+
+	return t.collectPosixVerifyItems(p, meter)
+}
+
+func (t *Task) collectPosixVerifyItems(p *models.FileParam, meter *verifyMeter) (map[string]*verifyItem, error) {
+	uri, err := p.GetResourceUri()
+	if err != nil {
+		return nil, err
+	}
+
+	var root = filepath.Clean(uri + p.Path)
+	var result = make(map[string]*verifyItem)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		meter.start(info.Size())
+		hash, err := t.hashLocalFile(root, meter)
+		if err != nil {
+			return nil, err
+		}
+		result[verifyRootKey] = &verifyItem{Size: info.Size(), Hash: hash}
+		return result, nil
+	}
+
+	// the files are listed first so the hashing knows its total
+	type localFile struct {
+		rel  string
+		path string
+		size int64
+	}
+	var list []localFile
+	var total int64
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return ctxErr
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		list = append(list, localFile{rel: filepath.ToSlash(rel), path: path, size: fi.Size()})
+		total += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	meter.start(total)
+	for _, f := range list {
+		hash, err := t.hashLocalFile(f.path, meter)
+		if err != nil {
+			return nil, err
+		}
+		result[f.rel] = &verifyItem{Size: f.size, Hash: hash}
+	}
+
+	return result, nil
+}
+
+func (t *Task) collectCloudVerifyItems(p *models.FileParam) (map[string]*verifyItem, error) {
+	hashes, err := rclone.Command.GetFilesHash(p, verifyAlgoMd5)
+	if err != nil {
+		return nil, err
+	}
+
+	var _, isFile = p.IsFile()
+	var result = make(map[string]*verifyItem)
+	for name, hash := range hashes {
+		if isFile {
+			name = verifyRootKey
+		}
+		result[name] = &verifyItem{Size: -1, Hash: strings.ToLower(hash)}
+	}
+
+	return result, nil
+}
+
+func (t *Task) collectSyncVerifyItems(p *models.FileParam, algo string, meter *verifyMeter) (map[string]*verifyItem, error) {
+	var result = make(map[string]*verifyItem)
+
+	if _, isFile := p.IsFile(); isFile {
+		fileInfo := seahub.GetFileInfo(p.Extend, p.Path)
+		objId, _ := fileInfo["obj_id"].(string)
+		if objId == "" {
+			return nil, fmt.Errorf("file not found: %s", p.Path)
+		}
+		size := fileInfo["size"].(int64)
+		if algo != verifyAlgoSeafile {
+			meter.start(size)
+		}
+		item, err := t.syncVerifyItem(p, objId, size, algo, meter)
+		if err != nil {
+			return nil, err
+		}
+		result[verifyRootKey] = item
+		return result, nil
+	}
+
+	type syncFile struct {
+		fp    *models.FileParam
+		objId string
+		size  int64
+	}
+	var list []syncFile
+	var total int64
+	err := t.walkSyncDir(p, func(fp *models.FileParam, dirent map[string]interface{}) error {
+		objId, _ := dirent["id"].(string)
+		size, _ := dirent["size"].(float64)
+		list = append(list, syncFile{fp: fp, objId: objId, size: int64(size)})
+		total += int64(size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if algo != verifyAlgoSeafile {
+		meter.start(total)
+	}
+	var root = strings.TrimSuffix(p.Path, "/") + "/"
+	for _, f := range list {
+		item, err := t.syncVerifyItem(f.fp, f.objId, f.size, algo, meter)
+		if err != nil {
+			return nil, err
+		}
+		result[strings.TrimPrefix(f.fp.Path, root)] = item
+	}
+
+	return result, nil
+}
+
+// walkSyncDir visits every file below the Seafile folder p breadth-first,
+// handing fn the file's own FileParam and its raw dirent.
+func (t *Task) walkSyncDir(p *models.FileParam, fn func(fp *models.FileParam, dirent map[string]interface{}) error) error {
+	var root = strings.TrimSuffix(p.Path, "/") + "/"
+	var queue = []string{root}
+
+	for len(queue) > 0 {
+		if ctxCancel, ctxErr := t.isCancel(); ctxCancel {
+			return ctxErr
+		}
+
+		var dir = queue[0]
+		queue = queue[1:]
+
+		dirInfoRes, err := seahub.HandleGetRepoDir(&models.FileParam{Owner: p.Owner, FileType: p.FileType, Extend: p.Extend, Path: dir})
+		if err != nil {
+			return err
+		}
+		if dirInfoRes == nil {
+			return errors.New("folder not found")
+		}
+
+		var dirInfo map[string]interface{}
+		if err = json.Unmarshal(dirInfoRes, &dirInfo); err != nil {
+			return err
+		}
+
+		direntList, err := normalizeSyncDirentList(dirInfo["dirent_list"], dir)
+		if err != nil {
+			return err
+		}
+
+		for _, dirent := range direntList {
+			name, _ := dirent["name"].(string)
+			objType, _ := dirent["type"].(string)
+			if objType == "dir" {
+				queue = append(queue, dir+name+"/")
+				continue
+			}
+
+			var fp = &models.FileParam{Owner: p.Owner, FileType: p.FileType, Extend: p.Extend, Path: dir + name}
+			if err = fn(fp, dirent); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *Task) syncVerifyItem(p *models.FileParam, objId string, size int64, algo string, meter *verifyMeter) (*verifyItem, error) {
+	if algo == verifyAlgoSeafile {
+		return &verifyItem{Size: size, Hash: objId}, nil
+	}
+
+	hash, err := t.hashSyncFile(p, meter)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyItem{Size: size, Hash: hash}, nil
+}
+
+// hashSyncFile streams a Seafile file through the fileserver and
+// hashes it without touching local disk.
+func (t *Task) hashSyncFile(p *models.FileParam, meter *verifyMeter) (string, error) {
+	body, err := openSyncFile(t.ctx, p)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	return hashReader(t.ctx, meter.reader(body))
+}
+
+func openSyncFile(ctx context.Context, p *models.FileParam) (io.ReadCloser, error) {
+	dlUrlRaw, err := seahub.ViewLibFile(p, "dl")
+	if err != nil {
+		return nil, err
+	}
+	dlUrl := "http://127.0.0.1:80/" + string(dlUrlRaw)
+
+	request, err := http.NewRequestWithContext(ctx, "GET", dlUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("url: %s, error: %v", dlUrl, err)
+	}
+
+	response, err := streamHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("request failed, status code: %d", response.StatusCode)
+	}
+
+	return response.Body, nil
+}
+
+func (t *Task) hashLocalFile(path string, meter *verifyMeter) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return hashReader(t.ctx, meter.reader(f))
+}
+
+// verifyMeter reports the bytes a verify pass hashed as task progress,
+// up to 99% of the total it was started with. A nil meter reports
+// nothing.
+type verifyMeter struct {
+	t     *Task
+	total int64
+	done  int64
+	last  int
+}
+
+func (m *verifyMeter) start(total int64) {
+	if m == nil {
+		return
+	}
+	m.total = total
+	m.t.updateTotalSize(total)
+}
+
+func (m *verifyMeter) add(n int64) {
+	if m.total <= 0 {
+		return
+	}
+	m.done += n
+	progress := int(m.done * 99 / m.total)
+	if progress > 99 {
+		progress = 99
+	}
+	if progress != m.last {
+		m.last = progress
+		m.t.updateProgressRsync(progress, m.done)
+	}
+}
+
+// reader counts what is read from r.
+func (m *verifyMeter) reader(r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return &meterReader{r: r, m: m}
+}
+
+type meterReader struct {
+	r io.Reader
+	m *verifyMeter
+}
+
+func (r *meterReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.m.add(int64(n))
+	}
+	return n, err
+}
+
+func hashReader(ctx context.Context, r io.Reader) (string, error) {
+	var h = md5.New()
+	var buf = make([]byte, 1024*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"context"
+	"files/pkg/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCompareVerifyItems pins how a verify report classifies files:
+// missing on the destination, differing by size or hash, and extra
+// files that only count when the whole tree was copied.
+func TestCompareVerifyItems(t *testing.T) {
+	src := map[string]*verifyItem{
+		"a.txt":     {Size: 3, Hash: "aaa"},
+		"b.txt":     {Size: 4, Hash: "bbb"},
+		"c.txt":     {Size: 5, Hash: "ccc"},
+		"dir/d.txt": {Size: -1, Hash: "ddd"},
+	}
+	dst := map[string]*verifyItem{
+		"a.txt":     {Size: 3, Hash: "aaa"},
+		"b.txt":     {Size: 5, Hash: "bbb"},
+		"dir/d.txt": {Size: 10, Hash: "ddd"},
+		"z.txt":     {Size: 1, Hash: "zzz"},
+	}
+
+	report := compareVerifyItems(verifyAlgoMd5, src, dst, true)
+
+	if report.Checked != 4 || report.Matched != 2 {
+		t.Fatalf("checked/matched = %d/%d, want 4/2", report.Checked, report.Matched)
+	}
+	if !reflect.DeepEqual(report.Missing, []string{"c.txt"}) {
+		t.Errorf("missing = %v", report.Missing)
+	}
+	if !reflect.DeepEqual(report.Different, []string{"b.txt"}) {
+		t.Errorf("different = %v", report.Different)
+	}
+	if !reflect.DeepEqual(report.Extra, []string{"z.txt"}) {
+		t.Errorf("extra = %v", report.Extra)
+	}
+	if report.Ok {
+		t.Error("report should not be ok")
+	}
+
+	report = compareVerifyItems(verifyAlgoMd5, src, dst, false)
+	if len(report.Extra) != 0 {
+		t.Errorf("extra without withExtra = %v", report.Extra)
+	}
+}
+
+// TestPrepareVerifyCancel pins that a paste paused or cancelled while
+// hashing its source keeps no verify state, so resuming hashes again,
+// while a real failure is recorded as a skipped verification.
+func TestPrepareVerifyCancel(t *testing.T) {
+	param := &models.PasteParam{
+		Verify: true,
+		Src:    &models.FileParam{FileType: "external", Extend: "node", Path: "/missing-verify-src/"},
+		Dst:    &models.FileParam{FileType: "external", Extend: "node", Path: "/missing-verify-dst/"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	task := &Task{ctx: ctx, param: param}
+	if err := task.prepareVerify(); err == nil {
+		t.Fatal("cancelled hash must be returned")
+	}
+	if task.verifySource != nil || task.verifyReport != nil {
+		t.Errorf("cancelled hash left verify state: %v, %+v", task.verifySource, task.verifyReport)
+	}
+
+	task = &Task{ctx: context.Background(), param: param}
+	if err := task.prepareVerify(); err != nil {
+		t.Fatalf("failed hash must not abort the paste: %v", err)
+	}
+	if task.verifySource == nil || task.verifyAlgo != "" || task.verifyReport == nil || task.verifyReport.Skipped == "" {
+		t.Errorf("failed hash not recorded as skipped: %+v", task.verifyReport)
+	}
+}
+
+func TestVerifyMeter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.bin")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 3*1024*1024)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &Task{ctx: context.Background()}
+	meter := &verifyMeter{t: task, last: -1}
+	meter.start(6 * 1024 * 1024)
+	if _, err := task.hashLocalFile(path, meter); err != nil {
+		t.Fatal(err)
+	}
+	if task.progress != 49 || task.transfer != 3*1024*1024 || task.totalSize != 6*1024*1024 {
+		t.Errorf("progress %d, transfer %d, total %d", task.progress, task.transfer, task.totalSize)
+	}
+
+	if _, err := task.hashLocalFile(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if task.progress != 49 {
+		t.Errorf("a nil meter reported progress %d", task.progress)
+	}
+}
@@ -0,0 +1,178 @@
+// Package textenc decodes text files of unknown encoding to UTF-8.
+package textenc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// ErrImproperEncoding means the content does not decode with the
+	// encoding asked for.
+	ErrImproperEncoding = errors.New("The encoding you chose is not proper.")
+	// ErrUnknownEncoding means no encoding the content decodes with
+	// was found.
+	ErrUnknownEncoding = errors.New("Unknown file encoding")
+)
+
+// TryList is the encodings Detect tries, in order, when the charset
+// detector names none the content decodes with. ISO-8859-1 decodes
+// anything, it comes last.
+var TryList = []string{"utf-8", "gbk", "iso-8859-1"}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// Decode decodes content from the encoding named enc.
+func Decode(content []byte, enc string) (string, error) {
+	e, err := lookup(enc)
+	if err != nil {
+		return "", ErrImproperEncoding
+	}
+	decoded, err := decode(content, e)
+	if err != nil {
+		return "", ErrImproperEncoding
+	}
+	return decoded, nil
+}
+
+// DecodeLenient decodes content from the encoding named enc the way
+// NewReader does: the bytes not in enc are replaced by U+FFFD. It only
+// fails on an encoding it does not know, for the encodings users choose.
+func DecodeLenient(content []byte, enc string) (string, error) {
+	r, err := NewReader(bytes.NewReader(trimBOM(content)), enc)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return "", ErrImproperEncoding
+	}
+	return string(decoded), nil
+}
+
+// Detect decodes content from the encoding it is found in, and names
+// that encoding: the one of its byte order mark, UTF-8 when it is valid,
+// else the one the charset detector guesses or, failing that, the first
+// of TryList it decodes with.
+func Detect(content []byte) (decoded string, enc string, err error) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		enc = "utf-8"
+	case bytes.HasPrefix(content, bomUTF16BE):
+		enc = "utf-16be"
+	case bytes.HasPrefix(content, bomUTF16LE):
+		enc = "utf-16le"
+	case utf8.Valid(content):
+		return string(content), "utf-8", nil
+	}
+	if enc != "" {
+		decoded, err = Decode(content, enc)
+		return decoded, enc, err
+	}
+
+	if result, e := chardet.NewTextDetector().DetectBest(content); e != nil {
+		klog.Errorf("Encoding detection failed: %v", e)
+	} else {
+		detected := strings.ToLower(result.Charset)
+		if decoded, e := Decode(content, detected); e == nil {
+			return decoded, detected, nil
+		}
+	}
+
+	for _, enc := range TryList {
+		if decoded, e := Decode(content, enc); e == nil {
+			return decoded, enc, nil
+		}
+	}
+	return "", "", ErrUnknownEncoding
+}
+
+// DetectSample names the encoding of a file from its first bytes, which
+// may end in the middle of a line or of a character.
+func DetectSample(sample []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return "utf-16be", nil
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return "utf-16le", nil
+	}
+	if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+		sample = sample[:i+1]
+	} else {
+		// a partial character at the end is not an error of the encoding
+		for n := 0; n < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); n++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	_, enc, err := Detect(sample)
+	return enc, err
+}
+
+// NewReader decodes r from the encoding named enc. Unlike Decode, the
+// bytes it cannot read are replaced by U+FFFD rather than an error,
+// since r may start or end in the middle of a character.
+func NewReader(r io.Reader, enc string) (io.Reader, error) {
+	e, err := lookup(enc)
+	if err != nil {
+		return nil, ErrImproperEncoding
+	}
+	return transform.NewReader(r, e.NewDecoder()), nil
+}
+
+func lookup(name string) (encoding.Encoding, error) {
+	switch name = strings.ToLower(name); name {
+	case "utf-8":
+		return unicode.UTF8, nil
+	case "gbk":
+		return simplifiedchinese.GBK, nil
+	case "iso-8859-1":
+		return charmap.ISO8859_1, nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), nil
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	case "gb-18030":
+		name = "gb18030"
+	}
+	return htmlindex.Get(name)
+}
+
+// decode decodes content from e, without its byte order mark. Decoders
+// replace the bytes they cannot read by U+FFFD, content holding such
+// bytes is not in e.
+func decode(content []byte, e encoding.Encoding) (string, error) {
+	content = trimBOM(content)
+	decoded, err := io.ReadAll(transform.NewReader(bytes.NewReader(content), e.NewDecoder()))
+	if err != nil {
+		return "", err
+	}
+	if replacement := []byte("\uFFFD"); bytes.Contains(decoded, replacement) && !bytes.Contains(content, replacement) {
+		return "", ErrImproperEncoding
+	}
+	return string(decoded), nil
+}
+
+func trimBOM(content []byte) []byte {
+	for _, bom := range [][]byte{bomUTF8, bomUTF16BE, bomUTF16LE} {
+		if bytes.HasPrefix(content, bom) {
+			return content[len(bom):]
+		}
+	}
+	return content
+}
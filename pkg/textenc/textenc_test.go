@@ -0,0 +1,58 @@
+package textenc
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDetect(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("你好，世界。这是一个用来测试编码检测的中文文本文件。"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name    string
+		content []byte
+		want    string
+		enc     string
+	}{
+		{"utf-8", []byte("héllo wörld"), "héllo wörld", "utf-8"},
+		{"utf-8 bom", []byte("\xef\xbb\xbfhello"), "hello", "utf-8"},
+		{"utf-16le bom", []byte("\xff\xfeh\x00i\x00"), "hi", "utf-16le"},
+		{"utf-16be bom", []byte("\xfe\xff\x00h\x00i"), "hi", "utf-16be"},
+		{"gbk", gbk, "你好，世界。这是一个用来测试编码检测的中文文本文件。", ""},
+		{"latin-1", []byte("caf\xe9"), "café", ""},
+	} {
+		decoded, enc, err := Detect(c.content)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if decoded != c.want || (c.enc != "" && enc != c.enc) {
+			t.Errorf("%s: Detect = %q, %s", c.name, decoded, enc)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if s, err := Decode([]byte("caf\xe9"), "ISO-8859-1"); err != nil || s != "café" {
+		t.Errorf("Decode latin-1 = %q, %v", s, err)
+	}
+	if _, err := Decode([]byte("caf\xe9"), "utf-8"); err != ErrImproperEncoding {
+		t.Errorf("Decode invalid utf-8 error = %v", err)
+	}
+	if _, err := Decode([]byte("abc"), "no-such-encoding"); err != ErrImproperEncoding {
+		t.Errorf("Decode unknown encoding error = %v", err)
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	if s, err := DecodeLenient([]byte("\xEF\xBB\xBFcaf\xe9"), "utf-8"); err != nil || s != "caf\uFFFD" {
+		t.Errorf("DecodeLenient invalid utf-8 = %q, %v", s, err)
+	}
+	if _, err := DecodeLenient([]byte("abc"), "no-such-encoding"); err != ErrImproperEncoding {
+		t.Errorf("DecodeLenient unknown encoding error = %v", err)
+	}
+}
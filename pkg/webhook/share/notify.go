@@ -0,0 +1,88 @@
+// Package share posts share lifecycle events - an expiry reminder, or a
+// share removed by the expiry cleanup job - to an outgoing webhook so the
+// owner can be told about them.
+//
+// Design summary:
+//   - Disabled unless SHARE_WEBHOOK_URL is set.
+//   - Notify is synchronous and only called from the lifecycle cron job,
+//     so a slow receiver delays the job, never a request. Each POST has a
+//     30s timeout.
+//   - A failed POST is returned to the caller, which leaves the share
+//     unmarked so the reminder is retried on the next run.
+package share
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	envURL = "SHARE_WEBHOOK_URL"
+
+	EventExpiring = "share.expiring"
+	EventArchived = "share.archived"
+	EventDeleted  = "share.deleted"
+)
+
+// Event describes one share the owner should hear about.
+type Event struct {
+	Event      string `json:"event"`
+	Owner      string `json:"owner"`
+	ShareId    string `json:"share_id"`
+	Name       string `json:"name"`
+	ShareType  string `json:"share_type"`
+	FileType   string `json:"file_type"`
+	Extend     string `json:"extend"`
+	Path       string `json:"path"`
+	ExpireTime string `json:"expire_time"`
+	OccurredAt int64  `json:"occurred_at"` // unix milliseconds
+}
+
+type eventsBody struct {
+	Events []Event `json:"events"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Enabled reports whether a webhook target is configured.
+func Enabled() bool {
+	return strings.TrimSpace(os.Getenv(envURL)) != ""
+}
+
+// Notify posts events in one request. It is a no-op when the webhook is
+// disabled or there is nothing to send.
+func Notify(ctx context.Context, events []Event) error {
+	url := strings.TrimSpace(os.Getenv(envURL))
+	if url == "" || len(events) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(eventsBody{Events: events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("share webhook %s status %d", url, resp.StatusCode)
+	}
+	return nil
+}
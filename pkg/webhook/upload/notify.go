@@ -57,6 +57,9 @@ type NewItem struct {
 	Mime        string `json:"mime"`
 	UploadedAt  int64  `json:"uploaded_at"` // unix milliseconds
 	Owner       string `json:"owner"`
+	// Set for uploads through a file request link: who sent the file.
+	Uploader      string `json:"uploader,omitempty"`
+	UploaderEmail string `json:"uploader_email,omitempty"`
 }
 
 type newItemsBody struct {